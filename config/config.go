@@ -6,6 +6,7 @@ import (
 	"github.com/kelseyhightower/envconfig"
 	"log"
 	"sync"
+	"time"
 )
 
 type Config struct {
@@ -17,6 +18,120 @@ type Config struct {
 	LogLevel         string `envconfig:"LOG_LEVEL"`
 	PgURL            string `envconfig:"PG_URL"`
 	PgMigrationsPath string `envconfig:"PG_MIGRATIONS_PATH"`
+
+	// StoreDriver is the database of the repositories: "postgres" at PgURL,
+	// or "sqlite" in the file SQLitePath, created if it does not exist.
+	StoreDriver string `envconfig:"STORE_DRIVER" default:"postgres"`
+	SQLitePath  string `envconfig:"SQLITE_PATH" default:"users.db"`
+
+	// Postgres user queries are canceled once they run longer than
+	// DbReadTimeout, or DbWriteTimeout if they change data; zero sets no
+	// limit beyond the request. A canceled request cancels its queries
+	// either way.
+	DbReadTimeout  time.Duration `envconfig:"DB_READ_TIMEOUT" default:"5s"`
+	DbWriteTimeout time.Duration `envconfig:"DB_WRITE_TIMEOUT" default:"10s"`
+
+	// Postgres connection pool. The pool is checked every DbHealthInterval;
+	// after failures the checks back off up to DbReconnectMaxBackoff while
+	// the service reports itself as not ready.
+	DbMaxOpenConns        int           `envconfig:"DB_MAX_OPEN_CONNS" default:"25"`
+	DbMaxIdleConns        int           `envconfig:"DB_MAX_IDLE_CONNS" default:"5"`
+	DbConnMaxLifetime     time.Duration `envconfig:"DB_CONN_MAX_LIFETIME" default:"30m"`
+	DbConnMaxIdleTime     time.Duration `envconfig:"DB_CONN_MAX_IDLE_TIME" default:"5m"`
+	DbHealthInterval      time.Duration `envconfig:"DB_HEALTH_INTERVAL" default:"3s"`
+	DbReconnectMaxBackoff time.Duration `envconfig:"DB_RECONNECT_MAX_BACKOFF" default:"30s"`
+
+	// JWT signing keys. Every key is identified by its "kid". HMAC secrets
+	// are given inline, asymmetric keys (RSA, ECDSA P-256, Ed25519) as PEM
+	// files. Keys other than JWTSigningKeyID only verify tokens, which lets
+	// old tokens stay valid during a key rotation.
+	JWTSigningKeyID string            `envconfig:"JWT_SIGNING_KEY_ID"`
+	JWTSecrets      map[string]string `envconfig:"JWT_SECRETS" json:"-"`
+	JWTKeyFiles     map[string]string `envconfig:"JWT_KEY_FILES"`
+	JWTIssuer       string            `envconfig:"JWT_ISSUER"`
+	AccessTokenTTL  time.Duration     `envconfig:"ACCESS_TOKEN_TTL" default:"15m"`
+	RefreshTokenTTL time.Duration     `envconfig:"REFRESH_TOKEN_TTL" default:"720h"`
+
+	// Soft-deleted users are purged for good after UserRetention
+	UserRetention     time.Duration `envconfig:"USER_RETENTION" default:"720h"`
+	UserPurgeInterval time.Duration `envconfig:"USER_PURGE_INTERVAL" default:"1h"`
+
+	// Failed logins are counted per account and per client IP in
+	// LoginAttemptStore ("memory", or StoreDriver for several instances).
+	// Every failure doubles the delay before the next attempt, starting at
+	// LoginBackoff; after the max failures logins are locked for LoginLockout.
+	LoginAttemptStore  string        `envconfig:"LOGIN_ATTEMPT_STORE" default:"memory"`
+	LoginMaxFailures   int           `envconfig:"LOGIN_MAX_FAILURES" default:"5"`
+	LoginMaxIPFailures int           `envconfig:"LOGIN_MAX_IP_FAILURES" default:"20"`
+	LoginBackoff       time.Duration `envconfig:"LOGIN_BACKOFF" default:"1s"`
+	LoginLockout       time.Duration `envconfig:"LOGIN_LOCKOUT" default:"15m"`
+
+	// Users with two-factor authentication exchange a challenge token, valid
+	// for LoginChallengeTTL, and a code for their tokens. TOTPIssuer names
+	// the service in authenticator apps.
+	LoginChallengeTTL time.Duration `envconfig:"LOGIN_CHALLENGE_TTL" default:"5m"`
+	TOTPIssuer        string        `envconfig:"TOTP_ISSUER" default:"REST_API"`
+
+	// OAuthProvider makes the service an OpenID Connect provider for the
+	// registered OAuth clients. It requires JWTIssuer, the public URL of the
+	// service, which ID tokens carry and discovery names, and SessionCookies
+	// that are sent along the redirects of the clients, so SameSite "lax" or
+	// "none". Clients are registered by admins and trusted as first-party:
+	// logged in users are not asked for consent. Clients exchange
+	// authorization codes within OAuthCodeTTL.
+	OAuthProvider bool          `envconfig:"OAUTH_PROVIDER" default:"false"`
+	OAuthCodeTTL  time.Duration `envconfig:"OAUTH_CODE_TTL" default:"1m"`
+
+	// Users may log in with the external OpenID Connect providers listed in
+	// the JSON file OIDCProvidersFile, finishing within OIDCLoginTTL.
+	OIDCProvidersFile string        `envconfig:"OIDC_PROVIDERS_FILE"`
+	OIDCLoginTTL      time.Duration `envconfig:"OIDC_LOGIN_TTL" default:"10m"`
+
+	// Browsers may log in with a cookie session instead of tokens if
+	// SessionCookies is set. Sessions end SessionTTL after the login, or
+	// once unused for SessionIdleTimeout. SessionCookieSameSite is "strict",
+	// "lax" or "none"; the latter needs SessionCookieSecure.
+	SessionCookies        bool          `envconfig:"SESSION_COOKIES" default:"false"`
+	SessionTTL            time.Duration `envconfig:"SESSION_TTL" default:"24h"`
+	SessionIdleTimeout    time.Duration `envconfig:"SESSION_IDLE_TIMEOUT" default:"30m"`
+	SessionCookieSecure   bool          `envconfig:"SESSION_COOKIE_SECURE" default:"true"`
+	SessionCookieSameSite string        `envconfig:"SESSION_COOKIE_SAMESITE" default:"strict"`
+
+	// Emails are sent by Mailer: "smtp", "file" to write them to MailDir or
+	// "log". Password reset links point to PasswordResetURL, with the token
+	// in the "token" query parameter, and expire after PasswordResetTTL.
+	Mailer           string        `envconfig:"MAILER" default:"log"`
+	MailFrom         string        `envconfig:"MAIL_FROM" default:"no-reply@localhost"`
+	MailDir          string        `envconfig:"MAIL_DIR" default:"mail"`
+	SMTPHost         string        `envconfig:"SMTP_HOST"`
+	SMTPPort         int           `envconfig:"SMTP_PORT" default:"587"`
+	SMTPUsername     string        `envconfig:"SMTP_USERNAME"`
+	SMTPPassword     string        `envconfig:"SMTP_PASSWORD" json:"-"`
+	PasswordResetURL string        `envconfig:"PASSWORD_RESET_URL"`
+	PasswordResetTTL time.Duration `envconfig:"PASSWORD_RESET_TTL" default:"1h"`
+
+	// A new password must differ from the last PasswordHistory passwords
+	PasswordHistory int `envconfig:"PASSWORD_HISTORY" default:"5"`
+
+	// New passwords are hashed with PasswordHash, "argon2id" or "bcrypt".
+	// Hashes of another algorithm or other parameters are upgraded on login.
+	PasswordHash     string `envconfig:"PASSWORD_HASH" default:"argon2id"`
+	BcryptCost       int    `envconfig:"BCRYPT_COST" default:"10"`
+	Argon2Memory     uint32 `envconfig:"ARGON2_MEMORY" default:"65536"`
+	Argon2Iterations uint32 `envconfig:"ARGON2_ITERATIONS" default:"3"`
+	Argon2Threads    uint8  `envconfig:"ARGON2_THREADS" default:"2"`
+
+	// Password policy. PasswordMinScore is a zxcvbn-style score from 0 to 4.
+	// PasswordBreachedDir holds a breached password list in the k-anonymity
+	// range format, one "SUFFIX:COUNT" file per SHA-1 prefix; empty disables it.
+	PasswordMinLength      int    `envconfig:"PASSWORD_MIN_LENGTH" default:"8"`
+	PasswordMaxLength      int    `envconfig:"PASSWORD_MAX_LENGTH" default:"72"`
+	PasswordRequireUpper   bool   `envconfig:"PASSWORD_REQUIRE_UPPER" default:"false"`
+	PasswordRequireLower   bool   `envconfig:"PASSWORD_REQUIRE_LOWER" default:"false"`
+	PasswordRequireDigit   bool   `envconfig:"PASSWORD_REQUIRE_DIGIT" default:"true"`
+	PasswordRequireSpecial bool   `envconfig:"PASSWORD_REQUIRE_SPECIAL" default:"true"`
+	PasswordMinScore       int    `envconfig:"PASSWORD_MIN_SCORE" default:"2"`
+	PasswordBreachedDir    string `envconfig:"PASSWORD_BREACHED_DIR"`
 }
 
 var (
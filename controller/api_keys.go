@@ -0,0 +1,114 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/VikaGo/REST_API/model"
+	"github.com/VikaGo/REST_API/pkg/types"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/pkg/errors"
+)
+
+// CreateAPIKey creates an API key of a user. The key is only in this response.
+func (ctr *UserController) CreateAPIKey(ctx echo.Context) error {
+	userID, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, errors.Wrap(err, "could not parse user UUID"))
+	}
+
+	var input model.APIKeyInput
+	if err := ctx.Bind(&input); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, errors.Wrap(err, "could not decode API key"))
+	}
+	if err := ctx.Validate(&input); err != nil {
+		return echo.NewHTTPError(http.StatusUnprocessableEntity, err)
+	}
+	if err := checkScopes(principalFromContext(ctx), input.Scopes); err != nil {
+		return err
+	}
+
+	key, err := ctr.services.APIKey.CreateAPIKey(ctx.Request().Context(), userID, &input)
+	if err != nil {
+		switch errors.Cause(err) {
+		case types.ErrNotFound:
+			return echo.NewHTTPError(http.StatusNotFound, err)
+		case types.ErrUnprocessableEntity:
+			// names the expires_at field
+			return err
+		default:
+			return echo.NewHTTPError(http.StatusInternalServerError, errors.Wrap(err, "could not create API key"))
+		}
+	}
+
+	ctr.logger.Debug().Msgf("Created API key '%s' of user '%s'", key.ID.String(), userID.String())
+
+	return ctx.JSON(http.StatusCreated, key)
+}
+
+// ListAPIKeys returns the API keys of a user, without the keys themselves
+func (ctr *UserController) ListAPIKeys(ctx echo.Context) error {
+	userID, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, errors.Wrap(err, "could not parse user UUID"))
+	}
+
+	keys, err := ctr.services.APIKey.ListAPIKeys(ctx.Request().Context(), userID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, errors.Wrap(err, "could not list API keys"))
+	}
+
+	return ctx.JSON(http.StatusOK, keys)
+}
+
+// RevokeAPIKey revokes an API key of a user
+func (ctr *UserController) RevokeAPIKey(ctx echo.Context) error {
+	userID, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, errors.Wrap(err, "could not parse user UUID"))
+	}
+	keyID, err := uuid.Parse(ctx.Param("key_id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, errors.Wrap(err, "could not parse API key UUID"))
+	}
+
+	err = ctr.services.APIKey.RevokeAPIKey(ctx.Request().Context(), userID, keyID)
+	if err != nil {
+		switch errors.Cause(err) {
+		case types.ErrNotFound:
+			return echo.NewHTTPError(http.StatusNotFound, err)
+		default:
+			return echo.NewHTTPError(http.StatusInternalServerError, errors.Wrap(err, "could not revoke API key"))
+		}
+	}
+
+	ctr.logger.Debug().Msgf("Revoked API key '%s' of user '%s'", keyID.String(), userID.String())
+
+	return ctx.NoContent(http.StatusNoContent)
+}
+
+// checkScopes requires scopes to name permissions. A caller using a scoped
+// API key may only create keys limited to its own scopes.
+func checkScopes(principal *model.Principal, scopes []string) error {
+	for _, scope := range scopes {
+		if !isPermission(Permission(scope)) {
+			return &types.FieldError{
+				Err:   errors.Wrap(types.ErrUnprocessableEntity, fmt.Sprintf("unknown scope '%s'", scope)),
+				Field: "scopes",
+			}
+		}
+	}
+	if principal == nil || principal.Scopes == nil {
+		return nil
+	}
+	if len(scopes) == 0 {
+		return errors.Wrap(types.ErrForbidden, "a scoped API key can only create scoped API keys")
+	}
+	for _, scope := range scopes {
+		if !hasScope(principal, Permission(scope)) {
+			return errors.Wrap(types.ErrForbidden, fmt.Sprintf("scope '%s' exceeds the scopes of the API key", scope))
+		}
+	}
+	return nil
+}
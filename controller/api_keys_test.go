@@ -0,0 +1,108 @@
+package controller
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/VikaGo/REST_API/logger"
+	"github.com/VikaGo/REST_API/model"
+	"github.com/VikaGo/REST_API/pkg/types"
+	"github.com/VikaGo/REST_API/pkg/validator"
+	"github.com/VikaGo/REST_API/service"
+	"github.com/VikaGo/REST_API/service/mocks"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestCreateAPIKey(t *testing.T) {
+	l := logger.Get()
+	self := uuid.MustParse("7a2f922c-073a-11eb-adc1-0242ac120002")
+	created := &model.CreatedAPIKey{
+		APIKey: &model.APIKey{ID: uuid.MustParse("9b1deb4d-3b7d-4bad-9bdd-2b0d7b3dcb6d"), UserID: self, Name: "export", Prefix: "rak_abcdefgh"},
+		Key:    "rak_abcdefgh-secret",
+	}
+
+	tests := []struct {
+		testName     string
+		scopes       []string
+		body         string
+		expectations func(svc *mocks.APIKeyService)
+		code         int
+		cause        error
+	}{
+		{
+			testName: "create",
+			body:     `{"name":"export","scopes":["users:list"]}`,
+			expectations: func(svc *mocks.APIKeyService) {
+				svc.On("CreateAPIKey", mock.Anything, self, &model.APIKeyInput{Name: "export", Scopes: []string{"users:list"}}).Return(created, nil)
+			},
+			code: http.StatusCreated,
+		},
+		{
+			testName:     "missing name",
+			body:         `{}`,
+			expectations: func(svc *mocks.APIKeyService) {},
+			code:         http.StatusUnprocessableEntity,
+		},
+		{
+			testName:     "unknown scope",
+			body:         `{"name":"export","scopes":["users:everything"]}`,
+			expectations: func(svc *mocks.APIKeyService) {},
+			cause:        types.ErrUnprocessableEntity,
+		},
+		{
+			testName:     "unscoped key from scoped key",
+			scopes:       []string{"users:create_api_key"},
+			body:         `{"name":"export"}`,
+			expectations: func(svc *mocks.APIKeyService) {},
+			cause:        types.ErrForbidden,
+		},
+		{
+			testName:     "wider scopes from scoped key",
+			scopes:       []string{"users:create_api_key"},
+			body:         `{"name":"export","scopes":["users:list"]}`,
+			expectations: func(svc *mocks.APIKeyService) {},
+			cause:        types.ErrForbidden,
+		},
+	}
+
+	for _, test := range tests {
+		t.Logf("running %v", test.testName)
+
+		e := echo.New()
+		e.Validator = validator.NewValidator()
+		r := httptest.NewRequest(echo.POST, "/v1/users/"+self.String()+"/api-keys", strings.NewReader(test.body))
+		r.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		w := httptest.NewRecorder()
+		ctx := e.NewContext(r, w)
+		ctx.SetParamNames("id")
+		ctx.SetParamValues(self.String())
+		ctx.Set(ContextUserIDKey, self)
+		ctx.Set(ContextRoleKey, model.RoleViewer)
+		if test.scopes != nil {
+			ctx.Set(ContextScopesKey, test.scopes)
+		}
+
+		svc := &mocks.APIKeyService{}
+		test.expectations(svc)
+
+		d := NewUsers(ctx.Request().Context(), &service.Manager{APIKey: svc}, l)
+		err := d.CreateAPIKey(ctx)
+		switch {
+		case test.cause != nil:
+			assert.Equal(t, test.cause, errors.Cause(err))
+		case test.code < http.StatusBadRequest:
+			assert.NoError(t, err)
+			assert.Equal(t, test.code, w.Code)
+			assert.Contains(t, w.Body.String(), `"key":"rak_abcdefgh-secret"`)
+		default:
+			assert.Equal(t, test.code, types.HTTPCode(err))
+		}
+		svc.AssertExpectations(t)
+	}
+}
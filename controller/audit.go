@@ -0,0 +1,53 @@
+package controller
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/VikaGo/REST_API/logger"
+	"github.com/VikaGo/REST_API/model"
+	"github.com/VikaGo/REST_API/pkg/types"
+	"github.com/VikaGo/REST_API/service"
+	"github.com/labstack/echo/v4"
+	"github.com/pkg/errors"
+)
+
+// AuditController serves the audit log
+type AuditController struct {
+	ctx      context.Context
+	services *service.Manager
+	logger   *logger.Logger
+}
+
+// NewAudit creates a new audit controller.
+func NewAudit(ctx context.Context, services *service.Manager, logger *logger.Logger) *AuditController {
+	return &AuditController{
+		ctx:      ctx,
+		services: services,
+		logger:   logger,
+	}
+}
+
+// List returns one page of audit events, newest first
+func (ctr *AuditController) List(ctx echo.Context) error {
+	var params model.AuditListParams
+	err := ctx.Bind(&params)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, errors.Wrap(err, "could not decode audit parameters"))
+	}
+	err = ctx.Validate(&params)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnprocessableEntity, err)
+	}
+
+	page, err := ctr.services.Audit.ListAuditEvents(ctx.Request().Context(), &params)
+	if err != nil {
+		switch {
+		case errors.Cause(err) == types.ErrBadRequest:
+			return echo.NewHTTPError(http.StatusBadRequest, err)
+		default:
+			return echo.NewHTTPError(http.StatusInternalServerError, errors.Wrap(err, "could not list audit events"))
+		}
+	}
+	return ctx.JSON(http.StatusOK, page)
+}
@@ -0,0 +1,85 @@
+package controller
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/VikaGo/REST_API/logger"
+	"github.com/VikaGo/REST_API/model"
+	"github.com/VikaGo/REST_API/pkg/types"
+	"github.com/VikaGo/REST_API/pkg/validator"
+	"github.com/VikaGo/REST_API/service"
+	"github.com/VikaGo/REST_API/service/mocks"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestListAudit(t *testing.T) {
+	l := logger.Get()
+	actor := uuid.MustParse("7a2f922c-073a-11eb-adc1-0242ac120002")
+
+	tests := []struct {
+		testName     string
+		query        string
+		expectations func(svc *mocks.AuditService)
+		code         int
+	}{
+		{
+			testName: "filtered",
+			query:    "actor_id=" + actor.String() + "&action=user.update&from=2023-10-01T00:00:00Z",
+			expectations: func(svc *mocks.AuditService) {
+				svc.On("ListAuditEvents", mock.Anything, mock.MatchedBy(func(params *model.AuditListParams) bool {
+					return params.ActorID == actor && params.Action == model.AuditUserUpdate && params.From.Year() == 2023
+				})).Return(&model.AuditPage{Items: []*model.AuditEvent{}}, nil)
+			},
+			code: http.StatusOK,
+		},
+		{
+			testName:     "malformed actor",
+			query:        "actor_id=someone",
+			expectations: func(svc *mocks.AuditService) {},
+			code:         http.StatusBadRequest,
+		},
+		{
+			testName:     "limit too large",
+			query:        "limit=1000",
+			expectations: func(svc *mocks.AuditService) {},
+			code:         http.StatusUnprocessableEntity,
+		},
+		{
+			testName: "malformed cursor",
+			query:    "cursor=abc",
+			expectations: func(svc *mocks.AuditService) {
+				svc.On("ListAuditEvents", mock.Anything, mock.Anything).Return(nil, errors.Wrap(types.ErrBadRequest, "invalid cursor"))
+			},
+			code: http.StatusBadRequest,
+		},
+	}
+
+	for _, test := range tests {
+		t.Logf("running %v", test.testName)
+
+		e := echo.New()
+		e.Validator = validator.NewValidator()
+		r := httptest.NewRequest(echo.GET, "/v1/audit?"+test.query, nil)
+		w := httptest.NewRecorder()
+		ctx := e.NewContext(r, w)
+
+		svc := &mocks.AuditService{}
+		test.expectations(svc)
+
+		d := NewAudit(ctx.Request().Context(), &service.Manager{Audit: svc}, l)
+		err := d.List(ctx)
+		if test.code == http.StatusOK {
+			assert.NoError(t, err)
+			assert.Equal(t, test.code, w.Code)
+		} else {
+			assert.Equal(t, test.code, types.HTTPCode(err))
+		}
+		svc.AssertExpectations(t)
+	}
+}
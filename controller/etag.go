@@ -0,0 +1,81 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/pkg/errors"
+)
+
+// etag returns the entity tag of a resource version
+func etag(version int) string {
+	return fmt.Sprintf(`"%d"`, version)
+}
+
+// setETag sets the ETag header of the response
+func setETag(ctx echo.Context, version int) {
+	ctx.Response().Header().Set("ETag", etag(version))
+}
+
+// ifMatchVersion returns the version required by the If-Match header. It
+// returns 0 if there is no precondition on the version ("*" or no header).
+func ifMatchVersion(ctx echo.Context) (int, error) {
+	header := ctx.Request().Header.Get("If-Match")
+	if header == "" {
+		return 0, nil
+	}
+
+	tags := splitETags(header)
+	for _, tag := range tags {
+		if tag == "*" {
+			return 0, nil
+		}
+	}
+	if len(tags) != 1 {
+		return 0, echo.NewHTTPError(http.StatusBadRequest, "If-Match supports a single entity tag only")
+	}
+	// If-Match uses the strong comparison, weak tags never match
+	tag := tags[0]
+	if strings.HasPrefix(tag, "W/") || len(tag) < 2 || !strings.HasPrefix(tag, `"`) || !strings.HasSuffix(tag, `"`) {
+		return 0, echo.NewHTTPError(http.StatusPreconditionFailed, "If-Match does not match the current entity tag")
+	}
+	version, err := strconv.Atoi(tag[1 : len(tag)-1])
+	if err != nil || version <= 0 {
+		return 0, echo.NewHTTPError(http.StatusPreconditionFailed, "If-Match does not match the current entity tag")
+	}
+	return version, nil
+}
+
+// notModified reports whether the If-None-Match header matches version
+func notModified(ctx echo.Context, version int) bool {
+	header := ctx.Request().Header.Get("If-None-Match")
+	if header == "" {
+		return false
+	}
+	current := etag(version)
+	for _, tag := range splitETags(header) {
+		// If-None-Match uses the weak comparison
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == current {
+			return true
+		}
+	}
+	return false
+}
+
+// preconditionFailed is returned when a conditional write lost against another one
+func preconditionFailed(err error) error {
+	return echo.NewHTTPError(http.StatusPreconditionFailed, errors.Wrap(err, "user was modified since it was read"))
+}
+
+func splitETags(header string) []string {
+	var tags []string
+	for _, tag := range strings.Split(header, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
@@ -0,0 +1,38 @@
+package controller
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/VikaGo/REST_API/logger"
+	"github.com/VikaGo/REST_API/model"
+	"github.com/VikaGo/REST_API/service"
+	"github.com/labstack/echo/v4"
+)
+
+// HealthController serves the readiness check of load balancers and
+// orchestrators
+type HealthController struct {
+	ctx      context.Context
+	services *service.Manager
+	logger   *logger.Logger
+}
+
+// NewHealth creates a new health controller.
+func NewHealth(ctx context.Context, services *service.Manager, logger *logger.Logger) *HealthController {
+	return &HealthController{
+		ctx:      ctx,
+		services: services,
+		logger:   logger,
+	}
+}
+
+// Ready returns the health of the service, with status 503 while it cannot
+// serve requests
+func (ctr *HealthController) Ready(ctx echo.Context) error {
+	health := ctr.services.Health.Health(ctx.Request().Context())
+	if health.Status != model.HealthOK {
+		return ctx.JSON(http.StatusServiceUnavailable, health)
+	}
+	return ctx.JSON(http.StatusOK, health)
+}
@@ -0,0 +1,40 @@
+package controller
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/VikaGo/REST_API/logger"
+	"github.com/VikaGo/REST_API/model"
+	"github.com/VikaGo/REST_API/service"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReady(t *testing.T) {
+	l := logger.Get()
+
+	tests := []struct {
+		testName string
+		status   string
+		code     int
+	}{
+		{testName: "ready", status: model.HealthOK, code: http.StatusOK},
+		{testName: "database unreachable", status: model.HealthUnavailable, code: http.StatusServiceUnavailable},
+	}
+
+	for _, test := range tests {
+		t.Logf("running %v", test.testName)
+
+		e := echo.New()
+		r := httptest.NewRequest(echo.GET, "/ready", nil)
+		w := httptest.NewRecorder()
+		ctx := e.NewContext(r, w)
+
+		d := NewHealth(ctx.Request().Context(), &service.Manager{Health: testHealth(test.status)}, l)
+		assert.NoError(t, d.Ready(ctx))
+		assert.Equal(t, test.code, w.Code)
+		assert.Contains(t, w.Body.String(), `"status":"`+test.status+`"`)
+	}
+}
@@ -0,0 +1,31 @@
+package controller
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/VikaGo/REST_API/logger"
+	"github.com/VikaGo/REST_API/service"
+	"github.com/labstack/echo/v4"
+)
+
+// KeyController serves the public keys that verify issued tokens
+type KeyController struct {
+	ctx      context.Context
+	services *service.Manager
+	logger   *logger.Logger
+}
+
+// NewKeys creates a new key controller.
+func NewKeys(ctx context.Context, services *service.Manager, logger *logger.Logger) *KeyController {
+	return &KeyController{
+		ctx:      ctx,
+		services: services,
+		logger:   logger,
+	}
+}
+
+// JWKS returns the JSON Web Key Set of the token signing keys
+func (ctr *KeyController) JWKS(ctx echo.Context) error {
+	return ctx.JSON(http.StatusOK, ctr.services.Keys.JWKS())
+}
@@ -0,0 +1,164 @@
+package controller
+
+import (
+	"strings"
+
+	"github.com/VikaGo/REST_API/model"
+	"github.com/VikaGo/REST_API/pkg/types"
+	"github.com/VikaGo/REST_API/service"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/pkg/errors"
+)
+
+// Keys under which the authenticated caller is stored in echo.Context
+const (
+	ContextUserIDKey = "user_id"
+	ContextRoleKey   = "role"
+	// ContextScopesKey holds the scopes of a scoped API key
+	ContextScopesKey = "scopes"
+	// ContextSessionIDKey holds the ID of the cookie session of the caller
+	ContextSessionIDKey = "session_id"
+)
+
+const bearerScheme = "Bearer"
+
+// HeaderAPIKey carries the API key of callers that do not log in
+const HeaderAPIKey = "X-API-Key"
+
+// AuthMiddleware requires a valid "Authorization: Bearer <token>" or
+// "X-API-Key" header, or a session cookie, and stores the authenticated
+// user ID, role, API key scopes and session ID in the echo context.
+func AuthMiddleware(services *service.Manager) echo.MiddlewareFunc {
+	return authMiddleware(services, authenticate)
+}
+
+// BrowserAuthMiddleware requires a session cookie, like AuthMiddleware
+// does without the headers. It guards the routes browsers reach by
+// redirect, which carry nothing but their cookies.
+func BrowserAuthMiddleware(services *service.Manager) echo.MiddlewareFunc {
+	return authMiddleware(services, authenticateSession)
+}
+
+func authMiddleware(services *service.Manager, authenticate func(echo.Context, *service.Manager) (*model.Principal, error)) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			principal, err := authenticate(ctx, services)
+			if err != nil {
+				if errors.Cause(err) == types.ErrForbidden {
+					// a CSRF failure, the caller is known
+					return err
+				}
+				return unauthorized(ctx, err)
+			}
+
+			ctx.Set(ContextUserIDKey, principal.UserID)
+			ctx.Set(ContextRoleKey, principal.Role)
+			if principal.Scopes != nil {
+				ctx.Set(ContextScopesKey, principal.Scopes)
+			}
+			if principal.SessionID != nil {
+				ctx.Set(ContextSessionIDKey, *principal.SessionID)
+			}
+
+			// the caller is the actor of everything the request audits
+			info := *service.RequestInfoFromContext(ctx.Request().Context())
+			info.ActorID = &principal.UserID
+			info.SessionID = principal.SessionID
+			ctx.SetRequest(ctx.Request().WithContext(service.WithRequestInfo(ctx.Request().Context(), &info)))
+
+			return next(ctx)
+		}
+	}
+}
+
+// authenticate resolves the caller from an API key, or else from a bearer
+// token, or else from a session cookie
+func authenticate(ctx echo.Context, services *service.Manager) (*model.Principal, error) {
+	if key := ctx.Request().Header.Get(HeaderAPIKey); key != "" {
+		return services.APIKey.ParseAPIKey(ctx.Request().Context(), key)
+	}
+	if ctx.Request().Header.Get(echo.HeaderAuthorization) == "" {
+		if cookie, err := ctx.Cookie(sessionCookie); err == nil {
+			return sessionPrincipal(ctx, services, cookie.Value)
+		}
+	}
+
+	token, err := bearerToken(ctx.Request().Header.Get(echo.HeaderAuthorization))
+	if err != nil {
+		return nil, err
+	}
+	return services.User.ParseToken(ctx.Request().Context(), token)
+}
+
+// authenticateSession resolves the caller from a session cookie only
+func authenticateSession(ctx echo.Context, services *service.Manager) (*model.Principal, error) {
+	cookie, err := ctx.Cookie(sessionCookie)
+	if err != nil {
+		return nil, errors.Wrap(types.ErrUnauthorized, "missing session cookie")
+	}
+	return sessionPrincipal(ctx, services, cookie.Value)
+}
+
+// RequestInfoMiddleware stores where a request comes from in its context,
+// so the services can audit it. It must run after middleware.RequestID.
+func RequestInfoMiddleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			requestID := ctx.Request().Header.Get(echo.HeaderXRequestID)
+			if requestID == "" {
+				requestID = ctx.Response().Header().Get(echo.HeaderXRequestID)
+			}
+			info := &model.RequestInfo{
+				IP:        ctx.RealIP(),
+				UserAgent: ctx.Request().UserAgent(),
+				RequestID: requestID,
+			}
+			ctx.SetRequest(ctx.Request().WithContext(service.WithRequestInfo(ctx.Request().Context(), info)))
+
+			return next(ctx)
+		}
+	}
+}
+
+// principalFromContext returns the caller stored by AuthMiddleware, or nil
+func principalFromContext(ctx echo.Context) *model.Principal {
+	userID, ok := ctx.Get(ContextUserIDKey).(uuid.UUID)
+	if !ok {
+		return nil
+	}
+	role, _ := ctx.Get(ContextRoleKey).(string)
+	scopes, _ := ctx.Get(ContextScopesKey).([]string)
+	principal := &model.Principal{
+		UserID: userID,
+		Role:   role,
+		Scopes: scopes,
+	}
+	if sessionID, ok := ctx.Get(ContextSessionIDKey).(uuid.UUID); ok {
+		principal.SessionID = &sessionID
+	}
+	return principal
+}
+
+func bearerToken(header string) (string, error) {
+	if header == "" {
+		return "", errors.Wrap(types.ErrUnauthorized, "missing authorization header")
+	}
+	scheme, token, found := strings.Cut(header, " ")
+	if !found || !strings.EqualFold(scheme, bearerScheme) {
+		return "", errors.Wrap(types.ErrUnauthorized, "authorization scheme must be Bearer")
+	}
+	token = strings.TrimSpace(token)
+	if token == "" {
+		return "", errors.Wrap(types.ErrUnauthorized, "empty bearer token")
+	}
+	return token, nil
+}
+
+func unauthorized(ctx echo.Context, err error) error {
+	ctx.Response().Header().Set(echo.HeaderWWWAuthenticate, bearerScheme)
+	if errors.Cause(err) != types.ErrUnauthorized {
+		return errors.Wrap(types.ErrUnauthorized, err.Error())
+	}
+	return err
+}
@@ -0,0 +1,110 @@
+package controller
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/VikaGo/REST_API/model"
+	"github.com/VikaGo/REST_API/pkg/types"
+	"github.com/VikaGo/REST_API/service"
+	"github.com/VikaGo/REST_API/service/mocks"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuthMiddleware(t *testing.T) {
+	principal := &model.Principal{
+		UserID: uuid.MustParse("7a2f922c-073a-11eb-adc1-0242ac120002"),
+		Role:   "viewer",
+	}
+
+	tests := []struct {
+		testName     string
+		expectations func(ctx context.Context, svc *mocks.UserService)
+		header       string
+		apiKey       string
+		err          error
+	}{
+		{
+			testName: "valid token",
+			expectations: func(ctx context.Context, svc *mocks.UserService) {
+				svc.On("ParseToken", ctx, "good").Return(principal, nil)
+			},
+			header: "Bearer good",
+		},
+		{
+			testName:     "missing header",
+			expectations: func(ctx context.Context, svc *mocks.UserService) {},
+			err:          errors.New("missing authorization header: unauthorized"),
+		},
+		{
+			testName:     "wrong scheme",
+			expectations: func(ctx context.Context, svc *mocks.UserService) {},
+			header:       "Basic dXNlcjpwYXNz",
+			err:          errors.New("authorization scheme must be Bearer: unauthorized"),
+		},
+		{
+			testName: "invalid token",
+			expectations: func(ctx context.Context, svc *mocks.UserService) {
+				svc.On("ParseToken", ctx, "bad").Return(nil, errors.Wrap(types.ErrUnauthorized, "token is expired"))
+			},
+			header: "Bearer bad",
+			err:    errors.New("token is expired: unauthorized"),
+		},
+		{
+			testName:     "valid API key",
+			expectations: func(ctx context.Context, svc *mocks.UserService) {},
+			apiKey:       "rak_good",
+		},
+		{
+			testName:     "revoked API key",
+			expectations: func(ctx context.Context, svc *mocks.UserService) {},
+			apiKey:       "rak_revoked",
+			err:          errors.New("API key was revoked: unauthorized"),
+		},
+	}
+
+	for _, test := range tests {
+		t.Logf("running %v", test.testName)
+
+		e := echo.New()
+		r := httptest.NewRequest(echo.GET, "/v1/users/"+principal.UserID.String(), nil)
+		if test.header != "" {
+			r.Header.Set(echo.HeaderAuthorization, test.header)
+		}
+		if test.apiKey != "" {
+			r.Header.Set(HeaderAPIKey, test.apiKey)
+		}
+		w := httptest.NewRecorder()
+		ctx := e.NewContext(r, w)
+
+		svc := &mocks.UserService{}
+		test.expectations(ctx.Request().Context(), svc)
+		keys := &mocks.APIKeyService{}
+		keys.On("ParseAPIKey", ctx.Request().Context(), "rak_good").Return(principal, nil).Maybe()
+		keys.On("ParseAPIKey", ctx.Request().Context(), "rak_revoked").Return(nil, errors.Wrap(types.ErrUnauthorized, "API key was revoked")).Maybe()
+
+		called := false
+		handler := AuthMiddleware(&service.Manager{User: svc, APIKey: keys})(func(ctx echo.Context) error {
+			called = true
+			assert.Equal(t, principal.UserID, ctx.Get(ContextUserIDKey))
+			assert.Equal(t, principal.Role, ctx.Get(ContextRoleKey))
+			return ctx.NoContent(http.StatusOK)
+		})
+
+		err := handler(ctx)
+		assert.Equal(t, test.err == nil, called)
+		if test.err != nil {
+			assert.Equal(t, test.err.Error(), err.Error())
+			assert.Equal(t, types.ErrUnauthorized, errors.Cause(err))
+			assert.Equal(t, "Bearer", w.Header().Get(echo.HeaderWWWAuthenticate))
+		} else {
+			assert.NoError(t, err)
+		}
+		svc.AssertExpectations(t)
+	}
+}
@@ -0,0 +1,170 @@
+package controller
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+
+	"github.com/VikaGo/REST_API/logger"
+	"github.com/VikaGo/REST_API/model"
+	"github.com/VikaGo/REST_API/pkg/types"
+	"github.com/VikaGo/REST_API/service"
+	"github.com/labstack/echo/v4"
+	"github.com/pkg/errors"
+)
+
+// OAuthController serves the OAuth 2.0 and OpenID Connect provider endpoints
+type OAuthController struct {
+	ctx      context.Context
+	services *service.Manager
+	logger   *logger.Logger
+}
+
+// NewOAuth creates a new OAuth controller.
+func NewOAuth(ctx context.Context, services *service.Manager, logger *logger.Logger) *OAuthController {
+	return &OAuthController{
+		ctx:      ctx,
+		services: services,
+		logger:   logger,
+	}
+}
+
+// RegisterClient registers an OAuth client. The secret is only in this response.
+func (ctr *OAuthController) RegisterClient(ctx echo.Context) error {
+	var input model.OAuthClientInput
+	if err := ctx.Bind(&input); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, errors.Wrap(err, "could not decode OAuth client"))
+	}
+	if err := ctx.Validate(&input); err != nil {
+		return echo.NewHTTPError(http.StatusUnprocessableEntity, err)
+	}
+
+	client, err := ctr.services.OAuth.RegisterClient(ctx.Request().Context(), &input)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, errors.Wrap(err, "could not register OAuth client"))
+	}
+
+	ctr.logger.Debug().Msgf("Registered OAuth client '%s'", client.ClientID)
+
+	return ctx.JSON(http.StatusCreated, client)
+}
+
+// ListClients returns every OAuth client, without secrets
+func (ctr *OAuthController) ListClients(ctx echo.Context) error {
+	clients, err := ctr.services.OAuth.ListClients(ctx.Request().Context())
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, errors.Wrap(err, "could not list OAuth clients"))
+	}
+	return ctx.JSON(http.StatusOK, clients)
+}
+
+// DeleteClient deletes an OAuth client
+func (ctr *OAuthController) DeleteClient(ctx echo.Context) error {
+	clientID := ctx.Param("client_id")
+	err := ctr.services.OAuth.DeleteClient(ctx.Request().Context(), clientID)
+	if err != nil {
+		switch errors.Cause(err) {
+		case types.ErrNotFound:
+			return echo.NewHTTPError(http.StatusNotFound, err)
+		default:
+			return echo.NewHTTPError(http.StatusInternalServerError, errors.Wrap(err, "could not delete OAuth client"))
+		}
+	}
+
+	ctr.logger.Debug().Msgf("Deleted OAuth client '%s'", clientID)
+
+	return ctx.NoContent(http.StatusNoContent)
+}
+
+// Authorize grants an authorization request of a client to the logged in
+// user and redirects back to the client with a code. It must run after
+// BrowserAuthMiddleware: the user agent is redirected here by the client.
+// Clients are registered by admins and trusted as first-party, so the user
+// is not asked for consent.
+func (ctr *OAuthController) Authorize(ctx echo.Context) error {
+	principal := principalFromContext(ctx)
+	if principal == nil {
+		return errors.Wrap(types.ErrUnauthorized, "no authenticated user")
+	}
+	if principal.Scopes != nil {
+		return errors.Wrap(types.ErrForbidden, "scoped API keys cannot authorize OAuth clients")
+	}
+
+	var req model.AuthorizeRequest
+	if err := ctx.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, errors.Wrap(err, "could not decode authorization request"))
+	}
+
+	location, err := ctr.services.OAuth.Authorize(ctx.Request().Context(), principal.UserID, &req)
+	if err != nil {
+		switch errors.Cause(err) {
+		case types.ErrBadRequest:
+			return echo.NewHTTPError(http.StatusBadRequest, err)
+		case types.ErrUnauthorized:
+			return err
+		default:
+			return echo.NewHTTPError(http.StatusInternalServerError, errors.Wrap(err, "could not authorize client"))
+		}
+	}
+
+	return ctx.Redirect(http.StatusFound, location)
+}
+
+// Token exchanges an authorization code for tokens. Clients authenticate
+// with HTTP Basic or with client_id and client_secret in the form.
+func (ctr *OAuthController) Token(ctx echo.Context) error {
+	var req model.TokenRequest
+	if err := ctx.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, errors.Wrap(err, "could not decode token request"))
+	}
+	clientID, clientSecret, basic := ctx.Request().BasicAuth()
+	if basic {
+		// RFC 6749 section 2.3.1 form-encodes the credentials first
+		req.ClientID, _ = url.QueryUnescape(clientID)
+		req.ClientSecret, _ = url.QueryUnescape(clientSecret)
+	}
+
+	// tokens must never be cached
+	ctx.Response().Header().Set(echo.HeaderCacheControl, "no-store")
+	ctx.Response().Header().Set("Pragma", "no-cache")
+
+	response, err := ctr.services.OAuth.Token(ctx.Request().Context(), &req)
+	if err != nil {
+		var oauthErr *types.OAuthError
+		if !errors.As(err, &oauthErr) {
+			return echo.NewHTTPError(http.StatusInternalServerError, errors.Wrap(err, "could not issue token"))
+		}
+		if basic && errors.Cause(err) == types.ErrUnauthorized {
+			ctx.Response().Header().Set(echo.HeaderWWWAuthenticate, "Basic")
+		}
+		return err
+	}
+
+	return ctx.JSON(http.StatusOK, response)
+}
+
+// UserInfo returns the claims about the user an OAuth access token was issued for
+func (ctr *OAuthController) UserInfo(ctx echo.Context) error {
+	token, err := bearerToken(ctx.Request().Header.Get(echo.HeaderAuthorization))
+	if err != nil {
+		return unauthorized(ctx, err)
+	}
+
+	info, err := ctr.services.OAuth.UserInfo(ctx.Request().Context(), token)
+	if err != nil {
+		var oauthErr *types.OAuthError
+		if !errors.As(err, &oauthErr) {
+			return echo.NewHTTPError(http.StatusInternalServerError, errors.Wrap(err, "could not get user info"))
+		}
+		// RFC 6750 section 3
+		ctx.Response().Header().Set(echo.HeaderWWWAuthenticate, bearerScheme+` error="`+oauthErr.Code+`"`)
+		return err
+	}
+
+	return ctx.JSON(http.StatusOK, info)
+}
+
+// Discovery returns the OpenID Connect discovery document
+func (ctr *OAuthController) Discovery(ctx echo.Context) error {
+	return ctx.JSON(http.StatusOK, ctr.services.OAuth.Discovery())
+}
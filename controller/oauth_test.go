@@ -0,0 +1,159 @@
+package controller
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/VikaGo/REST_API/logger"
+	"github.com/VikaGo/REST_API/model"
+	"github.com/VikaGo/REST_API/pkg/types"
+	"github.com/VikaGo/REST_API/service"
+	"github.com/VikaGo/REST_API/service/mocks"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOAuthAuthorize(t *testing.T) {
+	l := logger.Get()
+	self := uuid.MustParse("7a2f922c-073a-11eb-adc1-0242ac120002")
+	query := "response_type=code&client_id=wiki&redirect_uri=https%3A%2F%2Fwiki.example.com%2Fcallback&state=xyz&code_challenge=abc&code_challenge_method=S256"
+	req := &model.AuthorizeRequest{
+		ResponseType:        "code",
+		ClientID:            "wiki",
+		RedirectURI:         "https://wiki.example.com/callback",
+		State:               "xyz",
+		CodeChallenge:       "abc",
+		CodeChallengeMethod: "S256",
+	}
+
+	tests := []struct {
+		testName string
+		scopes   []string
+		location string
+		code     int
+		cause    error
+	}{
+		{
+			testName: "granted",
+			location: "https://wiki.example.com/callback?code=c0de&state=xyz",
+			code:     http.StatusFound,
+		},
+		{
+			testName: "scoped API key",
+			scopes:   []string{"users:read"},
+			cause:    types.ErrForbidden,
+		},
+		{
+			testName: "unknown redirect",
+			code:     http.StatusBadRequest,
+		},
+	}
+
+	for _, test := range tests {
+		t.Logf("running %v", test.testName)
+
+		e := echo.New()
+		r := httptest.NewRequest(echo.GET, "/oauth/authorize?"+query, nil)
+		w := httptest.NewRecorder()
+		ctx := e.NewContext(r, w)
+		ctx.Set(ContextUserIDKey, self)
+		ctx.Set(ContextRoleKey, model.RoleViewer)
+		if test.scopes != nil {
+			ctx.Set(ContextScopesKey, test.scopes)
+		}
+
+		svc := &mocks.OAuthService{}
+		switch {
+		case test.location != "":
+			svc.On("Authorize", ctx.Request().Context(), self, req).Return(test.location, nil)
+		case test.code == http.StatusBadRequest:
+			svc.On("Authorize", ctx.Request().Context(), self, req).Return("", errors.Wrap(types.ErrBadRequest, "redirect_uri is not registered for the client"))
+		}
+
+		d := NewOAuth(ctx.Request().Context(), &service.Manager{OAuth: svc}, l)
+		err := d.Authorize(ctx)
+		switch {
+		case test.cause != nil:
+			assert.Equal(t, test.cause, errors.Cause(err))
+		case test.code < http.StatusBadRequest:
+			assert.NoError(t, err)
+			assert.Equal(t, test.code, w.Code)
+			assert.Equal(t, test.location, w.Header().Get(echo.HeaderLocation))
+		default:
+			assert.Equal(t, test.code, types.HTTPCode(err))
+		}
+		svc.AssertExpectations(t)
+	}
+}
+
+func TestOAuthToken(t *testing.T) {
+	l := logger.Get()
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {"c0de"},
+		"redirect_uri":  {"https://wiki.example.com/callback"},
+		"code_verifier": {"verifier"},
+	}
+	expected := &model.TokenRequest{
+		GrantType:    "authorization_code",
+		Code:         "c0de",
+		RedirectURI:  "https://wiki.example.com/callback",
+		ClientID:     "wiki",
+		ClientSecret: "s3cret",
+		CodeVerifier: "verifier",
+	}
+
+	tests := []struct {
+		testName      string
+		err           error
+		code          int
+		wwwAuthHeader string
+	}{
+		{testName: "issued", code: http.StatusOK},
+		{
+			testName:      "invalid client",
+			err:           &types.OAuthError{Err: errors.Wrap(types.ErrUnauthorized, "client authentication failed"), Code: "invalid_client"},
+			wwwAuthHeader: "Basic",
+		},
+		{
+			testName: "invalid grant",
+			err:      &types.OAuthError{Err: errors.Wrap(types.ErrBadRequest, "invalid PKCE code verifier"), Code: "invalid_grant"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Logf("running %v", test.testName)
+
+		e := echo.New()
+		r := httptest.NewRequest(echo.POST, "/oauth/token", strings.NewReader(form.Encode()))
+		r.Header.Set(echo.HeaderContentType, echo.MIMEApplicationForm)
+		r.SetBasicAuth("wiki", "s3cret")
+		w := httptest.NewRecorder()
+		ctx := e.NewContext(r, w)
+
+		svc := &mocks.OAuthService{}
+		if test.err != nil {
+			svc.On("Token", ctx.Request().Context(), expected).Return(nil, test.err)
+		} else {
+			svc.On("Token", ctx.Request().Context(), expected).Return(&model.OAuthTokenResponse{AccessToken: "at", TokenType: "Bearer"}, nil)
+		}
+
+		d := NewOAuth(ctx.Request().Context(), &service.Manager{OAuth: svc}, l)
+		err := d.Token(ctx)
+		assert.Equal(t, "no-store", w.Header().Get(echo.HeaderCacheControl))
+		if test.err != nil {
+			var oauthErr *types.OAuthError
+			assert.True(t, errors.As(err, &oauthErr))
+			assert.Equal(t, test.wwwAuthHeader, w.Header().Get(echo.HeaderWWWAuthenticate))
+		} else {
+			assert.NoError(t, err)
+			assert.Equal(t, test.code, w.Code)
+		}
+		svc.AssertExpectations(t)
+	}
+}
@@ -0,0 +1,95 @@
+package controller
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/VikaGo/REST_API/model"
+	"github.com/VikaGo/REST_API/pkg/types"
+	"github.com/VikaGo/REST_API/service"
+	"github.com/labstack/echo/v4"
+	"github.com/pkg/errors"
+)
+
+const (
+	// oidcStateCookie holds the state of an external login until its callback
+	oidcStateCookie = "oidc_state"
+	oidcCookiePath  = "/v1/auth/oidc"
+)
+
+// OIDCLogIn redirects to an external OpenID Connect provider to log in.
+// "?session=true" ends the login with a cookie session instead of tokens.
+func (ctr *UserController) OIDCLogIn(ctx echo.Context) error {
+	reqCtx := ctx.Request().Context()
+	if ctx.QueryParam("session") == "true" {
+		reqCtx = service.WithSessionLogin(reqCtx)
+	}
+	redirect, err := ctr.services.User.OIDCLogin(reqCtx, ctx.Param("provider"))
+	if err != nil {
+		switch errors.Cause(err) {
+		case types.ErrNotFound:
+			return echo.NewHTTPError(http.StatusNotFound, err)
+		case types.ErrBadRequest:
+			return echo.NewHTTPError(http.StatusBadRequest, err)
+		default:
+			return echo.NewHTTPError(http.StatusBadGateway, errors.Wrap(err, "could not start login"))
+		}
+	}
+
+	// the provider redirects back with a top-level GET, which Lax cookies survive
+	ctx.SetCookie(&http.Cookie{
+		Name:     oidcStateCookie,
+		Value:    redirect.StateToken,
+		Path:     oidcCookiePath,
+		MaxAge:   int(redirect.ExpiresIn),
+		Secure:   ctx.IsTLS(),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	return ctx.Redirect(http.StatusFound, redirect.URL)
+}
+
+// OIDCCallback completes a login at an external OpenID Connect provider
+func (ctr *UserController) OIDCCallback(ctx echo.Context) error {
+	var callback model.OIDCCallback
+	if err := (&echo.DefaultBinder{}).BindQueryParams(ctx, &callback); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, errors.Wrap(err, "could not decode callback"))
+	}
+	if callback.Code == "" && callback.Error == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "code is required")
+	}
+
+	var stateToken string
+	if cookie, err := ctx.Cookie(oidcStateCookie); err == nil {
+		stateToken = cookie.Value
+	}
+	// the state is used once, whatever the outcome
+	ctx.SetCookie(&http.Cookie{
+		Name:     oidcStateCookie,
+		Path:     oidcCookiePath,
+		MaxAge:   -1,
+		Expires:  time.Unix(0, 0),
+		Secure:   ctx.IsTLS(),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	result, err := ctr.services.User.CompleteOIDCLogin(ctx.Request().Context(), ctx.Param("provider"), &callback, stateToken)
+	if err != nil {
+		switch errors.Cause(err) {
+		case types.ErrNotFound:
+			return echo.NewHTTPError(http.StatusNotFound, err)
+		case types.ErrUnauthorized, types.ErrForbidden, types.ErrDuplicateEntry:
+			return err
+		default:
+			return echo.NewHTTPError(http.StatusInternalServerError, errors.Wrap(err, "could not log in"))
+		}
+	}
+
+	if result.Session != nil {
+		ctr.setSessionCookies(ctx, result.Session)
+	}
+
+	ctx.Response().Header().Set(echo.HeaderCacheControl, "no-store")
+	return ctx.JSON(http.StatusOK, result)
+}
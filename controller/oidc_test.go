@@ -0,0 +1,161 @@
+package controller
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/VikaGo/REST_API/logger"
+	"github.com/VikaGo/REST_API/model"
+	"github.com/VikaGo/REST_API/pkg/types"
+	"github.com/VikaGo/REST_API/service"
+	"github.com/VikaGo/REST_API/service/mocks"
+	"github.com/labstack/echo/v4"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOIDCLogIn(t *testing.T) {
+	l := logger.Get()
+
+	tests := []struct {
+		testName     string
+		provider     string
+		expectations func(svc *mocks.UserService)
+		code         int
+	}{
+		{
+			testName: "redirect",
+			provider: "corp",
+			expectations: func(svc *mocks.UserService) {
+				svc.On("OIDCLogin", mock.Anything, "corp").Return(&model.OIDCLoginRedirect{
+					URL:        "https://idp.example.com/authorize?state=xyz",
+					StateToken: "state-token",
+					ExpiresIn:  600,
+				}, nil)
+			},
+			code: http.StatusFound,
+		},
+		{
+			testName: "unknown provider",
+			provider: "unknown",
+			expectations: func(svc *mocks.UserService) {
+				svc.On("OIDCLogin", mock.Anything, "unknown").Return(nil, errors.Wrap(types.ErrNotFound, "OIDC provider 'unknown' not found"))
+			},
+			code: http.StatusNotFound,
+		},
+	}
+
+	for _, test := range tests {
+		t.Logf("running %v", test.testName)
+
+		e := echo.New()
+		r := httptest.NewRequest(echo.GET, "/v1/auth/oidc/"+test.provider+"/login", nil)
+		w := httptest.NewRecorder()
+		ctx := e.NewContext(r, w)
+		ctx.SetParamNames("provider")
+		ctx.SetParamValues(test.provider)
+
+		svc := &mocks.UserService{}
+		test.expectations(svc)
+
+		d := NewUsers(ctx.Request().Context(), &service.Manager{User: svc}, l)
+		err := d.OIDCLogIn(ctx)
+		if test.code < http.StatusBadRequest {
+			require.NoError(t, err)
+			assert.Equal(t, test.code, w.Code)
+			assert.Equal(t, "https://idp.example.com/authorize?state=xyz", w.Header().Get(echo.HeaderLocation))
+			cookie := w.Result().Cookies()[0]
+			assert.Equal(t, oidcStateCookie, cookie.Name)
+			assert.Equal(t, "state-token", cookie.Value)
+			assert.True(t, cookie.HttpOnly)
+			assert.Equal(t, http.SameSiteLaxMode, cookie.SameSite)
+		} else {
+			assert.Equal(t, test.code, types.HTTPCode(err))
+		}
+		svc.AssertExpectations(t)
+	}
+}
+
+func TestOIDCCallback(t *testing.T) {
+	l := logger.Get()
+	callback := &model.OIDCCallback{Code: "abc", State: "xyz"}
+
+	tests := []struct {
+		testName     string
+		query        string
+		cookie       string
+		expectations func(svc *mocks.UserService)
+		code         int
+		cause        error
+	}{
+		{
+			testName: "login",
+			query:    "?code=abc&state=xyz",
+			cookie:   "state-token",
+			expectations: func(svc *mocks.UserService) {
+				svc.On("CompleteOIDCLogin", mock.Anything, "corp", callback, "state-token").
+					Return(&model.LoginResult{TokenPair: &model.TokenPair{AccessToken: "access", TokenType: "Bearer"}}, nil)
+			},
+			code: http.StatusOK,
+		},
+		{
+			testName:     "missing code",
+			query:        "?state=xyz",
+			expectations: func(svc *mocks.UserService) {},
+			code:         http.StatusBadRequest,
+		},
+		{
+			testName: "missing state cookie",
+			query:    "?code=abc&state=xyz",
+			expectations: func(svc *mocks.UserService) {
+				svc.On("CompleteOIDCLogin", mock.Anything, "corp", callback, "").Return(nil, errors.Wrap(types.ErrUnauthorized, "invalid OIDC login state"))
+			},
+			cause: types.ErrUnauthorized,
+		},
+		{
+			testName: "identity not linked",
+			query:    "?code=abc&state=xyz",
+			cookie:   "state-token",
+			expectations: func(svc *mocks.UserService) {
+				svc.On("CompleteOIDCLogin", mock.Anything, "corp", callback, "state-token").Return(nil, errors.Wrap(types.ErrForbidden, "no user is linked to this identity"))
+			},
+			cause: types.ErrForbidden,
+		},
+	}
+
+	for _, test := range tests {
+		t.Logf("running %v", test.testName)
+
+		e := echo.New()
+		r := httptest.NewRequest(echo.GET, "/v1/auth/oidc/corp/callback"+test.query, nil)
+		if test.cookie != "" {
+			r.AddCookie(&http.Cookie{Name: oidcStateCookie, Value: test.cookie})
+		}
+		w := httptest.NewRecorder()
+		ctx := e.NewContext(r, w)
+		ctx.SetParamNames("provider")
+		ctx.SetParamValues("corp")
+
+		svc := &mocks.UserService{}
+		test.expectations(svc)
+
+		d := NewUsers(ctx.Request().Context(), &service.Manager{User: svc}, l)
+		err := d.OIDCCallback(ctx)
+		switch {
+		case test.cause != nil:
+			assert.Equal(t, test.cause, errors.Cause(err))
+		case test.code < http.StatusBadRequest:
+			require.NoError(t, err)
+			assert.Equal(t, test.code, w.Code)
+			assert.Contains(t, w.Body.String(), `"access_token":"access"`)
+			// the state cookie is cleared
+			assert.Equal(t, -1, w.Result().Cookies()[0].MaxAge)
+		default:
+			assert.Equal(t, test.code, types.HTTPCode(err))
+		}
+		svc.AssertExpectations(t)
+	}
+}
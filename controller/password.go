@@ -0,0 +1,88 @@
+package controller
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/VikaGo/REST_API/logger"
+	"github.com/VikaGo/REST_API/model"
+	"github.com/VikaGo/REST_API/pkg/types"
+	"github.com/VikaGo/REST_API/service"
+	"github.com/labstack/echo/v4"
+	"github.com/pkg/errors"
+)
+
+// PasswordController resets forgotten passwords
+type PasswordController struct {
+	ctx      context.Context
+	services *service.Manager
+	logger   *logger.Logger
+}
+
+// NewPasswords creates a new password controller.
+func NewPasswords(ctx context.Context, services *service.Manager, logger *logger.Logger) *PasswordController {
+	return &PasswordController{
+		ctx:      ctx,
+		services: services,
+		logger:   logger,
+	}
+}
+
+// Forgot emails a password reset token. The response is the same whether
+// the user exists or not, unless requests are throttled.
+func (ctr *PasswordController) Forgot(ctx echo.Context) error {
+	var input model.ForgotPasswordInput
+	err := ctx.Bind(&input)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, errors.Wrap(err, "could not decode input"))
+	}
+	err = ctx.Validate(&input)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnprocessableEntity, err)
+	}
+
+	err = ctr.services.Password.ForgotPassword(ctx.Request().Context(), input.Login)
+	if err != nil {
+		switch errors.Cause(err) {
+		case types.ErrTooManyRequests:
+			// keeps the Retry-After of the error
+			return err
+		default:
+			return echo.NewHTTPError(http.StatusInternalServerError, errors.Wrap(err, "could not request password reset"))
+		}
+	}
+	return ctx.NoContent(http.StatusAccepted)
+}
+
+// Reset sets a new password with a reset token
+func (ctr *PasswordController) Reset(ctx echo.Context) error {
+	var input model.ResetPasswordInput
+	err := ctx.Bind(&input)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, errors.Wrap(err, "could not decode input"))
+	}
+	err = ctx.Validate(&input)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnprocessableEntity, err)
+	}
+
+	err = ctr.services.Password.ResetPassword(ctx.Request().Context(), input.Token, string(input.Password))
+	if err != nil {
+		switch errors.Cause(err) {
+		case types.ErrUnprocessableEntity:
+			// names the token field or lists the password policy violations
+			return err
+		case types.ErrConflict:
+			// the user changed meanwhile, the token was not used up
+			return echo.NewHTTPError(http.StatusConflict, err)
+		case types.ErrNotFound:
+			return echo.NewHTTPError(http.StatusNotFound, err)
+		default:
+			return echo.NewHTTPError(http.StatusInternalServerError, errors.Wrap(err, "could not reset password"))
+		}
+	}
+
+	ctr.logger.Debug().Msg("Reset a forgotten password")
+
+	return ctx.NoContent(http.StatusNoContent)
+}
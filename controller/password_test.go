@@ -0,0 +1,137 @@
+package controller
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/VikaGo/REST_API/logger"
+	"github.com/VikaGo/REST_API/pkg/types"
+	"github.com/VikaGo/REST_API/pkg/validator"
+	"github.com/VikaGo/REST_API/service"
+	"github.com/VikaGo/REST_API/service/mocks"
+	"github.com/labstack/echo/v4"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestPasswordReset(t *testing.T) {
+	l := logger.Get()
+
+	tests := []struct {
+		testName     string
+		path         string
+		body         string
+		expectations func(svc *mocks.PasswordService)
+		code         int
+		cause        error
+	}{
+		{
+			testName: "forgot",
+			path:     "/v1/password/forgot",
+			body:     `{"login":"topol@example.com"}`,
+			expectations: func(svc *mocks.PasswordService) {
+				svc.On("ForgotPassword", mock.Anything, "topol@example.com").Return(nil)
+			},
+			code: http.StatusAccepted,
+		},
+		{
+			testName:     "forgot without login",
+			path:         "/v1/password/forgot",
+			body:         `{}`,
+			expectations: func(svc *mocks.PasswordService) {},
+			code:         http.StatusUnprocessableEntity,
+		},
+		{
+			testName: "forgot too often",
+			path:     "/v1/password/forgot",
+			body:     `{"login":"topol@example.com"}`,
+			expectations: func(svc *mocks.PasswordService) {
+				svc.On("ForgotPassword", mock.Anything, "topol@example.com").Return(&types.RetryError{
+					Err:        errors.Wrap(types.ErrTooManyRequests, "too many password reset requests"),
+					RetryAfter: time.Second,
+				})
+			},
+			cause: types.ErrTooManyRequests,
+		},
+		{
+			testName: "reset",
+			path:     "/v1/password/reset",
+			body:     `{"token":"abc","password":"n3w-Passw0rd"}`,
+			expectations: func(svc *mocks.PasswordService) {
+				svc.On("ResetPassword", mock.Anything, "abc", "n3w-Passw0rd").Return(nil)
+			},
+			code: http.StatusNoContent,
+		},
+		{
+			testName: "weak password",
+			path:     "/v1/password/reset",
+			body:     `{"token":"abc","password":"short"}`,
+			expectations: func(svc *mocks.PasswordService) {
+				svc.On("ResetPassword", mock.Anything, "abc", "short").Return(&types.ValidationError{
+					Err: errors.Wrap(types.ErrUnprocessableEntity, "password does not follow the policy"),
+					Violations: []types.Violation{
+						{Field: "password", Rule: "min_length", Message: "Password must be at least 8 characters long"},
+					},
+				})
+			},
+			cause: types.ErrUnprocessableEntity,
+		},
+		{
+			testName: "invalid token",
+			path:     "/v1/password/reset",
+			body:     `{"token":"abc","password":"n3w-Passw0rd"}`,
+			expectations: func(svc *mocks.PasswordService) {
+				svc.On("ResetPassword", mock.Anything, "abc", mock.Anything).Return(&types.FieldError{
+					Err:   errors.Wrap(types.ErrUnprocessableEntity, "invalid, expired or used reset token"),
+					Field: "token",
+				})
+			},
+			cause: types.ErrUnprocessableEntity,
+		},
+		{
+			testName: "user changed meanwhile",
+			path:     "/v1/password/reset",
+			body:     `{"token":"abc","password":"n3w-Passw0rd"}`,
+			expectations: func(svc *mocks.PasswordService) {
+				svc.On("ResetPassword", mock.Anything, "abc", mock.Anything).Return(errors.Wrap(types.ErrConflict, "svc.user.UpdateUser error"))
+			},
+			code: http.StatusConflict,
+		},
+	}
+
+	for _, test := range tests {
+		t.Logf("running %v", test.testName)
+
+		e := echo.New()
+		e.Validator = validator.NewValidator()
+		r := httptest.NewRequest(echo.POST, test.path, strings.NewReader(test.body))
+		r.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		w := httptest.NewRecorder()
+		ctx := e.NewContext(r, w)
+
+		svc := &mocks.PasswordService{}
+		test.expectations(svc)
+
+		d := NewPasswords(ctx.Request().Context(), &service.Manager{Password: svc}, l)
+		var err error
+		if strings.HasSuffix(test.path, "/forgot") {
+			err = d.Forgot(ctx)
+		} else {
+			err = d.Reset(ctx)
+		}
+		switch {
+		case test.cause != nil:
+			assert.Equal(t, test.cause, errors.Cause(err))
+		case test.code < http.StatusBadRequest:
+			assert.NoError(t, err)
+			assert.Equal(t, test.code, w.Code)
+		default:
+			assert.Equal(t, test.code, types.HTTPCode(err))
+		}
+		svc.AssertExpectations(t)
+	}
+}
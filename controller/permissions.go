@@ -0,0 +1,165 @@
+package controller
+
+import (
+	"fmt"
+
+	"github.com/VikaGo/REST_API/model"
+	"github.com/VikaGo/REST_API/pkg/types"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/pkg/errors"
+)
+
+// Permission is an action a caller may perform on a resource
+type Permission string
+
+// Permissions on user resources
+const (
+	PermListUsers  Permission = "users:list"
+	PermReadUser   Permission = "users:read"
+	PermUpdateUser Permission = "users:update"
+	PermDeleteUser Permission = "users:delete"
+	PermChangeRole Permission = "users:change_role"
+	// PermManageDeleted allows reading and restoring soft-deleted users
+	PermManageDeleted Permission = "users:manage_deleted"
+	// PermChangePassword allows changing the password of a user
+	PermChangePassword Permission = "users:change_password"
+	// PermUnlockUser allows lifting the login lockout of a user
+	PermUnlockUser Permission = "users:unlock"
+	// PermManageTwoFactor allows enrolling two-factor authentication
+	PermManageTwoFactor Permission = "users:manage_2fa"
+	// PermManageAPIKeys allows listing and revoking API keys
+	PermManageAPIKeys Permission = "users:manage_api_keys"
+	// PermCreateAPIKey allows creating API keys. A key acts as its owner,
+	// so nobody may create one for another user.
+	PermCreateAPIKey Permission = "users:create_api_key"
+	// PermManageSessions allows listing and revoking cookie sessions
+	PermManageSessions Permission = "users:manage_sessions"
+)
+
+// Permissions on the audit log
+const (
+	PermReadAudit Permission = "audit:read"
+)
+
+// Permissions on roles
+const (
+	PermManageRoles Permission = "roles:manage"
+)
+
+// Permissions on OAuth clients
+const (
+	PermManageOAuthClients Permission = "oauth:manage_clients"
+)
+
+// Scope tells which resources a granted permission applies to
+type Scope int
+
+const (
+	// ScopeOwn grants the permission on the caller's own user only
+	ScopeOwn Scope = iota + 1
+	// ScopeAny grants the permission on every user
+	ScopeAny
+)
+
+// rolePermissions is the access policy: what every role may do and on whom
+var rolePermissions = map[string]map[Permission]Scope{
+	model.RoleAdmin: {
+		PermListUsers:      ScopeAny,
+		PermReadUser:       ScopeAny,
+		PermUpdateUser:     ScopeAny,
+		PermDeleteUser:     ScopeAny,
+		PermChangeRole:     ScopeAny,
+		PermManageDeleted:  ScopeAny,
+		PermChangePassword: ScopeAny,
+		PermUnlockUser:     ScopeAny,
+		// the shared secret must only reach its owner, even for admins
+		PermManageTwoFactor: ScopeOwn,
+		PermManageAPIKeys:   ScopeAny,
+		PermCreateAPIKey:    ScopeOwn,
+		PermManageSessions:  ScopeAny,
+		PermReadAudit:       ScopeAny,
+		PermManageRoles:     ScopeAny,
+		// clients have no owner, ScopeAny is the only meaningful scope
+		PermManageOAuthClients: ScopeAny,
+	},
+	model.RoleEditor: {
+		PermListUsers:       ScopeAny,
+		PermReadUser:        ScopeAny,
+		PermUpdateUser:      ScopeOwn,
+		PermChangePassword:  ScopeOwn,
+		PermManageTwoFactor: ScopeOwn,
+		PermManageAPIKeys:   ScopeOwn,
+		PermCreateAPIKey:    ScopeOwn,
+		PermManageSessions:  ScopeOwn,
+	},
+	model.RoleViewer: {
+		PermReadUser:        ScopeOwn,
+		PermUpdateUser:      ScopeOwn,
+		PermChangePassword:  ScopeOwn,
+		PermManageTwoFactor: ScopeOwn,
+		PermManageAPIKeys:   ScopeOwn,
+		PermCreateAPIKey:    ScopeOwn,
+		PermManageSessions:  ScopeOwn,
+	},
+}
+
+// isPermission reports whether perm is granted to any role
+func isPermission(perm Permission) bool {
+	for _, permissions := range rolePermissions {
+		if _, ok := permissions[perm]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// hasScope reports whether the API key of principal, if any, allows perm
+func hasScope(principal *model.Principal, perm Permission) bool {
+	if principal.Scopes == nil {
+		return true
+	}
+	for _, scope := range principal.Scopes {
+		if Permission(scope) == perm {
+			return true
+		}
+	}
+	return false
+}
+
+// can reports whether principal holds perm on the user identified by target
+func can(principal *model.Principal, perm Permission, target uuid.UUID) bool {
+	if principal == nil || !hasScope(principal, perm) {
+		return false
+	}
+	switch rolePermissions[principal.Role][perm] {
+	case ScopeAny:
+		return true
+	case ScopeOwn:
+		return principal.UserID == target
+	default:
+		return false
+	}
+}
+
+// Authorize requires the authenticated caller to hold perm on the user
+// addressed by the ":id" route parameter. It must run after AuthMiddleware.
+func Authorize(perm Permission) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			principal := principalFromContext(ctx)
+			if principal == nil {
+				return errors.Wrap(types.ErrUnauthorized, "no authenticated user")
+			}
+
+			// An unparsable ID can only be checked against ScopeAny;
+			// the handler reports the bad ID once access is granted.
+			target, _ := uuid.Parse(ctx.Param("id"))
+			if !can(principal, perm, target) {
+				return errors.Wrap(types.ErrForbidden, fmt.Sprintf("role '%s' is not allowed to %s", principal.Role, perm))
+			}
+
+			return next(ctx)
+		}
+	}
+}
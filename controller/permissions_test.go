@@ -0,0 +1,72 @@
+package controller
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/VikaGo/REST_API/model"
+	"github.com/VikaGo/REST_API/pkg/types"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuthorize(t *testing.T) {
+	self := uuid.MustParse("7a2f922c-073a-11eb-adc1-0242ac120002")
+	other := uuid.MustParse("9b1deb4d-3b7d-4bad-9bdd-2b0d7b3dcb6d")
+
+	tests := []struct {
+		testName string
+		role     string
+		scopes   []string
+		perm     Permission
+		target   uuid.UUID
+		err      error
+	}{
+		{testName: "viewer reads self", role: model.RoleViewer, perm: PermReadUser, target: self},
+		{testName: "viewer reads other", role: model.RoleViewer, perm: PermReadUser, target: other, err: types.ErrForbidden},
+		{testName: "viewer updates self", role: model.RoleViewer, perm: PermUpdateUser, target: self},
+		{testName: "viewer deletes self", role: model.RoleViewer, perm: PermDeleteUser, target: self, err: types.ErrForbidden},
+		{testName: "editor reads other", role: model.RoleEditor, perm: PermReadUser, target: other},
+		{testName: "editor updates other", role: model.RoleEditor, perm: PermUpdateUser, target: other, err: types.ErrForbidden},
+		{testName: "admin deletes other", role: model.RoleAdmin, perm: PermDeleteUser, target: other},
+		{testName: "admin updates other", role: model.RoleAdmin, perm: PermUpdateUser, target: other},
+		{testName: "unknown role", role: "root", perm: PermReadUser, target: self, err: types.ErrForbidden},
+		{testName: "unauthenticated", perm: PermReadUser, target: self, err: types.ErrUnauthorized},
+		{testName: "scoped key within scopes", role: model.RoleAdmin, scopes: []string{"users:read"}, perm: PermReadUser, target: other},
+		{testName: "scoped key outside scopes", role: model.RoleAdmin, scopes: []string{"users:read"}, perm: PermDeleteUser, target: other, err: types.ErrForbidden},
+		{testName: "admin creates own API key", role: model.RoleAdmin, perm: PermCreateAPIKey, target: self},
+		{testName: "admin creates API key of other", role: model.RoleAdmin, perm: PermCreateAPIKey, target: other, err: types.ErrForbidden},
+		{testName: "admin revokes API key of other", role: model.RoleAdmin, perm: PermManageAPIKeys, target: other},
+		{testName: "scope beyond role", role: model.RoleViewer, scopes: []string{"users:delete"}, perm: PermDeleteUser, target: other, err: types.ErrForbidden},
+	}
+
+	for _, test := range tests {
+		t.Logf("running %v", test.testName)
+
+		e := echo.New()
+		r := httptest.NewRequest(echo.GET, "/", nil)
+		w := httptest.NewRecorder()
+		ctx := e.NewContext(r, w)
+		ctx.SetParamNames("id")
+		ctx.SetParamValues(test.target.String())
+		if test.role != "" {
+			ctx.Set(ContextUserIDKey, self)
+			ctx.Set(ContextRoleKey, test.role)
+		}
+		if test.scopes != nil {
+			ctx.Set(ContextScopesKey, test.scopes)
+		}
+
+		called := false
+		err := Authorize(test.perm)(func(ctx echo.Context) error {
+			called = true
+			return ctx.NoContent(http.StatusOK)
+		})(ctx)
+
+		assert.Equal(t, test.err == nil, called)
+		assert.Equal(t, test.err, errors.Cause(err))
+	}
+}
@@ -0,0 +1,67 @@
+package controller
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/VikaGo/REST_API/logger"
+	"github.com/VikaGo/REST_API/model"
+	"github.com/VikaGo/REST_API/pkg/types"
+	"github.com/VikaGo/REST_API/service"
+	"github.com/labstack/echo/v4"
+	"github.com/pkg/errors"
+)
+
+// RoleController manages the policies of roles
+type RoleController struct {
+	ctx      context.Context
+	services *service.Manager
+	logger   *logger.Logger
+}
+
+// NewRoles creates a new role controller.
+func NewRoles(ctx context.Context, services *service.Manager, logger *logger.Logger) *RoleController {
+	return &RoleController{
+		ctx:      ctx,
+		services: services,
+		logger:   logger,
+	}
+}
+
+// GetSettings returns the settings of a role
+func (ctr *RoleController) GetSettings(ctx echo.Context) error {
+	settings, err := ctr.services.Role.GetRoleSettings(ctx.Request().Context(), ctx.Param("role"))
+	if err != nil {
+		switch {
+		case errors.Cause(err) == types.ErrNotFound:
+			return echo.NewHTTPError(http.StatusNotFound, err)
+		default:
+			return echo.NewHTTPError(http.StatusInternalServerError, errors.Wrap(err, "could not get role settings"))
+		}
+	}
+	return ctx.JSON(http.StatusOK, settings)
+}
+
+// UpdateSettings replaces the settings of a role
+func (ctr *RoleController) UpdateSettings(ctx echo.Context) error {
+	var settings model.RoleSettings
+	err := ctx.Bind(&settings)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, errors.Wrap(err, "could not decode role settings"))
+	}
+	settings.Role = ctx.Param("role")
+
+	updated, err := ctr.services.Role.UpdateRoleSettings(ctx.Request().Context(), &settings)
+	if err != nil {
+		switch {
+		case errors.Cause(err) == types.ErrNotFound:
+			return echo.NewHTTPError(http.StatusNotFound, err)
+		default:
+			return echo.NewHTTPError(http.StatusInternalServerError, errors.Wrap(err, "could not update role settings"))
+		}
+	}
+
+	ctr.logger.Debug().Msgf("Updated settings of role '%s'", settings.Role)
+
+	return ctx.JSON(http.StatusOK, updated)
+}
@@ -0,0 +1,93 @@
+package controller
+
+import (
+	"github.com/VikaGo/REST_API/service"
+	"github.com/labstack/echo/v4"
+)
+
+// Controllers are the controllers serving the routes
+type Controllers struct {
+	Users     *UserController
+	Keys      *KeyController
+	Audit     *AuditController
+	Roles     *RoleController
+	Passwords *PasswordController
+	OAuth     *OAuthController
+	Health    *HealthController
+}
+
+// RegisterRoutes registers every route of the API on e
+func RegisterRoutes(e *echo.Echo, services *service.Manager, ctrs Controllers) {
+	// Readiness check
+	e.GET("/ready", ctrs.Health.Ready)
+
+	// Token verification keys
+	e.GET("/.well-known/jwks.json", ctrs.Keys.JWKS)
+
+	// API V1
+	v1 := e.Group("/v1")
+
+	// Auth middleware
+	auth := AuthMiddleware(services)
+
+	// User routes
+	userRoutes := v1.Group("/users")
+	userRoutes.POST("", ctrs.Users.Create)
+	userRoutes.POST("/login", ctrs.Users.LogIn)
+	userRoutes.POST("/login/2fa", ctrs.Users.CompleteLogIn)
+	userRoutes.POST("/login/2fa/enroll", ctrs.Users.EnrollTwoFactorOnLogIn)
+	userRoutes.POST("/refresh", ctrs.Users.Refresh)
+	userRoutes.POST("/logout", ctrs.Users.LogOut)
+	userRoutes.POST("/logout/all", ctrs.Users.LogOutAll, auth)
+	userRoutes.GET("", ctrs.Users.List, auth, Authorize(PermListUsers))
+	userRoutes.GET("/:id", ctrs.Users.Get, auth, Authorize(PermReadUser))
+	userRoutes.DELETE("/:id", ctrs.Users.Delete, auth, Authorize(PermDeleteUser))
+	userRoutes.PUT("/:id", ctrs.Users.Update, auth, Authorize(PermUpdateUser))
+	userRoutes.PATCH("/:id", ctrs.Users.Patch, auth, Authorize(PermUpdateUser))
+	userRoutes.POST("/:id/restore", ctrs.Users.Restore, auth, Authorize(PermManageDeleted))
+	userRoutes.POST("/:id/password", ctrs.Users.ChangePassword, auth, Authorize(PermChangePassword))
+	userRoutes.POST("/:id/unlock", ctrs.Users.Unlock, auth, Authorize(PermUnlockUser))
+	userRoutes.POST("/:id/2fa/enroll", ctrs.Users.EnrollTwoFactor, auth, Authorize(PermManageTwoFactor))
+	userRoutes.POST("/:id/2fa/confirm", ctrs.Users.ConfirmTwoFactor, auth, Authorize(PermManageTwoFactor))
+	userRoutes.GET("/:id/api-keys", ctrs.Users.ListAPIKeys, auth, Authorize(PermManageAPIKeys))
+	userRoutes.POST("/:id/api-keys", ctrs.Users.CreateAPIKey, auth, Authorize(PermCreateAPIKey))
+	userRoutes.DELETE("/:id/api-keys/:key_id", ctrs.Users.RevokeAPIKey, auth, Authorize(PermManageAPIKeys))
+	userRoutes.GET("/:id/sessions", ctrs.Users.ListSessions, auth, Authorize(PermManageSessions))
+	userRoutes.DELETE("/:id/sessions/:session_id", ctrs.Users.RevokeSession, auth, Authorize(PermManageSessions))
+
+	// External OpenID Connect login routes
+	oidcRoutes := v1.Group("/auth/oidc")
+	oidcRoutes.GET("/:provider/login", ctrs.Users.OIDCLogIn)
+	oidcRoutes.GET("/:provider/callback", ctrs.Users.OIDCCallback)
+
+	// Password reset routes
+	passwordRoutes := v1.Group("/password")
+	passwordRoutes.POST("/forgot", ctrs.Passwords.Forgot)
+	passwordRoutes.POST("/reset", ctrs.Passwords.Reset)
+
+	// Role routes
+	roleRoutes := v1.Group("/roles", auth, Authorize(PermManageRoles))
+	roleRoutes.GET("/:role/settings", ctrs.Roles.GetSettings)
+	roleRoutes.PUT("/:role/settings", ctrs.Roles.UpdateSettings)
+
+	// OAuth 2.0 / OpenID Connect provider routes, only served if the
+	// provider is configured
+	if services.OAuth != nil {
+		e.GET("/.well-known/openid-configuration", ctrs.OAuth.Discovery)
+
+		oauthRoutes := e.Group("/oauth")
+		oauthRoutes.GET("/authorize", ctrs.OAuth.Authorize, BrowserAuthMiddleware(services))
+		oauthRoutes.POST("/token", ctrs.OAuth.Token)
+		oauthRoutes.GET("/userinfo", ctrs.OAuth.UserInfo)
+		oauthRoutes.POST("/userinfo", ctrs.OAuth.UserInfo)
+
+		// OAuth client registration routes
+		oauthClientRoutes := v1.Group("/oauth/clients", auth, Authorize(PermManageOAuthClients))
+		oauthClientRoutes.GET("", ctrs.OAuth.ListClients)
+		oauthClientRoutes.POST("", ctrs.OAuth.RegisterClient)
+		oauthClientRoutes.DELETE("/:client_id", ctrs.OAuth.DeleteClient)
+	}
+
+	// Audit log routes
+	v1.GET("/audit", ctrs.Audit.List, auth, Authorize(PermReadAudit))
+}
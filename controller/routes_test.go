@@ -0,0 +1,245 @@
+package controller
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/VikaGo/REST_API/logger"
+	"github.com/VikaGo/REST_API/model"
+	Error "github.com/VikaGo/REST_API/pkg/error"
+	"github.com/VikaGo/REST_API/pkg/patch"
+	"github.com/VikaGo/REST_API/pkg/validator"
+	"github.com/VikaGo/REST_API/service"
+	"github.com/VikaGo/REST_API/service/mocks"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// hashPattern matches bcrypt and Argon2 password hashes
+var hashPattern = regexp.MustCompile(`\$(2[abxy]?|argon2(id|i|d))\$`)
+
+type testKeys struct{}
+
+func (testKeys) JWKS() *model.JWKSet {
+	return &model.JWKSet{Keys: []model.JWK{}}
+}
+
+// testHealth reports a service with the given health status
+type testHealth string
+
+func (status testHealth) Health(context.Context) *model.Health {
+	return &model.Health{Status: string(status)}
+}
+
+// TestNoSecretsInResponses calls every route with services returning a
+// user that has a password hash, and checks no response carries the hash
+// or a password of the request
+func TestNoSecretsInResponses(t *testing.T) {
+	const password = "s3cret-pass"
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	require.NoError(t, err)
+	userDB := &model.DBUser{
+		ID:        uuid.MustParse("7a2f922c-073a-11eb-adc1-0242ac120002"),
+		Role:      model.RoleViewer,
+		Firstname: "Olexandr",
+		Lastname:  "Topol",
+		Nickname:  "topol",
+		Password:  string(hash),
+		Version:   1,
+	}
+	user := userDB.ToWeb()
+	id := userDB.ID.String()
+	otherID := uuid.MustParse("9b1deb4d-3b7d-4bad-9bdd-2b0d7b3dcb6d").String()
+	admin := &model.Principal{UserID: uuid.MustParse("1b9d6bcd-bbfd-4b2d-9b5d-ab8dfbbd4bed"), Role: model.RoleAdmin}
+	// users only manage their own two-factor authentication
+	self := admin.UserID.String()
+	result := &model.LoginResult{TokenPair: &model.TokenPair{AccessToken: "access", RefreshToken: "refresh"}}
+
+	users := &mocks.UserService{}
+	users.On("ParseToken", mock.Anything, mock.Anything).Return(admin, nil)
+	users.On("ParseSession", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(admin, nil)
+	users.On("CreateUser", mock.Anything, mock.Anything).Return(user, nil)
+	users.On("GetUser", mock.Anything, mock.Anything).Return(user, nil)
+	users.On("GetUserIncludingDeleted", mock.Anything, mock.Anything).Return(user, nil)
+	users.On("UpdateUser", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(user, nil)
+	users.On("PatchUser", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(user, nil)
+	users.On("RestoreUser", mock.Anything, mock.Anything).Return(user, nil)
+	users.On("ListUsers", mock.Anything, mock.Anything).Return(&model.UserPage{Items: []*model.User{user}}, nil)
+	users.On("DeleteUser", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	users.On("UnlockUser", mock.Anything, mock.Anything).Return(nil)
+	users.On("ChangePassword", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	users.On("GenerateToken", mock.Anything, mock.Anything, mock.Anything).Return(result, nil)
+	users.On("CompleteLogin", mock.Anything, mock.Anything, mock.Anything).Return(result, nil)
+	users.On("OIDCLogin", mock.Anything, mock.Anything).Return(&model.OIDCLoginRedirect{URL: "https://idp.example.com/authorize", StateToken: "state", ExpiresIn: 600}, nil)
+	users.On("CompleteOIDCLogin", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(result, nil)
+	users.On("EnrollTwoFactor", mock.Anything, mock.Anything).Return(&model.TOTPEnrollment{}, nil)
+	users.On("EnrollTwoFactorWithChallenge", mock.Anything, mock.Anything).Return(&model.TOTPEnrollment{}, nil)
+	users.On("ConfirmTwoFactor", mock.Anything, mock.Anything, mock.Anything).Return(&model.RecoveryCodes{}, nil)
+	users.On("RefreshToken", mock.Anything, mock.Anything).Return(result.TokenPair, nil)
+	users.On("Logout", mock.Anything, mock.Anything).Return(nil)
+	users.On("LogoutAll", mock.Anything, mock.Anything).Return(nil)
+	users.On("ListSessions", mock.Anything, mock.Anything).Return([]*model.Session{}, nil)
+	users.On("RevokeSession", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+	apiKeys := &mocks.APIKeyService{}
+	apiKeys.On("ListAPIKeys", mock.Anything, mock.Anything).Return([]*model.APIKey{}, nil)
+	apiKeys.On("CreateAPIKey", mock.Anything, mock.Anything, mock.Anything).Return(&model.CreatedAPIKey{APIKey: &model.APIKey{}}, nil)
+	apiKeys.On("RevokeAPIKey", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+	passwords := &mocks.PasswordService{}
+	passwords.On("ForgotPassword", mock.Anything, mock.Anything).Return(nil)
+	passwords.On("ResetPassword", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+	roles := &mocks.RoleService{}
+	roles.On("GetRoleSettings", mock.Anything, mock.Anything).Return(&model.RoleSettings{}, nil)
+	roles.On("UpdateRoleSettings", mock.Anything, mock.Anything).Return(&model.RoleSettings{}, nil)
+
+	oauth := &mocks.OAuthService{}
+	oauth.On("Discovery").Return(&model.OIDCDiscovery{})
+	oauth.On("Authorize", mock.Anything, mock.Anything, mock.Anything).Return("https://wiki.example.com/callback?code=c0de", nil)
+	oauth.On("Token", mock.Anything, mock.Anything).Return(&model.OAuthTokenResponse{}, nil)
+	oauth.On("UserInfo", mock.Anything, mock.Anything).Return(&model.UserInfo{}, nil)
+	oauth.On("RegisterClient", mock.Anything, mock.Anything).Return(&model.RegisteredOAuthClient{OAuthClient: &model.OAuthClient{}}, nil)
+	oauth.On("ListClients", mock.Anything).Return([]*model.OAuthClient{}, nil)
+	oauth.On("DeleteClient", mock.Anything, mock.Anything).Return(nil)
+
+	audit := &mocks.AuditService{}
+	audit.On("ListAuditEvents", mock.Anything, mock.Anything).Return(&model.AuditPage{}, nil)
+
+	services := &service.Manager{User: users, Keys: testKeys{}, Audit: audit, Role: roles, Password: passwords, APIKey: apiKeys, OAuth: oauth, Health: testHealth(model.HealthOK)}
+	l := logger.Get()
+	ctx := context.Background()
+	e := echo.New()
+	e.Validator = validator.NewValidator()
+	e.HTTPErrorHandler = Error.Error
+	RegisterRoutes(e, services, Controllers{
+		Users:     NewUsers(ctx, services, l),
+		Keys:      NewKeys(ctx, services, l),
+		Audit:     NewAudit(ctx, services, l),
+		Roles:     NewRoles(ctx, services, l),
+		Passwords: NewPasswords(ctx, services, l),
+		OAuth:     NewOAuth(ctx, services, l),
+		Health:    NewHealth(ctx, services, l),
+	})
+
+	// tests are keyed by the method and the path of their route
+	tests := []struct {
+		route       string
+		target      string
+		contentType string
+		body        string
+	}{
+		{route: "GET /ready", target: "/ready"},
+		{route: "GET /.well-known/jwks.json", target: "/.well-known/jwks.json"},
+		{route: "GET /.well-known/openid-configuration", target: "/.well-known/openid-configuration"},
+		{route: "POST /v1/users", target: "/v1/users", body: `{"firstname":"Olexandr","lastname":"Topol","nickname":"topol","password":"` + password + `"}`},
+		{route: "POST /v1/users/login", target: "/v1/users/login", body: `{"nickname":"topol","password":"` + password + `"}`},
+		{route: "POST /v1/users/login/2fa", target: "/v1/users/login/2fa", body: `{"challenge_token":"challenge","code":"123456"}`},
+		{route: "POST /v1/users/login/2fa/enroll", target: "/v1/users/login/2fa/enroll", body: `{"challenge_token":"challenge"}`},
+		{route: "POST /v1/users/refresh", target: "/v1/users/refresh", body: `{"refresh_token":"refresh"}`},
+		{route: "POST /v1/users/logout", target: "/v1/users/logout", body: `{"refresh_token":"refresh"}`},
+		{route: "POST /v1/users/logout/all", target: "/v1/users/logout/all"},
+		{route: "GET /v1/users", target: "/v1/users?include_deleted=true"},
+		{route: "GET /v1/users/:id", target: "/v1/users/" + id + "?include_deleted=true"},
+		{route: "DELETE /v1/users/:id", target: "/v1/users/" + id},
+		{route: "PUT /v1/users/:id", target: "/v1/users/" + id, body: `{"role":"viewer","firstname":"Oleksandr","lastname":"Topol","nickname":"topol"}`},
+		{route: "PATCH /v1/users/:id", target: "/v1/users/" + id, contentType: patch.MIMEMergePatch, body: `{"firstname":"Oleksandr"}`},
+		{route: "POST /v1/users/:id/restore", target: "/v1/users/" + id + "/restore"},
+		{route: "POST /v1/users/:id/password", target: "/v1/users/" + id + "/password", body: `{"new_password":"n3w-` + password + `"}`},
+		{route: "POST /v1/users/:id/unlock", target: "/v1/users/" + id + "/unlock"},
+		{route: "POST /v1/users/:id/2fa/enroll", target: "/v1/users/" + self + "/2fa/enroll"},
+		{route: "POST /v1/users/:id/2fa/confirm", target: "/v1/users/" + self + "/2fa/confirm", body: `{"code":"123456"}`},
+		{route: "GET /v1/users/:id/api-keys", target: "/v1/users/" + id + "/api-keys"},
+		{route: "POST /v1/users/:id/api-keys", target: "/v1/users/" + self + "/api-keys", body: `{"name":"ci"}`},
+		{route: "DELETE /v1/users/:id/api-keys/:key_id", target: "/v1/users/" + id + "/api-keys/" + otherID},
+		{route: "GET /v1/users/:id/sessions", target: "/v1/users/" + id + "/sessions"},
+		{route: "DELETE /v1/users/:id/sessions/:session_id", target: "/v1/users/" + id + "/sessions/" + otherID},
+		{route: "GET /v1/auth/oidc/:provider/login", target: "/v1/auth/oidc/corp/login"},
+		{route: "GET /v1/auth/oidc/:provider/callback", target: "/v1/auth/oidc/corp/callback?code=c0de&state=state"},
+		{route: "POST /v1/password/forgot", target: "/v1/password/forgot", body: `{"login":"topol"}`},
+		{route: "POST /v1/password/reset", target: "/v1/password/reset", body: `{"token":"reset","password":"n3w-` + password + `"}`},
+		{route: "GET /v1/roles/:role/settings", target: "/v1/roles/editor/settings"},
+		{route: "PUT /v1/roles/:role/settings", target: "/v1/roles/editor/settings", body: `{"require_2fa":true}`},
+		{route: "GET /oauth/authorize", target: "/oauth/authorize?response_type=code&client_id=wiki"},
+		{route: "POST /oauth/token", target: "/oauth/token", contentType: echo.MIMEApplicationForm, body: "grant_type=authorization_code&code=c0de"},
+		{route: "GET /oauth/userinfo", target: "/oauth/userinfo"},
+		{route: "POST /oauth/userinfo", target: "/oauth/userinfo"},
+		{route: "GET /v1/oauth/clients", target: "/v1/oauth/clients"},
+		{route: "POST /v1/oauth/clients", target: "/v1/oauth/clients", body: `{"name":"wiki","redirect_uris":["https://wiki.example.com/callback"]}`},
+		{route: "DELETE /v1/oauth/clients/:client_id", target: "/v1/oauth/clients/wiki"},
+		{route: "GET /v1/audit", target: "/v1/audit"},
+	}
+
+	tested := map[string]bool{}
+	for _, test := range tests {
+		t.Logf("running %v", test.route)
+		tested[test.route] = true
+
+		method, _, _ := strings.Cut(test.route, " ")
+		r := httptest.NewRequest(method, test.target, strings.NewReader(test.body))
+		if test.body != "" {
+			contentType := test.contentType
+			if contentType == "" {
+				contentType = echo.MIMEApplicationJSON
+			}
+			r.Header.Set(echo.HeaderContentType, contentType)
+		}
+		r.Header.Set(echo.HeaderAuthorization, "Bearer admin-token")
+		// the routes browsers are redirected to only take the session cookie
+		r.AddCookie(&http.Cookie{Name: sessionCookie, Value: "admin-session"})
+		w := httptest.NewRecorder()
+		e.ServeHTTP(w, r)
+
+		assert.Less(t, w.Code, http.StatusBadRequest, w.Body.String())
+		assert.False(t, hashPattern.MatchString(w.Body.String()), "password hash in %s", w.Body.String())
+		assert.NotContains(t, w.Body.String(), password)
+	}
+
+	for _, route := range e.Routes() {
+		if route.Method == echo.RouteNotFound {
+			continue
+		}
+		assert.True(t, tested[route.Method+" "+route.Path], "route %s %s is not tested", route.Method, route.Path)
+	}
+}
+
+// TestOAuthProviderDisabled checks the OAuth provider routes are not served
+// without the provider
+func TestOAuthProviderDisabled(t *testing.T) {
+	services := &service.Manager{User: &mocks.UserService{}, Keys: testKeys{}, Health: testHealth(model.HealthOK)}
+	l := logger.Get()
+	ctx := context.Background()
+	e := echo.New()
+	RegisterRoutes(e, services, Controllers{
+		Users:  NewUsers(ctx, services, l),
+		Keys:   NewKeys(ctx, services, l),
+		OAuth:  NewOAuth(ctx, services, l),
+		Health: NewHealth(ctx, services, l),
+	})
+
+	for _, route := range e.Routes() {
+		assert.NotContains(t, route.Path, "oauth", "route %s %s is served", route.Method, route.Path)
+		assert.NotEqual(t, "/.well-known/openid-configuration", route.Path)
+	}
+}
+
+// TestSecret checks request secrets are never serialized
+func TestSecret(t *testing.T) {
+	change := &model.PasswordChange{CurrentPassword: "0ld-passw0rd", NewPassword: "n3w-passw0rd"}
+
+	e := echo.New()
+	w := httptest.NewRecorder()
+	ctx := e.NewContext(httptest.NewRequest(echo.GET, "/", nil), w)
+	require.NoError(t, ctx.JSON(http.StatusOK, change))
+	assert.NotContains(t, w.Body.String(), "passw0rd")
+	assert.NotContains(t, change.NewPassword.String(), "passw0rd")
+	assert.Equal(t, "n3w-passw0rd", string(change.NewPassword))
+}
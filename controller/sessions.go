@@ -0,0 +1,176 @@
+package controller
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"time"
+
+	"github.com/VikaGo/REST_API/model"
+	"github.com/VikaGo/REST_API/pkg/types"
+	"github.com/VikaGo/REST_API/service"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/pkg/errors"
+)
+
+const (
+	// sessionCookie holds the session token, out of reach of scripts
+	sessionCookie = "session"
+	// csrfCookie holds the CSRF token for scripts to send back in HeaderCSRFToken
+	csrfCookie = "csrf_token"
+)
+
+// HeaderCSRFToken carries the CSRF token of cookie sessions on unsafe requests
+const HeaderCSRFToken = "X-CSRF-Token"
+
+// SessionCookieOptions are the attributes of the session cookies
+type SessionCookieOptions struct {
+	Secure   bool
+	SameSite http.SameSite
+}
+
+// ParseSameSite parses a SameSite cookie attribute: "strict", "lax" or "none"
+func ParseSameSite(sameSite string) (http.SameSite, error) {
+	switch sameSite {
+	case "strict":
+		return http.SameSiteStrictMode, nil
+	case "lax":
+		return http.SameSiteLaxMode, nil
+	case "none":
+		return http.SameSiteNoneMode, nil
+	default:
+		return 0, errors.Errorf("unknown SameSite attribute '%s'", sameSite)
+	}
+}
+
+// SetSessionCookies sets the attributes of the session cookies
+func (ctr *UserController) SetSessionCookies(options SessionCookieOptions) {
+	ctr.cookies = options
+}
+
+// setSessionCookies hands a new cookie session to the browser
+func (ctr *UserController) setSessionCookies(ctx echo.Context, session *model.CreatedSession) {
+	ctx.SetCookie(&http.Cookie{
+		Name:     sessionCookie,
+		Value:    session.Token,
+		Path:     "/",
+		Expires:  session.ExpiresAt,
+		Secure:   ctr.cookies.Secure,
+		HttpOnly: true,
+		SameSite: ctr.cookies.SameSite,
+	})
+	ctx.SetCookie(&http.Cookie{
+		Name:     csrfCookie,
+		Value:    session.CSRFToken,
+		Path:     "/",
+		Expires:  session.ExpiresAt,
+		Secure:   ctr.cookies.Secure,
+		SameSite: ctr.cookies.SameSite,
+	})
+}
+
+// clearSessionCookies makes the browser forget its cookie session
+func (ctr *UserController) clearSessionCookies(ctx echo.Context) {
+	for _, name := range []string{sessionCookie, csrfCookie} {
+		ctx.SetCookie(&http.Cookie{
+			Name:     name,
+			Path:     "/",
+			MaxAge:   -1,
+			Expires:  time.Unix(0, 0),
+			Secure:   ctr.cookies.Secure,
+			HttpOnly: name == sessionCookie,
+			SameSite: ctr.cookies.SameSite,
+		})
+	}
+}
+
+// sessionPrincipal resolves the caller from a session cookie. Unsafe
+// requests must send the CSRF token of the session both in its cookie and
+// in HeaderCSRFToken, which other sites can neither read nor set.
+func sessionPrincipal(ctx echo.Context, services *service.Manager, token string) (*model.Principal, error) {
+	var csrfToken string
+	checkCSRF := !isSafeMethod(ctx.Request().Method)
+	if checkCSRF {
+		csrfToken = ctx.Request().Header.Get(HeaderCSRFToken)
+		cookie, err := ctx.Cookie(csrfCookie)
+		if err != nil || csrfToken == "" || subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(csrfToken)) != 1 {
+			return nil, errors.Wrap(types.ErrForbidden, "missing or mismatched CSRF token")
+		}
+	}
+	return services.User.ParseSession(ctx.Request().Context(), token, csrfToken, checkCSRF)
+}
+
+func isSafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	default:
+		return false
+	}
+}
+
+// endSession revokes the cookie session of the request and clears its cookies
+func (ctr *UserController) endSession(ctx echo.Context, token string) error {
+	principal, err := sessionPrincipal(ctx, ctr.services, token)
+	if err != nil {
+		switch errors.Cause(err) {
+		case types.ErrUnauthorized:
+			// the session already ended, the cookies are stale
+			ctr.clearSessionCookies(ctx)
+			return ctx.NoContent(http.StatusNoContent)
+		case types.ErrForbidden:
+			return err
+		default:
+			return echo.NewHTTPError(http.StatusInternalServerError, errors.Wrap(err, "could not log out"))
+		}
+	}
+
+	err = ctr.services.User.RevokeSession(ctx.Request().Context(), principal.UserID, *principal.SessionID)
+	if err != nil && errors.Cause(err) != types.ErrNotFound {
+		return echo.NewHTTPError(http.StatusInternalServerError, errors.Wrap(err, "could not log out"))
+	}
+	ctr.clearSessionCookies(ctx)
+
+	return ctx.NoContent(http.StatusNoContent)
+}
+
+// ListSessions returns the active cookie sessions of a user
+func (ctr *UserController) ListSessions(ctx echo.Context) error {
+	userID, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, errors.Wrap(err, "could not parse user UUID"))
+	}
+
+	sessions, err := ctr.services.User.ListSessions(ctx.Request().Context(), userID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, errors.Wrap(err, "could not list sessions"))
+	}
+
+	return ctx.JSON(http.StatusOK, sessions)
+}
+
+// RevokeSession ends a cookie session of a user
+func (ctr *UserController) RevokeSession(ctx echo.Context) error {
+	userID, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, errors.Wrap(err, "could not parse user UUID"))
+	}
+	sessionID, err := uuid.Parse(ctx.Param("session_id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, errors.Wrap(err, "could not parse session UUID"))
+	}
+
+	err = ctr.services.User.RevokeSession(ctx.Request().Context(), userID, sessionID)
+	if err != nil {
+		switch errors.Cause(err) {
+		case types.ErrNotFound:
+			return echo.NewHTTPError(http.StatusNotFound, err)
+		default:
+			return echo.NewHTTPError(http.StatusInternalServerError, errors.Wrap(err, "could not revoke session"))
+		}
+	}
+
+	ctr.logger.Debug().Msgf("Revoked session '%s' of user '%s'", sessionID.String(), userID.String())
+
+	return ctx.NoContent(http.StatusNoContent)
+}
@@ -0,0 +1,419 @@
+package controller
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/VikaGo/REST_API/logger"
+	"github.com/VikaGo/REST_API/model"
+	"github.com/VikaGo/REST_API/pkg/types"
+	"github.com/VikaGo/REST_API/pkg/validator"
+	"github.com/VikaGo/REST_API/service"
+	"github.com/VikaGo/REST_API/service/mocks"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSessionAuthMiddleware(t *testing.T) {
+	sessionID := uuid.MustParse("9b1deb4d-3b7d-4bad-9bdd-2b0d7b3dcb6d")
+	principal := &model.Principal{
+		UserID:    uuid.MustParse("7a2f922c-073a-11eb-adc1-0242ac120002"),
+		Role:      "viewer",
+		SessionID: &sessionID,
+	}
+
+	tests := []struct {
+		testName     string
+		method       string
+		csrfCookie   string
+		csrfHeader   string
+		bearer       string
+		expectations func(ctx context.Context, svc *mocks.UserService)
+		cause        error
+	}{
+		{
+			testName: "safe request without CSRF token",
+			method:   echo.GET,
+			expectations: func(ctx context.Context, svc *mocks.UserService) {
+				svc.On("ParseSession", ctx, "session-token", "", false).Return(principal, nil)
+			},
+		},
+		{
+			testName:   "unsafe request with CSRF token",
+			method:     echo.POST,
+			csrfCookie: "csrf",
+			csrfHeader: "csrf",
+			expectations: func(ctx context.Context, svc *mocks.UserService) {
+				svc.On("ParseSession", ctx, "session-token", "csrf", true).Return(principal, nil)
+			},
+		},
+		{
+			testName:     "unsafe request without CSRF header",
+			method:       echo.DELETE,
+			csrfCookie:   "csrf",
+			expectations: func(ctx context.Context, svc *mocks.UserService) {},
+			cause:        types.ErrForbidden,
+		},
+		{
+			testName:     "CSRF header not matching the cookie",
+			method:       echo.PATCH,
+			csrfCookie:   "csrf",
+			csrfHeader:   "forged",
+			expectations: func(ctx context.Context, svc *mocks.UserService) {},
+			cause:        types.ErrForbidden,
+		},
+		{
+			testName:   "CSRF token of another session",
+			method:     echo.POST,
+			csrfCookie: "other",
+			csrfHeader: "other",
+			expectations: func(ctx context.Context, svc *mocks.UserService) {
+				svc.On("ParseSession", ctx, "session-token", "other", true).Return(nil, errors.Wrap(types.ErrForbidden, "invalid CSRF token"))
+			},
+			cause: types.ErrForbidden,
+		},
+		{
+			testName: "revoked session",
+			method:   echo.GET,
+			expectations: func(ctx context.Context, svc *mocks.UserService) {
+				svc.On("ParseSession", ctx, "session-token", "", false).Return(nil, errors.Wrap(types.ErrUnauthorized, "session was revoked"))
+			},
+			cause: types.ErrUnauthorized,
+		},
+		{
+			testName: "bearer token wins over the cookie",
+			method:   echo.POST,
+			bearer:   "good",
+			expectations: func(ctx context.Context, svc *mocks.UserService) {
+				svc.On("ParseToken", ctx, "good").Return(&model.Principal{UserID: principal.UserID, Role: "viewer"}, nil)
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Logf("running %v", test.testName)
+
+		e := echo.New()
+		r := httptest.NewRequest(test.method, "/v1/users/"+principal.UserID.String(), nil)
+		r.AddCookie(&http.Cookie{Name: sessionCookie, Value: "session-token"})
+		if test.csrfCookie != "" {
+			r.AddCookie(&http.Cookie{Name: csrfCookie, Value: test.csrfCookie})
+		}
+		if test.csrfHeader != "" {
+			r.Header.Set(HeaderCSRFToken, test.csrfHeader)
+		}
+		if test.bearer != "" {
+			r.Header.Set(echo.HeaderAuthorization, "Bearer "+test.bearer)
+		}
+		w := httptest.NewRecorder()
+		ctx := e.NewContext(r, w)
+
+		svc := &mocks.UserService{}
+		test.expectations(ctx.Request().Context(), svc)
+
+		handler := AuthMiddleware(&service.Manager{User: svc})(func(ctx echo.Context) error {
+			assert.Equal(t, principal.UserID, ctx.Get(ContextUserIDKey))
+			if test.bearer == "" {
+				assert.Equal(t, sessionID, ctx.Get(ContextSessionIDKey))
+				assert.Equal(t, &sessionID, service.RequestInfoFromContext(ctx.Request().Context()).SessionID)
+			} else {
+				assert.Nil(t, ctx.Get(ContextSessionIDKey))
+			}
+			return ctx.NoContent(http.StatusOK)
+		})
+
+		err := handler(ctx)
+		if test.cause != nil {
+			assert.Equal(t, test.cause, errors.Cause(err))
+		} else {
+			assert.NoError(t, err)
+		}
+		svc.AssertExpectations(t)
+	}
+}
+
+// TestBrowserAuthMiddleware checks the routes browsers are redirected to
+// only take the session cookie
+func TestBrowserAuthMiddleware(t *testing.T) {
+	sessionID := uuid.MustParse("9b1deb4d-3b7d-4bad-9bdd-2b0d7b3dcb6d")
+	principal := &model.Principal{
+		UserID:    uuid.MustParse("7a2f922c-073a-11eb-adc1-0242ac120002"),
+		Role:      "viewer",
+		SessionID: &sessionID,
+	}
+
+	tests := []struct {
+		testName     string
+		cookie       bool
+		bearer       string
+		apiKey       string
+		expectations func(ctx context.Context, svc *mocks.UserService)
+		cause        error
+	}{
+		{
+			testName: "session cookie",
+			cookie:   true,
+			expectations: func(ctx context.Context, svc *mocks.UserService) {
+				svc.On("ParseSession", ctx, "session-token", "", false).Return(principal, nil)
+			},
+		},
+		{
+			testName: "session cookie with a bearer token",
+			cookie:   true,
+			bearer:   "good",
+			expectations: func(ctx context.Context, svc *mocks.UserService) {
+				svc.On("ParseSession", ctx, "session-token", "", false).Return(principal, nil)
+			},
+		},
+		{
+			testName:     "bearer token",
+			bearer:       "good",
+			expectations: func(ctx context.Context, svc *mocks.UserService) {},
+			cause:        types.ErrUnauthorized,
+		},
+		{
+			testName:     "API key",
+			apiKey:       "key",
+			expectations: func(ctx context.Context, svc *mocks.UserService) {},
+			cause:        types.ErrUnauthorized,
+		},
+	}
+
+	for _, test := range tests {
+		t.Logf("running %v", test.testName)
+
+		e := echo.New()
+		r := httptest.NewRequest(echo.GET, "/oauth/authorize", nil)
+		if test.cookie {
+			r.AddCookie(&http.Cookie{Name: sessionCookie, Value: "session-token"})
+		}
+		if test.bearer != "" {
+			r.Header.Set(echo.HeaderAuthorization, "Bearer "+test.bearer)
+		}
+		if test.apiKey != "" {
+			r.Header.Set(HeaderAPIKey, test.apiKey)
+		}
+		w := httptest.NewRecorder()
+		ctx := e.NewContext(r, w)
+
+		svc := &mocks.UserService{}
+		test.expectations(ctx.Request().Context(), svc)
+
+		handler := BrowserAuthMiddleware(&service.Manager{User: svc})(func(ctx echo.Context) error {
+			assert.Equal(t, principal.UserID, ctx.Get(ContextUserIDKey))
+			assert.Equal(t, sessionID, ctx.Get(ContextSessionIDKey))
+			return ctx.NoContent(http.StatusOK)
+		})
+
+		err := handler(ctx)
+		if test.cause != nil {
+			assert.Equal(t, test.cause, errors.Cause(err))
+		} else {
+			assert.NoError(t, err)
+		}
+		svc.AssertExpectations(t)
+	}
+}
+
+func TestLogInSession(t *testing.T) {
+	l := logger.Get()
+	session := &model.CreatedSession{
+		ID:        uuid.MustParse("9b1deb4d-3b7d-4bad-9bdd-2b0d7b3dcb6d"),
+		Token:     "session-token",
+		CSRFToken: "csrf",
+		ExpiresAt: time.Now().Add(time.Hour),
+	}
+
+	tests := []struct {
+		testName     string
+		body         string
+		expectations func(svc *mocks.UserService)
+		code         int
+	}{
+		{
+			testName: "cookie session",
+			body:     `{"nickname":"topol","password":"s3cret-pass","session":true}`,
+			expectations: func(svc *mocks.UserService) {
+				svc.On("GenerateToken", mock.MatchedBy(service.IsSessionLogin), "topol", "s3cret-pass").Return(&model.LoginResult{Session: session}, nil)
+			},
+			code: http.StatusOK,
+		},
+		{
+			testName: "cookie sessions disabled",
+			body:     `{"nickname":"topol","password":"s3cret-pass","session":true}`,
+			expectations: func(svc *mocks.UserService) {
+				svc.On("GenerateToken", mock.Anything, "topol", "s3cret-pass").Return(nil, errors.Wrap(types.ErrBadRequest, "cookie sessions are disabled"))
+			},
+			code: http.StatusBadRequest,
+		},
+	}
+
+	for _, test := range tests {
+		t.Logf("running %v", test.testName)
+
+		e := echo.New()
+		e.Validator = validator.NewValidator()
+		r := httptest.NewRequest(echo.POST, "/v1/users/login", strings.NewReader(test.body))
+		r.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		w := httptest.NewRecorder()
+		ctx := e.NewContext(r, w)
+
+		svc := &mocks.UserService{}
+		test.expectations(svc)
+
+		d := NewUsers(ctx.Request().Context(), &service.Manager{User: svc}, l)
+		err := d.LogIn(ctx)
+		if test.code < http.StatusBadRequest {
+			require.NoError(t, err)
+			assert.Equal(t, test.code, w.Code)
+			// the session token only travels in its HttpOnly cookie
+			assert.NotContains(t, w.Body.String(), "session-token")
+			assert.Contains(t, w.Body.String(), `"csrf_token":"csrf"`)
+			cookies := map[string]*http.Cookie{}
+			for _, cookie := range w.Result().Cookies() {
+				cookies[cookie.Name] = cookie
+			}
+			require.Contains(t, cookies, sessionCookie)
+			assert.Equal(t, "session-token", cookies[sessionCookie].Value)
+			assert.True(t, cookies[sessionCookie].HttpOnly)
+			assert.True(t, cookies[sessionCookie].Secure)
+			assert.Equal(t, http.SameSiteStrictMode, cookies[sessionCookie].SameSite)
+			require.Contains(t, cookies, csrfCookie)
+			assert.False(t, cookies[csrfCookie].HttpOnly, "scripts read the CSRF token")
+		} else {
+			assert.Equal(t, test.code, types.HTTPCode(err))
+		}
+		svc.AssertExpectations(t)
+	}
+}
+
+func TestRevokeSession(t *testing.T) {
+	l := logger.Get()
+	self := uuid.MustParse("7a2f922c-073a-11eb-adc1-0242ac120002")
+	sessionID := uuid.MustParse("9b1deb4d-3b7d-4bad-9bdd-2b0d7b3dcb6d")
+
+	tests := []struct {
+		testName     string
+		sessionID    string
+		expectations func(svc *mocks.UserService)
+		code         int
+	}{
+		{
+			testName:  "revoke",
+			sessionID: sessionID.String(),
+			expectations: func(svc *mocks.UserService) {
+				svc.On("RevokeSession", mock.Anything, self, sessionID).Return(nil)
+			},
+			code: http.StatusNoContent,
+		},
+		{
+			testName:  "unknown session",
+			sessionID: sessionID.String(),
+			expectations: func(svc *mocks.UserService) {
+				svc.On("RevokeSession", mock.Anything, self, sessionID).Return(errors.Wrap(types.ErrNotFound, "Session not found"))
+			},
+			code: http.StatusNotFound,
+		},
+		{
+			testName:     "invalid session ID",
+			sessionID:    "current",
+			expectations: func(svc *mocks.UserService) {},
+			code:         http.StatusBadRequest,
+		},
+	}
+
+	for _, test := range tests {
+		t.Logf("running %v", test.testName)
+
+		e := echo.New()
+		r := httptest.NewRequest(echo.DELETE, "/v1/users/"+self.String()+"/sessions/"+test.sessionID, nil)
+		w := httptest.NewRecorder()
+		ctx := e.NewContext(r, w)
+		ctx.SetParamNames("id", "session_id")
+		ctx.SetParamValues(self.String(), test.sessionID)
+
+		svc := &mocks.UserService{}
+		test.expectations(svc)
+
+		d := NewUsers(ctx.Request().Context(), &service.Manager{User: svc}, l)
+		err := d.RevokeSession(ctx)
+		if test.code < http.StatusBadRequest {
+			assert.NoError(t, err)
+			assert.Equal(t, test.code, w.Code)
+		} else {
+			assert.Equal(t, test.code, types.HTTPCode(err))
+		}
+		svc.AssertExpectations(t)
+	}
+}
+
+// TestLogOutAll checks scoped API keys need the sessions scope to log out everywhere
+func TestLogOutAll(t *testing.T) {
+	l := logger.Get()
+	self := uuid.MustParse("7a2f922c-073a-11eb-adc1-0242ac120002")
+
+	tests := []struct {
+		testName     string
+		scopes       []string
+		expectations func(svc *mocks.UserService)
+		code         int
+		cause        error
+	}{
+		{
+			testName: "token",
+			expectations: func(svc *mocks.UserService) {
+				svc.On("LogoutAll", mock.Anything, self).Return(nil)
+			},
+			code: http.StatusNoContent,
+		},
+		{
+			testName: "API key with the sessions scope",
+			scopes:   []string{string(PermManageSessions)},
+			expectations: func(svc *mocks.UserService) {
+				svc.On("LogoutAll", mock.Anything, self).Return(nil)
+			},
+			code: http.StatusNoContent,
+		},
+		{
+			testName:     "API key without the sessions scope",
+			scopes:       []string{string(PermListUsers)},
+			expectations: func(svc *mocks.UserService) {},
+			cause:        types.ErrForbidden,
+		},
+	}
+
+	for _, test := range tests {
+		t.Logf("running %v", test.testName)
+
+		e := echo.New()
+		r := httptest.NewRequest(echo.POST, "/v1/users/logout/all", nil)
+		w := httptest.NewRecorder()
+		ctx := e.NewContext(r, w)
+		ctx.Set(ContextUserIDKey, self)
+		ctx.Set(ContextRoleKey, model.RoleViewer)
+		if test.scopes != nil {
+			ctx.Set(ContextScopesKey, test.scopes)
+		}
+
+		svc := &mocks.UserService{}
+		test.expectations(svc)
+
+		d := NewUsers(ctx.Request().Context(), &service.Manager{User: svc}, l)
+		err := d.LogOutAll(ctx)
+		if test.cause != nil {
+			assert.Equal(t, test.cause, errors.Cause(err))
+		} else {
+			assert.NoError(t, err)
+			assert.Equal(t, test.code, w.Code)
+		}
+		svc.AssertExpectations(t)
+	}
+}
@@ -0,0 +1,144 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/VikaGo/REST_API/pkg/types"
+	"github.com/VikaGo/REST_API/service"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/pkg/errors"
+)
+
+// ChallengeInput is the second step of a login with two-factor authentication
+type ChallengeInput struct {
+	ChallengeToken string `json:"challenge_token" validate:"required"`
+	// Code is a TOTP code or a recovery code
+	Code string `json:"code" validate:"required"`
+	// Session asks for a cookie session instead of tokens
+	Session bool `json:"session"`
+}
+
+// ChallengeEnrollInput starts the enrollment a role requires during a login
+type ChallengeEnrollInput struct {
+	ChallengeToken string `json:"challenge_token" validate:"required"`
+}
+
+// TwoFactorCodeInput confirms a TOTP enrollment
+type TwoFactorCodeInput struct {
+	Code string `json:"code" validate:"required"`
+}
+
+// CompleteLogIn exchanges a login challenge and a second factor code for tokens
+func (ctr *UserController) CompleteLogIn(ctx echo.Context) error {
+	var input ChallengeInput
+	if err := ctx.Bind(&input); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, errors.Wrap(err, "could not decode challenge"))
+	}
+	if err := ctx.Validate(&input); err != nil {
+		return echo.NewHTTPError(http.StatusUnprocessableEntity, err)
+	}
+
+	reqCtx := ctx.Request().Context()
+	if input.Session {
+		reqCtx = service.WithSessionLogin(reqCtx)
+	}
+	result, err := ctr.services.User.CompleteLogin(reqCtx, input.ChallengeToken, input.Code)
+	if err != nil {
+		switch errors.Cause(err) {
+		case types.ErrUnauthorized, types.ErrTooManyRequests:
+			return err
+		case types.ErrBadRequest:
+			return echo.NewHTTPError(http.StatusBadRequest, err)
+		default:
+			return echo.NewHTTPError(http.StatusInternalServerError, errors.Wrap(err, "could not log in"))
+		}
+	}
+	if result.Session != nil {
+		ctr.setSessionCookies(ctx, result.Session)
+	}
+
+	return ctx.JSON(http.StatusOK, result)
+}
+
+// EnrollTwoFactorOnLogIn starts the TOTP enrollment the role of a user
+// requires before the login can complete
+func (ctr *UserController) EnrollTwoFactorOnLogIn(ctx echo.Context) error {
+	var input ChallengeEnrollInput
+	if err := ctx.Bind(&input); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, errors.Wrap(err, "could not decode challenge"))
+	}
+	if err := ctx.Validate(&input); err != nil {
+		return echo.NewHTTPError(http.StatusUnprocessableEntity, err)
+	}
+
+	enrollment, err := ctr.services.User.EnrollTwoFactorWithChallenge(ctx.Request().Context(), input.ChallengeToken)
+	if err != nil {
+		switch errors.Cause(err) {
+		case types.ErrUnauthorized:
+			return err
+		case types.ErrConflict:
+			return echo.NewHTTPError(http.StatusConflict, err)
+		default:
+			return echo.NewHTTPError(http.StatusInternalServerError, errors.Wrap(err, "could not enroll two-factor authentication"))
+		}
+	}
+
+	return ctx.JSON(http.StatusOK, enrollment)
+}
+
+// EnrollTwoFactor starts a TOTP enrollment and returns the otpauth URI
+func (ctr *UserController) EnrollTwoFactor(ctx echo.Context) error {
+	userID, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, errors.Wrap(err, "could not parse user UUID"))
+	}
+
+	enrollment, err := ctr.services.User.EnrollTwoFactor(ctx.Request().Context(), userID)
+	if err != nil {
+		switch errors.Cause(err) {
+		case types.ErrNotFound:
+			return echo.NewHTTPError(http.StatusNotFound, err)
+		case types.ErrGone:
+			return echo.NewHTTPError(http.StatusGone, err)
+		case types.ErrConflict:
+			return echo.NewHTTPError(http.StatusConflict, err)
+		default:
+			return echo.NewHTTPError(http.StatusInternalServerError, errors.Wrap(err, "could not enroll two-factor authentication"))
+		}
+	}
+
+	return ctx.JSON(http.StatusOK, enrollment)
+}
+
+// ConfirmTwoFactor enables TOTP with a code and returns the recovery codes
+func (ctr *UserController) ConfirmTwoFactor(ctx echo.Context) error {
+	userID, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, errors.Wrap(err, "could not parse user UUID"))
+	}
+
+	var input TwoFactorCodeInput
+	if err := ctx.Bind(&input); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, errors.Wrap(err, "could not decode code"))
+	}
+	if err := ctx.Validate(&input); err != nil {
+		return echo.NewHTTPError(http.StatusUnprocessableEntity, err)
+	}
+
+	codes, err := ctr.services.User.ConfirmTwoFactor(ctx.Request().Context(), userID, input.Code)
+	if err != nil {
+		switch errors.Cause(err) {
+		case types.ErrConflict:
+			return echo.NewHTTPError(http.StatusConflict, err)
+		case types.ErrUnprocessableEntity:
+			return echo.NewHTTPError(http.StatusUnprocessableEntity, err)
+		default:
+			return echo.NewHTTPError(http.StatusInternalServerError, errors.Wrap(err, "could not confirm two-factor authentication"))
+		}
+	}
+
+	ctr.logger.Debug().Msgf("Enabled two-factor authentication of user '%s'", userID.String())
+
+	return ctx.JSON(http.StatusOK, codes)
+}
@@ -1,18 +1,20 @@
 package controller
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"github.com/VikaGo/REST_API/logger"
 	"github.com/VikaGo/REST_API/model"
+	"github.com/VikaGo/REST_API/pkg/patch"
 	"github.com/VikaGo/REST_API/pkg/types"
 	"github.com/VikaGo/REST_API/service"
 	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
-	"github.com/labstack/echo/v4/middleware"
 	"github.com/pkg/errors"
-	"golang.org/x/crypto/bcrypt"
+	"io"
 	"net/http"
-	"regexp"
+	"strconv"
 )
 
 // UserController ...
@@ -20,6 +22,7 @@ type UserController struct {
 	ctx      context.Context
 	services *service.Manager
 	logger   *logger.Logger
+	cookies  SessionCookieOptions
 }
 
 // NewUsers creates a new user controller.
@@ -28,39 +31,50 @@ func NewUsers(ctx context.Context, services *service.Manager, logger *logger.Log
 		ctx:      ctx,
 		services: services,
 		logger:   logger,
+		cookies:  SessionCookieOptions{Secure: true, SameSite: http.SameSiteStrictMode},
 	}
 }
 
 type LogInInput struct {
 	Nickname string `json:"nickname" binding:"required"`
 	Password string `json:"password" binding:"required"`
+	// Session asks for a cookie session instead of tokens
+	Session bool `json:"session"`
 }
 
-// Create new user
+type RefreshInput struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
+}
+
+// Create registers a new user
 func (ctr *UserController) Create(ctx echo.Context) error {
-	var user model.User
+	var user model.UserCreate
 	err := ctx.Bind(&user)
 	if err != nil {
 		return echo.NewHTTPError(http.StatusBadRequest, errors.Wrap(err, "could not decode user data"))
 	}
 
-	// Validate user input, including checking for a strong password
-	if err := validatePassword(&user); err != nil {
-		return echo.NewHTTPError(http.StatusUnprocessableEntity, err)
+	// Self-registered users are always viewers
+	if user.Role == "" {
+		user.Role = model.RoleViewer
+	}
+	if user.Role != model.RoleViewer {
+		return errors.Wrap(types.ErrForbidden, "only admins can assign roles")
 	}
 
-	// Generate a hashed password
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
+	err = ctx.Validate(&user)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, errors.Wrap(err, "could not generate hashed password"))
+		return echo.NewHTTPError(http.StatusUnprocessableEntity, err)
 	}
 
-	// Set the hashed password in the user data
-	user.Password = string(hashedPassword)
-
 	createdUser, err := ctr.services.User.CreateUser(ctx.Request().Context(), &user)
 	if err != nil {
 		switch {
+		case errors.Cause(err) == types.ErrDuplicateEntry:
+			return err
+		case errors.Cause(err) == types.ErrUnprocessableEntity:
+			// keeps the password policy violations
+			return err
 		case errors.Cause(err) == types.ErrBadRequest:
 			return echo.NewHTTPError(http.StatusBadRequest, err)
 		default:
@@ -79,20 +93,64 @@ func (ctr *UserController) Get(ctx echo.Context) error {
 	if err != nil {
 		return echo.NewHTTPError(http.StatusBadRequest, errors.Wrap(err, "could not parse user UUID"))
 	}
-	user, err := ctr.services.User.GetUser(ctx.Request().Context(), userID)
+	includeDeleted, err := ctr.includeDeleted(ctx)
+	if err != nil {
+		return err
+	}
+
+	var user *model.User
+	if includeDeleted {
+		user, err = ctr.services.User.GetUserIncludingDeleted(ctx.Request().Context(), userID)
+	} else {
+		user, err = ctr.services.User.GetUser(ctx.Request().Context(), userID)
+	}
 	if err != nil {
 		switch {
 		case errors.Cause(err) == types.ErrNotFound:
 			return echo.NewHTTPError(http.StatusNotFound, err)
+		case errors.Cause(err) == types.ErrGone:
+			return echo.NewHTTPError(http.StatusGone, err)
 		case errors.Cause(err) == types.ErrBadRequest:
 			return echo.NewHTTPError(http.StatusBadRequest, err)
 		default:
 			return echo.NewHTTPError(http.StatusInternalServerError, errors.Wrap(err, "could not get user"))
 		}
 	}
+
+	setETag(ctx, user.Version)
+	if notModified(ctx, user.Version) {
+		return ctx.NoContent(http.StatusNotModified)
+	}
 	return ctx.JSON(http.StatusOK, user)
 }
 
+// List returns a page of users
+func (ctr *UserController) List(ctx echo.Context) error {
+	var params model.UserListParams
+	err := ctx.Bind(&params)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, errors.Wrap(err, "could not decode list parameters"))
+	}
+	err = ctx.Validate(&params)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnprocessableEntity, err)
+	}
+	if params.IncludeDeleted && !can(principalFromContext(ctx), PermManageDeleted, uuid.Nil) {
+		return errors.Wrap(types.ErrForbidden, "only admins can list deleted users")
+	}
+
+	page, err := ctr.services.User.ListUsers(ctx.Request().Context(), &params)
+	if err != nil {
+		switch {
+		case errors.Cause(err) == types.ErrBadRequest:
+			return echo.NewHTTPError(http.StatusBadRequest, err)
+		default:
+			return echo.NewHTTPError(http.StatusInternalServerError, errors.Wrap(err, "could not list users"))
+		}
+	}
+	return ctx.JSON(http.StatusOK, page)
+}
+
 // Update user by ID
 func (ctr *UserController) Update(ctx echo.Context) error {
 	userID, err := uuid.Parse(ctx.Param("id"))
@@ -100,32 +158,39 @@ func (ctr *UserController) Update(ctx echo.Context) error {
 		return echo.NewHTTPError(http.StatusBadRequest, errors.Wrap(err, "could not parse user UUID"))
 	}
 
-	basicAuthMiddleware("your_nickname", "your_password")
+	version, err := ifMatchVersion(ctx)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusUnauthorized, "Unauthorized")
+		return err
 	}
 
-	var updatedUser model.User
-	err = ctx.Bind(&updatedUser)
+	body, err := io.ReadAll(ctx.Request().Body)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, errors.Wrap(err, "could not read updated user data"))
+	}
+	update, err := decodeUserUpdate(body)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, errors.Wrap(err, "could not decode updated user data"))
+		return err
 	}
-	err = ctx.Validate(&updatedUser)
+	err = ctx.Validate(update)
 	if err != nil {
 		return echo.NewHTTPError(http.StatusUnprocessableEntity, err)
 	}
 
-	password := updatedUser.Password
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
-	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, errors.Wrap(err, "could not hash password"))
+	if err := ctr.authorizeRoleChange(ctx, userID, update.Role); err != nil {
+		return err
 	}
-	updatedUser.Password = string(hashedPassword)
 
-	updatedUser.ID = userID
-	u, err := ctr.services.User.UpdateUser(ctx.Request().Context(), &updatedUser)
+	u, err := ctr.services.User.UpdateUser(ctx.Request().Context(), userID, version, update)
 	if err != nil {
 		switch {
+		case errors.Cause(err) == types.ErrNotFound:
+			return echo.NewHTTPError(http.StatusNotFound, err)
+		case errors.Cause(err) == types.ErrGone:
+			return echo.NewHTTPError(http.StatusGone, err)
+		case errors.Cause(err) == types.ErrDuplicateEntry:
+			return err
+		case errors.Cause(err) == types.ErrConflict:
+			return preconditionFailed(err)
 		case errors.Cause(err) == types.ErrBadRequest:
 			return echo.NewHTTPError(http.StatusBadRequest, err)
 		default:
@@ -135,7 +200,142 @@ func (ctr *UserController) Update(ctx echo.Context) error {
 
 	ctr.logger.Debug().Msgf("Updated user '%s'", u.ID.String())
 
-	return ctx.JSON(http.StatusOK, updatedUser)
+	setETag(ctx, u.Version)
+	return ctx.JSON(http.StatusOK, u)
+}
+
+// decodeUserUpdate decodes the user document of PUT, which may not set the password
+func decodeUserUpdate(doc []byte) (*model.UserUpdate, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(doc, &fields); err != nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, errors.Wrap(err, "could not decode updated user data"))
+	}
+	if err := rejectPassword(fields); err != nil {
+		return nil, err
+	}
+
+	var update model.UserUpdate
+	if err := json.Unmarshal(doc, &update); err != nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, errors.Wrap(err, "could not decode updated user data"))
+	}
+	return &update, nil
+}
+
+// Patch partially updates user by ID with a JSON Merge Patch or a JSON Patch
+func (ctr *UserController) Patch(ctx echo.Context) error {
+	userID, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, errors.Wrap(err, "could not parse user UUID"))
+	}
+
+	version, err := ifMatchVersion(ctx)
+	if err != nil {
+		return err
+	}
+
+	body, err := io.ReadAll(ctx.Request().Body)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, errors.Wrap(err, "could not read patch"))
+	}
+
+	current, err := ctr.services.User.GetUser(ctx.Request().Context(), userID)
+	if err != nil {
+		switch {
+		case errors.Cause(err) == types.ErrNotFound:
+			return echo.NewHTTPError(http.StatusNotFound, err)
+		case errors.Cause(err) == types.ErrGone:
+			return echo.NewHTTPError(http.StatusGone, err)
+		default:
+			return echo.NewHTTPError(http.StatusInternalServerError, errors.Wrap(err, "could not get user"))
+		}
+	}
+	if version != 0 && version != current.Version {
+		return preconditionFailed(types.ErrConflict)
+	}
+
+	doc, err := json.Marshal(current.ToPatch())
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, errors.Wrap(err, "could not encode user"))
+	}
+	patched, err := patch.Apply(ctx.Request().Header.Get(echo.HeaderContentType), doc, body)
+	if err != nil {
+		switch {
+		case errors.Cause(err) == patch.ErrUnsupportedMediaType:
+			return echo.NewHTTPError(http.StatusUnsupportedMediaType, err)
+		case errors.Cause(err) == patch.ErrMalformedPatch:
+			return echo.NewHTTPError(http.StatusBadRequest, err)
+		case errors.Cause(err) == patch.ErrTestFailed:
+			return echo.NewHTTPError(http.StatusConflict, err)
+		default:
+			return echo.NewHTTPError(http.StatusUnprocessableEntity, err)
+		}
+	}
+
+	userPatch, err := decodeUserPatch(patched)
+	if err != nil {
+		return err
+	}
+	err = ctx.Validate(userPatch)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnprocessableEntity, err)
+	}
+
+	changes := userPatch.Changes(current)
+	if changes.Role != nil && !can(principalFromContext(ctx), PermChangeRole, userID) {
+		return errors.Wrap(types.ErrForbidden, "only admins can change roles")
+	}
+
+	// the patch was computed from current, so it must only apply to that version
+	u, err := ctr.services.User.PatchUser(ctx.Request().Context(), userID, current.Version, changes)
+	if err != nil {
+		switch {
+		case errors.Cause(err) == types.ErrNotFound:
+			return echo.NewHTTPError(http.StatusNotFound, err)
+		case errors.Cause(err) == types.ErrGone:
+			return echo.NewHTTPError(http.StatusGone, err)
+		case errors.Cause(err) == types.ErrDuplicateEntry:
+			return err
+		case errors.Cause(err) == types.ErrConflict && version != 0:
+			return preconditionFailed(err)
+		case errors.Cause(err) == types.ErrConflict:
+			return echo.NewHTTPError(http.StatusConflict, errors.Wrap(err, "user was modified concurrently"))
+		default:
+			return echo.NewHTTPError(http.StatusInternalServerError, errors.Wrap(err, "could not patch user"))
+		}
+	}
+
+	ctr.logger.Debug().Msgf("Patched user '%s'", u.ID.String())
+
+	setETag(ctx, u.Version)
+	return ctx.JSON(http.StatusOK, u)
+}
+
+// decodeUserPatch decodes a patched user document, rejecting fields PATCH may not set
+func decodeUserPatch(doc []byte) (*model.UserPatch, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(doc, &fields); err != nil {
+		return nil, echo.NewHTTPError(http.StatusUnprocessableEntity, errors.Wrap(err, "patched user is not an object"))
+	}
+	if err := rejectPassword(fields); err != nil {
+		return nil, err
+	}
+
+	var userPatch model.UserPatch
+	decoder := json.NewDecoder(bytes.NewReader(doc))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&userPatch); err != nil {
+		return nil, echo.NewHTTPError(http.StatusUnprocessableEntity, errors.Wrap(err, "could not decode patched user"))
+	}
+	return &userPatch, nil
+}
+
+// rejectPassword refuses user documents setting the password, which only
+// ChangePassword may change
+func rejectPassword(fields map[string]json.RawMessage) error {
+	if _, ok := fields["password"]; ok {
+		return errors.Wrap(types.ErrForbidden, "password can only be changed through the password endpoint")
+	}
+	return nil
 }
 
 // Delete deletes user by ID
@@ -144,11 +344,19 @@ func (ctr *UserController) Delete(ctx echo.Context) error {
 	if err != nil {
 		return echo.NewHTTPError(http.StatusBadRequest, errors.Wrap(err, "could not parse user UUID"))
 	}
-	err = ctr.services.User.DeleteUser(ctx.Request().Context(), userID)
+	version, err := ifMatchVersion(ctx)
+	if err != nil {
+		return err
+	}
+	err = ctr.services.User.DeleteUser(ctx.Request().Context(), userID, version)
 	if err != nil {
 		switch {
 		case errors.Cause(err) == types.ErrNotFound:
 			return echo.NewHTTPError(http.StatusNotFound, err)
+		case errors.Cause(err) == types.ErrGone:
+			return echo.NewHTTPError(http.StatusGone, err)
+		case errors.Cause(err) == types.ErrConflict:
+			return preconditionFailed(err)
 		case errors.Cause(err) == types.ErrBadRequest:
 			return echo.NewHTTPError(http.StatusBadRequest, err)
 		default:
@@ -161,6 +369,69 @@ func (ctr *UserController) Delete(ctx echo.Context) error {
 	return ctx.JSON(http.StatusOK, "OK")
 }
 
+// Restore restores a soft-deleted user by ID
+func (ctr *UserController) Restore(ctx echo.Context) error {
+	userID, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, errors.Wrap(err, "could not parse user UUID"))
+	}
+	user, err := ctr.services.User.RestoreUser(ctx.Request().Context(), userID)
+	if err != nil {
+		switch {
+		case errors.Cause(err) == types.ErrNotFound:
+			return echo.NewHTTPError(http.StatusNotFound, err)
+		case errors.Cause(err) == types.ErrConflict:
+			return echo.NewHTTPError(http.StatusConflict, err)
+		default:
+			return echo.NewHTTPError(http.StatusInternalServerError, errors.Wrap(err, "could not restore user"))
+		}
+	}
+
+	ctr.logger.Debug().Msgf("Restored user '%s'", userID.String())
+
+	setETag(ctx, user.Version)
+	return ctx.JSON(http.StatusOK, user)
+}
+
+// Unlock lifts the login lockout of a user
+func (ctr *UserController) Unlock(ctx echo.Context) error {
+	userID, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, errors.Wrap(err, "could not parse user UUID"))
+	}
+	err = ctr.services.User.UnlockUser(ctx.Request().Context(), userID)
+	if err != nil {
+		switch {
+		case errors.Cause(err) == types.ErrNotFound:
+			return echo.NewHTTPError(http.StatusNotFound, err)
+		case errors.Cause(err) == types.ErrGone:
+			return echo.NewHTTPError(http.StatusGone, err)
+		default:
+			return echo.NewHTTPError(http.StatusInternalServerError, errors.Wrap(err, "could not unlock user"))
+		}
+	}
+
+	ctr.logger.Debug().Msgf("Unlocked user '%s'", userID.String())
+
+	return ctx.NoContent(http.StatusNoContent)
+}
+
+// includeDeleted reads the "include_deleted" query option, which only admins may use
+func (ctr *UserController) includeDeleted(ctx echo.Context) (bool, error) {
+	value := ctx.QueryParam("include_deleted")
+	if value == "" {
+		return false, nil
+	}
+	includeDeleted, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, echo.NewHTTPError(http.StatusBadRequest, errors.Wrap(err, "could not parse include_deleted"))
+	}
+	if includeDeleted && !can(principalFromContext(ctx), PermManageDeleted, uuid.Nil) {
+		return false, errors.Wrap(types.ErrForbidden, "only admins can read deleted users")
+	}
+	return includeDeleted, nil
+}
+
 // Login
 func (ctr *UserController) LogIn(ctx echo.Context) error {
 	var input LogInInput
@@ -179,93 +450,158 @@ func (ctr *UserController) LogIn(ctx echo.Context) error {
 	//	return echo.NewHTTPError(http.StatusUnauthorized, "Пароль неправильний")
 	//}
 
-	token, err := ctr.services.User.GenerateToken(ctx.Request().Context(), input.Nickname, input.Password)
+	reqCtx := ctx.Request().Context()
+	if input.Session {
+		reqCtx = service.WithSessionLogin(reqCtx)
+	}
+	tokens, err := ctr.services.User.GenerateToken(reqCtx, input.Nickname, input.Password)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, err)
+		switch errors.Cause(err) {
+		case types.ErrUnauthorized:
+			ctx.Response().Header().Set(echo.HeaderWWWAuthenticate, bearerScheme)
+			return err
+		case types.ErrTooManyRequests:
+			// keeps the Retry-After of the error
+			return err
+		case types.ErrBadRequest:
+			return echo.NewHTTPError(http.StatusBadRequest, err)
+		default:
+			return echo.NewHTTPError(http.StatusInternalServerError, errors.Wrap(err, "could not log in"))
+		}
+	}
+	if tokens.Session != nil {
+		ctr.setSessionCookies(ctx, tokens.Session)
 	}
 
-	ctx.JSON(http.StatusOK, map[string]interface{}{
-		"token": token,
-	})
-	return nil
+	return ctx.JSON(http.StatusOK, tokens)
 }
 
-func basicAuthMiddleware(username, password string) echo.MiddlewareFunc {
-	return middleware.BasicAuth(func(u, p string, c echo.Context) (bool, error) {
-		if u == username && p == password {
-			return true, nil
+// Refresh exchanges a refresh token for a new token pair
+func (ctr *UserController) Refresh(ctx echo.Context) error {
+	var input RefreshInput
+	if err := ctx.Bind(&input); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, errors.Wrap(err, "could not decode refresh token"))
+	}
+	if err := ctx.Validate(&input); err != nil {
+		return echo.NewHTTPError(http.StatusUnprocessableEntity, err)
+	}
+
+	tokens, err := ctr.services.User.RefreshToken(ctx.Request().Context(), input.RefreshToken)
+	if err != nil {
+		switch {
+		case errors.Cause(err) == types.ErrUnauthorized:
+			return echo.NewHTTPError(http.StatusUnauthorized, err)
+		default:
+			return echo.NewHTTPError(http.StatusInternalServerError, errors.Wrap(err, "could not refresh token"))
 		}
-		return false, nil
-	})
+	}
+
+	return ctx.JSON(http.StatusOK, tokens)
+}
+
+// LogOut revokes the session the refresh token belongs to, or else the
+// cookie session of the request
+func (ctr *UserController) LogOut(ctx echo.Context) error {
+	var input RefreshInput
+	if err := ctx.Bind(&input); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, errors.Wrap(err, "could not decode refresh token"))
+	}
+	if cookie, err := ctx.Cookie(sessionCookie); err == nil && input.RefreshToken == "" {
+		return ctr.endSession(ctx, cookie.Value)
+	}
+	if err := ctx.Validate(&input); err != nil {
+		return echo.NewHTTPError(http.StatusUnprocessableEntity, err)
+	}
+
+	err := ctr.services.User.Logout(ctx.Request().Context(), input.RefreshToken)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, errors.Wrap(err, "could not log out"))
+	}
+
+	return ctx.NoContent(http.StatusNoContent)
 }
 
-func validatePassword(user *model.User) error {
+// LogOutAll revokes every session of the authenticated user
+func (ctr *UserController) LogOutAll(ctx echo.Context) error {
+	principal := principalFromContext(ctx)
+	if principal == nil {
+		return errors.Wrap(types.ErrUnauthorized, "no authenticated user")
+	}
 
-	// at least eight characters
-	if len(user.Password) < 8 {
-		return errors.New("Password must be at least 8 characters long")
+	// every role may log itself out, so only the scopes of an API key can refuse it
+	if !can(principal, PermManageSessions, principal.UserID) {
+		return errors.Wrap(types.ErrForbidden, "the API key is not allowed to "+string(PermManageSessions))
 	}
 
-	// at least one figure
-	hasNumber := regexp.MustCompile(`\d`).MatchString(user.Password)
-	if !hasNumber {
-		return errors.New("Password must include at least one figure")
+	err := ctr.services.User.LogoutAll(ctx.Request().Context(), principal.UserID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, errors.Wrap(err, "could not log out"))
 	}
 
-	// at least one special character
-	hasSpecialChar := regexp.MustCompile(`[^a-zA-Z0-9\s]`).MatchString(user.Password)
-	if !hasSpecialChar {
-		return errors.New("Password must include at least one special character")
+	ctr.logger.Debug().Msgf("Logged out user '%s' everywhere", principal.UserID.String())
+
+	return ctx.NoContent(http.StatusNoContent)
+}
+
+// authorizeRoleChange rejects updates that change the user's role unless
+// the caller is allowed to change roles.
+func (ctr *UserController) authorizeRoleChange(ctx echo.Context, userID uuid.UUID, role string) error {
+	if can(principalFromContext(ctx), PermChangeRole, userID) {
+		return nil
+	}
+	current, err := ctr.services.User.GetUser(ctx.Request().Context(), userID)
+	if err != nil {
+		return err
+	}
+	if current.Role != role {
+		return errors.Wrap(types.ErrForbidden, "only admins can change roles")
 	}
 	return nil
 }
 
-// Change password endpoint
+// ChangePassword changes the password of a user. Users confirm their
+// current password; admins may set the password of other users without it.
 func (ctr *UserController) ChangePassword(ctx echo.Context) error {
 	userID, err := uuid.Parse(ctx.Param("id"))
-
-	// Check Basic Auth
-	basicAuthMiddleware("your_nickname", "your_password")
 	if err != nil {
-		return echo.NewHTTPError(http.StatusUnauthorized, "Unauthorized")
-	}
-
-	// Get the new and existing passwords from the request
-	var newPassword struct {
-		NewPassword      string `json:"new_password"`
-		ExistingPassword string `json:"existing_password"`
+		return echo.NewHTTPError(http.StatusBadRequest, errors.Wrap(err, "could not parse user UUID"))
 	}
 
-	if err := ctx.Bind(&newPassword); err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, errors.Wrap(err, "could not decode new password"))
+	var change model.PasswordChange
+	if err := ctx.Bind(&change); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, errors.Wrap(err, "could not decode password change"))
 	}
-
-	// Validate the new password
-	if err := validatePassword(&model.User{Password: newPassword.NewPassword}); err != nil {
-		return echo.NewHTTPError(http.StatusUnprocessableEntity, err.Error())
+	if err := ctx.Validate(&change); err != nil {
+		return echo.NewHTTPError(http.StatusUnprocessableEntity, err)
 	}
 
-	// Generate a hashed password for the new password
-	hashedNewPassword, err := bcrypt.GenerateFromPassword([]byte(newPassword.NewPassword), bcrypt.DefaultCost)
-	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, errors.Wrap(err, "could not generate hashed password"))
+	principal := principalFromContext(ctx)
+	verifyCurrent := principal == nil || principal.UserID == userID
+	if verifyCurrent && change.CurrentPassword == "" {
+		return &types.FieldError{
+			Err:   errors.Wrap(types.ErrUnprocessableEntity, "current password is required"),
+			Field: "current_password",
+		}
 	}
 
-	// Retrieve the existing password from the database
-	existingPasswordHash, err := ctr.services.User.GetPassword(ctx.Request().Context(), userID)
+	err = ctr.services.User.ChangePassword(ctx.Request().Context(), userID, &change, verifyCurrent)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, errors.Wrap(err, "could not retrieve existing password"))
-	}
-
-	// Compare the existing password hash with the new hashed password (both as strings)
-	if existingPasswordHash != string(hashedNewPassword) {
-		// The passwords do not match, updating the password in the database
-		if err := ctr.services.User.UpdatePassword(ctx.Request().Context(), userID, string(hashedNewPassword)); err != nil {
-			return echo.NewHTTPError(http.StatusInternalServerError, errors.Wrap(err, "Could not update password hash"))
+		switch errors.Cause(err) {
+		case types.ErrNotFound:
+			return echo.NewHTTPError(http.StatusNotFound, err)
+		case types.ErrGone:
+			return echo.NewHTTPError(http.StatusGone, err)
+		case types.ErrConflict:
+			return echo.NewHTTPError(http.StatusConflict, err)
+		case types.ErrForbidden, types.ErrUnprocessableEntity, types.ErrTooManyRequests:
+			// keeps the field or the Retry-After of the error
+			return err
+		default:
+			return echo.NewHTTPError(http.StatusInternalServerError, errors.Wrap(err, "could not change password"))
 		}
-		return ctx.JSON(http.StatusOK, "Password changed successfully")
 	}
 
-	// Passwords match, please choose a new password
-	return echo.NewHTTPError(http.StatusUnauthorized, "Passwords match, please choose a new password")
+	ctr.logger.Debug().Msgf("Changed password of user '%s'", userID.String())
+
+	return ctx.NoContent(http.StatusNoContent)
 }
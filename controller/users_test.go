@@ -6,25 +6,37 @@ import (
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/VikaGo/REST_API/logger"
 	"github.com/VikaGo/REST_API/model"
+	"github.com/VikaGo/REST_API/pkg/patch"
 	"github.com/VikaGo/REST_API/pkg/types"
 	"github.com/VikaGo/REST_API/pkg/validator"
 	"github.com/VikaGo/REST_API/service"
 	"github.com/VikaGo/REST_API/service/mocks"
+	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 )
 
 func TestNewUsers(t *testing.T) {
 	l := logger.Get()
 
-	testUser := &model.User{
+	testUser := &model.UserCreate{
+		Role:      model.RoleViewer,
 		Firstname: "Olexandr",
 		Lastname:  "Topol",
+		Nickname:  "topol",
 	}
+	// the service hashes the password
+	matchUser := mock.MatchedBy(func(user *model.UserCreate) bool {
+		return user.Role == testUser.Role && user.Firstname == testUser.Firstname &&
+			user.Lastname == testUser.Lastname && user.Nickname == testUser.Nickname &&
+			user.Password == "s3cret-pass"
+	})
 	tests := []struct {
 		testName     string
 		expectations func(ctx context.Context, svc *mocks.UserService)
@@ -35,18 +47,24 @@ func TestNewUsers(t *testing.T) {
 		{
 			testName: "valid",
 			expectations: func(ctx context.Context, svc *mocks.UserService) {
-				svc.On("CreateUser", ctx, testUser).Return(testUser, nil)
+				svc.On("CreateUser", ctx, matchUser).Return(&model.User{ID: uuid.New(), Role: testUser.Role}, nil)
 			},
-			input: `{ "firstname": "Olexandr", "lastname": "Topol" }`,
+			input: `{ "firstname": "Olexandr", "lastname": "Topol", "nickname": "topol", "password": "s3cret-pass" }`,
 			code:  http.StatusCreated,
 		},
 		{
 			testName:     "missing parameter",
 			expectations: func(ctx context.Context, svc *mocks.UserService) {},
 			input:        `{}`,
-			err:          errors.New("code=422, message=Key: 'User.Firstname' Error:Field validation for 'Firstname' failed on the 'required' tag, Key: 'User.Lastname' Error:Field validation for 'Lastname' failed on the 'required' tag"),
+			err:          errors.New("code=422, message=Key: 'UserCreate.Firstname' Error:Field validation for 'Firstname' failed on the 'required' tag, Key: 'UserCreate.Lastname' Error:Field validation for 'Lastname' failed on the 'required' tag, Key: 'UserCreate.Nickname' Error:Field validation for 'Nickname' failed on the 'required' tag, Key: 'UserCreate.Password' Error:Field validation for 'Password' failed on the 'required' tag"),
 			code:         http.StatusUnprocessableEntity,
 		},
+		{
+			testName:     "role assignment",
+			expectations: func(ctx context.Context, svc *mocks.UserService) {},
+			input:        `{ "role": "admin", "firstname": "Olexandr", "lastname": "Topol", "nickname": "topol", "password": "s3cret-pass" }`,
+			err:          errors.New("only admins can assign roles: forbidden access"),
+		},
 		{
 			testName:     "bad request",
 			expectations: func(ctx context.Context, svc *mocks.UserService) {},
@@ -57,12 +75,20 @@ func TestNewUsers(t *testing.T) {
 		{
 			testName: "service error",
 			expectations: func(ctx context.Context, svc *mocks.UserService) {
-				svc.On("CreateUser", ctx, testUser).Return(nil, types.ErrBadRequest)
+				svc.On("CreateUser", ctx, matchUser).Return(nil, types.ErrBadRequest)
 			},
-			input: `{ "firstname": "Olexandr", "lastname": "Topol" }`,
+			input: `{ "firstname": "Olexandr", "lastname": "Topol", "nickname": "topol", "password": "s3cret-pass" }`,
 			err:   errors.New("code=400, message=bad request"),
 			code:  http.StatusBadRequest,
 		},
+		{
+			testName: "nickname taken",
+			expectations: func(ctx context.Context, svc *mocks.UserService) {
+				svc.On("CreateUser", ctx, matchUser).Return(nil, errors.Wrap(&types.FieldError{Err: types.ErrDuplicateEntry, Field: "nickname"}, "svc.user.CreateUser error"))
+			},
+			input: `{ "firstname": "Olexandr", "lastname": "Topol", "nickname": "topol", "password": "s3cret-pass" }`,
+			err:   errors.New("svc.user.CreateUser error: nickname: duplicate entry"),
+		},
 	}
 
 	for _, test := range tests {
@@ -84,7 +110,7 @@ func TestNewUsers(t *testing.T) {
 
 		test.expectations(ctx.Request().Context(), svc)
 
-		d := &UserController{ctx.Request().Context(), &service.Manager{User: svc}, l}
+		d := NewUsers(ctx.Request().Context(), &service.Manager{User: svc}, l)
 		err = d.Create(ctx)
 		assert.Equal(t, test.err == nil, err == nil)
 		if err != nil {
@@ -100,3 +126,487 @@ func TestNewUsers(t *testing.T) {
 		svc.AssertExpectations(t)
 	}
 }
+
+func TestPatchUser(t *testing.T) {
+	l := logger.Get()
+
+	current := &model.User{
+		ID:        uuid.MustParse("7a2f922c-073a-11eb-adc1-0242ac120002"),
+		Role:      model.RoleViewer,
+		Firstname: "Olexandr",
+		Lastname:  "Topol",
+		Nickname:  "topol",
+		Version:   3,
+	}
+	firstname := "Oleksandr"
+	admin := model.RoleAdmin
+
+	tests := []struct {
+		testName     string
+		expectations func(ctx context.Context, svc *mocks.UserService)
+		contentType  string
+		ifMatch      string
+		input        string
+		err          error
+		code         int
+	}{
+		{
+			testName: "merge patch",
+			expectations: func(ctx context.Context, svc *mocks.UserService) {
+				svc.On("GetUser", ctx, current.ID).Return(current, nil)
+				svc.On("PatchUser", ctx, current.ID, current.Version, &model.UserPatch{Firstname: &firstname}).Return(current, nil)
+			},
+			contentType: patch.MIMEMergePatch,
+			input:       `{ "firstname": "Oleksandr" }`,
+			code:        http.StatusOK,
+		},
+		{
+			testName: "json patch",
+			expectations: func(ctx context.Context, svc *mocks.UserService) {
+				svc.On("GetUser", ctx, current.ID).Return(current, nil)
+				svc.On("PatchUser", ctx, current.ID, current.Version, &model.UserPatch{Firstname: &firstname}).Return(current, nil)
+			},
+			contentType: patch.MIMEJSONPatch,
+			input:       `[{ "op": "test", "path": "/firstname", "value": "Olexandr" }, { "op": "replace", "path": "/firstname", "value": "Oleksandr" }]`,
+			code:        http.StatusOK,
+		},
+		{
+			testName: "json patch test failed",
+			expectations: func(ctx context.Context, svc *mocks.UserService) {
+				svc.On("GetUser", ctx, current.ID).Return(current, nil)
+			},
+			contentType: patch.MIMEJSONPatch,
+			input:       `[{ "op": "test", "path": "/firstname", "value": "Taras" }]`,
+			code:        http.StatusConflict,
+		},
+		{
+			testName: "password",
+			expectations: func(ctx context.Context, svc *mocks.UserService) {
+				svc.On("GetUser", ctx, current.ID).Return(current, nil)
+			},
+			contentType: patch.MIMEMergePatch,
+			input:       `{ "password": "s3cret-pass" }`,
+			err:         errors.New("password can only be changed through the password endpoint: forbidden access"),
+		},
+		{
+			testName: "removing a required field",
+			expectations: func(ctx context.Context, svc *mocks.UserService) {
+				svc.On("GetUser", ctx, current.ID).Return(current, nil)
+			},
+			contentType: patch.MIMEMergePatch,
+			input:       `{ "lastname": null }`,
+			code:        http.StatusUnprocessableEntity,
+		},
+		{
+			testName: "unknown field",
+			expectations: func(ctx context.Context, svc *mocks.UserService) {
+				svc.On("GetUser", ctx, current.ID).Return(current, nil)
+			},
+			contentType: patch.MIMEMergePatch,
+			input:       `{ "created_at": "2023-10-01T00:00:00Z" }`,
+			code:        http.StatusUnprocessableEntity,
+		},
+		{
+			testName: "role change by owner",
+			expectations: func(ctx context.Context, svc *mocks.UserService) {
+				svc.On("GetUser", ctx, current.ID).Return(current, nil)
+			},
+			contentType: patch.MIMEMergePatch,
+			input:       `{ "role": "` + admin + `" }`,
+			err:         errors.New("only admins can change roles: forbidden access"),
+		},
+		{
+			testName: "matching If-Match",
+			expectations: func(ctx context.Context, svc *mocks.UserService) {
+				svc.On("GetUser", ctx, current.ID).Return(current, nil)
+				svc.On("PatchUser", ctx, current.ID, current.Version, &model.UserPatch{Firstname: &firstname}).Return(current, nil)
+			},
+			contentType: patch.MIMEMergePatch,
+			ifMatch:     `"3"`,
+			input:       `{ "firstname": "Oleksandr" }`,
+			code:        http.StatusOK,
+		},
+		{
+			testName: "stale If-Match",
+			expectations: func(ctx context.Context, svc *mocks.UserService) {
+				svc.On("GetUser", ctx, current.ID).Return(current, nil)
+			},
+			contentType: patch.MIMEMergePatch,
+			ifMatch:     `"2"`,
+			input:       `{ "firstname": "Oleksandr" }`,
+			code:        http.StatusPreconditionFailed,
+		},
+		{
+			testName: "concurrent update",
+			expectations: func(ctx context.Context, svc *mocks.UserService) {
+				svc.On("GetUser", ctx, current.ID).Return(current, nil)
+				svc.On("PatchUser", ctx, current.ID, current.Version, &model.UserPatch{Firstname: &firstname}).Return(nil, types.ErrConflict)
+			},
+			contentType: patch.MIMEMergePatch,
+			input:       `{ "firstname": "Oleksandr" }`,
+			code:        http.StatusConflict,
+		},
+		{
+			testName: "unsupported media type",
+			expectations: func(ctx context.Context, svc *mocks.UserService) {
+				svc.On("GetUser", ctx, current.ID).Return(current, nil)
+			},
+			contentType: "text/plain",
+			input:       `firstname=Oleksandr`,
+			code:        http.StatusUnsupportedMediaType,
+		},
+	}
+
+	for _, test := range tests {
+		t.Logf("running %v", test.testName)
+
+		e := echo.New()
+		e.Validator = validator.NewValidator()
+		r := httptest.NewRequest(echo.PATCH, "/v1/users/"+current.ID.String(), strings.NewReader(test.input))
+		r.Header.Set(echo.HeaderContentType, test.contentType)
+		if test.ifMatch != "" {
+			r.Header.Set("If-Match", test.ifMatch)
+		}
+		w := httptest.NewRecorder()
+		ctx := e.NewContext(r, w)
+		ctx.SetParamNames("id")
+		ctx.SetParamValues(current.ID.String())
+		ctx.Set(ContextUserIDKey, current.ID)
+		ctx.Set(ContextRoleKey, current.Role)
+
+		svc := &mocks.UserService{}
+		test.expectations(ctx.Request().Context(), svc)
+
+		d := NewUsers(ctx.Request().Context(), &service.Manager{User: svc}, l)
+		err := d.Patch(ctx)
+		switch {
+		case test.err != nil:
+			assert.EqualError(t, err, test.err.Error())
+		case test.code == http.StatusOK:
+			assert.NoError(t, err)
+			assert.Equal(t, test.code, w.Code)
+			assert.Equal(t, `"3"`, w.Header().Get("ETag"))
+		default:
+			assert.Equal(t, test.code, types.HTTPCode(err))
+		}
+		svc.AssertExpectations(t)
+	}
+}
+
+func TestUpdateUser(t *testing.T) {
+	l := logger.Get()
+
+	current := &model.User{
+		ID:        uuid.MustParse("7a2f922c-073a-11eb-adc1-0242ac120002"),
+		Role:      model.RoleViewer,
+		Firstname: "Olexandr",
+		Lastname:  "Topol",
+		Nickname:  "topol",
+		Version:   3,
+	}
+	update := &model.UserUpdate{Role: model.RoleViewer, Firstname: "Oleksandr", Lastname: "Topol", Nickname: "topol"}
+
+	tests := []struct {
+		testName     string
+		expectations func(ctx context.Context, svc *mocks.UserService)
+		input        string
+		err          error
+		code         int
+	}{
+		{
+			testName: "valid",
+			expectations: func(ctx context.Context, svc *mocks.UserService) {
+				svc.On("GetUser", ctx, current.ID).Return(current, nil)
+				svc.On("UpdateUser", ctx, current.ID, 0, update).Return(current, nil)
+			},
+			input: `{ "role": "viewer", "firstname": "Oleksandr", "lastname": "Topol", "nickname": "topol" }`,
+			code:  http.StatusOK,
+		},
+		{
+			testName:     "password",
+			expectations: func(ctx context.Context, svc *mocks.UserService) {},
+			input:        `{ "role": "viewer", "firstname": "Oleksandr", "lastname": "Topol", "nickname": "topol", "password": "s3cret-pass" }`,
+			err:          errors.New("password can only be changed through the password endpoint: forbidden access"),
+		},
+		{
+			testName: "role change by owner",
+			expectations: func(ctx context.Context, svc *mocks.UserService) {
+				svc.On("GetUser", ctx, current.ID).Return(current, nil)
+			},
+			input: `{ "role": "admin", "firstname": "Olexandr", "lastname": "Topol", "nickname": "topol" }`,
+			err:   errors.New("only admins can change roles: forbidden access"),
+		},
+		{
+			testName:     "missing parameter",
+			expectations: func(ctx context.Context, svc *mocks.UserService) {},
+			input:        `{ "role": "viewer" }`,
+			code:         http.StatusUnprocessableEntity,
+		},
+		{
+			testName:     "bad request",
+			expectations: func(ctx context.Context, svc *mocks.UserService) {},
+			input:        `{some"}`,
+			code:         http.StatusBadRequest,
+		},
+	}
+
+	for _, test := range tests {
+		t.Logf("running %v", test.testName)
+
+		e := echo.New()
+		e.Validator = validator.NewValidator()
+		r := httptest.NewRequest(echo.PUT, "/v1/users/"+current.ID.String(), strings.NewReader(test.input))
+		r.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		w := httptest.NewRecorder()
+		ctx := e.NewContext(r, w)
+		ctx.SetParamNames("id")
+		ctx.SetParamValues(current.ID.String())
+		ctx.Set(ContextUserIDKey, current.ID)
+		ctx.Set(ContextRoleKey, current.Role)
+
+		svc := &mocks.UserService{}
+		test.expectations(ctx.Request().Context(), svc)
+
+		d := NewUsers(ctx.Request().Context(), &service.Manager{User: svc}, l)
+		err := d.Update(ctx)
+		switch {
+		case test.err != nil:
+			assert.EqualError(t, err, test.err.Error())
+		case test.code == http.StatusOK:
+			assert.NoError(t, err)
+			assert.Equal(t, test.code, w.Code)
+			// the response is the stored user, not the request
+			assert.Contains(t, w.Body.String(), `"firstname":"Olexandr"`)
+			assert.Equal(t, `"3"`, w.Header().Get("ETag"))
+		default:
+			assert.Equal(t, test.code, types.HTTPCode(err))
+		}
+		svc.AssertExpectations(t)
+	}
+}
+
+func TestGetUserETag(t *testing.T) {
+	l := logger.Get()
+
+	user := &model.User{
+		ID:      uuid.MustParse("7a2f922c-073a-11eb-adc1-0242ac120002"),
+		Role:    model.RoleViewer,
+		Version: 5,
+	}
+
+	tests := []struct {
+		testName    string
+		ifNoneMatch string
+		code        int
+	}{
+		{testName: "no condition", code: http.StatusOK},
+		{testName: "current version", ifNoneMatch: `"5"`, code: http.StatusNotModified},
+		{testName: "weak current version", ifNoneMatch: `W/"5"`, code: http.StatusNotModified},
+		{testName: "any version", ifNoneMatch: "*", code: http.StatusNotModified},
+		{testName: "stale version", ifNoneMatch: `"4", "3"`, code: http.StatusOK},
+	}
+
+	for _, test := range tests {
+		t.Logf("running %v", test.testName)
+
+		e := echo.New()
+		r := httptest.NewRequest(echo.GET, "/v1/users/"+user.ID.String(), nil)
+		if test.ifNoneMatch != "" {
+			r.Header.Set("If-None-Match", test.ifNoneMatch)
+		}
+		w := httptest.NewRecorder()
+		ctx := e.NewContext(r, w)
+		ctx.SetParamNames("id")
+		ctx.SetParamValues(user.ID.String())
+		ctx.Set(ContextUserIDKey, user.ID)
+		ctx.Set(ContextRoleKey, user.Role)
+
+		svc := &mocks.UserService{}
+		svc.On("GetUser", ctx.Request().Context(), user.ID).Return(user, nil)
+
+		d := NewUsers(ctx.Request().Context(), &service.Manager{User: svc}, l)
+		assert.NoError(t, d.Get(ctx))
+		assert.Equal(t, test.code, w.Code)
+		assert.Equal(t, `"5"`, w.Header().Get("ETag"))
+		svc.AssertExpectations(t)
+	}
+}
+
+func TestLogIn(t *testing.T) {
+	l := logger.Get()
+	input := `{ "nickname": "topol", "password": "s3cret-pass" }`
+
+	tests := []struct {
+		testName     string
+		err          error
+		code         int
+		cause        error
+		authenticate bool
+	}{
+		{
+			testName: "valid credentials",
+			code:     http.StatusOK,
+		},
+		{
+			testName:     "wrong credentials",
+			err:          errors.Wrap(types.ErrUnauthorized, "incorrect nickname or password"),
+			cause:        types.ErrUnauthorized,
+			authenticate: true,
+		},
+		{
+			testName: "locked out",
+			err:      &types.RetryError{Err: errors.Wrap(types.ErrTooManyRequests, "too many failed logins"), RetryAfter: time.Minute},
+			cause:    types.ErrTooManyRequests,
+		},
+		{
+			testName: "store failure",
+			err:      errors.New("connection refused"),
+			code:     http.StatusInternalServerError,
+		},
+	}
+
+	for _, test := range tests {
+		t.Logf("running %v", test.testName)
+
+		e := echo.New()
+		r := httptest.NewRequest(echo.POST, "/v1/users/login", strings.NewReader(input))
+		r.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		w := httptest.NewRecorder()
+		ctx := e.NewContext(r, w)
+
+		svc := &mocks.UserService{}
+		result := &model.LoginResult{TokenPair: &model.TokenPair{AccessToken: "access", RefreshToken: "refresh", TokenType: "Bearer"}}
+		if test.err != nil {
+			result = nil
+		}
+		svc.On("GenerateToken", ctx.Request().Context(), "topol", "s3cret-pass").Return(result, test.err)
+
+		d := NewUsers(ctx.Request().Context(), &service.Manager{User: svc}, l)
+		err := d.LogIn(ctx)
+		switch {
+		case test.code == http.StatusOK:
+			assert.NoError(t, err)
+			assert.Equal(t, test.code, w.Code)
+			assert.Contains(t, w.Body.String(), `"access_token":"access"`)
+		case test.cause != nil:
+			assert.Equal(t, test.cause, errors.Cause(err))
+		default:
+			assert.Equal(t, test.code, types.HTTPCode(err))
+		}
+		assert.Equal(t, test.authenticate, w.Header().Get(echo.HeaderWWWAuthenticate) != "")
+		svc.AssertExpectations(t)
+	}
+}
+
+func TestChangePassword(t *testing.T) {
+	l := logger.Get()
+	self := uuid.MustParse("7a2f922c-073a-11eb-adc1-0242ac120002")
+	other := uuid.MustParse("9b1deb4d-3b7d-4bad-9bdd-2b0d7b3dcb6d")
+
+	tests := []struct {
+		testName     string
+		role         string
+		target       string
+		body         string
+		expectations func(svc *mocks.UserService)
+		code         int
+		cause        error
+	}{
+		{
+			testName: "own password",
+			role:     model.RoleViewer,
+			target:   self.String(),
+			body:     `{"current_password":"0ld-passw0rd","new_password":"n3w-passw0rd"}`,
+			expectations: func(svc *mocks.UserService) {
+				svc.On("ChangePassword", mock.Anything, self, &model.PasswordChange{CurrentPassword: "0ld-passw0rd", NewPassword: "n3w-passw0rd"}, true).Return(nil)
+			},
+			code: http.StatusNoContent,
+		},
+		{
+			testName:     "own password without current one",
+			role:         model.RoleViewer,
+			target:       self.String(),
+			body:         `{"new_password":"n3w-passw0rd"}`,
+			expectations: func(svc *mocks.UserService) {},
+			cause:        types.ErrUnprocessableEntity,
+		},
+		{
+			testName: "wrong current password",
+			role:     model.RoleViewer,
+			target:   self.String(),
+			body:     `{"current_password":"guess","new_password":"n3w-passw0rd"}`,
+			expectations: func(svc *mocks.UserService) {
+				svc.On("ChangePassword", mock.Anything, self, mock.Anything, true).Return(&types.FieldError{
+					Err:   errors.Wrap(types.ErrForbidden, "current password is incorrect"),
+					Field: "current_password",
+				})
+			},
+			cause: types.ErrForbidden,
+		},
+		{
+			testName: "admin sets password of other",
+			role:     model.RoleAdmin,
+			target:   other.String(),
+			body:     `{"new_password":"n3w-passw0rd"}`,
+			expectations: func(svc *mocks.UserService) {
+				svc.On("ChangePassword", mock.Anything, other, mock.Anything, false).Return(nil)
+			},
+			code: http.StatusNoContent,
+		},
+		{
+			testName: "weak password",
+			role:     model.RoleViewer,
+			target:   self.String(),
+			body:     `{"current_password":"0ld-passw0rd","new_password":"short"}`,
+			expectations: func(svc *mocks.UserService) {
+				svc.On("ChangePassword", mock.Anything, self, mock.Anything, true).Return(&types.ValidationError{
+					Err: errors.Wrap(types.ErrUnprocessableEntity, "password does not follow the policy"),
+					Violations: []types.Violation{
+						{Field: "new_password", Rule: "min_length", Message: "Password must be at least 8 characters long"},
+						{Field: "new_password", Rule: "strength", Message: "Password is too easy to guess"},
+					},
+				})
+			},
+			cause: types.ErrUnprocessableEntity,
+		},
+		{
+			testName:     "malformed id",
+			role:         model.RoleViewer,
+			target:       "someone",
+			body:         `{"current_password":"0ld-passw0rd","new_password":"n3w-passw0rd"}`,
+			expectations: func(svc *mocks.UserService) {},
+			code:         http.StatusBadRequest,
+		},
+	}
+
+	for _, test := range tests {
+		t.Logf("running %v", test.testName)
+
+		e := echo.New()
+		e.Validator = validator.NewValidator()
+		r := httptest.NewRequest(echo.POST, "/v1/users/"+test.target+"/password", strings.NewReader(test.body))
+		r.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		w := httptest.NewRecorder()
+		ctx := e.NewContext(r, w)
+		ctx.SetParamNames("id")
+		ctx.SetParamValues(test.target)
+		ctx.Set(ContextUserIDKey, self)
+		ctx.Set(ContextRoleKey, test.role)
+
+		svc := &mocks.UserService{}
+		test.expectations(svc)
+
+		d := NewUsers(ctx.Request().Context(), &service.Manager{User: svc}, l)
+		err := d.ChangePassword(ctx)
+		switch {
+		case test.cause != nil:
+			assert.Equal(t, test.cause, errors.Cause(err))
+		case test.code < http.StatusBadRequest:
+			assert.NoError(t, err)
+			assert.Equal(t, test.code, w.Code)
+		default:
+			assert.Equal(t, test.code, types.HTTPCode(err))
+		}
+		svc.AssertExpectations(t)
+	}
+}
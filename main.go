@@ -4,6 +4,9 @@ import (
 	"context"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/VikaGo/REST_API/config"
@@ -19,6 +22,10 @@ import (
 	"github.com/pkg/errors"
 )
 
+// shutdownTimeout is how long running requests may take once the process
+// is told to stop
+const shutdownTimeout = 30 * time.Second
+
 func main() {
 	if err := run(); err != nil {
 		log.Fatal(err)
@@ -26,8 +33,43 @@ func main() {
 }
 
 func run() error {
-	ctx := context.Background()
+	// Background work stops once the process is told to stop
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	e, store, err := newServer(ctx)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	// Start server
+	s := &http.Server{
+		ReadTimeout:  30 * time.Minute,
+		WriteTimeout: 30 * time.Minute,
+		Addr:         ":8080",
+	}
+	serverErr := make(chan error, 1)
+	go func() {
+		serverErr <- e.StartServer(s)
+	}()
+
+	select {
+	case err := <-serverErr:
+		return err
+	case <-ctx.Done():
+	}
+
+	// Let running requests finish before the store is closed
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	return e.Shutdown(shutdownCtx)
+}
 
+// newServer wires the store, services and controllers into the routes of
+// an Echo instance. Background work stops once ctx is done; the caller
+// closes the store after the server.
+func newServer(ctx context.Context) (*echo.Echo, *store.Store, error) {
 	// config
 	cfg := config.Get()
 
@@ -37,22 +79,41 @@ func run() error {
 	// Init repository store
 	store, err := store.New(ctx)
 	if err != nil {
-		return errors.Wrap(err, "store.New failed")
+		return nil, nil, errors.Wrap(err, "store.New failed")
 	}
 
 	// Init service manager
 	serviceManager, err := service.NewManager(ctx, store)
 	if err != nil {
-		return errors.Wrap(err, "manager.New failed")
+		store.Close()
+		return nil, nil, errors.Wrap(err, "manager.New failed")
 	}
 
+	// Purge soft-deleted users in the background
+	go service.NewUserPurger(store, cfg.UserRetention, cfg.UserPurgeInterval).Run(ctx)
+
 	// Init controllers
 	userController := controller.NewUsers(ctx, serviceManager, l)
+	sameSite, err := controller.ParseSameSite(cfg.SessionCookieSameSite)
+	if err != nil {
+		store.Close()
+		return nil, nil, err
+	}
+	userController.SetSessionCookies(controller.SessionCookieOptions{Secure: cfg.SessionCookieSecure, SameSite: sameSite})
+	keyController := controller.NewKeys(ctx, serviceManager, l)
+	auditController := controller.NewAudit(ctx, serviceManager, l)
+	roleController := controller.NewRoles(ctx, serviceManager, l)
+	passwordController := controller.NewPasswords(ctx, serviceManager, l)
+	oauthController := controller.NewOAuth(ctx, serviceManager, l)
+	healthController := controller.NewHealth(ctx, serviceManager, l)
 
 	// Initialize Echo instance
 	e := echo.New()
 	e.Validator = validator.NewValidator()
 	e.HTTPErrorHandler = Error.Error
+	// Only trust X-Forwarded-For set by proxies in private networks, the
+	// client IP limits failed logins and is audited
+	e.IPExtractor = echo.ExtractIPFromXFFHeader()
 
 	// Disable Echo JSON logger in debug mode
 	if cfg.LogLevel == "debug" {
@@ -64,24 +125,19 @@ func run() error {
 	// Middleware
 	e.Use(middleware.Logger())
 	e.Use(middleware.Recover())
-
-	// API V1
-	v1 := e.Group("/v1")
-
-	// User routes
-	userRoutes := v1.Group("/users")
-	userRoutes.POST("/login", userController.LogIn)
-	userRoutes.GET("/:id", userController.Get)
-	userRoutes.DELETE("/:id", userController.Delete)
-	userRoutes.PUT("/:id", userController.Update)
-
-	// Start server
-	s := &http.Server{
-		ReadTimeout:  30 * time.Minute,
-		WriteTimeout: 30 * time.Minute,
-		Addr:         ":8080",
-	}
-	e.Logger.Fatal(e.StartServer(s))
-
-	return nil
+	e.Use(middleware.RequestID())
+	e.Use(controller.RequestInfoMiddleware())
+
+	// Routes
+	controller.RegisterRoutes(e, serviceManager, controller.Controllers{
+		Users:     userController,
+		Keys:      keyController,
+		Audit:     auditController,
+		Roles:     roleController,
+		Passwords: passwordController,
+		OAuth:     oauthController,
+		Health:    healthController,
+	})
+
+	return e, store, nil
 }
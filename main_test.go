@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/VikaGo/REST_API/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestMain configures the server to keep its data in a temporary SQLite
+// database, so the tests need no Postgres
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "rest_api")
+	if err != nil {
+		panic(err)
+	}
+	env := map[string]string{
+		"STORE_DRIVER":       "sqlite",
+		"SQLITE_PATH":        filepath.Join(dir, "users.db"),
+		"JWT_SIGNING_KEY_ID": "test",
+		"JWT_SECRETS":        "test:integration-test-secret",
+		"PASSWORD_HASH":      "bcrypt",
+		"BCRYPT_COST":        "4",
+		"LOG_LEVEL":          "error",
+	}
+	for key, value := range env {
+		os.Setenv(key, value)
+	}
+
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// TestServer registers and logs in a user through the whole server
+func TestServer(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	e, store, err := newServer(ctx)
+	require.NoError(t, err)
+	defer store.Close()
+	server := httptest.NewServer(e)
+	defer server.Close()
+
+	var (
+		user   model.User
+		tokens model.LoginResult
+	)
+	newUser := `{"firstname":"Olexandr","lastname":"Topol","nickname":"topol","password":"Zhovten-1991!"}`
+
+	tests := []struct {
+		testName     string
+		method       string
+		path         func() string
+		body         string
+		token        func() string
+		expectedCode int
+		result       interface{}
+	}{
+		{
+			testName:     "ready",
+			method:       http.MethodGet,
+			path:         func() string { return "/ready" },
+			expectedCode: http.StatusOK,
+		},
+		{
+			testName:     "register",
+			method:       http.MethodPost,
+			path:         func() string { return "/v1/users" },
+			body:         newUser,
+			expectedCode: http.StatusCreated,
+			result:       &user,
+		},
+		{
+			testName:     "register a taken nickname",
+			method:       http.MethodPost,
+			path:         func() string { return "/v1/users" },
+			body:         newUser,
+			expectedCode: http.StatusConflict,
+		},
+		{
+			testName:     "log in",
+			method:       http.MethodPost,
+			path:         func() string { return "/v1/users/login" },
+			body:         `{"nickname":"topol","password":"Zhovten-1991!"}`,
+			expectedCode: http.StatusOK,
+			result:       &tokens,
+		},
+		{
+			testName:     "get own user",
+			method:       http.MethodGet,
+			path:         func() string { return "/v1/users/" + user.ID.String() },
+			token:        func() string { return tokens.AccessToken },
+			expectedCode: http.StatusOK,
+			result:       &user,
+		},
+		{
+			testName:     "log in with a wrong password",
+			method:       http.MethodPost,
+			path:         func() string { return "/v1/users/login" },
+			body:         `{"nickname":"topol","password":"Lystopad-1991!"}`,
+			expectedCode: http.StatusUnauthorized,
+		},
+	}
+
+	for _, test := range tests {
+		t.Logf("running %v", test.testName)
+
+		req, err := http.NewRequest(test.method, server.URL+test.path(), bytes.NewBufferString(test.body))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+		if test.token != nil {
+			req.Header.Set("Authorization", "Bearer "+test.token())
+		}
+
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		// the later requests build on the earlier ones
+		require.Equal(t, test.expectedCode, resp.StatusCode)
+		if test.result != nil {
+			assert.NoError(t, json.NewDecoder(resp.Body).Decode(test.result))
+		}
+		resp.Body.Close()
+	}
+
+	assert.Equal(t, "topol", user.Nickname)
+	assert.Equal(t, model.RoleViewer, user.Role)
+}
@@ -0,0 +1,122 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// Audited actions
+const (
+	AuditUserCreate        = "user.create"
+	AuditUserUpdate        = "user.update"
+	AuditUserDelete        = "user.delete"
+	AuditUserRestore       = "user.restore"
+	AuditUserUnlock        = "user.unlock"
+	AuditPasswordChange    = "user.password_change"
+	AuditPasswordForgot    = "user.password_forgot"
+	AuditPasswordReset     = "user.password_reset"
+	AuditLoginSuccess      = "auth.login_success"
+	AuditLoginFailure      = "auth.login_failure"
+	AuditLoginChallenge    = "auth.login_challenge"
+	AuditTwoFactorEnroll   = "user.2fa_enroll"
+	AuditAPIKeyCreate      = "user.api_key_create"
+	AuditAPIKeyRevoke      = "user.api_key_revoke"
+	AuditIdentityLink      = "user.identity_link"
+	AuditSessionRevoke     = "user.session_revoke"
+	AuditRoleUpdate        = "role.update"
+	AuditOAuthClientCreate = "oauth.client_create"
+	AuditOAuthClientDelete = "oauth.client_delete"
+	AuditOAuthAuthorize    = "oauth.authorize"
+)
+
+// RequestInfo describes who sent a request and from where
+type RequestInfo struct {
+	ActorID   *uuid.UUID
+	IP        string
+	UserAgent string
+	RequestID string
+	// SessionID is the cookie session the request was sent with, if any
+	SessionID *uuid.UUID
+}
+
+// AuditEvent is a JSON audit event
+type AuditEvent struct {
+	ID        uuid.UUID  `json:"id"`
+	Action    string     `json:"action"`
+	ActorID   *uuid.UUID `json:"actor_id,omitempty"`
+	TargetID  *uuid.UUID `json:"target_id,omitempty"`
+	Fields    []string   `json:"fields,omitempty"`
+	IP        string     `json:"ip,omitempty"`
+	UserAgent string     `json:"user_agent,omitempty"`
+	RequestID string     `json:"request_id,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// DBAuditEvent is a Postgres audit event. Fields holds the names of the
+// changed fields only, never their values.
+type DBAuditEvent struct {
+	ID        uuid.UUID      `db:"id"`
+	Action    string         `db:"action"`
+	ActorID   *uuid.UUID     `db:"actor_id"`
+	TargetID  *uuid.UUID     `db:"target_id"`
+	Fields    pq.StringArray `db:"fields"`
+	IP        string         `db:"ip"`
+	UserAgent string         `db:"user_agent"`
+	RequestID string         `db:"request_id"`
+	CreatedAt time.Time      `db:"created_at"`
+}
+
+// ToWeb converts DBAuditEvent to AuditEvent
+func (event *DBAuditEvent) ToWeb() *AuditEvent {
+	if event == nil {
+		return nil
+	}
+
+	return &AuditEvent{
+		ID:        event.ID,
+		Action:    event.Action,
+		ActorID:   event.ActorID,
+		TargetID:  event.TargetID,
+		Fields:    event.Fields,
+		IP:        event.IP,
+		UserAgent: event.UserAgent,
+		RequestID: event.RequestID,
+		CreatedAt: event.CreatedAt,
+	}
+}
+
+// AuditListParams are the query parameters of the audit log listing
+type AuditListParams struct {
+	ActorID  uuid.UUID `query:"actor_id"`
+	TargetID uuid.UUID `query:"target_id"`
+	Action   string    `query:"action"`
+	From     time.Time `query:"from"`
+	To       time.Time `query:"to"`
+	Cursor   string    `query:"cursor"`
+	Limit    int       `query:"limit" validate:"omitempty,min=1,max=100"`
+}
+
+// AuditPage is a JSON page of audit events, newest first
+type AuditPage struct {
+	Items      []*AuditEvent `json:"items"`
+	NextCursor string        `json:"next_cursor,omitempty"`
+}
+
+// AuditCursor is the position of the last event of a page
+type AuditCursor struct {
+	CreatedAt time.Time `json:"c"`
+	ID        uuid.UUID `json:"i"`
+}
+
+// AuditQuery is a store query for one page of audit events
+type AuditQuery struct {
+	ActorID  uuid.UUID
+	TargetID uuid.UUID
+	Action   string
+	From     time.Time
+	To       time.Time
+	Before   *AuditCursor
+	Limit    int
+}
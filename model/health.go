@@ -0,0 +1,26 @@
+package model
+
+import "time"
+
+// Health statuses
+const (
+	HealthOK          = "ok"
+	HealthUnavailable = "unavailable"
+)
+
+// Health tells whether the service is ready to serve requests
+type Health struct {
+	Status   string          `json:"status"`
+	Database *DatabaseHealth `json:"database,omitempty"`
+}
+
+// DatabaseHealth is the state of the database connection pool as of its
+// last health check
+type DatabaseHealth struct {
+	Healthy         bool      `json:"healthy"`
+	CheckedAt       time.Time `json:"checked_at"`
+	Failures        int       `json:"failures,omitempty"`
+	OpenConnections int       `json:"open_connections"`
+	InUse           int       `json:"in_use"`
+	Idle            int       `json:"idle"`
+}
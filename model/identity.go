@@ -0,0 +1,34 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DBUserIdentity links a user to an account at an external OpenID Connect
+// provider, identified by the issuer and the subject of its ID tokens
+type DBUserIdentity struct {
+	ID        uuid.UUID `db:"id"`
+	UserID    uuid.UUID `db:"user_id"`
+	Provider  string    `db:"provider"`
+	Issuer    string    `db:"issuer"`
+	Subject   string    `db:"subject"`
+	CreatedAt time.Time `db:"created_at"`
+}
+
+// OIDCLoginRedirect starts a login at an external provider. StateToken
+// binds the callback to the browser that started the login.
+type OIDCLoginRedirect struct {
+	URL        string
+	StateToken string
+	ExpiresIn  int64
+}
+
+// OIDCCallback is the query of a provider redirecting back after a login
+type OIDCCallback struct {
+	Code             string `query:"code"`
+	State            string `query:"state"`
+	Error            string `query:"error"`
+	ErrorDescription string `query:"error_description"`
+}
@@ -0,0 +1,10 @@
+package model
+
+import "time"
+
+// LoginAttempts counts the recent failed logins of an account or a client
+type LoginAttempts struct {
+	Key         string    `db:"attempt_key"`
+	Failures    int       `db:"failures"`
+	LastFailure time.Time `db:"last_failure"`
+}
@@ -0,0 +1,143 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// OAuth scopes. ScopeOpenID asks for an ID token; ScopeProfile and
+// ScopeEmail add the matching user fields to it and to the user info.
+const (
+	ScopeOpenID  = "openid"
+	ScopeProfile = "profile"
+	ScopeEmail   = "email"
+)
+
+// OAuthClientInput registers an OAuth client. Public clients, like single
+// page and mobile apps, cannot keep a secret and get none.
+type OAuthClientInput struct {
+	Name         string   `json:"name" validate:"required,max=100"`
+	RedirectURIs []string `json:"redirect_uris" validate:"required,min=1,dive,url"`
+	Public       bool     `json:"public"`
+}
+
+// OAuthClient is a JSON OAuth client
+type OAuthClient struct {
+	ClientID     string    `json:"client_id"`
+	Name         string    `json:"name"`
+	RedirectURIs []string  `json:"redirect_uris"`
+	Public       bool      `json:"public"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// RegisteredOAuthClient is a new OAuth client along with its secret, which
+// is only shown once
+type RegisteredOAuthClient struct {
+	*OAuthClient
+	ClientSecret string `json:"client_secret,omitempty"`
+}
+
+// DBOAuthClient is a Postgres OAuth client. Only the hash of the secret is
+// stored; public clients have none.
+type DBOAuthClient struct {
+	ID           string         `db:"id"`
+	Name         string         `db:"name"`
+	SecretHash   string         `db:"secret_hash"`
+	RedirectURIs pq.StringArray `db:"redirect_uris"`
+	CreatedAt    time.Time      `db:"created_at"`
+}
+
+// ToWeb converts DBOAuthClient to OAuthClient
+func (client *DBOAuthClient) ToWeb() *OAuthClient {
+	if client == nil {
+		return nil
+	}
+
+	return &OAuthClient{
+		ClientID:     client.ID,
+		Name:         client.Name,
+		RedirectURIs: client.RedirectURIs,
+		Public:       client.SecretHash == "",
+		CreatedAt:    client.CreatedAt,
+	}
+}
+
+// AuthorizeRequest is an OAuth authorization request (RFC 6749 section
+// 4.1.1) with a PKCE challenge (RFC 7636)
+type AuthorizeRequest struct {
+	ResponseType        string `query:"response_type"`
+	ClientID            string `query:"client_id"`
+	RedirectURI         string `query:"redirect_uri"`
+	Scope               string `query:"scope"`
+	State               string `query:"state"`
+	Nonce               string `query:"nonce"`
+	CodeChallenge       string `query:"code_challenge"`
+	CodeChallengeMethod string `query:"code_challenge_method"`
+}
+
+// TokenRequest is an OAuth access token request (RFC 6749 section 4.1.3).
+// Confidential clients may send their credentials with HTTP Basic instead.
+type TokenRequest struct {
+	GrantType    string `form:"grant_type"`
+	Code         string `form:"code"`
+	RedirectURI  string `form:"redirect_uri"`
+	ClientID     string `form:"client_id"`
+	ClientSecret string `form:"client_secret"`
+	CodeVerifier string `form:"code_verifier"`
+}
+
+// OAuthTokenResponse is a JSON OAuth access token response
+type OAuthTokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int64  `json:"expires_in"`
+	Scope       string `json:"scope,omitempty"`
+	IDToken     string `json:"id_token,omitempty"`
+}
+
+// DBAuthorizationCode is a Postgres OAuth authorization code. Only the hash
+// of the code is stored.
+type DBAuthorizationCode struct {
+	ID            uuid.UUID  `db:"id"`
+	CodeHash      string     `db:"code_hash"`
+	ClientID      string     `db:"client_id"`
+	UserID        uuid.UUID  `db:"user_id"`
+	RedirectURI   string     `db:"redirect_uri"`
+	Scope         string     `db:"scope"`
+	Nonce         string     `db:"nonce"`
+	CodeChallenge string     `db:"code_challenge"`
+	ExpiresAt     time.Time  `db:"expires_at"`
+	CreatedAt     time.Time  `db:"created_at"`
+	UsedAt        *time.Time `db:"used_at"`
+}
+
+// UserInfo is the JSON OpenID Connect user info of a user. The fields
+// besides Subject depend on the granted scopes.
+type UserInfo struct {
+	Subject           string `json:"sub"`
+	Name              string `json:"name,omitempty"`
+	GivenName         string `json:"given_name,omitempty"`
+	FamilyName        string `json:"family_name,omitempty"`
+	PreferredUsername string `json:"preferred_username,omitempty"`
+	Email             string `json:"email,omitempty"`
+	UpdatedAt         int64  `json:"updated_at,omitempty"`
+}
+
+// OIDCDiscovery is an OpenID Connect discovery document
+type OIDCDiscovery struct {
+	Issuer                            string   `json:"issuer"`
+	AuthorizationEndpoint             string   `json:"authorization_endpoint"`
+	TokenEndpoint                     string   `json:"token_endpoint"`
+	UserinfoEndpoint                  string   `json:"userinfo_endpoint"`
+	JWKSURI                           string   `json:"jwks_uri"`
+	ResponseTypesSupported            []string `json:"response_types_supported"`
+	GrantTypesSupported               []string `json:"grant_types_supported"`
+	SubjectTypesSupported             []string `json:"subject_types_supported"`
+	IDTokenSigningAlgValuesSupported  []string `json:"id_token_signing_alg_values_supported"`
+	ScopesSupported                   []string `json:"scopes_supported"`
+	TokenEndpointAuthMethodsSupported []string `json:"token_endpoint_auth_methods_supported"`
+	ClaimsSupported                   []string `json:"claims_supported"`
+	CodeChallengeMethodsSupported     []string `json:"code_challenge_methods_supported"`
+}
@@ -0,0 +1,25 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// PasswordChange is a request to change the password of a user.
+// CurrentPassword is required when users change their own password. The
+// refresh token family of RefreshToken, if given, stays logged in.
+type PasswordChange struct {
+	CurrentPassword Secret `json:"current_password"`
+	NewPassword     Secret `json:"new_password" validate:"required"`
+	RefreshToken    string `json:"refresh_token,omitempty"`
+}
+
+// DBPasswordHistory is a previous password hash of a user, kept to refuse
+// its reuse
+type DBPasswordHistory struct {
+	ID           uuid.UUID `db:"id"`
+	UserID       uuid.UUID `db:"user_id"`
+	PasswordHash string    `db:"password_hash"`
+	CreatedAt    time.Time `db:"created_at"`
+}
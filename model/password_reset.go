@@ -0,0 +1,30 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ForgotPasswordInput asks for a password reset of the user with the
+// nickname or email Login
+type ForgotPasswordInput struct {
+	Login string `json:"login" validate:"required"`
+}
+
+// ResetPasswordInput sets a new password with a reset token
+type ResetPasswordInput struct {
+	Token    string `json:"token" validate:"required"`
+	Password Secret `json:"password" validate:"required"`
+}
+
+// DBPasswordResetToken is a Postgres password reset token. Only the hash of
+// the token is stored.
+type DBPasswordResetToken struct {
+	ID        uuid.UUID  `db:"id"`
+	UserID    uuid.UUID  `db:"user_id"`
+	TokenHash string     `db:"token_hash"`
+	ExpiresAt time.Time  `db:"expires_at"`
+	CreatedAt time.Time  `db:"created_at"`
+	UsedAt    *time.Time `db:"used_at"`
+}
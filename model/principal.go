@@ -0,0 +1,16 @@
+package model
+
+import (
+	"github.com/google/uuid"
+)
+
+// Principal is the authenticated caller of a request
+type Principal struct {
+	UserID uuid.UUID
+	Role   string
+	// Scopes limits the permissions of a caller using a scoped API key;
+	// nil means every permission of Role
+	Scopes []string
+	// SessionID is the cookie session the caller authenticated with, if any
+	SessionID *uuid.UUID
+}
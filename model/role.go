@@ -0,0 +1,21 @@
+package model
+
+// Roles a user can have
+const (
+	RoleAdmin  = "admin"
+	RoleEditor = "editor"
+	RoleViewer = "viewer"
+)
+
+// Roles lists every known role
+var Roles = []string{RoleAdmin, RoleEditor, RoleViewer}
+
+// IsValidRole reports whether role is one of the known roles
+func IsValidRole(role string) bool {
+	for _, r := range Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,19 @@
+package model
+
+// redacted replaces secrets wherever they are serialized
+const redacted = "[REDACTED]"
+
+// Secret is a plaintext secret of a request, like a password. It is decoded
+// from JSON as usual but never encoded or printed; use string(secret) to
+// read it.
+type Secret string
+
+// MarshalJSON never emits the secret
+func (Secret) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + redacted + `"`), nil
+}
+
+// String keeps the secret out of logs
+func (Secret) String() string {
+	return redacted
+}
@@ -0,0 +1,65 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Session is a JSON cookie session of a browser
+type Session struct {
+	ID         uuid.UUID `json:"id"`
+	UserID     uuid.UUID `json:"user_id"`
+	Device     string    `json:"device,omitempty"`
+	IP         string    `json:"ip,omitempty"`
+	UserAgent  string    `json:"user_agent,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	// Current marks the session the request was sent with
+	Current bool `json:"current"`
+}
+
+// CreatedSession is returned once, when a login starts a cookie session.
+// Token goes into the HttpOnly session cookie, never into a JSON body; the
+// browser sends CSRFToken back in a header with unsafe requests.
+type CreatedSession struct {
+	ID        uuid.UUID `json:"id"`
+	Token     string    `json:"-"`
+	CSRFToken string    `json:"csrf_token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// DBSession is a Postgres cookie session. Only the hashes of the session
+// and CSRF tokens are stored.
+type DBSession struct {
+	ID         uuid.UUID  `db:"id"`
+	UserID     uuid.UUID  `db:"user_id"`
+	TokenHash  string     `db:"token_hash"`
+	CSRFHash   string     `db:"csrf_hash"`
+	Device     string     `db:"device"`
+	IP         string     `db:"ip"`
+	UserAgent  string     `db:"user_agent"`
+	CreatedAt  time.Time  `db:"created_at"`
+	LastSeenAt time.Time  `db:"last_seen_at"`
+	ExpiresAt  time.Time  `db:"expires_at"`
+	RevokedAt  *time.Time `db:"revoked_at"`
+}
+
+// ToWeb converts DBSession to Session
+func (session *DBSession) ToWeb() *Session {
+	if session == nil {
+		return nil
+	}
+
+	return &Session{
+		ID:         session.ID,
+		UserID:     session.UserID,
+		Device:     session.Device,
+		IP:         session.IP,
+		UserAgent:  session.UserAgent,
+		CreatedAt:  session.CreatedAt,
+		LastSeenAt: session.LastSeenAt,
+		ExpiresAt:  session.ExpiresAt,
+	}
+}
@@ -0,0 +1,51 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// LoginResult is the JSON outcome of a login. Users with two-factor
+// authentication get a challenge token instead of tokens at the password
+// step; the challenge is exchanged together with a code for the tokens.
+type LoginResult struct {
+	*TokenPair
+	ChallengeToken     string `json:"challenge_token,omitempty"`
+	ChallengeExpiresIn int64  `json:"challenge_expires_in,omitempty"`
+	// EnrollmentRequired tells the role of the user requires two-factor
+	// authentication the user did not enroll yet
+	EnrollmentRequired bool `json:"enrollment_required,omitempty"`
+	// RecoveryCodes are returned once, when a login completes an enrollment
+	RecoveryCodes []string `json:"recovery_codes,omitempty"`
+	// Session replaces the tokens of a login asking for a cookie session
+	Session *CreatedSession `json:"session,omitempty"`
+}
+
+// TOTPEnrollment is the JSON shared secret of a TOTP enrollment
+type TOTPEnrollment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"otpauth_uri"`
+}
+
+// RecoveryCodes is the JSON list of single-use recovery codes. They are only
+// shown once, the store keeps their hashes.
+type RecoveryCodes struct {
+	Codes []string `json:"recovery_codes"`
+}
+
+// DBUserTOTP is the Postgres TOTP secret of a user. The enrollment only
+// takes effect once it is confirmed with a code.
+type DBUserTOTP struct {
+	UserID      uuid.UUID  `db:"user_id"`
+	Secret      string     `db:"secret"`
+	LastStep    int64      `db:"last_step"`
+	CreatedAt   time.Time  `db:"created_at"`
+	ConfirmedAt *time.Time `db:"confirmed_at"`
+}
+
+// RoleSettings is a JSON/Postgres set of policies applying to every user of a role
+type RoleSettings struct {
+	Role       string `json:"role" db:"role"`
+	Require2FA bool   `json:"require_2fa" db:"require_2fa"`
+}
@@ -6,47 +6,82 @@ import (
 	"github.com/google/uuid"
 )
 
-// User is a JSON user
+// User is the JSON user of responses. It has no secret fields, so the
+// password hash can never be emitted.
 type User struct {
-	ID        uuid.UUID `json:"id"`
-	Role      string    `json:"role" validate:"required"`
-	Firstname string    `json:"firstname" validate:"required"`
-	Lastname  string    `json:"lastname" validate:"required"`
-	Nickname  string    `json:"nickname" validate:"required"`
-	Password  string    `json:"password" validate:"required"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
-	DeletedAt time.Time `json:"deleted_at"`
+	ID        uuid.UUID  `json:"id"`
+	Role      string     `json:"role"`
+	Firstname string     `json:"firstname"`
+	Lastname  string     `json:"lastname"`
+	Nickname  string     `json:"nickname"`
+	Email     string     `json:"email,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+	Version   int        `json:"-"`
 }
 
-// ToDB converts User to DBUser
-func (user *User) ToDB() *DBUser {
+// UserCreate is a JSON request to create a user
+type UserCreate struct {
+	Role      string `json:"role" validate:"required,oneof=admin editor viewer"`
+	Firstname string `json:"firstname" validate:"required"`
+	Lastname  string `json:"lastname" validate:"required"`
+	Nickname  string `json:"nickname" validate:"required"`
+	Email     string `json:"email,omitempty" validate:"omitempty,email"`
+	Password  Secret `json:"password" validate:"required"`
+}
+
+// ToDB converts UserCreate to DBUser, without the password which must be
+// hashed first
+func (user *UserCreate) ToDB() *DBUser {
 
 	if user == nil {
 		return nil
 	}
 
 	return &DBUser{
-		ID:        user.ID,
 		Role:      user.Role,
 		Firstname: user.Firstname,
 		Lastname:  user.Lastname,
 		Nickname:  user.Nickname,
-		Password:  user.Password,
+		Email:     user.Email,
 	}
 }
 
+// UserUpdate is a JSON request to replace the fields of a user. Passwords
+// are changed through PasswordChange.
+type UserUpdate struct {
+	Role      string `json:"role" validate:"required,oneof=admin editor viewer"`
+	Firstname string `json:"firstname" validate:"required"`
+	Lastname  string `json:"lastname" validate:"required"`
+	Nickname  string `json:"nickname" validate:"required"`
+	Email     string `json:"email,omitempty" validate:"omitempty,email"`
+}
+
+// Apply returns current with the fields of update
+func (update *UserUpdate) Apply(current *DBUser) *DBUser {
+	updated := *current
+	updated.Role = update.Role
+	updated.Firstname = update.Firstname
+	updated.Lastname = update.Lastname
+	updated.Nickname = update.Nickname
+	updated.Email = update.Email
+	return &updated
+}
+
 // DBUser is a Postgres user
 type DBUser struct {
-	ID        uuid.UUID `db:"id"`
-	Role      string    `db:"role"`
-	Firstname string    `db:"firstname"`
-	Lastname  string    `db:"lastname"`
-	Nickname  string    `db:"nickname"`
-	Password  string    `db:"password"`
-	CreatedAt time.Time `db:"created_at"`
-	UpdatedAt time.Time `db:"updated_at"`
-	DeletedAt time.Time `db:"deleted_at"`
+	ID        uuid.UUID  `db:"id"`
+	Role      string     `db:"role"`
+	Firstname string     `db:"firstname"`
+	Lastname  string     `db:"lastname"`
+	Nickname  string     `db:"nickname"`
+	Email     string     `db:"email"`
+	Password  string     `db:"password" json:"-"`
+	CreatedAt time.Time  `db:"created_at"`
+	UpdatedAt time.Time  `db:"updated_at"`
+	DeletedAt *time.Time `db:"deleted_at"`
+	Version   int        `db:"version"`
 }
 
 // ToWeb converts DBUser to User
@@ -62,9 +97,35 @@ func (dbUser *DBUser) ToWeb() *User {
 		Firstname: dbUser.Firstname,
 		Lastname:  dbUser.Lastname,
 		Nickname:  dbUser.Nickname,
-		Password:  dbUser.Password,
+		Email:     dbUser.Email,
 		CreatedAt: dbUser.CreatedAt,
 		UpdatedAt: dbUser.UpdatedAt,
 		DeletedAt: dbUser.DeletedAt,
+		Version:   dbUser.Version,
+	}
+}
+
+// ChangedFields returns the names of the fields that differ between dbUser
+// and updated. Only names are returned, so secrets never leak through them.
+func (dbUser *DBUser) ChangedFields(updated *DBUser) []string {
+	var fields []string
+	if dbUser.Role != updated.Role {
+		fields = append(fields, "role")
+	}
+	if dbUser.Firstname != updated.Firstname {
+		fields = append(fields, "firstname")
+	}
+	if dbUser.Lastname != updated.Lastname {
+		fields = append(fields, "lastname")
+	}
+	if dbUser.Nickname != updated.Nickname {
+		fields = append(fields, "nickname")
+	}
+	if dbUser.Email != updated.Email {
+		fields = append(fields, "email")
+	}
+	if dbUser.Password != updated.Password {
+		fields = append(fields, "password")
 	}
+	return fields
 }
@@ -0,0 +1,61 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Sort orders of the user listing
+const (
+	UserSortName      = "name"
+	UserSortCreatedAt = "created_at"
+)
+
+// UserListParams are the JSON/query parameters of the user listing
+type UserListParams struct {
+	Role           string    `query:"role" validate:"omitempty,oneof=admin editor viewer"`
+	NicknamePrefix string    `query:"nickname_prefix"`
+	CreatedFrom    time.Time `query:"created_from"`
+	CreatedTo      time.Time `query:"created_to"`
+	Sort           string    `query:"sort" validate:"omitempty,oneof=name -name created_at -created_at"`
+	Cursor         string    `query:"cursor"`
+	Limit          int       `query:"limit" validate:"omitempty,min=1,max=100"`
+	IncludeTotal   bool      `query:"include_total"`
+	IncludeDeleted bool      `query:"include_deleted"`
+}
+
+// UserPage is a JSON page of users
+type UserPage struct {
+	Items      []*User `json:"items"`
+	NextCursor string  `json:"next_cursor,omitempty"`
+	Total      *int    `json:"total,omitempty"`
+}
+
+// UserFilter selects the users to list
+type UserFilter struct {
+	Role           string
+	NicknamePrefix string
+	CreatedFrom    time.Time
+	CreatedTo      time.Time
+	IncludeDeleted bool
+}
+
+// UserCursor is the position of the last user of a page in the listing order
+type UserCursor struct {
+	Sort      string    `json:"s"`
+	Desc      bool      `json:"d,omitempty"`
+	Lastname  string    `json:"l,omitempty"`
+	Firstname string    `json:"f,omitempty"`
+	CreatedAt time.Time `json:"c,omitempty"`
+	ID        uuid.UUID `json:"i"`
+}
+
+// UserListQuery is a store query for one page of users
+type UserListQuery struct {
+	UserFilter
+	Sort  string
+	Desc  bool
+	After *UserCursor
+	Limit int
+}
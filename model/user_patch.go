@@ -0,0 +1,73 @@
+package model
+
+// UserPatch is the JSON document of the user fields PATCH may change. After
+// a patch is applied every field must still be present.
+type UserPatch struct {
+	Role      *string `json:"role,omitempty" validate:"required,oneof=admin editor viewer"`
+	Firstname *string `json:"firstname,omitempty" validate:"required,min=1"`
+	Lastname  *string `json:"lastname,omitempty" validate:"required,min=1"`
+	Nickname  *string `json:"nickname,omitempty" validate:"required,min=1"`
+	Email     *string `json:"email,omitempty" validate:"omitempty,email"`
+}
+
+// ToPatch returns the patchable fields of User
+func (user *User) ToPatch() *UserPatch {
+	return &UserPatch{
+		Role:      stringPtr(user.Role),
+		Firstname: stringPtr(user.Firstname),
+		Lastname:  stringPtr(user.Lastname),
+		Nickname:  stringPtr(user.Nickname),
+		Email:     optionalStringPtr(user.Email),
+	}
+}
+
+// Changes returns the fields of patch that differ from user
+func (patch *UserPatch) Changes(user *User) *UserPatch {
+	return &UserPatch{
+		Role:      changed(patch.Role, user.Role),
+		Firstname: changed(patch.Firstname, user.Firstname),
+		Lastname:  changed(patch.Lastname, user.Lastname),
+		Nickname:  changed(patch.Nickname, user.Nickname),
+		Email:     changed(patch.Email, user.Email),
+	}
+}
+
+// ToDB converts UserPatch to the Postgres columns to update
+func (patch *UserPatch) ToDB() map[string]interface{} {
+	columns := map[string]interface{}{}
+	if patch.Role != nil {
+		columns["role"] = *patch.Role
+	}
+	if patch.Firstname != nil {
+		columns["firstname"] = *patch.Firstname
+	}
+	if patch.Lastname != nil {
+		columns["lastname"] = *patch.Lastname
+	}
+	if patch.Nickname != nil {
+		columns["nickname"] = *patch.Nickname
+	}
+	if patch.Email != nil {
+		columns["email"] = *patch.Email
+	}
+	return columns
+}
+
+func stringPtr(s string) *string {
+	return &s
+}
+
+// optionalStringPtr leaves an unset optional field out of the patch document
+func optionalStringPtr(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+func changed(value *string, current string) *string {
+	if value == nil || *value == current {
+		return nil
+	}
+	return value
+}
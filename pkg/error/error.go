@@ -2,10 +2,13 @@ package error
 
 import (
 	"fmt"
+	"math"
 	"net/http"
+	"strconv"
 
 	"github.com/VikaGo/REST_API/pkg/types"
 	"github.com/labstack/echo/v4"
+	"github.com/pkg/errors"
 )
 
 type HTTPError struct {
@@ -13,6 +16,15 @@ type HTTPError struct {
 	Name    string `json:"name"`
 	Message string `json:"message"`
 	Cause   string `json:"cause,omitempty"`
+	Field   string `json:"field,omitempty"`
+	// Violations lists every rule the input breaks
+	Violations []types.Violation `json:"violations,omitempty"`
+}
+
+// OAuthError is the error body of the OAuth endpoints (RFC 6749 section 5.2)
+type OAuthError struct {
+	Error       string `json:"error"`
+	Description string `json:"error_description,omitempty"`
 }
 
 func Error(err error, ctx echo.Context) {
@@ -20,7 +32,7 @@ func Error(err error, ctx echo.Context) {
 		Code:    http.StatusInternalServerError,
 		Message: err.Error(),
 	}
-	switch err {
+	switch errors.Cause(err) {
 	case types.ErrBadRequest:
 		errObj.Code = http.StatusBadRequest
 	case types.ErrNotFound:
@@ -37,6 +49,30 @@ func Error(err error, ctx echo.Context) {
 		errObj.Code = http.StatusGone
 	case types.ErrUnauthorized:
 		errObj.Code = http.StatusUnauthorized
+	case types.ErrTooManyRequests:
+		errObj.Code = http.StatusTooManyRequests
+	}
+	var fieldErr *types.FieldError
+	if errors.As(err, &fieldErr) {
+		errObj.Field = fieldErr.Field
+	}
+	var validationErr *types.ValidationError
+	if errors.As(err, &validationErr) {
+		errObj.Violations = validationErr.Violations
+	}
+	var retryErr *types.RetryError
+	if errors.As(err, &retryErr) {
+		// round up, a client retrying early would be rejected again
+		seconds := int64(math.Ceil(retryErr.RetryAfter.Seconds()))
+		ctx.Response().Header().Set(echo.HeaderRetryAfter, strconv.FormatInt(seconds, 10))
+	}
+	var oauthErr *types.OAuthError
+	if errors.As(err, &oauthErr) {
+		// OAuth clients only understand the error format of RFC 6749
+		if !ctx.Response().Committed {
+			ctx.JSON(errObj.Code, OAuthError{Error: oauthErr.Code, Description: oauthErr.Description})
+		}
+		return
 	}
 	he, ok := err.(*echo.HTTPError)
 	if ok {
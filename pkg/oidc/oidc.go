@@ -0,0 +1,378 @@
+package oidc
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/pkg/errors"
+)
+
+// ProviderConfig describes an external OpenID Connect identity provider
+type ProviderConfig struct {
+	// Name identifies the provider in the login URLs
+	Name         string   `json:"name"`
+	Issuer       string   `json:"issuer"`
+	ClientID     string   `json:"client_id"`
+	ClientSecret string   `json:"client_secret"`
+	RedirectURL  string   `json:"redirect_url"`
+	Scopes       []string `json:"scopes"`
+	// NicknameClaim names the claim new users get their nickname from,
+	// "preferred_username" by default
+	NicknameClaim string `json:"nickname_claim"`
+	// RoleClaim names the claim, a string or a list, RoleMapping maps to
+	// roles. Users matching no mapping get DefaultRole.
+	RoleClaim   string            `json:"role_claim"`
+	RoleMapping map[string]string `json:"role_mapping"`
+	DefaultRole string            `json:"default_role"`
+	// AutoProvision creates users logging in for the first time
+	AutoProvision bool `json:"auto_provision"`
+	// LinkByNickname links a first login to the existing user with the
+	// same nickname. Only enable it for providers that own the nicknames.
+	LinkByNickname bool `json:"link_by_nickname"`
+}
+
+// LoadProviders reads the provider configs from a JSON file holding a list
+func LoadProviders(path string) ([]ProviderConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not read OIDC providers")
+	}
+	var configs []ProviderConfig
+	if err := json.Unmarshal(data, &configs); err != nil {
+		return nil, errors.Wrap(err, "could not parse OIDC providers")
+	}
+	for _, cfg := range configs {
+		if cfg.Name == "" || cfg.Issuer == "" || cfg.ClientID == "" || cfg.RedirectURL == "" {
+			return nil, errors.Errorf("OIDC provider '%s' needs a name, issuer, client_id and redirect_url", cfg.Name)
+		}
+	}
+	return configs, nil
+}
+
+// Metadata is the part of the discovery document of a provider used here
+type Metadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// Claims are the claims of a verified ID token
+type Claims jwt.MapClaims
+
+// String returns the string claim name, or ""
+func (c Claims) String(name string) string {
+	s, _ := c[name].(string)
+	return s
+}
+
+// Strings returns the claim name as a list, whether it is a string or a list
+func (c Claims) Strings(name string) []string {
+	switch value := c[name].(type) {
+	case string:
+		return []string{value}
+	case []interface{}:
+		var values []string
+		for _, v := range value {
+			if s, ok := v.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	default:
+		return nil
+	}
+}
+
+// keysRefreshInterval bounds how often an unknown "kid" refetches the keys
+const keysRefreshInterval = time.Minute
+
+// Provider is the relying party side of the authorization code flow with
+// one provider. The discovery document and the keys are fetched on first
+// use, so a provider being down does not keep the service from starting.
+type Provider struct {
+	cfg    ProviderConfig
+	client *http.Client
+
+	mu          sync.Mutex
+	metadata    *Metadata
+	keys        map[string]interface{}
+	keysFetched time.Time
+}
+
+// NewProvider creates a provider talking to the identity provider with client
+func NewProvider(cfg ProviderConfig, client *http.Client) *Provider {
+	if cfg.NicknameClaim == "" {
+		cfg.NicknameClaim = "preferred_username"
+	}
+	if len(cfg.Scopes) == 0 {
+		cfg.Scopes = []string{"openid", "profile", "email"}
+	}
+	return &Provider{cfg: cfg, client: client}
+}
+
+// Config returns the config of the provider
+func (p *Provider) Config() ProviderConfig {
+	return p.cfg
+}
+
+// AuthCodeURL returns where to send the user to log in at the provider
+func (p *Provider) AuthCodeURL(ctx context.Context, state, nonce, codeChallenge string) (string, error) {
+	metadata, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+	u, err := url.Parse(metadata.AuthorizationEndpoint)
+	if err != nil {
+		return "", errors.Wrap(err, "invalid authorization endpoint")
+	}
+	query := u.Query()
+	query.Set("response_type", "code")
+	query.Set("client_id", p.cfg.ClientID)
+	query.Set("redirect_uri", p.cfg.RedirectURL)
+	query.Set("scope", strings.Join(p.cfg.Scopes, " "))
+	query.Set("state", state)
+	query.Set("nonce", nonce)
+	query.Set("code_challenge", codeChallenge)
+	query.Set("code_challenge_method", "S256")
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
+
+// Exchange redeems an authorization code and returns the verified claims
+// of the ID token. nonce must match the one of the authorization request.
+func (p *Provider) Exchange(ctx context.Context, code, codeVerifier, nonce string) (Claims, error) {
+	metadata, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.cfg.RedirectURL},
+		"client_id":     {p.cfg.ClientID},
+		"client_secret": {p.cfg.ClientSecret},
+		"code_verifier": {codeVerifier},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, metadata.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, errors.Wrap(err, "could not create token request")
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	var response struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := p.do(req, &response); err != nil {
+		if response.Error != "" {
+			return nil, errors.Errorf("token request failed: %s %s", response.Error, response.ErrorDescription)
+		}
+		return nil, errors.Wrap(err, "token request failed")
+	}
+	if response.IDToken == "" {
+		return nil, errors.New("token response has no ID token")
+	}
+	return p.verify(ctx, response.IDToken, nonce)
+}
+
+// verify checks the signature, issuer, audience, expiry and nonce of an ID token
+func (p *Provider) verify(ctx context.Context, rawIDToken, nonce string) (Claims, error) {
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(rawIDToken, claims, func(token *jwt.Token) (interface{}, error) {
+		// the provider publishes asymmetric keys only, never accept HS256 or none
+		switch token.Method.(type) {
+		case *jwt.SigningMethodRSA, *jwt.SigningMethodECDSA:
+		default:
+			return nil, errors.Errorf("unexpected signing method '%s'", token.Method.Alg())
+		}
+		kid, _ := token.Header["kid"].(string)
+		return p.key(ctx, kid)
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid ID token")
+	}
+	if !claims.VerifyIssuer(p.cfg.Issuer, true) {
+		return nil, errors.New("ID token issued by another issuer")
+	}
+	if !claims.VerifyAudience(p.cfg.ClientID, true) {
+		return nil, errors.New("ID token issued to another client")
+	}
+	if _, ok := claims["exp"]; !ok {
+		return nil, errors.New("ID token does not expire")
+	}
+	if got, _ := claims["nonce"].(string); got != nonce {
+		return nil, errors.New("ID token nonce does not match")
+	}
+	if sub, _ := claims["sub"].(string); sub == "" {
+		return nil, errors.New("ID token has no subject")
+	}
+	return Claims(claims), nil
+}
+
+func (p *Provider) discover(ctx context.Context) (*Metadata, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.metadata != nil {
+		return p.metadata, nil
+	}
+
+	wellKnown := strings.TrimSuffix(p.cfg.Issuer, "/") + "/.well-known/openid-configuration"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, wellKnown, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not create discovery request")
+	}
+	metadata := &Metadata{}
+	if err := p.do(req, metadata); err != nil {
+		return nil, errors.Wrap(err, "discovery failed")
+	}
+	// OpenID Connect Discovery section 4.3
+	if metadata.Issuer != p.cfg.Issuer {
+		return nil, errors.Errorf("discovery document of '%s' names issuer '%s'", p.cfg.Issuer, metadata.Issuer)
+	}
+	p.metadata = metadata
+	return metadata, nil
+}
+
+// key returns the verification key kid. Unknown keys refetch the key set,
+// as the provider may have rotated its keys.
+func (p *Provider) key(ctx context.Context, kid string) (interface{}, error) {
+	metadata, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if key, ok := p.keys[kid]; ok {
+		return key, nil
+	}
+	if time.Since(p.keysFetched) < keysRefreshInterval {
+		return nil, errors.Errorf("unknown key '%s'", kid)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, metadata.JWKSURI, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not create JWKS request")
+	}
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := p.do(req, &set); err != nil {
+		return nil, errors.Wrap(err, "could not fetch JWKS")
+	}
+	keys := map[string]interface{}{}
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		if key, err := k.publicKey(); err == nil {
+			keys[k.Kid] = key
+		}
+	}
+	p.keys = keys
+	p.keysFetched = time.Now()
+
+	if key, ok := keys[kid]; ok {
+		return key, nil
+	}
+	// a single key may be used without "kid"
+	if kid == "" && len(keys) == 1 {
+		for _, key := range keys {
+			return key, nil
+		}
+	}
+	return nil, errors.Errorf("unknown key '%s'", kid)
+}
+
+// maxResponseSize bounds what is read from a provider
+const maxResponseSize = 1 << 20
+
+// do sends req and decodes the JSON response into v, also on errors
+func (p *Provider) do(req *http.Request, v interface{}) error {
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return err
+	}
+	decodeErr := json.Unmarshal(body, v)
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return decodeErr
+}
+
+// jwk is a JSON Web Key of a provider
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func (k *jwk) publicKey() (interface{}, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		if k.Crv != "P-256" {
+			return nil, errors.Errorf("unsupported curve '%s'", k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !elliptic.P256().IsOnCurve(x, y) {
+			return nil, errors.New("EC key is not on its curve")
+		}
+		return &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}, nil
+	default:
+		return nil, errors.Errorf("unsupported key type '%s'", k.Kty)
+	}
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid JWK number")
+	}
+	return new(big.Int).SetBytes(b), nil
+}
@@ -0,0 +1,60 @@
+package patch
+
+import (
+	"encoding/json"
+	"mime"
+
+	jsonpatch "github.com/evanphx/json-patch/v5"
+	"github.com/pkg/errors"
+)
+
+// Media types of the supported patch formats
+const (
+	MIMEMergePatch = "application/merge-patch+json"
+	MIMEJSONPatch  = "application/json-patch+json"
+)
+
+// Errors returned by Apply
+var (
+	ErrUnsupportedMediaType = errors.New("unsupported patch media type")
+	ErrMalformedPatch       = errors.New("malformed patch document")
+	ErrTestFailed           = errors.New("patch test operation failed")
+	ErrCannotApply          = errors.New("patch cannot be applied")
+)
+
+// Apply applies a JSON Merge Patch (RFC 7396) or a JSON Patch (RFC 6902)
+// to the JSON document doc. The format is picked from the content type;
+// plain "application/json" is treated as a merge patch.
+func Apply(contentType string, doc, patch []byte) ([]byte, error) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, errors.Wrap(ErrUnsupportedMediaType, err.Error())
+	}
+
+	switch mediaType {
+	case MIMEMergePatch, "application/json":
+		if !json.Valid(patch) {
+			return nil, ErrMalformedPatch
+		}
+		patched, err := jsonpatch.MergePatch(doc, patch)
+		if err != nil {
+			return nil, errors.Wrap(ErrMalformedPatch, err.Error())
+		}
+		return patched, nil
+	case MIMEJSONPatch:
+		operations, err := jsonpatch.DecodePatch(patch)
+		if err != nil {
+			return nil, errors.Wrap(ErrMalformedPatch, err.Error())
+		}
+		patched, err := operations.Apply(doc)
+		if err != nil {
+			if errors.Is(err, jsonpatch.ErrTestFailed) {
+				return nil, errors.Wrap(ErrTestFailed, err.Error())
+			}
+			return nil, errors.Wrap(ErrCannotApply, err.Error())
+		}
+		return patched, nil
+	default:
+		return nil, errors.Wrapf(ErrUnsupportedMediaType, "'%s'", mediaType)
+	}
+}
@@ -2,6 +2,9 @@ package types
 
 import (
 	"errors"
+	"fmt"
+	"strings"
+	"time"
 
 	"github.com/labstack/echo/v4"
 )
@@ -20,8 +23,103 @@ var (
 	ErrNotAllowed          = errors.New("operation not allowed")
 	ErrBusy                = errors.New("resource is busy")
 	ErrUnauthorized        = errors.New("unauthorized")
+	ErrTooManyRequests     = errors.New("too many requests")
 )
 
+// FieldError is a domain error caused by a single field of the input
+type FieldError struct {
+	Err   error
+	Field string
+}
+
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Field, e.Err.Error())
+}
+
+// Cause returns the domain error, for github.com/pkg/errors
+func (e *FieldError) Cause() error {
+	return e.Err
+}
+
+// Unwrap returns the domain error, for the standard errors package
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// RetryError is a domain error that goes away after RetryAfter
+type RetryError struct {
+	Err        error
+	RetryAfter time.Duration
+}
+
+func (e *RetryError) Error() string {
+	return fmt.Sprintf("retry in %s: %s", e.RetryAfter.Round(time.Second), e.Err.Error())
+}
+
+// Cause returns the domain error, for github.com/pkg/errors
+func (e *RetryError) Cause() error {
+	return e.Err
+}
+
+// Unwrap returns the domain error, for the standard errors package
+func (e *RetryError) Unwrap() error {
+	return e.Err
+}
+
+// Violation is one rule an input breaks
+type Violation struct {
+	Field   string `json:"field,omitempty"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// ValidationError is a domain error listing every rule an input breaks, so
+// clients can show them all at once
+type ValidationError struct {
+	Err        error
+	Violations []Violation
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, len(e.Violations))
+	for i, violation := range e.Violations {
+		messages[i] = violation.Message
+	}
+	return fmt.Sprintf("%s: %s", e.Err.Error(), strings.Join(messages, ", "))
+}
+
+// Cause returns the domain error, for github.com/pkg/errors
+func (e *ValidationError) Cause() error {
+	return e.Err
+}
+
+// Unwrap returns the domain error, for the standard errors package
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+// OAuthError is a domain error of the OAuth endpoints, which report it with
+// an OAuth error code (RFC 6749 section 5.2) instead of the usual body
+type OAuthError struct {
+	Err         error
+	Code        string
+	Description string
+}
+
+func (e *OAuthError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Code, e.Err.Error())
+}
+
+// Cause returns the domain error, for github.com/pkg/errors
+func (e *OAuthError) Cause() error {
+	return e.Err
+}
+
+// Unwrap returns the domain error, for the standard errors package
+func (e *OAuthError) Unwrap() error {
+	return e.Err
+}
+
 // HTTPError is our custom HTTP error to get a proper string output.
 type HTTPError struct {
 	Code    int
@@ -0,0 +1,198 @@
+package validator
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/VikaGo/REST_API/config"
+	"github.com/VikaGo/REST_API/pkg/types"
+	"github.com/pkg/errors"
+)
+
+// Rules of the password policy, as reported in violations
+const (
+	RuleMinLength    = "min_length"
+	RuleMaxLength    = "max_length"
+	RuleUpper        = "uppercase"
+	RuleLower        = "lowercase"
+	RuleDigit        = "digit"
+	RuleSpecial      = "special"
+	RulePersonalInfo = "personal_info"
+	RuleStrength     = "strength"
+	RuleBreached     = "breached"
+)
+
+// PasswordPolicy is the set of rules new passwords must follow
+type PasswordPolicy struct {
+	// MinLength and MaxLength count characters, a zero MaxLength is unlimited
+	MinLength      int
+	MaxLength      int
+	RequireUpper   bool
+	RequireLower   bool
+	RequireDigit   bool
+	RequireSpecial bool
+	// MinScore is the lowest accepted Score
+	MinScore int
+	// Breached lists leaked passwords, nil skips the check
+	Breached *BreachedPasswords
+}
+
+// DefaultPasswordPolicy returns the policy used when none is configured
+func DefaultPasswordPolicy() *PasswordPolicy {
+	return &PasswordPolicy{
+		MinLength:      8,
+		MaxLength:      72,
+		RequireDigit:   true,
+		RequireSpecial: true,
+		MinScore:       ScoreSomewhatGuessable,
+	}
+}
+
+// NewPasswordPolicy creates the password policy of cfg
+func NewPasswordPolicy(cfg *config.Config) *PasswordPolicy {
+	policy := &PasswordPolicy{
+		MinLength:      cfg.PasswordMinLength,
+		MaxLength:      cfg.PasswordMaxLength,
+		RequireUpper:   cfg.PasswordRequireUpper,
+		RequireLower:   cfg.PasswordRequireLower,
+		RequireDigit:   cfg.PasswordRequireDigit,
+		RequireSpecial: cfg.PasswordRequireSpecial,
+		MinScore:       cfg.PasswordMinScore,
+	}
+	if cfg.PasswordBreachedDir != "" {
+		policy.Breached = NewBreachedPasswords(cfg.PasswordBreachedDir)
+	}
+	return policy
+}
+
+// Check returns every rule password breaks. userInputs, like the nickname
+// and the names of the user, must not be part of the password.
+func (p *PasswordPolicy) Check(password string, userInputs ...string) ([]types.Violation, error) {
+	var violations []types.Violation
+	violate := func(rule, message string) {
+		violations = append(violations, types.Violation{Rule: rule, Message: message})
+	}
+
+	length := utf8.RuneCountInString(password)
+	if length < p.MinLength {
+		violate(RuleMinLength, fmt.Sprintf("Password must be at least %d characters long", p.MinLength))
+	}
+	if p.MaxLength > 0 && length > p.MaxLength {
+		violate(RuleMaxLength, fmt.Sprintf("Password must be at most %d characters long", p.MaxLength))
+	}
+
+	var hasUpper, hasLower, hasDigit, hasSpecial bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			hasUpper = true
+		case unicode.IsLower(r):
+			hasLower = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		case !unicode.IsSpace(r):
+			hasSpecial = true
+		}
+	}
+	if p.RequireUpper && !hasUpper {
+		violate(RuleUpper, "Password must include at least one uppercase letter")
+	}
+	if p.RequireLower && !hasLower {
+		violate(RuleLower, "Password must include at least one lowercase letter")
+	}
+	if p.RequireDigit && !hasDigit {
+		violate(RuleDigit, "Password must include at least one figure")
+	}
+	if p.RequireSpecial && !hasSpecial {
+		violate(RuleSpecial, "Password must include at least one special character")
+	}
+
+	lower := strings.ToLower(password)
+	for _, input := range userInputs {
+		if utf8.RuneCountInString(input) >= minPatternLength && strings.Contains(lower, strings.ToLower(input)) {
+			violate(RulePersonalInfo, "Password must not contain your nickname or name")
+			break
+		}
+	}
+
+	if p.MinScore > 0 && Score(password, userInputs...) < p.MinScore {
+		violate(RuleStrength, "Password is too easy to guess")
+	}
+
+	if p.Breached != nil {
+		breached, err := p.Breached.Contains(password)
+		if err != nil {
+			return nil, err
+		}
+		if breached {
+			violate(RuleBreached, "Password appeared in a data breach")
+		}
+	}
+	return violations, nil
+}
+
+// BreachedPasswords looks passwords up in a local copy of a breached
+// password list in the k-anonymity range format of Have I Been Pwned: one
+// file per 5 character prefix of the uppercase SHA-1 hex of the passwords,
+// named after the prefix, with "SUFFIX:COUNT" lines.
+type BreachedPasswords struct {
+	dir string
+}
+
+// NewBreachedPasswords creates a lookup in the range files of dir
+func NewBreachedPasswords(dir string) *BreachedPasswords {
+	return &BreachedPasswords{dir: dir}
+}
+
+const rangePrefixLength = 5
+
+// Contains reports whether password is in the list. Only the range file of
+// its hash prefix is read.
+func (b *BreachedPasswords) Contains(password string) (bool, error) {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	prefix, suffix := hash[:rangePrefixLength], hash[rangePrefixLength:]
+
+	file, err := b.openRange(prefix)
+	if err != nil {
+		return false, err
+	}
+	if file == nil {
+		return false, nil
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		lineSuffix, count, _ := strings.Cut(line, ":")
+		if strings.EqualFold(lineSuffix, suffix) && count != "0" {
+			return true, nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return false, errors.Wrap(err, "could not read breached password range")
+	}
+	return false, nil
+}
+
+// openRange opens the range file of prefix, nil if the list has none
+func (b *BreachedPasswords) openRange(prefix string) (*os.File, error) {
+	for _, name := range []string{prefix, prefix + ".txt"} {
+		file, err := os.Open(filepath.Join(b.dir, name))
+		if err == nil {
+			return file, nil
+		}
+		if !os.IsNotExist(err) {
+			return nil, errors.Wrap(err, "could not open breached password range")
+		}
+	}
+	return nil, nil
+}
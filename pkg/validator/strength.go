@@ -0,0 +1,181 @@
+package validator
+
+import (
+	"math"
+	"strings"
+	"unicode"
+)
+
+// Strength scores of Score, with the guess thresholds of zxcvbn
+const (
+	ScoreTooGuessable      = 0 // < 10^3 guesses
+	ScoreVeryGuessable     = 1 // < 10^6 guesses
+	ScoreSomewhatGuessable = 2 // < 10^8 guesses
+	ScoreSafelyUnguessable = 3 // < 10^10 guesses
+	ScoreVeryUnguessable   = 4
+)
+
+// scoreThresholds are the thresholds of the scores in bits, log2 of guesses
+var scoreThresholds = []float64{3 * math.Log2(10), 6 * math.Log2(10), 8 * math.Log2(10), 10 * math.Log2(10)}
+
+// minPatternLength is the shortest repeat or sequence counted as a pattern
+const minPatternLength = 3
+
+// commonPasswords are frequent passwords and words, most common first
+var commonPasswords = []string{
+	"password", "123456", "qwerty", "abc123", "letmein", "monkey", "dragon", "111111",
+	"iloveyou", "admin", "welcome", "login", "master", "hello", "freedom", "whatever",
+	"shadow", "sunshine", "princess", "football", "baseball", "trustno1", "superman",
+	"batman", "starwars", "secret", "passw", "pass", "love", "michael", "jordan",
+	"hunter", "ranger", "buster", "soccer", "hockey", "killer", "george", "charlie",
+	"andrew", "thomas", "summer", "winter", "spring", "autumn", "flower", "cookie",
+	"pepper", "ginger", "cheese", "orange", "banana", "apple", "purple", "silver",
+	"golden", "diamond", "matrix", "computer", "internet", "service", "server",
+	"user", "guest", "root", "test", "demo", "default", "changeme", "access",
+	"mustang", "harley", "corvette", "ferrari", "liverpool", "chelsea", "arsenal",
+	"maggie", "daniel", "jessica", "ashley", "nicole", "angel", "lovely", "family",
+	"friend", "money", "power", "magic", "secure", "private", "please", "sorry",
+	"qazwsx", "zaq12wsx", "asdfgh", "zxcvbn", "1q2w3e", "q1w2e3", "admin123",
+}
+
+// keyboardRows are character runs that are typed in a row
+var keyboardRows = []string{
+	"abcdefghijklmnopqrstuvwxyz",
+	"0123456789",
+	"qwertyuiop",
+	"asdfghjkl",
+	"zxcvbnm",
+	"1qaz2wsx3edc4rfv5tgb6yhn7ujm8ik9ol0p",
+}
+
+// leetSubstitutions undo common character substitutions
+var leetSubstitutions = strings.NewReplacer(
+	"4", "a", "@", "a", "3", "e", "1", "i", "!", "i", "0", "o", "$", "s", "5", "s", "7", "t", "+", "t",
+)
+
+// Score estimates how hard password is to guess, from ScoreTooGuessable to
+// ScoreVeryUnguessable. Like zxcvbn, it finds the cheapest way to build the
+// password from dictionary words, userInputs, repeats, sequences and
+// brute-forced characters.
+func Score(password string, userInputs ...string) int {
+	bits := guessBits(password, userInputs)
+	for score, threshold := range scoreThresholds {
+		if bits < threshold {
+			return score
+		}
+	}
+	return ScoreVeryUnguessable
+}
+
+// guessBits returns log2 of the guesses needed to find password
+func guessBits(password string, userInputs []string) float64 {
+	runes := []rune(password)
+	if len(runes) == 0 {
+		return 0
+	}
+	lower := []rune(strings.ToLower(password))
+	unleet := []rune(leetSubstitutions.Replace(string(lower)))
+	if len(unleet) != len(lower) {
+		unleet = lower
+	}
+	charBits := math.Log2(float64(cardinality(runes)))
+
+	dictionary := map[string]int{}
+	for rank, word := range commonPasswords {
+		dictionary[word] = rank + 1
+	}
+	for _, input := range userInputs {
+		if input = strings.ToLower(input); len([]rune(input)) >= minPatternLength {
+			dictionary[input] = 1
+		}
+	}
+
+	// best[j] is the cheapest cost of the first j characters
+	n := len(runes)
+	best := make([]float64, n+1)
+	for j := 1; j <= n; j++ {
+		best[j] = best[j-1] + charBits
+		for i := 0; i <= j-minPatternLength; i++ {
+			segment := string(lower[i:j])
+			if rank, ok := dictionary[segment]; ok {
+				best[j] = math.Min(best[j], best[i]+wordBits(rank, runes[i:j], false))
+			}
+			if rank, ok := dictionary[string(unleet[i:j])]; ok && string(unleet[i:j]) != segment {
+				best[j] = math.Min(best[j], best[i]+wordBits(rank, runes[i:j], true))
+			}
+			if isRepeat(lower[i:j]) || isSequence(segment) {
+				best[j] = math.Min(best[j], best[i]+charBits+math.Log2(float64(j-i)))
+			}
+		}
+	}
+	return best[n]
+}
+
+// wordBits is the cost of a dictionary word of rank, plus one bit for
+// capitalization and one for character substitutions
+func wordBits(rank int, original []rune, leet bool) float64 {
+	bits := math.Max(math.Log2(float64(rank)), 1)
+	for _, r := range original {
+		if unicode.IsUpper(r) {
+			bits++
+			break
+		}
+	}
+	if leet {
+		bits++
+	}
+	return bits
+}
+
+func isRepeat(runes []rune) bool {
+	for _, r := range runes[1:] {
+		if r != runes[0] {
+			return false
+		}
+	}
+	return true
+}
+
+// isSequence reports whether s is typed along a keyboard row or the
+// alphabet, forwards or backwards
+func isSequence(s string) bool {
+	reversed := []rune(s)
+	for i, j := 0, len(reversed)-1; i < j; i, j = i+1, j-1 {
+		reversed[i], reversed[j] = reversed[j], reversed[i]
+	}
+	for _, row := range keyboardRows {
+		if strings.Contains(row, s) || strings.Contains(row, string(reversed)) {
+			return true
+		}
+	}
+	return false
+}
+
+// cardinality is the size of the character set a brute force of runes needs
+func cardinality(runes []rune) int {
+	var lower, upper, digit, symbol, other bool
+	for _, r := range runes {
+		switch {
+		case r >= 'a' && r <= 'z':
+			lower = true
+		case r >= 'A' && r <= 'Z':
+			upper = true
+		case r >= '0' && r <= '9':
+			digit = true
+		case r < unicode.MaxASCII:
+			symbol = true
+		default:
+			other = true
+		}
+	}
+	size := 0
+	for _, class := range []struct {
+		present bool
+		size    int
+	}{{lower, 26}, {upper, 26}, {digit, 10}, {symbol, 33}, {other, 100}} {
+		if class.present {
+			size += class.size
+		}
+	}
+	return size
+}
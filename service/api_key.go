@@ -0,0 +1,155 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/VikaGo/REST_API/logger"
+	"github.com/VikaGo/REST_API/model"
+	"github.com/VikaGo/REST_API/pkg/types"
+	"github.com/VikaGo/REST_API/store"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+const (
+	// apiKeyPrefix marks API keys, so leaked ones are easy to spot
+	apiKeyPrefix = "rak_"
+	// apiKeyVisibleLength is the length of the stored beginning of a key
+	apiKeyVisibleLength = len(apiKeyPrefix) + 8
+	// apiKeyTouchInterval bounds how often the last use of a key is written
+	apiKeyTouchInterval = time.Minute
+)
+
+// APIKeyWebService manages the API keys services use instead of a login
+type APIKeyWebService struct {
+	ctx   context.Context
+	store *store.Store
+	now   func() time.Time
+}
+
+// NewAPIKeyWebService creates a new API key web service
+func NewAPIKeyWebService(ctx context.Context, store *store.Store) *APIKeyWebService {
+	return &APIKeyWebService{
+		ctx:   ctx,
+		store: store,
+		now:   time.Now,
+	}
+}
+
+// CreateAPIKey creates an API key of a user. The returned key is not stored
+// and cannot be shown again.
+func (svc *APIKeyWebService) CreateAPIKey(ctx context.Context, userID uuid.UUID, input *model.APIKeyInput) (*model.CreatedAPIKey, error) {
+	if input.ExpiresAt != nil && !input.ExpiresAt.After(svc.now()) {
+		return nil, &types.FieldError{
+			Err:   errors.Wrap(types.ErrUnprocessableEntity, "expiry must be in the future"),
+			Field: "expires_at",
+		}
+	}
+
+	user, err := svc.store.User.GetUser(ctx, userID)
+	if err != nil {
+		return nil, errors.Wrap(err, "svc.user.GetUser error")
+	}
+	if user == nil {
+		return nil, errors.Wrap(types.ErrNotFound, fmt.Sprintf("User '%s' not found", userID.String()))
+	}
+
+	secret, err := newOpaqueToken()
+	if err != nil {
+		return nil, errors.Wrap(err, "could not generate API key")
+	}
+	key := apiKeyPrefix + secret
+
+	dbKey := &model.DBAPIKey{
+		ID:        uuid.New(),
+		UserID:    userID,
+		Name:      input.Name,
+		Prefix:    key[:apiKeyVisibleLength],
+		KeyHash:   hashToken(key),
+		Scopes:    input.Scopes,
+		ExpiresAt: input.ExpiresAt,
+		CreatedAt: svc.now(),
+	}
+	err = svc.store.Tx(ctx, func(repos *store.Store) error {
+		if err := repos.APIKey.CreateAPIKey(ctx, dbKey); err != nil {
+			return errors.Wrap(err, "svc.apiKey.CreateAPIKey error")
+		}
+		return recordAuditTx(ctx, repos, model.AuditAPIKeyCreate, &userID, nil)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.CreatedAPIKey{APIKey: dbKey.ToWeb(), Key: key}, nil
+}
+
+// ListAPIKeys returns the API keys of a user that were not revoked
+func (svc *APIKeyWebService) ListAPIKeys(ctx context.Context, userID uuid.UUID) ([]*model.APIKey, error) {
+	dbKeys, err := svc.store.APIKey.ListUserAPIKeys(ctx, userID)
+	if err != nil {
+		return nil, errors.Wrap(err, "svc.apiKey.ListUserAPIKeys error")
+	}
+
+	keys := []*model.APIKey{}
+	for _, dbKey := range dbKeys {
+		keys = append(keys, dbKey.ToWeb())
+	}
+	return keys, nil
+}
+
+// RevokeAPIKey revokes an API key of a user
+func (svc *APIKeyWebService) RevokeAPIKey(ctx context.Context, userID, keyID uuid.UUID) error {
+	return svc.store.Tx(ctx, func(repos *store.Store) error {
+		revoked, err := repos.APIKey.RevokeAPIKey(ctx, userID, keyID)
+		if err != nil {
+			return errors.Wrap(err, "svc.apiKey.RevokeAPIKey error")
+		}
+		if !revoked {
+			return errors.Wrap(types.ErrNotFound, fmt.Sprintf("API key '%s' not found", keyID.String()))
+		}
+		return recordAuditTx(ctx, repos, model.AuditAPIKeyRevoke, &userID, nil)
+	})
+}
+
+// ParseAPIKey returns the caller an API key belongs to. The caller has the
+// current role of the key owner, limited to the scopes of the key.
+func (svc *APIKeyWebService) ParseAPIKey(ctx context.Context, key string) (*model.Principal, error) {
+	dbKey, err := svc.store.APIKey.GetAPIKeyByHash(ctx, hashToken(key))
+	if err != nil {
+		return nil, errors.Wrap(err, "svc.apiKey.GetAPIKeyByHash error")
+	}
+	if dbKey == nil {
+		return nil, errors.Wrap(types.ErrUnauthorized, "invalid API key")
+	}
+	if dbKey.RevokedAt != nil {
+		return nil, errors.Wrap(types.ErrUnauthorized, "API key was revoked")
+	}
+	now := svc.now()
+	if dbKey.ExpiresAt != nil && now.After(*dbKey.ExpiresAt) {
+		return nil, errors.Wrap(types.ErrUnauthorized, "API key expired")
+	}
+
+	user, err := svc.store.User.GetUser(ctx, dbKey.UserID)
+	if err != nil {
+		return nil, errors.Wrap(err, "svc.user.GetUser error")
+	}
+	if user == nil {
+		return nil, errors.Wrap(types.ErrUnauthorized, "API key owner no longer exists")
+	}
+
+	// the request is served anyway, so a failed write is only logged
+	if err := svc.store.APIKey.TouchAPIKey(ctx, dbKey.ID, now, now.Add(-apiKeyTouchInterval)); err != nil {
+		logger.Get().Error().Err(err).Msgf("[service.apiKey] Could not record use of API key '%s'", dbKey.ID)
+	}
+
+	principal := &model.Principal{
+		UserID: user.ID,
+		Role:   user.Role,
+	}
+	if len(dbKey.Scopes) > 0 {
+		principal.Scopes = dbKey.Scopes
+	}
+	return principal, nil
+}
@@ -0,0 +1,89 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/VikaGo/REST_API/model"
+	"github.com/VikaGo/REST_API/pkg/types"
+	"github.com/VikaGo/REST_API/store"
+	"github.com/VikaGo/REST_API/store/mocks"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// TestAPIKeys creates an API key and authenticates with it
+func TestAPIKeys(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2023, 10, 16, 12, 0, 0, 0, time.UTC)
+	user := &model.DBUser{ID: uuid.MustParse("7a2f922c-073a-11eb-adc1-0242ac120002"), Role: model.RoleEditor}
+
+	userRepo := &mocks.UserRepo{}
+	keyRepo := &mocks.APIKeyRepo{}
+	auditRepo := &mocks.AuditRepo{}
+	svc := NewAPIKeyWebService(ctx, &store.Store{User: userRepo, APIKey: keyRepo, Audit: auditRepo})
+	svc.now = func() time.Time { return now }
+
+	var stored *model.DBAPIKey
+	userRepo.On("GetUser", ctx, user.ID).Return(user, nil)
+	keyRepo.On("CreateAPIKey", ctx, mock.Anything).Run(func(args mock.Arguments) {
+		stored = args.Get(1).(*model.DBAPIKey)
+	}).Return(nil).Once()
+	auditRepo.On("CreateAuditEvent", ctx, mock.Anything).Return(nil)
+
+	created, err := svc.CreateAPIKey(ctx, user.ID, &model.APIKeyInput{Name: "nightly export", Scopes: []string{"users:list"}})
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(created.Key, created.Prefix))
+	assert.Equal(t, apiKeyVisibleLength, len(created.Prefix))
+	// only the hash of the key is stored
+	assert.Equal(t, hashToken(created.Key), stored.KeyHash)
+	assert.NotContains(t, stored.KeyHash, created.Key)
+
+	keyRepo.On("GetAPIKeyByHash", ctx, stored.KeyHash).Return(stored, nil)
+	keyRepo.On("TouchAPIKey", ctx, stored.ID, now, now.Add(-apiKeyTouchInterval)).Return(nil)
+	principal, err := svc.ParseAPIKey(ctx, created.Key)
+	require.NoError(t, err)
+	assert.Equal(t, &model.Principal{UserID: user.ID, Role: model.RoleEditor, Scopes: []string{"users:list"}}, principal)
+
+	revoked := *stored
+	revoked.RevokedAt = &now
+	keyRepo.On("GetAPIKeyByHash", ctx, hashToken("rak_revoked")).Return(&revoked, nil)
+	_, err = svc.ParseAPIKey(ctx, "rak_revoked")
+	assert.Equal(t, types.ErrUnauthorized, errors.Cause(err))
+
+	expired := *stored
+	expiresAt := now.Add(-time.Second)
+	expired.ExpiresAt = &expiresAt
+	keyRepo.On("GetAPIKeyByHash", ctx, hashToken("rak_expired")).Return(&expired, nil)
+	_, err = svc.ParseAPIKey(ctx, "rak_expired")
+	assert.Equal(t, types.ErrUnauthorized, errors.Cause(err))
+
+	keyRepo.On("GetAPIKeyByHash", ctx, hashToken("rak_unknown")).Return(nil, nil)
+	_, err = svc.ParseAPIKey(ctx, "rak_unknown")
+	assert.Equal(t, types.ErrUnauthorized, errors.Cause(err))
+
+	_, err = svc.CreateAPIKey(ctx, user.ID, &model.APIKeyInput{Name: "too late", ExpiresAt: &expiresAt})
+	assert.Equal(t, types.ErrUnprocessableEntity, errors.Cause(err))
+
+	keyRepo.On("RevokeAPIKey", ctx, user.ID, stored.ID).Return(true, nil).Once()
+	keyRepo.On("RevokeAPIKey", ctx, user.ID, stored.ID).Return(false, nil).Once()
+	assert.NoError(t, svc.RevokeAPIKey(ctx, user.ID, stored.ID))
+	assert.Equal(t, types.ErrNotFound, errors.Cause(svc.RevokeAPIKey(ctx, user.ID, stored.ID)))
+
+	// the key is only revoked along with its event
+	failingAudit := &mocks.AuditRepo{}
+	failingAudit.On("CreateAuditEvent", ctx, mock.Anything).Return(errors.New("insert failed"))
+	svc.store.Audit = failingAudit
+	keyRepo.On("RevokeAPIKey", ctx, user.ID, stored.ID).Return(true, nil).Once()
+	assert.Error(t, svc.RevokeAPIKey(ctx, user.ID, stored.ID))
+	failingAudit.AssertExpectations(t)
+
+	userRepo.AssertExpectations(t)
+	keyRepo.AssertExpectations(t)
+	auditRepo.AssertExpectations(t)
+}
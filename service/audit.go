@@ -0,0 +1,138 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+
+	"github.com/VikaGo/REST_API/logger"
+	"github.com/VikaGo/REST_API/model"
+	"github.com/VikaGo/REST_API/pkg/types"
+	"github.com/VikaGo/REST_API/store"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+type requestInfoKey struct{}
+
+// WithRequestInfo returns a copy of ctx carrying info about the request
+func WithRequestInfo(ctx context.Context, info *model.RequestInfo) context.Context {
+	return context.WithValue(ctx, requestInfoKey{}, info)
+}
+
+// RequestInfoFromContext returns the request info stored in ctx, or an empty one
+func RequestInfoFromContext(ctx context.Context) *model.RequestInfo {
+	if info, ok := ctx.Value(requestInfoKey{}).(*model.RequestInfo); ok {
+		return info
+	}
+	return &model.RequestInfo{}
+}
+
+// recordAudit appends an event about target to the audit log. It is only
+// used for logins and unlocks, whose state is not kept in a unit of work;
+// the action has already happened, so a failure is logged, not returned.
+func recordAudit(ctx context.Context, store *store.Store, action string, target *uuid.UUID, fields []string) {
+	if err := store.Audit.CreateAuditEvent(ctx, newAuditEvent(ctx, action, target, fields)); err != nil {
+		logger.Get().Error().Err(err).Msgf("[service.audit] Could not record '%s' event", action)
+	}
+}
+
+// recordAuditTx appends an event about target to the audit log within a
+// unit of work. A failed insert aborts the transaction on Postgres, so the
+// error is returned and the action is rolled back with its event.
+func recordAuditTx(ctx context.Context, repos *store.Store, action string, target *uuid.UUID, fields []string) error {
+	if err := repos.Audit.CreateAuditEvent(ctx, newAuditEvent(ctx, action, target, fields)); err != nil {
+		return errors.Wrap(err, fmt.Sprintf("could not record '%s' event", action))
+	}
+	return nil
+}
+
+func newAuditEvent(ctx context.Context, action string, target *uuid.UUID, fields []string) *model.DBAuditEvent {
+	info := RequestInfoFromContext(ctx)
+	return &model.DBAuditEvent{
+		ID:        uuid.New(),
+		Action:    action,
+		ActorID:   info.ActorID,
+		TargetID:  target,
+		Fields:    fields,
+		IP:        info.IP,
+		UserAgent: info.UserAgent,
+		RequestID: info.RequestID,
+	}
+}
+
+// AuditWebService ...
+type AuditWebService struct {
+	ctx   context.Context
+	store *store.Store
+}
+
+// NewAuditWebService creates a new audit web service
+func NewAuditWebService(ctx context.Context, store *store.Store) *AuditWebService {
+	return &AuditWebService{
+		ctx:   ctx,
+		store: store,
+	}
+}
+
+const defaultAuditPageSize = 50
+
+// ListAuditEvents returns one page of audit events matching params, newest first
+func (svc *AuditWebService) ListAuditEvents(ctx context.Context, params *model.AuditListParams) (*model.AuditPage, error) {
+	query := &model.AuditQuery{
+		ActorID:  params.ActorID,
+		TargetID: params.TargetID,
+		Action:   params.Action,
+		From:     params.From,
+		To:       params.To,
+		Limit:    params.Limit,
+	}
+	if query.Limit <= 0 {
+		query.Limit = defaultAuditPageSize
+	}
+	if params.Cursor != "" {
+		cursor, err := decodeAuditCursor(params.Cursor)
+		if err != nil {
+			return nil, errors.Wrap(types.ErrBadRequest, "invalid cursor")
+		}
+		query.Before = cursor
+	}
+
+	// fetch one extra row to know whether there is a next page
+	pageSize := query.Limit
+	query.Limit++
+	eventsDB, err := svc.store.Audit.ListAuditEvents(ctx, query)
+	if err != nil {
+		return nil, errors.Wrap(err, "svc.audit.ListAuditEvents")
+	}
+
+	page := &model.AuditPage{Items: []*model.AuditEvent{}}
+	if len(eventsDB) > pageSize {
+		eventsDB = eventsDB[:pageSize]
+		last := eventsDB[pageSize-1]
+		page.NextCursor = encodeAuditCursor(&model.AuditCursor{CreatedAt: last.CreatedAt, ID: last.ID})
+	}
+	for _, eventDB := range eventsDB {
+		page.Items = append(page.Items, eventDB.ToWeb())
+	}
+
+	return page, nil
+}
+
+func encodeAuditCursor(cursor *model.AuditCursor) string {
+	b, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeAuditCursor(s string) (*model.AuditCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	cursor := &model.AuditCursor{}
+	if err := json.Unmarshal(b, cursor); err != nil {
+		return nil, err
+	}
+	return cursor, nil
+}
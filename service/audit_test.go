@@ -0,0 +1,189 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/VikaGo/REST_API/model"
+	"github.com/VikaGo/REST_API/pkg/types"
+	"github.com/VikaGo/REST_API/store"
+	"github.com/VikaGo/REST_API/store/mocks"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// TestAuditUserMutations checks user mutations and logins append to the audit log
+func TestAuditUserMutations(t *testing.T) {
+	actor := uuid.MustParse("9b1deb4d-3b7d-4bad-9bdd-2b0d7b3dcb6d")
+	hash, err := bcrypt.GenerateFromPassword([]byte("s3cret-pass"), bcrypt.MinCost)
+	assert.NoError(t, err)
+	user := &model.DBUser{
+		ID:        uuid.MustParse("7a2f922c-073a-11eb-adc1-0242ac120002"),
+		Role:      model.RoleViewer,
+		Firstname: "Olexandr",
+		Nickname:  "topol",
+		Password:  string(hash),
+	}
+	patched := *user
+	patched.Firstname = "Oleksandr"
+	info := &model.RequestInfo{ActorID: &actor, IP: "192.0.2.1", UserAgent: "curl/8.0", RequestID: "req-1"}
+	ctx := WithRequestInfo(context.Background(), info)
+
+	// matchEvent matches an event with the request info of ctx
+	matchEvent := func(action string, target *uuid.UUID, fields ...string) interface{} {
+		return mock.MatchedBy(func(event *model.DBAuditEvent) bool {
+			return event.Action == action && assert.ObjectsAreEqual(target, event.TargetID) &&
+				assert.ObjectsAreEqual(info.ActorID, event.ActorID) && event.IP == info.IP &&
+				event.UserAgent == info.UserAgent && event.RequestID == info.RequestID &&
+				assert.ObjectsAreEqual(fields, []string(event.Fields))
+		})
+	}
+
+	tests := []struct {
+		name         string
+		expectations func(userRepo *mocks.UserRepo, tokenRepo *mocks.RefreshTokenRepo, sessionRepo *mocks.SessionRepo, auditRepo *mocks.AuditRepo)
+		call         func(svc *UserWebService) error
+	}{
+		{
+			name: "patch records changed fields",
+			expectations: func(userRepo *mocks.UserRepo, tokenRepo *mocks.RefreshTokenRepo, sessionRepo *mocks.SessionRepo, auditRepo *mocks.AuditRepo) {
+				userRepo.On("PatchUser", ctx, user.ID, 0, map[string]interface{}{"firstname": "Oleksandr"}).Return(&patched, nil)
+				auditRepo.On("CreateAuditEvent", ctx, matchEvent(model.AuditUserUpdate, &user.ID, "firstname")).Return(nil)
+			},
+			call: func(svc *UserWebService) error {
+				firstname := "Oleksandr"
+				_, err := svc.PatchUser(ctx, user.ID, 0, &model.UserPatch{Firstname: &firstname})
+				return err
+			},
+		},
+		{
+			name: "patch fails without its event",
+			expectations: func(userRepo *mocks.UserRepo, tokenRepo *mocks.RefreshTokenRepo, sessionRepo *mocks.SessionRepo, auditRepo *mocks.AuditRepo) {
+				userRepo.On("PatchUser", ctx, user.ID, 0, map[string]interface{}{"firstname": "Oleksandr"}).Return(&patched, nil)
+				auditRepo.On("CreateAuditEvent", ctx, matchEvent(model.AuditUserUpdate, &user.ID, "firstname")).Return(errors.New("insert failed"))
+			},
+			call: func(svc *UserWebService) error {
+				// the unit of work is rolled back with the event
+				firstname := "Oleksandr"
+				_, err := svc.PatchUser(ctx, user.ID, 0, &model.UserPatch{Firstname: &firstname})
+				assert.Error(t, err)
+				return nil
+			},
+		},
+		{
+			name: "failed login without its event",
+			expectations: func(userRepo *mocks.UserRepo, tokenRepo *mocks.RefreshTokenRepo, sessionRepo *mocks.SessionRepo, auditRepo *mocks.AuditRepo) {
+				userRepo.On("GetUserByNickname", ctx, user.Nickname).Return(user, nil)
+				auditRepo.On("CreateAuditEvent", ctx, matchEvent(model.AuditLoginFailure, &user.ID)).Return(errors.New("insert failed"))
+			},
+			call: func(svc *UserWebService) error {
+				// events outside a unit of work are only logged
+				_, err := svc.GenerateToken(ctx, user.Nickname, "wrong-pass")
+				assert.Equal(t, types.ErrUnauthorized, errors.Cause(err))
+				return nil
+			},
+		},
+		{
+			name: "update records changed fields only",
+			expectations: func(userRepo *mocks.UserRepo, tokenRepo *mocks.RefreshTokenRepo, sessionRepo *mocks.SessionRepo, auditRepo *mocks.AuditRepo) {
+				userRepo.On("GetUser", ctx, user.ID).Return(user, nil)
+				// the update keeps the password hash
+				userRepo.On("UpdateUser", ctx, mock.MatchedBy(func(updated *model.DBUser) bool {
+					return updated.Password == user.Password && updated.Firstname == patched.Firstname
+				})).Return(&patched, nil)
+				auditRepo.On("CreateAuditEvent", ctx, matchEvent(model.AuditUserUpdate, &user.ID, "firstname")).Return(nil)
+			},
+			call: func(svc *UserWebService) error {
+				_, err := svc.UpdateUser(ctx, user.ID, 0, &model.UserUpdate{
+					Role:      patched.Role,
+					Firstname: patched.Firstname,
+					Lastname:  patched.Lastname,
+					Nickname:  patched.Nickname,
+					Email:     patched.Email,
+				})
+				return err
+			},
+		},
+		{
+			name: "delete",
+			expectations: func(userRepo *mocks.UserRepo, tokenRepo *mocks.RefreshTokenRepo, sessionRepo *mocks.SessionRepo, auditRepo *mocks.AuditRepo) {
+				userRepo.On("GetUser", ctx, user.ID).Return(user, nil)
+				userRepo.On("DeleteUser", ctx, user.ID, 0).Return(nil)
+				tokenRepo.On("RevokeUserTokens", ctx, user.ID).Return(nil)
+				sessionRepo.On("RevokeUserSessions", ctx, user.ID, (*uuid.UUID)(nil)).Return(nil)
+				auditRepo.On("CreateAuditEvent", ctx, matchEvent(model.AuditUserDelete, &user.ID)).Return(nil)
+			},
+			call: func(svc *UserWebService) error {
+				return svc.DeleteUser(ctx, user.ID, 0)
+			},
+		},
+		{
+			name: "failed login",
+			expectations: func(userRepo *mocks.UserRepo, tokenRepo *mocks.RefreshTokenRepo, sessionRepo *mocks.SessionRepo, auditRepo *mocks.AuditRepo) {
+				userRepo.On("GetUserByNickname", ctx, user.Nickname).Return(user, nil)
+				auditRepo.On("CreateAuditEvent", ctx, matchEvent(model.AuditLoginFailure, &user.ID)).Return(nil)
+			},
+			call: func(svc *UserWebService) error {
+				_, err := svc.GenerateToken(ctx, user.Nickname, "wrong-pass")
+				assert.Error(t, err)
+				return nil
+			},
+		},
+		{
+			name: "login of unknown user",
+			expectations: func(userRepo *mocks.UserRepo, tokenRepo *mocks.RefreshTokenRepo, sessionRepo *mocks.SessionRepo, auditRepo *mocks.AuditRepo) {
+				userRepo.On("GetUserByNickname", ctx, "nobody").Return((*model.DBUser)(nil), nil)
+				auditRepo.On("CreateAuditEvent", ctx, matchEvent(model.AuditLoginFailure, nil)).Return(nil)
+			},
+			call: func(svc *UserWebService) error {
+				_, err := svc.GenerateToken(ctx, "nobody", "s3cret-pass")
+				assert.Error(t, err)
+				return nil
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Logf("running: %s", test.name)
+
+		userRepo := &mocks.UserRepo{}
+		tokenRepo := &mocks.RefreshTokenRepo{}
+		sessionRepo := &mocks.SessionRepo{}
+		auditRepo := &mocks.AuditRepo{}
+		svc := NewUserWebService(ctx, &store.Store{User: userRepo, RefreshToken: tokenRepo, Session: sessionRepo, Audit: auditRepo}, newTestTokenIssuer(t), nil)
+		svc.hasher = testPasswordHasher()
+		test.expectations(userRepo, tokenRepo, sessionRepo, auditRepo)
+
+		assert.NoError(t, test.call(svc))
+		userRepo.AssertExpectations(t)
+		tokenRepo.AssertExpectations(t)
+		sessionRepo.AssertExpectations(t)
+		auditRepo.AssertExpectations(t)
+	}
+}
+
+// TestListAuditEvents runs tests for the audit log listing
+func TestListAuditEvents(t *testing.T) {
+	events := []*model.DBAuditEvent{
+		{ID: uuid.MustParse("3f1c2a1e-5d0b-4b8e-9a57-2f6c1e8d9b10"), Action: model.AuditUserCreate},
+		{ID: uuid.MustParse("c0a8e7d2-6b1f-4c3e-8a9d-5e4f3b2a1c0d"), Action: model.AuditUserUpdate},
+	}
+	ctx := context.Background()
+
+	auditRepo := &mocks.AuditRepo{}
+	auditRepo.On("ListAuditEvents", ctx, &model.AuditQuery{Action: model.AuditUserCreate, Limit: 2}).Return(events, nil)
+	svc := NewAuditWebService(ctx, &store.Store{Audit: auditRepo})
+
+	page, err := svc.ListAuditEvents(ctx, &model.AuditListParams{Action: model.AuditUserCreate, Limit: 1})
+	assert.NoError(t, err)
+	assert.Len(t, page.Items, 1)
+	cursor, err := decodeAuditCursor(page.NextCursor)
+	assert.NoError(t, err)
+	assert.Equal(t, events[0].ID, cursor.ID)
+	auditRepo.AssertExpectations(t)
+
+	_, err = svc.ListAuditEvents(ctx, &model.AuditListParams{Cursor: "not a cursor"})
+	assert.EqualError(t, err, "invalid cursor: bad request")
+}
@@ -0,0 +1,181 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"github.com/VikaGo/REST_API/config"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// PasswordHasher hashes passwords and verifies them against stored hashes.
+// The stored hash names its algorithm and parameters, so hashes of older
+// policies stay verifiable.
+type PasswordHasher interface {
+	Hash(password string) (string, error)
+	// Verify reports whether password matches hash, and whether hash
+	// should be replaced because it does not follow the current policy
+	Verify(hash, password string) (ok, rehash bool, err error)
+}
+
+// Password hashing algorithms
+const (
+	HashBcrypt   = "bcrypt"
+	HashArgon2id = "argon2id"
+)
+
+// Argon2Params are the parameters of Argon2id hashes
+type Argon2Params struct {
+	// Memory is the memory used in KiB
+	Memory     uint32
+	Iterations uint32
+	Threads    uint8
+	SaltLength uint32
+	KeyLength  uint32
+}
+
+// DefaultArgon2Params are the second recommended parameters of RFC 9106
+var DefaultArgon2Params = Argon2Params{
+	Memory:     64 * 1024,
+	Iterations: 3,
+	Threads:    2,
+	SaltLength: 16,
+	KeyLength:  32,
+}
+
+// policyHasher hashes new passwords with one algorithm and verifies hashes
+// of both
+type policyHasher struct {
+	algorithm  string
+	bcryptCost int
+	argon2     Argon2Params
+}
+
+// NewPasswordHasher creates a hasher for the policy of cfg
+func NewPasswordHasher(cfg *config.Config) (PasswordHasher, error) {
+	hasher := &policyHasher{
+		algorithm:  cfg.PasswordHash,
+		bcryptCost: cfg.BcryptCost,
+		argon2: Argon2Params{
+			Memory:     cfg.Argon2Memory,
+			Iterations: cfg.Argon2Iterations,
+			Threads:    cfg.Argon2Threads,
+			SaltLength: DefaultArgon2Params.SaltLength,
+			KeyLength:  DefaultArgon2Params.KeyLength,
+		},
+	}
+	switch hasher.algorithm {
+	case HashBcrypt:
+		if hasher.bcryptCost < bcrypt.MinCost || hasher.bcryptCost > bcrypt.MaxCost {
+			return nil, errors.Errorf("bcrypt cost %d out of range", hasher.bcryptCost)
+		}
+	case HashArgon2id:
+		if hasher.argon2.Memory < 8*uint32(hasher.argon2.Threads) || hasher.argon2.Iterations < 1 || hasher.argon2.Threads < 1 {
+			return nil, errors.New("invalid argon2id parameters")
+		}
+	default:
+		return nil, errors.Errorf("unknown password hash '%s'", hasher.algorithm)
+	}
+	return hasher, nil
+}
+
+// defaultPasswordHasher hashes with Argon2id and the default parameters
+func defaultPasswordHasher() PasswordHasher {
+	return &policyHasher{
+		algorithm:  HashArgon2id,
+		bcryptCost: bcrypt.DefaultCost,
+		argon2:     DefaultArgon2Params,
+	}
+}
+
+// Hash hashes password with the algorithm of the policy
+func (h *policyHasher) Hash(password string) (string, error) {
+	if h.algorithm == HashBcrypt {
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), h.bcryptCost)
+		if err != nil {
+			return "", errors.Wrap(err, "could not generate hashed password")
+		}
+		return string(hash), nil
+	}
+
+	salt := make([]byte, h.argon2.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", errors.Wrap(err, "could not generate salt")
+	}
+	return encodeArgon2(h.argon2, salt, argon2Key(h.argon2, salt, password)), nil
+}
+
+// Verify checks password against a bcrypt or an Argon2id hash
+func (h *policyHasher) Verify(hash, password string) (bool, bool, error) {
+	if strings.HasPrefix(hash, "$argon2id$") {
+		params, salt, key, err := decodeArgon2(hash)
+		if err != nil {
+			return false, false, err
+		}
+		if subtle.ConstantTimeCompare(key, argon2Key(params, salt, password)) != 1 {
+			return false, false, nil
+		}
+		return true, h.algorithm != HashArgon2id || params != h.argon2, nil
+	}
+
+	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+	if err == bcrypt.ErrMismatchedHashAndPassword {
+		return false, false, nil
+	}
+	if err != nil {
+		return false, false, errors.Wrap(err, "invalid bcrypt hash")
+	}
+	cost, err := bcrypt.Cost([]byte(hash))
+	if err != nil {
+		return false, false, errors.Wrap(err, "invalid bcrypt hash")
+	}
+	return true, h.algorithm != HashBcrypt || cost != h.bcryptCost, nil
+}
+
+func argon2Key(params Argon2Params, salt []byte, password string) []byte {
+	return argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Threads, params.KeyLength)
+}
+
+// encodeArgon2 formats an Argon2id hash in the PHC string format, like
+// $argon2id$v=19$m=65536,t=3,p=2$<salt>$<key>
+func encodeArgon2(params Argon2Params, salt, key []byte) string {
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version,
+		params.Memory, params.Iterations, params.Threads,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key))
+}
+
+func decodeArgon2(hash string) (Argon2Params, []byte, []byte, error) {
+	var params Argon2Params
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 {
+		return params, nil, nil, errors.New("invalid argon2id hash")
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return params, nil, nil, errors.New("unsupported argon2id version")
+	}
+	_, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Threads)
+	if err != nil {
+		return params, nil, nil, errors.Wrap(err, "invalid argon2id parameters")
+	}
+	if params.Iterations < 1 || params.Threads < 1 {
+		return params, nil, nil, errors.New("invalid argon2id parameters")
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return params, nil, nil, errors.Wrap(err, "invalid argon2id salt")
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return params, nil, nil, errors.Wrap(err, "invalid argon2id key")
+	}
+	params.SaltLength = uint32(len(salt))
+	params.KeyLength = uint32(len(key))
+	return params, salt, key, nil
+}
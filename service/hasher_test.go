@@ -0,0 +1,149 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/VikaGo/REST_API/model"
+	"github.com/VikaGo/REST_API/pkg/types"
+	"github.com/VikaGo/REST_API/store"
+	"github.com/VikaGo/REST_API/store/mocks"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// testPasswordHasher is a fast hasher for tests
+func testPasswordHasher() *policyHasher {
+	return &policyHasher{algorithm: HashBcrypt, bcryptCost: bcrypt.MinCost}
+}
+
+// cheapArgon2Params keep Argon2id fast in tests
+var cheapArgon2Params = Argon2Params{Memory: 64, Iterations: 1, Threads: 1, SaltLength: 16, KeyLength: 32}
+
+func TestPasswordHasher(t *testing.T) {
+	bcryptMin := testPasswordHasher()
+	bcryptHigher := &policyHasher{algorithm: HashBcrypt, bcryptCost: bcrypt.MinCost + 1}
+	argon := &policyHasher{algorithm: HashArgon2id, argon2: cheapArgon2Params}
+	argonTuned := cheapArgon2Params
+	argonTuned.Iterations = 2
+	argonStronger := &policyHasher{algorithm: HashArgon2id, argon2: argonTuned}
+
+	tests := []struct {
+		testName string
+		hashWith *policyHasher
+		verifier *policyHasher
+		password string
+		ok       bool
+		rehash   bool
+	}{
+		{testName: "bcrypt", hashWith: bcryptMin, verifier: bcryptMin, password: "s3cret-pass", ok: true},
+		{testName: "argon2id", hashWith: argon, verifier: argon, password: "s3cret-pass", ok: true},
+		{testName: "wrong password", hashWith: argon, verifier: argon, password: "guess"},
+		{testName: "bcrypt cost raised", hashWith: bcryptMin, verifier: bcryptHigher, password: "s3cret-pass", ok: true, rehash: true},
+		{testName: "bcrypt to argon2id", hashWith: bcryptMin, verifier: argon, password: "s3cret-pass", ok: true, rehash: true},
+		{testName: "argon2id to bcrypt", hashWith: argon, verifier: bcryptMin, password: "s3cret-pass", ok: true, rehash: true},
+		{testName: "argon2id parameters changed", hashWith: argon, verifier: argonStronger, password: "s3cret-pass", ok: true, rehash: true},
+		{testName: "wrong password needs no rehash", hashWith: bcryptMin, verifier: argon, password: "guess"},
+	}
+
+	for _, test := range tests {
+		t.Logf("running %v", test.testName)
+
+		hash, err := test.hashWith.Hash("s3cret-pass")
+		require.NoError(t, err)
+		assert.True(t, strings.HasPrefix(hash, "$2") || strings.HasPrefix(hash, "$argon2id$v=19$m=64,t=1,p=1$"), hash)
+
+		ok, rehash, err := test.verifier.Verify(hash, test.password)
+		assert.NoError(t, err)
+		assert.Equal(t, test.ok, ok)
+		assert.Equal(t, test.rehash, rehash)
+	}
+
+	for _, hash := range []string{"", "plain", "$argon2id$v=19$m=64,t=1,p=1$salt", "$argon2id$v=16$m=64,t=1,p=1$c2FsdA$a2V5", "$argon2id$v=19$m=64,t=0,p=0$c2FsdA$a2V5"} {
+		t.Logf("running malformed hash %q", hash)
+		ok, _, err := argon.Verify(hash, "s3cret-pass")
+		assert.Error(t, err)
+		assert.False(t, ok)
+	}
+}
+
+// TestLoginRehash checks a login upgrades a hash of an older policy
+func TestLoginRehash(t *testing.T) {
+	ctx := context.Background()
+	hash, err := testPasswordHasher().Hash("s3cret-pass")
+	require.NoError(t, err)
+	user := &model.DBUser{
+		ID:       uuid.MustParse("7a2f922c-073a-11eb-adc1-0242ac120002"),
+		Role:     model.RoleViewer,
+		Nickname: "topol",
+		Password: hash,
+	}
+
+	m := &twoFactorMocks{
+		user:         &mocks.UserRepo{},
+		token:        &mocks.RefreshTokenRepo{},
+		twoFactor:    &mocks.TwoFactorRepo{},
+		roleSettings: &mocks.RoleSettingsRepo{},
+		audit:        &mocks.AuditRepo{},
+	}
+	m.audit.On("CreateAuditEvent", mock.Anything, mock.Anything).Return(nil)
+	m.user.On("GetUserByNickname", ctx, user.Nickname).Return(user, nil)
+	m.twoFactor.On("GetTOTP", ctx, user.ID).Return(nil, nil)
+	m.roleSettings.On("GetRoleSettings", ctx, user.Role).Return(nil, nil)
+	m.token.On("CreateRefreshToken", ctx, mock.Anything).Return(nil)
+	m.user.On("RehashPassword", ctx, user.ID, hash, mock.MatchedBy(func(newHash string) bool {
+		return strings.HasPrefix(newHash, "$argon2id$")
+	})).Return(nil).Once()
+
+	svc := NewUserWebService(ctx, &store.Store{
+		User:         m.user,
+		RefreshToken: m.token,
+		TwoFactor:    m.twoFactor,
+		RoleSettings: m.roleSettings,
+		Audit:        m.audit,
+	}, newTestTokenIssuer(t), nil)
+	svc.hasher = &policyHasher{algorithm: HashArgon2id, argon2: cheapArgon2Params}
+
+	result, err := svc.GenerateToken(ctx, user.Nickname, "s3cret-pass")
+	require.NoError(t, err)
+	assert.NotEmpty(t, result.AccessToken)
+	m.assertExpectations(t)
+}
+
+// countingHasher counts the hashes it verifies
+type countingHasher struct {
+	PasswordHasher
+	verified int
+}
+
+func (h *countingHasher) Verify(hash, password string) (bool, bool, error) {
+	h.verified++
+	return h.PasswordHasher.Verify(hash, password)
+}
+
+// TestGenerateTokenUnknownUser checks unknown nicknames cost a password check too
+func TestGenerateTokenUnknownUser(t *testing.T) {
+	ctx := context.Background()
+	userRepo := &mocks.UserRepo{}
+	auditRepo := &mocks.AuditRepo{}
+	userRepo.On("GetUserByNickname", ctx, "nobody").Return((*model.DBUser)(nil), nil)
+	auditRepo.On("CreateAuditEvent", ctx, mock.Anything).Return(nil)
+
+	svc := NewUserWebService(ctx, &store.Store{User: userRepo, Audit: auditRepo}, newTestTokenIssuer(t), nil)
+	hasher := &countingHasher{PasswordHasher: testPasswordHasher()}
+	svc.hasher = hasher
+
+	for i := 1; i <= 2; i++ {
+		_, err := svc.GenerateToken(ctx, "nobody", "s3cret-pass")
+		assert.Equal(t, types.ErrUnauthorized, errors.Cause(err))
+		assert.Equal(t, i, hasher.verified)
+	}
+	assert.True(t, strings.HasPrefix(svc.dummyHash, "$2a$"), "the dummy hash follows the policy")
+	userRepo.AssertExpectations(t)
+	auditRepo.AssertExpectations(t)
+}
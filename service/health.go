@@ -0,0 +1,32 @@
+package service
+
+import (
+	"context"
+
+	"github.com/VikaGo/REST_API/model"
+	"github.com/VikaGo/REST_API/store"
+)
+
+// HealthWebService reports whether the service can serve requests
+type HealthWebService struct {
+	ctx   context.Context
+	store *store.Store
+}
+
+// NewHealthWebService creates a new health web service
+func NewHealthWebService(ctx context.Context, store *store.Store) *HealthWebService {
+	return &HealthWebService{
+		ctx:   ctx,
+		store: store,
+	}
+}
+
+// Health reports the service as unavailable while its database is
+// unreachable
+func (svc *HealthWebService) Health(ctx context.Context) *model.Health {
+	health := &model.Health{Status: model.HealthOK, Database: svc.store.DatabaseHealth()}
+	if health.Database != nil && !health.Database.Healthy {
+		health.Status = model.HealthUnavailable
+	}
+	return health
+}
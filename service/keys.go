@@ -0,0 +1,266 @@
+package service
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"log"
+	"math/big"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/VikaGo/REST_API/config"
+	"github.com/VikaGo/REST_API/model"
+	"github.com/golang-jwt/jwt"
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultAccessTokenTTL    = 15 * time.Minute
+	defaultRefreshTokenTTL   = 30 * 24 * time.Hour
+	defaultChallengeTokenTTL = 5 * time.Minute
+)
+
+// jwtKey is a single key of the key set
+type jwtKey struct {
+	id      string
+	method  jwt.SigningMethod
+	private interface{} // nil for verification-only keys
+	public  interface{}
+}
+
+// TokenIssuer signs and verifies JWTs with a set of keys. One key signs new
+// tokens; every key in the set verifies them, selected by the "kid" header.
+type TokenIssuer struct {
+	keys              map[string]*jwtKey
+	signingKey        *jwtKey
+	issuer            string
+	AccessTokenTTL    time.Duration
+	RefreshTokenTTL   time.Duration
+	ChallengeTokenTTL time.Duration
+}
+
+// NewTokenIssuer loads the signing keys described by cfg
+func NewTokenIssuer(cfg *config.Config) (*TokenIssuer, error) {
+	issuer := &TokenIssuer{
+		keys:              map[string]*jwtKey{},
+		issuer:            cfg.JWTIssuer,
+		AccessTokenTTL:    cfg.AccessTokenTTL,
+		RefreshTokenTTL:   cfg.RefreshTokenTTL,
+		ChallengeTokenTTL: cfg.LoginChallengeTTL,
+	}
+	if issuer.AccessTokenTTL <= 0 {
+		issuer.AccessTokenTTL = defaultAccessTokenTTL
+	}
+	if issuer.RefreshTokenTTL <= 0 {
+		issuer.RefreshTokenTTL = defaultRefreshTokenTTL
+	}
+	if issuer.ChallengeTokenTTL <= 0 {
+		issuer.ChallengeTokenTTL = defaultChallengeTokenTTL
+	}
+
+	for kid, secret := range cfg.JWTSecrets {
+		if secret == "" {
+			return nil, errors.Errorf("empty secret for JWT key '%s'", kid)
+		}
+		if err := issuer.addKey(&jwtKey{
+			id:      kid,
+			method:  jwt.SigningMethodHS256,
+			private: []byte(secret),
+			public:  []byte(secret),
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	for kid, path := range cfg.JWTKeyFiles {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not read JWT key '%s'", kid)
+		}
+		key, err := parsePEMKey(kid, data)
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not parse JWT key '%s'", kid)
+		}
+		if err := issuer.addKey(key); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(issuer.keys) == 0 {
+		// Nothing configured: sign with a random key, so tokens are only
+		// valid until the service restarts.
+		log.Println("No JWT keys configured, using an ephemeral signing key")
+		secret := make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, errors.Wrap(err, "could not generate ephemeral JWT key")
+		}
+		if err := issuer.addKey(&jwtKey{
+			id:      "ephemeral",
+			method:  jwt.SigningMethodHS256,
+			private: secret,
+			public:  secret,
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	signingKeyID := cfg.JWTSigningKeyID
+	if signingKeyID == "" {
+		if len(issuer.keys) > 1 {
+			return nil, errors.New("JWT_SIGNING_KEY_ID is required when several JWT keys are configured")
+		}
+		for kid := range issuer.keys {
+			signingKeyID = kid
+		}
+	}
+	signingKey, ok := issuer.keys[signingKeyID]
+	if !ok {
+		return nil, errors.Errorf("JWT signing key '%s' is not configured", signingKeyID)
+	}
+	if signingKey.private == nil {
+		return nil, errors.Errorf("JWT signing key '%s' has no private key", signingKeyID)
+	}
+	issuer.signingKey = signingKey
+
+	return issuer, nil
+}
+
+func (issuer *TokenIssuer) addKey(key *jwtKey) error {
+	if _, ok := issuer.keys[key.id]; ok {
+		return errors.Errorf("JWT key '%s' is configured twice", key.id)
+	}
+	issuer.keys[key.id] = key
+	return nil
+}
+
+// Sign signs claims with the active signing key
+func (issuer *TokenIssuer) Sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(issuer.signingKey.method, claims)
+	token.Header["kid"] = issuer.signingKey.id
+	return token.SignedString(issuer.signingKey.private)
+}
+
+// Parse verifies a token signed by any key of the set and decodes it into claims
+func (issuer *TokenIssuer) Parse(tokenString string, claims jwt.Claims) (*jwt.Token, error) {
+	return jwt.ParseWithClaims(tokenString, claims, issuer.keyFunc)
+}
+
+// SigningAlg returns the JWT algorithm of the active signing key
+func (issuer *TokenIssuer) SigningAlg() string {
+	return issuer.signingKey.method.Alg()
+}
+
+// Issuer returns the "iss" claim put into issued tokens
+func (issuer *TokenIssuer) Issuer() string {
+	return issuer.issuer
+}
+
+func (issuer *TokenIssuer) keyFunc(token *jwt.Token) (interface{}, error) {
+	key := issuer.signingKey
+	if kid, ok := token.Header["kid"]; ok {
+		id, _ := kid.(string)
+		if key, ok = issuer.keys[id]; !ok {
+			return nil, errors.Errorf("unknown key '%v'", kid)
+		}
+	}
+	// never let the token choose the algorithm, e.g. HS256 with a public key
+	if token.Method.Alg() != key.method.Alg() {
+		return nil, errors.New("invalid signing method")
+	}
+	return key.public, nil
+}
+
+// JWKS returns the public keys of the set. HMAC keys are secret and never published.
+func (issuer *TokenIssuer) JWKS() *model.JWKSet {
+	set := &model.JWKSet{Keys: []model.JWK{}}
+	for _, key := range issuer.keys {
+		jwk := model.JWK{
+			Kid: key.id,
+			Use: "sig",
+			Alg: key.method.Alg(),
+		}
+		switch pub := key.public.(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = encodeBase64URL(pub.N.Bytes())
+			jwk.E = encodeBase64URL(big.NewInt(int64(pub.E)).Bytes())
+		case *ecdsa.PublicKey:
+			size := (pub.Curve.Params().BitSize + 7) / 8
+			jwk.Kty = "EC"
+			jwk.Crv = pub.Curve.Params().Name
+			jwk.X = encodeBase64URL(pub.X.FillBytes(make([]byte, size)))
+			jwk.Y = encodeBase64URL(pub.Y.FillBytes(make([]byte, size)))
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = encodeBase64URL(pub)
+		default:
+			continue
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	sort.Slice(set.Keys, func(i, j int) bool { return set.Keys[i].Kid < set.Keys[j].Kid })
+	return set
+}
+
+// parsePEMKey reads a PEM private or public key and picks the matching JWT algorithm
+func parsePEMKey(kid string, data []byte) (*jwtKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM data found")
+	}
+
+	var (
+		private interface{}
+		public  interface{}
+		err     error
+	)
+	switch block.Type {
+	case "PRIVATE KEY":
+		private, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		private, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		private, err = x509.ParseECPrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		public, err = x509.ParsePKIXPublicKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		public, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	default:
+		return nil, errors.Errorf("unsupported PEM block '%s'", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if signer, ok := private.(crypto.Signer); ok {
+		public = signer.Public()
+	}
+
+	key := &jwtKey{id: kid, private: private, public: public}
+	switch pub := public.(type) {
+	case *rsa.PublicKey:
+		key.method = jwt.SigningMethodRS256
+	case *ecdsa.PublicKey:
+		if pub.Curve != elliptic.P256() {
+			return nil, errors.Errorf("unsupported curve '%s', only P-256 (ES256) is supported", pub.Curve.Params().Name)
+		}
+		key.method = jwt.SigningMethodES256
+	case ed25519.PublicKey:
+		key.method = jwt.SigningMethodEdDSA
+	default:
+		return nil, errors.Errorf("unsupported key type %T", public)
+	}
+	return key, nil
+}
+
+func encodeBase64URL(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
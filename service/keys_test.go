@@ -0,0 +1,149 @@
+package service
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/VikaGo/REST_API/config"
+	"github.com/golang-jwt/jwt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestTokenIssuer returns an HS256 token issuer for tests
+func newTestTokenIssuer(t *testing.T) *TokenIssuer {
+	issuer, err := NewTokenIssuer(&config.Config{JWTSecrets: map[string]string{"test": "secret"}})
+	require.NoError(t, err)
+	return issuer
+}
+
+// writePEMKey stores a PKCS8 private key and returns the file path
+func writePEMKey(t *testing.T, key interface{}) string {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "key.pem")
+	require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600))
+	return path
+}
+
+// TestTokenIssuer runs sign/verify round trips for every supported algorithm
+func TestTokenIssuer(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name string
+		cfg  *config.Config
+		alg  string
+		jwks int
+	}{
+		{
+			name: "HS256",
+			cfg:  &config.Config{JWTSecrets: map[string]string{"hmac": "secret"}},
+			alg:  "HS256",
+		},
+		{
+			name: "RS256",
+			cfg:  &config.Config{JWTKeyFiles: map[string]string{"rsa": writePEMKey(t, rsaKey)}},
+			alg:  "RS256",
+			jwks: 1,
+		},
+		{
+			name: "ES256",
+			cfg:  &config.Config{JWTKeyFiles: map[string]string{"ec": writePEMKey(t, ecKey)}},
+			alg:  "ES256",
+			jwks: 1,
+		},
+		{
+			name: "EdDSA",
+			cfg:  &config.Config{JWTKeyFiles: map[string]string{"ed": writePEMKey(t, edKey)}},
+			alg:  "EdDSA",
+			jwks: 1,
+		},
+	}
+	for _, test := range tests {
+		t.Logf("running: %s", test.name)
+
+		issuer, err := NewTokenIssuer(test.cfg)
+		require.NoError(t, err)
+
+		signed, err := issuer.Sign(&jwt.StandardClaims{Subject: "someone", ExpiresAt: time.Now().Add(time.Minute).Unix()})
+		require.NoError(t, err)
+
+		claims := &jwt.StandardClaims{}
+		token, err := issuer.Parse(signed, claims)
+		require.NoError(t, err)
+		assert.Equal(t, test.alg, token.Method.Alg())
+		assert.Equal(t, issuer.signingKey.id, token.Header["kid"])
+		assert.Equal(t, "someone", claims.Subject)
+		assert.Len(t, issuer.JWKS().Keys, test.jwks)
+	}
+}
+
+// TestTokenIssuerRotation checks tokens of a retired key verify during rotation
+func TestTokenIssuerRotation(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	old, err := NewTokenIssuer(&config.Config{JWTSecrets: map[string]string{"old": "old-secret"}})
+	require.NoError(t, err)
+	signed, err := old.Sign(&jwt.StandardClaims{Subject: "someone"})
+	require.NoError(t, err)
+
+	rotated, err := NewTokenIssuer(&config.Config{
+		JWTSigningKeyID: "new",
+		JWTSecrets:      map[string]string{"old": "old-secret"},
+		JWTKeyFiles:     map[string]string{"new": writePEMKey(t, rsaKey)},
+	})
+	require.NoError(t, err)
+
+	_, err = rotated.Parse(signed, &jwt.StandardClaims{})
+	assert.NoError(t, err)
+
+	// a token for an unknown key must be rejected
+	stranger, err := NewTokenIssuer(&config.Config{JWTSecrets: map[string]string{"stranger": "old-secret"}})
+	require.NoError(t, err)
+	signed, err = stranger.Sign(&jwt.StandardClaims{Subject: "someone"})
+	require.NoError(t, err)
+	_, err = rotated.Parse(signed, &jwt.StandardClaims{})
+	assert.Error(t, err)
+
+	// several keys need an explicit signing key
+	_, err = NewTokenIssuer(&config.Config{
+		JWTSecrets:  map[string]string{"old": "old-secret"},
+		JWTKeyFiles: map[string]string{"new": writePEMKey(t, rsaKey)},
+	})
+	assert.Error(t, err)
+}
+
+// TestTokenIssuerAlgorithmConfusion checks a token cannot pick its own algorithm
+func TestTokenIssuerAlgorithmConfusion(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	issuer, err := NewTokenIssuer(&config.Config{JWTKeyFiles: map[string]string{"rsa": writePEMKey(t, rsaKey)}})
+	require.NoError(t, err)
+
+	// HS256 signed with the public key bytes, claiming the RSA kid
+	pub, err := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	require.NoError(t, err)
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS256, &jwt.StandardClaims{Subject: "admin"})
+	forged.Header["kid"] = "rsa"
+	signed, err := forged.SignedString(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pub}))
+	require.NoError(t, err)
+
+	_, err = issuer.Parse(signed, &jwt.StandardClaims{})
+	assert.Error(t, err)
+}
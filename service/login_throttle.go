@@ -0,0 +1,170 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/VikaGo/REST_API/config"
+	"github.com/VikaGo/REST_API/model"
+	"github.com/VikaGo/REST_API/pkg/types"
+	"github.com/VikaGo/REST_API/store"
+	"github.com/pkg/errors"
+)
+
+// LoginThrottle slows down password guessing. Failed logins are counted per
+// account and per client IP; every failure doubles the delay before the next
+// attempt and too many failures lock logins out for a while.
+type LoginThrottle struct {
+	attempts           store.LoginAttemptRepo
+	maxAccountFailures int
+	maxIPFailures      int
+	backoff            time.Duration
+	lockout            time.Duration
+	now                func() time.Time
+}
+
+// NewLoginThrottle creates a login throttle with the limits of cfg
+func NewLoginThrottle(attempts store.LoginAttemptRepo, cfg *config.Config) *LoginThrottle {
+	return &LoginThrottle{
+		attempts:           attempts,
+		maxAccountFailures: cfg.LoginMaxFailures,
+		maxIPFailures:      cfg.LoginMaxIPFailures,
+		backoff:            cfg.LoginBackoff,
+		lockout:            cfg.LoginLockout,
+		now:                time.Now,
+	}
+}
+
+// What the throttle counts, as told in its errors
+const (
+	failedLogins  = "failed logins"
+	resetRequests = "password reset requests"
+)
+
+// Accounts are keyed by nickname, so unknown nicknames are throttled alike
+// and the throttle does not tell which accounts exist.
+func accountKey(nickname string) string {
+	return "account:" + strings.ToLower(nickname)
+}
+
+func ipKey(ip string) string {
+	return "ip:" + ip
+}
+
+// Password reset requests are counted apart from failed logins, so asking
+// for resets does not lock anybody out of logging in.
+func resetAccountKey(login string) string {
+	return "reset:account:" + strings.ToLower(login)
+}
+
+func resetIPKey(ip string) string {
+	return "reset:ip:" + ip
+}
+
+// Check returns a types.RetryError if logins to nickname or from ip are
+// currently blocked. A nil throttle never blocks.
+func (t *LoginThrottle) Check(ctx context.Context, nickname, ip string) error {
+	if t == nil {
+		return nil
+	}
+	if err := t.check(ctx, accountKey(nickname), t.maxAccountFailures, failedLogins); err != nil {
+		return err
+	}
+	if ip != "" {
+		return t.check(ctx, ipKey(ip), t.maxIPFailures, failedLogins)
+	}
+	return nil
+}
+
+// check returns a types.RetryError saying there were too many of what if
+// key is blocked
+func (t *LoginThrottle) check(ctx context.Context, key string, maxFailures int, what string) error {
+	attempts, err := t.attempts.GetLoginAttempts(ctx, key)
+	if err != nil {
+		return errors.Wrap(err, "could not get login attempts")
+	}
+	if attempts == nil {
+		return nil
+	}
+	if wait := t.blockedUntil(attempts, maxFailures).Sub(t.now()); wait > 0 {
+		return &types.RetryError{
+			Err:        errors.Wrapf(types.ErrTooManyRequests, "too many %s", what),
+			RetryAfter: wait,
+		}
+	}
+	return nil
+}
+
+// blockedUntil returns when the next login may be attempted
+func (t *LoginThrottle) blockedUntil(attempts *model.LoginAttempts, maxFailures int) time.Time {
+	if attempts.Failures >= maxFailures {
+		return attempts.LastFailure.Add(t.lockout)
+	}
+	delay := t.backoff
+	for i := 1; i < attempts.Failures && delay < t.lockout; i++ {
+		delay *= 2
+	}
+	if delay > t.lockout {
+		delay = t.lockout
+	}
+	return attempts.LastFailure.Add(delay)
+}
+
+// Failure counts a failed login to nickname from ip
+func (t *LoginThrottle) Failure(ctx context.Context, nickname, ip string) error {
+	if t == nil {
+		return nil
+	}
+	keys := []string{accountKey(nickname)}
+	if ip != "" {
+		keys = append(keys, ipKey(ip))
+	}
+	return t.record(ctx, keys...)
+}
+
+// ResetRequest counts a password reset request for login from ip. Like
+// Check, it returns a types.RetryError if requests for login or from ip are
+// currently blocked; every request backs off the next one like a failed
+// login does.
+func (t *LoginThrottle) ResetRequest(ctx context.Context, login, ip string) error {
+	if t == nil {
+		return nil
+	}
+	if err := t.check(ctx, resetAccountKey(login), t.maxAccountFailures, resetRequests); err != nil {
+		return err
+	}
+	keys := []string{resetAccountKey(login)}
+	if ip != "" {
+		if err := t.check(ctx, resetIPKey(ip), t.maxIPFailures, resetRequests); err != nil {
+			return err
+		}
+		keys = append(keys, resetIPKey(ip))
+	}
+	return t.record(ctx, keys...)
+}
+
+// record counts a failure on every key
+func (t *LoginThrottle) record(ctx context.Context, keys ...string) error {
+	// failures are forgotten once a lockout would have expired
+	now := t.now()
+	resetBefore := now.Add(-t.lockout)
+	for _, key := range keys {
+		if _, err := t.attempts.RecordLoginFailure(ctx, key, now, resetBefore); err != nil {
+			return errors.Wrap(err, "could not record failed login")
+		}
+	}
+	return nil
+}
+
+// Unlock forgets the failed logins to nickname. The client IP counters are
+// kept, so one valid account does not reset the limit of a guessing client.
+func (t *LoginThrottle) Unlock(ctx context.Context, nickname string) error {
+	if t == nil {
+		return nil
+	}
+	if err := t.attempts.ResetLoginAttempts(ctx, accountKey(nickname)); err != nil {
+		return errors.Wrap(err, "could not reset login attempts")
+	}
+	return nil
+}
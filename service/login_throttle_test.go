@@ -0,0 +1,127 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/VikaGo/REST_API/config"
+	"github.com/VikaGo/REST_API/pkg/types"
+	"github.com/VikaGo/REST_API/store/memory"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestLoginThrottle runs the backoff and lockout of failed logins
+func TestLoginThrottle(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2023, 10, 11, 12, 0, 0, 0, time.UTC)
+	throttle := NewLoginThrottle(memory.NewLoginAttemptRepo(), &config.Config{
+		LoginMaxFailures:   3,
+		LoginMaxIPFailures: 5,
+		LoginBackoff:       time.Second,
+		LoginLockout:       time.Minute,
+	})
+	throttle.now = func() time.Time { return now }
+
+	// retryAfter returns how long logins are blocked, 0 if they are not
+	retryAfter := func(nickname, ip string) time.Duration {
+		err := throttle.Check(ctx, nickname, ip)
+		if err == nil {
+			return 0
+		}
+		var retryErr *types.RetryError
+		require.True(t, errors.As(err, &retryErr))
+		assert.Equal(t, types.ErrTooManyRequests, errors.Cause(err))
+		return retryErr.RetryAfter
+	}
+
+	assert.Zero(t, retryAfter("topol", "192.0.2.1"))
+
+	// every failure doubles the backoff
+	require.NoError(t, throttle.Failure(ctx, "topol", "192.0.2.1"))
+	assert.Equal(t, time.Second, retryAfter("topol", "192.0.2.1"))
+	require.NoError(t, throttle.Failure(ctx, "Topol", "192.0.2.1"))
+	assert.Equal(t, 2*time.Second, retryAfter("topol", "192.0.2.1"))
+
+	// the backoff expires
+	now = now.Add(2 * time.Second)
+	assert.Zero(t, retryAfter("topol", "192.0.2.1"))
+
+	// the max failures lock the account out, from every IP
+	require.NoError(t, throttle.Failure(ctx, "topol", "192.0.2.1"))
+	assert.Equal(t, time.Minute, retryAfter("topol", "198.51.100.7"))
+
+	// an admin unlocks the account
+	require.NoError(t, throttle.Unlock(ctx, "topol"))
+	assert.Zero(t, retryAfter("topol", "198.51.100.7"))
+
+	// guessing many accounts from one IP locks the IP out
+	for _, nickname := range []string{"a", "b"} {
+		require.NoError(t, throttle.Failure(ctx, nickname, "192.0.2.1"))
+	}
+	assert.Equal(t, time.Minute, retryAfter("shevchenko", "192.0.2.1"))
+	assert.Zero(t, retryAfter("shevchenko", "198.51.100.7"))
+
+	// failures are forgotten once the lockout expired
+	now = now.Add(time.Minute)
+	require.NoError(t, throttle.Failure(ctx, "a", "192.0.2.1"))
+	assert.Equal(t, time.Second, retryAfter("a", "198.51.100.7"))
+	assert.Equal(t, time.Second, retryAfter("shevchenko", "192.0.2.1"))
+}
+
+// TestNilLoginThrottle checks a nil throttle never blocks
+func TestNilLoginThrottle(t *testing.T) {
+	var throttle *LoginThrottle
+	ctx := context.Background()
+	assert.NoError(t, throttle.Failure(ctx, "topol", "192.0.2.1"))
+	assert.NoError(t, throttle.Check(ctx, "topol", "192.0.2.1"))
+	assert.NoError(t, throttle.Unlock(ctx, "topol"))
+	assert.NoError(t, throttle.ResetRequest(ctx, "topol", "192.0.2.1"))
+}
+
+// TestResetRequestThrottle runs the backoff of password reset requests
+func TestResetRequestThrottle(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2023, 10, 13, 12, 0, 0, 0, time.UTC)
+	throttle := NewLoginThrottle(memory.NewLoginAttemptRepo(), &config.Config{
+		LoginMaxFailures:   2,
+		LoginMaxIPFailures: 3,
+		LoginBackoff:       time.Second,
+		LoginLockout:       time.Minute,
+	})
+	throttle.now = func() time.Time { return now }
+
+	// retryAfter requests a reset and returns how long requests are
+	// blocked, 0 if the request went through
+	retryAfter := func(login, ip string) time.Duration {
+		err := throttle.ResetRequest(ctx, login, ip)
+		if err == nil {
+			return 0
+		}
+		var retryErr *types.RetryError
+		require.True(t, errors.As(err, &retryErr))
+		assert.Equal(t, types.ErrTooManyRequests, errors.Cause(err))
+		return retryErr.RetryAfter
+	}
+
+	// every request backs off the next one for the login
+	assert.Zero(t, retryAfter("topol", "192.0.2.1"))
+	assert.Equal(t, time.Second, retryAfter("Topol", "198.51.100.7"))
+	now = now.Add(time.Second)
+	assert.Zero(t, retryAfter("topol", "198.51.100.7"))
+	assert.Equal(t, time.Minute, retryAfter("topol", "203.0.113.5"))
+
+	// reset requests do not block logins
+	assert.NoError(t, throttle.Check(ctx, "topol", "192.0.2.1"))
+
+	// requests for many logins from one IP lock the IP out
+	now = now.Add(2 * time.Minute)
+	for _, login := range []string{"a", "b", "c"} {
+		assert.Zero(t, retryAfter(login, "192.0.2.1"))
+		now = now.Add(10 * time.Second)
+	}
+	assert.Equal(t, time.Minute-10*time.Second, retryAfter("shevchenko", "192.0.2.1"))
+	assert.Zero(t, retryAfter("shevchenko", "198.51.100.7"))
+}
@@ -3,13 +3,25 @@ package service
 import (
 	"context"
 
+	"github.com/VikaGo/REST_API/config"
+	"github.com/VikaGo/REST_API/logger"
+	"github.com/VikaGo/REST_API/pkg/mail"
+	"github.com/VikaGo/REST_API/pkg/oidc"
+	"github.com/VikaGo/REST_API/pkg/validator"
 	"github.com/VikaGo/REST_API/store"
 	"github.com/pkg/errors"
 )
 
 // Manager is just a collection of all services we have in the project
 type Manager struct {
-	User UserService
+	User     UserService
+	Keys     KeyService
+	Audit    AuditService
+	Role     RoleService
+	Password PasswordService
+	APIKey   APIKeyService
+	OAuth    OAuthService
+	Health   HealthService
 }
 
 // NewManager creates new service manager
@@ -17,7 +29,90 @@ func NewManager(ctx context.Context, store *store.Store) (*Manager, error) {
 	if store == nil {
 		return nil, errors.New("No store provided")
 	}
-	return &Manager{
-		User: NewUserWebService(ctx, store),
-	}, nil
+	cfg := config.Get()
+	tokens, err := NewTokenIssuer(cfg)
+	if err != nil {
+		return nil, errors.Wrap(err, "NewTokenIssuer failed")
+	}
+	hasher, err := NewPasswordHasher(cfg)
+	if err != nil {
+		return nil, errors.Wrap(err, "NewPasswordHasher failed")
+	}
+	throttle := NewLoginThrottle(store.LoginAttempt, cfg)
+	users := NewUserWebService(ctx, store, tokens, throttle)
+	users.hasher = hasher
+	if cfg.TOTPIssuer != "" {
+		users.totpIssuer = cfg.TOTPIssuer
+	}
+	if cfg.OIDCProvidersFile != "" {
+		configs, err := oidc.LoadProviders(cfg.OIDCProvidersFile)
+		if err != nil {
+			return nil, err
+		}
+		if users.oidcProviders, err = NewOIDCProviders(configs); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.OIDCLoginTTL > 0 {
+		users.oidcLoginTTL = cfg.OIDCLoginTTL
+	}
+	users.sessions = cfg.SessionCookies
+	if cfg.SessionTTL > 0 {
+		users.sessionTTL = cfg.SessionTTL
+	}
+	users.sessionIdleTimeout = cfg.SessionIdleTimeout
+	mailer, err := newMailer(cfg)
+	if err != nil {
+		return nil, err
+	}
+	passwords := NewPasswordWebService(ctx, store, mailer, throttle)
+	passwords.resetURL = cfg.PasswordResetURL
+	if cfg.PasswordResetTTL > 0 {
+		passwords.resetTTL = cfg.PasswordResetTTL
+	}
+	users.passwordHistory = cfg.PasswordHistory
+	passwords.history = cfg.PasswordHistory
+	passwords.hasher = hasher
+	policy := validator.NewPasswordPolicy(cfg)
+	users.policy = policy
+	passwords.policy = policy
+	manager := &Manager{
+		User:     users,
+		Keys:     tokens,
+		Audit:    NewAuditWebService(ctx, store),
+		Role:     NewRoleWebService(ctx, store),
+		Password: passwords,
+		APIKey:   NewAPIKeyWebService(ctx, store),
+		Health:   NewHealthWebService(ctx, store),
+	}
+	if cfg.OAuthProvider {
+		// ID tokens must name the issuer discovery names
+		if cfg.JWTIssuer == "" {
+			return nil, errors.New("OAUTH_PROVIDER requires JWT_ISSUER")
+		}
+		// browsers follow the redirects of clients with their session cookie
+		if !cfg.SessionCookies || cfg.SessionCookieSameSite == "strict" {
+			return nil, errors.New("OAUTH_PROVIDER requires SESSION_COOKIES with SESSION_COOKIE_SAMESITE lax or none")
+		}
+		oauth := NewOAuthWebService(ctx, store, tokens)
+		if cfg.OAuthCodeTTL > 0 {
+			oauth.codeTTL = cfg.OAuthCodeTTL
+		}
+		manager.OAuth = oauth
+	}
+	return manager, nil
+}
+
+// newMailer creates the mailer selected by cfg.Mailer
+func newMailer(cfg *config.Config) (mail.Mailer, error) {
+	switch cfg.Mailer {
+	case "smtp":
+		return mail.NewSMTPMailer(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUsername, cfg.SMTPPassword, cfg.MailFrom), nil
+	case "file":
+		return mail.NewFileMailer(cfg.MailDir, cfg.MailFrom), nil
+	case "log":
+		return mail.NewLogMailer(logger.Get(), cfg.MailFrom), nil
+	default:
+		return nil, errors.Errorf("unknown mailer '%s'", cfg.Mailer)
+	}
 }
@@ -0,0 +1,57 @@
+package mocks
+
+import (
+	"context"
+
+	"github.com/VikaGo/REST_API/model"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/mock"
+)
+
+// APIKeyService is an autogenerated mock type for the APIKeyService type
+type APIKeyService struct {
+	mock.Mock
+}
+
+// CreateAPIKey provides a mock function with given fields: ctx, userID, input
+func (_m *APIKeyService) CreateAPIKey(ctx context.Context, userID uuid.UUID, input *model.APIKeyInput) (*model.CreatedAPIKey, error) {
+	ret := _m.Called(ctx, userID, input)
+
+	var r0 *model.CreatedAPIKey
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(*model.CreatedAPIKey)
+	}
+
+	return r0, ret.Error(1)
+}
+
+// ListAPIKeys provides a mock function with given fields: ctx, userID
+func (_m *APIKeyService) ListAPIKeys(ctx context.Context, userID uuid.UUID) ([]*model.APIKey, error) {
+	ret := _m.Called(ctx, userID)
+
+	var r0 []*model.APIKey
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).([]*model.APIKey)
+	}
+
+	return r0, ret.Error(1)
+}
+
+// RevokeAPIKey provides a mock function with given fields: ctx, userID, keyID
+func (_m *APIKeyService) RevokeAPIKey(ctx context.Context, userID uuid.UUID, keyID uuid.UUID) error {
+	ret := _m.Called(ctx, userID, keyID)
+
+	return ret.Error(0)
+}
+
+// ParseAPIKey provides a mock function with given fields: ctx, key
+func (_m *APIKeyService) ParseAPIKey(ctx context.Context, key string) (*model.Principal, error) {
+	ret := _m.Called(ctx, key)
+
+	var r0 *model.Principal
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(*model.Principal)
+	}
+
+	return r0, ret.Error(1)
+}
@@ -0,0 +1,36 @@
+package mocks
+
+import (
+	"context"
+
+	"github.com/VikaGo/REST_API/model"
+	"github.com/stretchr/testify/mock"
+)
+
+// AuditService is an autogenerated mock type for the AuditService type
+type AuditService struct {
+	mock.Mock
+}
+
+// ListAuditEvents provides a mock function with given fields: _a0, _a1
+func (_m *AuditService) ListAuditEvents(_a0 context.Context, _a1 *model.AuditListParams) (*model.AuditPage, error) {
+	ret := _m.Called(_a0, _a1)
+
+	var r0 *model.AuditPage
+	if rf, ok := ret.Get(0).(func(context.Context, *model.AuditListParams) *model.AuditPage); ok {
+		r0 = rf(_a0, _a1)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.AuditPage)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, *model.AuditListParams) error); ok {
+		r1 = rf(_a0, _a1)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
@@ -0,0 +1,88 @@
+package mocks
+
+import (
+	"context"
+
+	"github.com/VikaGo/REST_API/model"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/mock"
+)
+
+// OAuthService is an autogenerated mock type for the OAuthService type
+type OAuthService struct {
+	mock.Mock
+}
+
+// RegisterClient provides a mock function with given fields: _a0, _a1
+func (_m *OAuthService) RegisterClient(_a0 context.Context, _a1 *model.OAuthClientInput) (*model.RegisteredOAuthClient, error) {
+	ret := _m.Called(_a0, _a1)
+
+	var r0 *model.RegisteredOAuthClient
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(*model.RegisteredOAuthClient)
+	}
+
+	return r0, ret.Error(1)
+}
+
+// ListClients provides a mock function with given fields: _a0
+func (_m *OAuthService) ListClients(_a0 context.Context) ([]*model.OAuthClient, error) {
+	ret := _m.Called(_a0)
+
+	var r0 []*model.OAuthClient
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).([]*model.OAuthClient)
+	}
+
+	return r0, ret.Error(1)
+}
+
+// DeleteClient provides a mock function with given fields: ctx, clientID
+func (_m *OAuthService) DeleteClient(ctx context.Context, clientID string) error {
+	ret := _m.Called(ctx, clientID)
+
+	return ret.Error(0)
+}
+
+// Authorize provides a mock function with given fields: ctx, userID, req
+func (_m *OAuthService) Authorize(ctx context.Context, userID uuid.UUID, req *model.AuthorizeRequest) (string, error) {
+	ret := _m.Called(ctx, userID, req)
+
+	return ret.String(0), ret.Error(1)
+}
+
+// Token provides a mock function with given fields: _a0, _a1
+func (_m *OAuthService) Token(_a0 context.Context, _a1 *model.TokenRequest) (*model.OAuthTokenResponse, error) {
+	ret := _m.Called(_a0, _a1)
+
+	var r0 *model.OAuthTokenResponse
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(*model.OAuthTokenResponse)
+	}
+
+	return r0, ret.Error(1)
+}
+
+// UserInfo provides a mock function with given fields: ctx, accessToken
+func (_m *OAuthService) UserInfo(ctx context.Context, accessToken string) (*model.UserInfo, error) {
+	ret := _m.Called(ctx, accessToken)
+
+	var r0 *model.UserInfo
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(*model.UserInfo)
+	}
+
+	return r0, ret.Error(1)
+}
+
+// Discovery provides a mock function with given fields:
+func (_m *OAuthService) Discovery() *model.OIDCDiscovery {
+	ret := _m.Called()
+
+	var r0 *model.OIDCDiscovery
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(*model.OIDCDiscovery)
+	}
+
+	return r0
+}
@@ -0,0 +1,26 @@
+package mocks
+
+import (
+	"context"
+
+	"github.com/stretchr/testify/mock"
+)
+
+// PasswordService is an autogenerated mock type for the PasswordService type
+type PasswordService struct {
+	mock.Mock
+}
+
+// ForgotPassword provides a mock function with given fields: ctx, login
+func (_m *PasswordService) ForgotPassword(ctx context.Context, login string) error {
+	ret := _m.Called(ctx, login)
+
+	return ret.Error(0)
+}
+
+// ResetPassword provides a mock function with given fields: ctx, token, password
+func (_m *PasswordService) ResetPassword(ctx context.Context, token string, password string) error {
+	ret := _m.Called(ctx, token, password)
+
+	return ret.Error(0)
+}
@@ -0,0 +1,37 @@
+package mocks
+
+import (
+	"context"
+
+	"github.com/VikaGo/REST_API/model"
+	"github.com/stretchr/testify/mock"
+)
+
+// RoleService is an autogenerated mock type for the RoleService type
+type RoleService struct {
+	mock.Mock
+}
+
+// GetRoleSettings provides a mock function with given fields: ctx, role
+func (_m *RoleService) GetRoleSettings(ctx context.Context, role string) (*model.RoleSettings, error) {
+	ret := _m.Called(ctx, role)
+
+	var r0 *model.RoleSettings
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(*model.RoleSettings)
+	}
+
+	return r0, ret.Error(1)
+}
+
+// UpdateRoleSettings provides a mock function with given fields: _a0, _a1
+func (_m *RoleService) UpdateRoleSettings(_a0 context.Context, _a1 *model.RoleSettings) (*model.RoleSettings, error) {
+	ret := _m.Called(_a0, _a1)
+
+	var r0 *model.RoleSettings
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(*model.RoleSettings)
+	}
+
+	return r0, ret.Error(1)
+}
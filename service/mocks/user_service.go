@@ -14,11 +14,11 @@ type UserService struct {
 }
 
 // CreateUser provides a mock function with given fields: _a0, _a1
-func (_m *UserService) CreateUser(_a0 context.Context, _a1 *model.User) (*model.User, error) {
+func (_m *UserService) CreateUser(_a0 context.Context, _a1 *model.UserCreate) (*model.User, error) {
 	ret := _m.Called(_a0, _a1)
 
 	var r0 *model.User
-	if rf, ok := ret.Get(0).(func(context.Context, *model.User) *model.User); ok {
+	if rf, ok := ret.Get(0).(func(context.Context, *model.UserCreate) *model.User); ok {
 		r0 = rf(_a0, _a1)
 	} else {
 		if ret.Get(0) != nil {
@@ -27,7 +27,7 @@ func (_m *UserService) CreateUser(_a0 context.Context, _a1 *model.User) (*model.
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(context.Context, *model.User) error); ok {
+	if rf, ok := ret.Get(1).(func(context.Context, *model.UserCreate) error); ok {
 		r1 = rf(_a0, _a1)
 	} else {
 		r1 = ret.Error(1)
@@ -36,13 +36,13 @@ func (_m *UserService) CreateUser(_a0 context.Context, _a1 *model.User) (*model.
 	return r0, r1
 }
 
-// DeleteUser provides a mock function with given fields: _a0, _a1
-func (_m *UserService) DeleteUser(_a0 context.Context, _a1 uuid.UUID) error {
-	ret := _m.Called(_a0, _a1)
+// DeleteUser provides a mock function with given fields: ctx, id, version
+func (_m *UserService) DeleteUser(ctx context.Context, id uuid.UUID, version int) error {
+	ret := _m.Called(ctx, id, version)
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID) error); ok {
-		r0 = rf(_a0, _a1)
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID, int) error); ok {
+		r0 = rf(ctx, id, version)
 	} else {
 		r0 = ret.Error(0)
 	}
@@ -73,13 +73,13 @@ func (_m *UserService) GetUser(_a0 context.Context, _a1 uuid.UUID) (*model.User,
 	return r0, r1
 }
 
-// UpdateUser provides a mock function with given fields: _a0, _a1
-func (_m *UserService) UpdateUser(_a0 context.Context, _a1 *model.User) (*model.User, error) {
-	ret := _m.Called(_a0, _a1)
+// UpdateUser provides a mock function with given fields: ctx, id, version, update
+func (_m *UserService) UpdateUser(ctx context.Context, id uuid.UUID, version int, update *model.UserUpdate) (*model.User, error) {
+	ret := _m.Called(ctx, id, version, update)
 
 	var r0 *model.User
-	if rf, ok := ret.Get(0).(func(context.Context, *model.User) *model.User); ok {
-		r0 = rf(_a0, _a1)
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID, int, *model.UserUpdate) *model.User); ok {
+		r0 = rf(ctx, id, version, update)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).(*model.User)
@@ -87,8 +87,8 @@ func (_m *UserService) UpdateUser(_a0 context.Context, _a1 *model.User) (*model.
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(context.Context, *model.User) error); ok {
-		r1 = rf(_a0, _a1)
+	if rf, ok := ret.Get(1).(func(context.Context, uuid.UUID, int, *model.UserUpdate) error); ok {
+		r1 = rf(ctx, id, version, update)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -96,17 +96,252 @@ func (_m *UserService) UpdateUser(_a0 context.Context, _a1 *model.User) (*model.
 	return r0, r1
 }
 
-func (_m *UserService) UpdatePassword(ctx context.Context, id uuid.UUID, newPassword string) error {
-	args := _m.Called(ctx, id, newPassword)
-	return args.Error(0)
-}
+// ChangePassword provides a mock function with given fields: ctx, id, change, verifyCurrent
+func (_m *UserService) ChangePassword(ctx context.Context, id uuid.UUID, change *model.PasswordChange, verifyCurrent bool) error {
+	ret := _m.Called(ctx, id, change, verifyCurrent)
 
-func (_m *UserService) GetPassword(ctx context.Context, u uuid.UUID) (string, error) {
-	args := _m.Called(ctx, u)
-	return args.String(0), args.Error(1)
+	return ret.Error(0)
 }
 
 func (_m *UserService) FindOne(nickname, password string) (string, error) {
 	args := _m.Called(nickname, password)
 	return args.String(0), args.Error(1)
 }
+
+// GetUserByNickname provides a mock function with given fields: ctx, nickname
+func (_m *UserService) GetUserByNickname(ctx context.Context, nickname string) (*model.User, error) {
+	ret := _m.Called(ctx, nickname)
+
+	var r0 *model.User
+	if rf, ok := ret.Get(0).(func(context.Context, string) *model.User); ok {
+		r0 = rf(ctx, nickname)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.User)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, nickname)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GenerateToken provides a mock function with given fields: ctx, nickname, password
+func (_m *UserService) GenerateToken(ctx context.Context, nickname string, password string) (*model.LoginResult, error) {
+	ret := _m.Called(ctx, nickname, password)
+
+	var r0 *model.LoginResult
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(*model.LoginResult)
+	}
+
+	return r0, ret.Error(1)
+}
+
+// CompleteLogin provides a mock function with given fields: ctx, challengeToken, code
+func (_m *UserService) CompleteLogin(ctx context.Context, challengeToken string, code string) (*model.LoginResult, error) {
+	ret := _m.Called(ctx, challengeToken, code)
+
+	var r0 *model.LoginResult
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(*model.LoginResult)
+	}
+
+	return r0, ret.Error(1)
+}
+
+// OIDCLogin provides a mock function with given fields: ctx, provider
+func (_m *UserService) OIDCLogin(ctx context.Context, provider string) (*model.OIDCLoginRedirect, error) {
+	ret := _m.Called(ctx, provider)
+
+	var r0 *model.OIDCLoginRedirect
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(*model.OIDCLoginRedirect)
+	}
+
+	return r0, ret.Error(1)
+}
+
+// CompleteOIDCLogin provides a mock function with given fields: ctx, provider, callback, stateToken
+func (_m *UserService) CompleteOIDCLogin(ctx context.Context, provider string, callback *model.OIDCCallback, stateToken string) (*model.LoginResult, error) {
+	ret := _m.Called(ctx, provider, callback, stateToken)
+
+	var r0 *model.LoginResult
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(*model.LoginResult)
+	}
+
+	return r0, ret.Error(1)
+}
+
+// EnrollTwoFactor provides a mock function with given fields: _a0, _a1
+func (_m *UserService) EnrollTwoFactor(_a0 context.Context, _a1 uuid.UUID) (*model.TOTPEnrollment, error) {
+	ret := _m.Called(_a0, _a1)
+
+	var r0 *model.TOTPEnrollment
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(*model.TOTPEnrollment)
+	}
+
+	return r0, ret.Error(1)
+}
+
+// EnrollTwoFactorWithChallenge provides a mock function with given fields: ctx, challengeToken
+func (_m *UserService) EnrollTwoFactorWithChallenge(ctx context.Context, challengeToken string) (*model.TOTPEnrollment, error) {
+	ret := _m.Called(ctx, challengeToken)
+
+	var r0 *model.TOTPEnrollment
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(*model.TOTPEnrollment)
+	}
+
+	return r0, ret.Error(1)
+}
+
+// ConfirmTwoFactor provides a mock function with given fields: ctx, id, code
+func (_m *UserService) ConfirmTwoFactor(ctx context.Context, id uuid.UUID, code string) (*model.RecoveryCodes, error) {
+	ret := _m.Called(ctx, id, code)
+
+	var r0 *model.RecoveryCodes
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(*model.RecoveryCodes)
+	}
+
+	return r0, ret.Error(1)
+}
+
+// RefreshToken provides a mock function with given fields: ctx, refreshToken
+func (_m *UserService) RefreshToken(ctx context.Context, refreshToken string) (*model.TokenPair, error) {
+	ret := _m.Called(ctx, refreshToken)
+
+	var r0 *model.TokenPair
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(*model.TokenPair)
+	}
+
+	return r0, ret.Error(1)
+}
+
+// Logout provides a mock function with given fields: ctx, refreshToken
+func (_m *UserService) Logout(ctx context.Context, refreshToken string) error {
+	ret := _m.Called(ctx, refreshToken)
+	return ret.Error(0)
+}
+
+// LogoutAll provides a mock function with given fields: ctx, userID
+func (_m *UserService) LogoutAll(ctx context.Context, userID uuid.UUID) error {
+	ret := _m.Called(ctx, userID)
+	return ret.Error(0)
+}
+
+// ParseSession provides a mock function with given fields: ctx, sessionToken, csrfToken, checkCSRF
+func (_m *UserService) ParseSession(ctx context.Context, sessionToken string, csrfToken string, checkCSRF bool) (*model.Principal, error) {
+	ret := _m.Called(ctx, sessionToken, csrfToken, checkCSRF)
+
+	var r0 *model.Principal
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(*model.Principal)
+	}
+
+	return r0, ret.Error(1)
+}
+
+// ListSessions provides a mock function with given fields: ctx, userID
+func (_m *UserService) ListSessions(ctx context.Context, userID uuid.UUID) ([]*model.Session, error) {
+	ret := _m.Called(ctx, userID)
+
+	var r0 []*model.Session
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).([]*model.Session)
+	}
+
+	return r0, ret.Error(1)
+}
+
+// RevokeSession provides a mock function with given fields: ctx, userID, sessionID
+func (_m *UserService) RevokeSession(ctx context.Context, userID uuid.UUID, sessionID uuid.UUID) error {
+	ret := _m.Called(ctx, userID, sessionID)
+	return ret.Error(0)
+}
+
+// ParseToken provides a mock function with given fields: ctx, accessToken
+func (_m *UserService) ParseToken(ctx context.Context, accessToken string) (*model.Principal, error) {
+	ret := _m.Called(ctx, accessToken)
+
+	var r0 *model.Principal
+	if rf, ok := ret.Get(0).(func(context.Context, string) *model.Principal); ok {
+		r0 = rf(ctx, accessToken)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.Principal)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, accessToken)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ListUsers provides a mock function with given fields: _a0, _a1
+func (_m *UserService) ListUsers(_a0 context.Context, _a1 *model.UserListParams) (*model.UserPage, error) {
+	ret := _m.Called(_a0, _a1)
+
+	var r0 *model.UserPage
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(*model.UserPage)
+	}
+
+	return r0, ret.Error(1)
+}
+
+// GetUserIncludingDeleted provides a mock function with given fields: _a0, _a1
+func (_m *UserService) GetUserIncludingDeleted(_a0 context.Context, _a1 uuid.UUID) (*model.User, error) {
+	ret := _m.Called(_a0, _a1)
+
+	var r0 *model.User
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(*model.User)
+	}
+
+	return r0, ret.Error(1)
+}
+
+// RestoreUser provides a mock function with given fields: _a0, _a1
+func (_m *UserService) RestoreUser(_a0 context.Context, _a1 uuid.UUID) (*model.User, error) {
+	ret := _m.Called(_a0, _a1)
+
+	var r0 *model.User
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(*model.User)
+	}
+
+	return r0, ret.Error(1)
+}
+
+// UnlockUser provides a mock function with given fields: _a0, _a1
+func (_m *UserService) UnlockUser(_a0 context.Context, _a1 uuid.UUID) error {
+	ret := _m.Called(_a0, _a1)
+	return ret.Error(0)
+}
+
+// PatchUser provides a mock function with given fields: ctx, id, version, patch
+func (_m *UserService) PatchUser(ctx context.Context, id uuid.UUID, version int, patch *model.UserPatch) (*model.User, error) {
+	ret := _m.Called(ctx, id, version, patch)
+
+	var r0 *model.User
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(*model.User)
+	}
+
+	return r0, ret.Error(1)
+}
@@ -0,0 +1,457 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/VikaGo/REST_API/model"
+	"github.com/VikaGo/REST_API/pkg/types"
+	"github.com/VikaGo/REST_API/store"
+	"github.com/golang-jwt/jwt"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+const (
+	// oauthPurpose marks the access tokens issued to OAuth clients. They only
+	// grant the user info, not the API of the service.
+	oauthPurpose = "oauth"
+
+	defaultAuthorizationCodeTTL = time.Minute
+	clientIDBytes               = 16
+
+	grantTypeAuthorizationCode = "authorization_code"
+	responseTypeCode           = "code"
+	// codeChallengeS256 is the only PKCE method accepted; "plain" does not
+	// protect a code intercepted along with its challenge
+	codeChallengeS256 = "S256"
+)
+
+// OAuth error codes, RFC 6749 sections 4.1.2.1 and 5.2
+const (
+	oauthInvalidRequest          = "invalid_request"
+	oauthInvalidClient           = "invalid_client"
+	oauthInvalidGrant            = "invalid_grant"
+	oauthInvalidScope            = "invalid_scope"
+	oauthInvalidToken            = "invalid_token"
+	oauthInsufficientScope       = "insufficient_scope"
+	oauthUnsupportedGrantType    = "unsupported_grant_type"
+	oauthUnsupportedResponseType = "unsupported_response_type"
+)
+
+var supportedScopes = []string{model.ScopeOpenID, model.ScopeProfile, model.ScopeEmail}
+
+// idTokenClaims are the claims of an OpenID Connect ID token
+type idTokenClaims struct {
+	Issuer    string `json:"iss"`
+	Audience  string `json:"aud"`
+	ExpiresAt int64  `json:"exp"`
+	IssuedAt  int64  `json:"iat"`
+	Nonce     string `json:"nonce,omitempty"`
+	*model.UserInfo
+}
+
+// Valid is never called, ID tokens are only signed here
+func (claims *idTokenClaims) Valid() error {
+	return nil
+}
+
+// OAuthWebService makes the service an OpenID Connect provider: clients log
+// users in with the authorization code flow and PKCE
+type OAuthWebService struct {
+	ctx     context.Context
+	store   *store.Store
+	tokens  *TokenIssuer
+	codeTTL time.Duration
+	now     func() time.Time
+}
+
+// NewOAuthWebService creates a new OAuth web service
+func NewOAuthWebService(ctx context.Context, store *store.Store, tokens *TokenIssuer) *OAuthWebService {
+	return &OAuthWebService{
+		ctx:     ctx,
+		store:   store,
+		tokens:  tokens,
+		codeTTL: defaultAuthorizationCodeTTL,
+		now:     time.Now,
+	}
+}
+
+// RegisterClient registers an OAuth client. The returned secret is not
+// stored and cannot be shown again.
+func (svc *OAuthWebService) RegisterClient(ctx context.Context, input *model.OAuthClientInput) (*model.RegisteredOAuthClient, error) {
+	id := make([]byte, clientIDBytes)
+	if _, err := rand.Read(id); err != nil {
+		return nil, errors.Wrap(err, "could not generate client ID")
+	}
+	client := &model.DBOAuthClient{
+		ID:           base64.RawURLEncoding.EncodeToString(id),
+		Name:         input.Name,
+		RedirectURIs: input.RedirectURIs,
+		CreatedAt:    svc.now(),
+	}
+
+	var secret string
+	if !input.Public {
+		var err error
+		if secret, err = newOpaqueToken(); err != nil {
+			return nil, errors.Wrap(err, "could not generate client secret")
+		}
+		client.SecretHash = hashToken(secret)
+	}
+
+	err := svc.store.Tx(ctx, func(repos *store.Store) error {
+		if err := repos.OAuth.CreateOAuthClient(ctx, client); err != nil {
+			return errors.Wrap(err, "svc.oauth.CreateOAuthClient error")
+		}
+		return recordAuditTx(ctx, repos, model.AuditOAuthClientCreate, nil, nil)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.RegisteredOAuthClient{OAuthClient: client.ToWeb(), ClientSecret: secret}, nil
+}
+
+// ListClients returns every registered OAuth client
+func (svc *OAuthWebService) ListClients(ctx context.Context) ([]*model.OAuthClient, error) {
+	dbClients, err := svc.store.OAuth.ListOAuthClients(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "svc.oauth.ListOAuthClients error")
+	}
+
+	clients := []*model.OAuthClient{}
+	for _, dbClient := range dbClients {
+		clients = append(clients, dbClient.ToWeb())
+	}
+	return clients, nil
+}
+
+// DeleteClient deletes an OAuth client. Tokens it was issued stay valid
+// until they expire.
+func (svc *OAuthWebService) DeleteClient(ctx context.Context, clientID string) error {
+	return svc.store.Tx(ctx, func(repos *store.Store) error {
+		deleted, err := repos.OAuth.DeleteOAuthClient(ctx, clientID)
+		if err != nil {
+			return errors.Wrap(err, "svc.oauth.DeleteOAuthClient error")
+		}
+		if !deleted {
+			return errors.Wrap(types.ErrNotFound, fmt.Sprintf("OAuth client '%s' not found", clientID))
+		}
+		return recordAuditTx(ctx, repos, model.AuditOAuthClientDelete, nil, nil)
+	})
+}
+
+// Authorize grants the authorization request of a client on behalf of the
+// logged in user and returns where to redirect the user to: the redirect
+// URI of the client with a code, or with an OAuth error. Requests naming an
+// unknown client or redirect URI fail with types.ErrBadRequest instead, as
+// redirecting them would be unsafe.
+func (svc *OAuthWebService) Authorize(ctx context.Context, userID uuid.UUID, req *model.AuthorizeRequest) (string, error) {
+	client, err := svc.store.OAuth.GetOAuthClient(ctx, req.ClientID)
+	if err != nil {
+		return "", errors.Wrap(err, "svc.oauth.GetOAuthClient error")
+	}
+	if client == nil {
+		return "", errors.Wrap(types.ErrBadRequest, "unknown client")
+	}
+	redirectURI, err := clientRedirectURI(client, req.RedirectURI)
+	if err != nil {
+		return "", err
+	}
+
+	if req.ResponseType != responseTypeCode {
+		return oauthRedirect(redirectURI, req.State, url.Values{
+			"error":             {oauthUnsupportedResponseType},
+			"error_description": {"only the authorization code flow is supported"},
+		}), nil
+	}
+	if req.CodeChallenge == "" || req.CodeChallengeMethod != codeChallengeS256 {
+		return oauthRedirect(redirectURI, req.State, url.Values{
+			"error":             {oauthInvalidRequest},
+			"error_description": {"a S256 PKCE code challenge is required"},
+		}), nil
+	}
+	scope, ok := normalizeScope(req.Scope)
+	if !ok {
+		return oauthRedirect(redirectURI, req.State, url.Values{
+			"error":             {oauthInvalidScope},
+			"error_description": {"unsupported scope"},
+		}), nil
+	}
+
+	user, err := svc.store.User.GetUser(ctx, userID)
+	if err != nil {
+		return "", errors.Wrap(err, "svc.user.GetUser error")
+	}
+	if user == nil {
+		return "", errors.Wrap(types.ErrUnauthorized, "user no longer exists")
+	}
+
+	code, err := newOpaqueToken()
+	if err != nil {
+		return "", errors.Wrap(err, "could not generate authorization code")
+	}
+	err = svc.store.Tx(ctx, func(repos *store.Store) error {
+		err := repos.OAuth.CreateAuthorizationCode(ctx, &model.DBAuthorizationCode{
+			ID:            uuid.New(),
+			CodeHash:      hashToken(code),
+			ClientID:      client.ID,
+			UserID:        user.ID,
+			RedirectURI:   redirectURI,
+			Scope:         scope,
+			Nonce:         req.Nonce,
+			CodeChallenge: req.CodeChallenge,
+			ExpiresAt:     svc.now().Add(svc.codeTTL),
+		})
+		if err != nil {
+			return errors.Wrap(err, "svc.oauth.CreateAuthorizationCode error")
+		}
+		return recordAuditTx(ctx, repos, model.AuditOAuthAuthorize, &user.ID, nil)
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return oauthRedirect(redirectURI, req.State, url.Values{"code": {code}}), nil
+}
+
+// Token exchanges an authorization code for an access token and, with the
+// openid scope, an ID token. Errors are types.OAuthError.
+func (svc *OAuthWebService) Token(ctx context.Context, req *model.TokenRequest) (*model.OAuthTokenResponse, error) {
+	if req.GrantType != grantTypeAuthorizationCode {
+		return nil, oauthError(types.ErrBadRequest, oauthUnsupportedGrantType, "only the authorization_code grant is supported")
+	}
+
+	client, err := svc.store.OAuth.GetOAuthClient(ctx, req.ClientID)
+	if err != nil {
+		return nil, errors.Wrap(err, "svc.oauth.GetOAuthClient error")
+	}
+	if client == nil || !clientSecretMatches(client, req.ClientSecret) {
+		return nil, oauthError(types.ErrUnauthorized, oauthInvalidClient, "client authentication failed")
+	}
+
+	code, err := svc.store.OAuth.ConsumeAuthorizationCode(ctx, hashToken(req.Code))
+	if err != nil {
+		return nil, errors.Wrap(err, "svc.oauth.ConsumeAuthorizationCode error")
+	}
+	if code == nil || code.ClientID != client.ID {
+		return nil, oauthError(types.ErrBadRequest, oauthInvalidGrant, "invalid, expired or used authorization code")
+	}
+	if req.RedirectURI != code.RedirectURI {
+		return nil, oauthError(types.ErrBadRequest, oauthInvalidGrant, "redirect URI does not match the authorization request")
+	}
+	if !verifyCodeChallenge(code.CodeChallenge, req.CodeVerifier) {
+		return nil, oauthError(types.ErrBadRequest, oauthInvalidGrant, "invalid PKCE code verifier")
+	}
+
+	user, err := svc.store.User.GetUser(ctx, code.UserID)
+	if err != nil {
+		return nil, errors.Wrap(err, "svc.user.GetUser error")
+	}
+	if user == nil {
+		return nil, oauthError(types.ErrBadRequest, oauthInvalidGrant, "user no longer exists")
+	}
+
+	now := svc.now()
+	accessToken, err := svc.tokens.Sign(&tokenClaims{
+		StandardClaims: jwt.StandardClaims{
+			Audience:  client.ID,
+			ExpiresAt: now.Add(svc.tokens.AccessTokenTTL).Unix(),
+			IssuedAt:  now.Unix(),
+			Issuer:    svc.tokens.Issuer(),
+			Subject:   user.ID.String(),
+		},
+		UserId:  user.ID,
+		Role:    user.Role,
+		Purpose: oauthPurpose,
+		Scope:   code.Scope,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "could not sign access token")
+	}
+	response := &model.OAuthTokenResponse{
+		AccessToken: accessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   int64(svc.tokens.AccessTokenTTL.Seconds()),
+		Scope:       code.Scope,
+	}
+
+	scopes := strings.Fields(code.Scope)
+	if hasOAuthScope(scopes, model.ScopeOpenID) {
+		response.IDToken, err = svc.tokens.Sign(&idTokenClaims{
+			Issuer:    svc.tokens.Issuer(),
+			Audience:  client.ID,
+			ExpiresAt: now.Add(svc.tokens.AccessTokenTTL).Unix(),
+			IssuedAt:  now.Unix(),
+			Nonce:     code.Nonce,
+			UserInfo:  userInfo(user, scopes),
+		})
+		if err != nil {
+			return nil, errors.Wrap(err, "could not sign ID token")
+		}
+	}
+
+	return response, nil
+}
+
+// UserInfo returns the claims about the user an OAuth access token was
+// issued for, as far as its scopes allow
+func (svc *OAuthWebService) UserInfo(ctx context.Context, accessToken string) (*model.UserInfo, error) {
+	claims := &tokenClaims{}
+	token, err := svc.tokens.Parse(accessToken, claims)
+	if err != nil || !token.Valid || claims.Purpose != oauthPurpose {
+		return nil, oauthError(types.ErrUnauthorized, oauthInvalidToken, "invalid access token")
+	}
+	if issuer := svc.tokens.Issuer(); issuer != "" && !claims.VerifyIssuer(issuer, true) {
+		return nil, oauthError(types.ErrUnauthorized, oauthInvalidToken, "token issued by another issuer")
+	}
+	scopes := strings.Fields(claims.Scope)
+	if !hasOAuthScope(scopes, model.ScopeOpenID) {
+		return nil, oauthError(types.ErrForbidden, oauthInsufficientScope, "the openid scope is required")
+	}
+
+	user, err := svc.store.User.GetUser(ctx, claims.UserId)
+	if err != nil {
+		return nil, errors.Wrap(err, "svc.user.GetUser error")
+	}
+	if user == nil {
+		return nil, oauthError(types.ErrUnauthorized, oauthInvalidToken, "user no longer exists")
+	}
+	return userInfo(user, scopes), nil
+}
+
+// Discovery returns the OpenID Connect discovery document. The issuer is
+// JWT_ISSUER, exactly as ID tokens carry it.
+func (svc *OAuthWebService) Discovery() *model.OIDCDiscovery {
+	issuer := svc.tokens.Issuer()
+	baseURL := strings.TrimSuffix(issuer, "/")
+
+	return &model.OIDCDiscovery{
+		Issuer:                            issuer,
+		AuthorizationEndpoint:             baseURL + "/oauth/authorize",
+		TokenEndpoint:                     baseURL + "/oauth/token",
+		UserinfoEndpoint:                  baseURL + "/oauth/userinfo",
+		JWKSURI:                           baseURL + "/.well-known/jwks.json",
+		ResponseTypesSupported:            []string{responseTypeCode},
+		GrantTypesSupported:               []string{grantTypeAuthorizationCode},
+		SubjectTypesSupported:             []string{"public"},
+		IDTokenSigningAlgValuesSupported:  []string{svc.tokens.SigningAlg()},
+		ScopesSupported:                   supportedScopes,
+		TokenEndpointAuthMethodsSupported: []string{"client_secret_basic", "client_secret_post", "none"},
+		ClaimsSupported:                   []string{"sub", "name", "given_name", "family_name", "preferred_username", "email", "updated_at"},
+		CodeChallengeMethodsSupported:     []string{codeChallengeS256},
+	}
+}
+
+// clientRedirectURI returns the registered redirect URI the request names.
+// A request may only leave it out if the client has a single one.
+func clientRedirectURI(client *model.DBOAuthClient, requested string) (string, error) {
+	if requested == "" {
+		if len(client.RedirectURIs) == 1 {
+			return client.RedirectURIs[0], nil
+		}
+		return "", errors.Wrap(types.ErrBadRequest, "redirect_uri is required")
+	}
+	// exact matches only, a prefix match would let open redirects leak codes
+	for _, uri := range client.RedirectURIs {
+		if uri == requested {
+			return uri, nil
+		}
+	}
+	return "", errors.Wrap(types.ErrBadRequest, "redirect_uri is not registered for the client")
+}
+
+// oauthRedirect adds params and state to the query of redirectURI
+func oauthRedirect(redirectURI, state string, params url.Values) string {
+	u, err := url.Parse(redirectURI)
+	if err != nil {
+		// registered redirect URIs were validated
+		return redirectURI
+	}
+	query := u.Query()
+	for key, values := range params {
+		query[key] = values
+	}
+	if state != "" {
+		query.Set("state", state)
+	}
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+// normalizeScope drops duplicate scopes. It fails on unsupported ones.
+func normalizeScope(scope string) (string, bool) {
+	var scopes []string
+	for _, s := range strings.Fields(scope) {
+		if !hasOAuthScope(supportedScopes, s) {
+			return "", false
+		}
+		if !hasOAuthScope(scopes, s) {
+			scopes = append(scopes, s)
+		}
+	}
+	return strings.Join(scopes, " "), true
+}
+
+func hasOAuthScope(scopes []string, scope string) bool {
+	for _, s := range scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// clientSecretMatches authenticates a client. Public clients have no
+// secret and rely on PKCE alone.
+func clientSecretMatches(client *model.DBOAuthClient, secret string) bool {
+	if client.SecretHash == "" {
+		return true
+	}
+	return subtle.ConstantTimeCompare([]byte(client.SecretHash), []byte(hashToken(secret))) == 1
+}
+
+// verifyCodeChallenge checks a PKCE code verifier against its S256 challenge
+func verifyCodeChallenge(challenge, verifier string) bool {
+	// RFC 7636 section 4.1
+	if len(verifier) < 43 || len(verifier) > 128 {
+		return false
+	}
+	sum := sha256.Sum256([]byte(verifier))
+	expected := base64.RawURLEncoding.EncodeToString(sum[:])
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(challenge)) == 1
+}
+
+// userInfo returns the claims about user the scopes allow
+func userInfo(user *model.DBUser, scopes []string) *model.UserInfo {
+	info := &model.UserInfo{Subject: user.ID.String()}
+	if hasOAuthScope(scopes, model.ScopeProfile) {
+		info.Name = strings.TrimSpace(user.Firstname + " " + user.Lastname)
+		info.GivenName = user.Firstname
+		info.FamilyName = user.Lastname
+		info.PreferredUsername = user.Nickname
+		if !user.UpdatedAt.IsZero() {
+			info.UpdatedAt = user.UpdatedAt.Unix()
+		}
+	}
+	if hasOAuthScope(scopes, model.ScopeEmail) {
+		info.Email = user.Email
+	}
+	return info
+}
+
+func oauthError(cause error, code, description string) error {
+	return &types.OAuthError{
+		Err:         errors.Wrap(cause, description),
+		Code:        code,
+		Description: description,
+	}
+}
@@ -0,0 +1,228 @@
+package service
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/VikaGo/REST_API/config"
+	"github.com/VikaGo/REST_API/model"
+	"github.com/VikaGo/REST_API/pkg/types"
+	"github.com/VikaGo/REST_API/store"
+	"github.com/VikaGo/REST_API/store/mocks"
+	"github.com/golang-jwt/jwt"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// TestOAuthCodeFlow runs an authorization code flow with PKCE up to the user info
+func TestOAuthCodeFlow(t *testing.T) {
+	ctx := context.Background()
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tokens, err := NewTokenIssuer(&config.Config{
+		JWTKeyFiles: map[string]string{"oidc": writePEMKey(t, ecKey)},
+		JWTIssuer:   "https://id.example.com",
+	})
+	require.NoError(t, err)
+
+	user := &model.DBUser{
+		ID:        uuid.MustParse("7a2f922c-073a-11eb-adc1-0242ac120002"),
+		Role:      model.RoleViewer,
+		Firstname: "Olexandr",
+		Lastname:  "Topol",
+		Nickname:  "topol",
+		Email:     "topol@example.com",
+	}
+	client := &model.DBOAuthClient{
+		ID:           "wiki",
+		SecretHash:   hashToken("wiki-secret"),
+		RedirectURIs: []string{"https://wiki.example.com/callback"},
+	}
+	verifier := "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	sum := sha256.Sum256([]byte(verifier))
+	challenge := base64.RawURLEncoding.EncodeToString(sum[:])
+
+	oauthRepo := &mocks.OAuthRepo{}
+	userRepo := &mocks.UserRepo{}
+	auditRepo := &mocks.AuditRepo{}
+	svc := NewOAuthWebService(ctx, &store.Store{OAuth: oauthRepo, User: userRepo, Audit: auditRepo}, tokens)
+
+	var code *model.DBAuthorizationCode
+	oauthRepo.On("GetOAuthClient", ctx, "wiki").Return(client, nil)
+	oauthRepo.On("GetOAuthClient", ctx, "unknown").Return(nil, nil)
+	userRepo.On("GetUser", ctx, user.ID).Return(user, nil)
+	oauthRepo.On("CreateAuthorizationCode", ctx, mock.Anything).Run(func(args mock.Arguments) {
+		code = args.Get(1).(*model.DBAuthorizationCode)
+	}).Return(nil)
+	auditRepo.On("CreateAuditEvent", ctx, mock.Anything).Return(nil)
+
+	// the redirect URI of an unknown client is never followed
+	_, err = svc.Authorize(ctx, user.ID, &model.AuthorizeRequest{ClientID: "unknown", RedirectURI: "https://evil.example.com"})
+	assert.Equal(t, types.ErrBadRequest, errors.Cause(err))
+	_, err = svc.Authorize(ctx, user.ID, &model.AuthorizeRequest{ClientID: "wiki", RedirectURI: "https://wiki.example.com/callback/../evil"})
+	assert.Equal(t, types.ErrBadRequest, errors.Cause(err))
+
+	location, err := svc.Authorize(ctx, user.ID, &model.AuthorizeRequest{
+		ResponseType: "code",
+		ClientID:     "wiki",
+		Scope:        "openid email",
+		State:        "xyz",
+	})
+	require.NoError(t, err)
+	redirect, err := url.Parse(location)
+	require.NoError(t, err)
+	assert.Equal(t, "invalid_request", redirect.Query().Get("error"), "PKCE is required")
+	assert.Equal(t, "xyz", redirect.Query().Get("state"))
+
+	location, err = svc.Authorize(ctx, user.ID, &model.AuthorizeRequest{
+		ResponseType:        "code",
+		ClientID:            "wiki",
+		RedirectURI:         "https://wiki.example.com/callback",
+		Scope:               "openid profile email",
+		State:               "xyz",
+		Nonce:               "n-0S6",
+		CodeChallenge:       challenge,
+		CodeChallengeMethod: "S256",
+	})
+	require.NoError(t, err)
+	redirect, err = url.Parse(location)
+	require.NoError(t, err)
+	assert.Equal(t, "wiki.example.com", redirect.Host)
+	assert.Equal(t, "xyz", redirect.Query().Get("state"))
+	issued := redirect.Query().Get("code")
+	require.NotEmpty(t, issued)
+	assert.Equal(t, hashToken(issued), code.CodeHash)
+
+	request := &model.TokenRequest{
+		GrantType:    "authorization_code",
+		Code:         issued,
+		RedirectURI:  "https://wiki.example.com/callback",
+		ClientID:     "wiki",
+		ClientSecret: "wiki-secret",
+		CodeVerifier: verifier,
+	}
+
+	wrongSecret := *request
+	wrongSecret.ClientSecret = "guess"
+	_, err = svc.Token(ctx, &wrongSecret)
+	var oauthErr *types.OAuthError
+	require.True(t, errors.As(err, &oauthErr))
+	assert.Equal(t, "invalid_client", oauthErr.Code)
+	assert.Equal(t, types.ErrUnauthorized, errors.Cause(err))
+
+	wrongVerifier := *request
+	wrongVerifier.CodeVerifier = "x" + verifier[1:]
+	oauthRepo.On("ConsumeAuthorizationCode", ctx, code.CodeHash).Return(code, nil).Once()
+	_, err = svc.Token(ctx, &wrongVerifier)
+	require.True(t, errors.As(err, &oauthErr))
+	assert.Equal(t, "invalid_grant", oauthErr.Code)
+
+	oauthRepo.On("ConsumeAuthorizationCode", ctx, code.CodeHash).Return(code, nil).Once()
+	response, err := svc.Token(ctx, request)
+	require.NoError(t, err)
+	assert.Equal(t, "openid profile email", response.Scope)
+
+	// the ID token verifies with the published keys
+	idClaims := jwt.MapClaims{}
+	idToken, err := tokens.Parse(response.IDToken, idClaims)
+	require.NoError(t, err)
+	assert.Equal(t, "ES256", idToken.Method.Alg())
+	assert.Equal(t, "https://id.example.com", idClaims["iss"])
+	assert.Equal(t, "wiki", idClaims["aud"])
+	assert.Equal(t, user.ID.String(), idClaims["sub"])
+	assert.Equal(t, "n-0S6", idClaims["nonce"])
+	assert.Equal(t, "topol@example.com", idClaims["email"])
+	assert.Equal(t, "Olexandr Topol", idClaims["name"])
+
+	info, err := svc.UserInfo(ctx, response.AccessToken)
+	require.NoError(t, err)
+	assert.Equal(t, &model.UserInfo{
+		Subject:           user.ID.String(),
+		Name:              "Olexandr Topol",
+		GivenName:         "Olexandr",
+		FamilyName:        "Topol",
+		PreferredUsername: "topol",
+		Email:             "topol@example.com",
+	}, info)
+
+	// a used code cannot be exchanged again
+	oauthRepo.On("ConsumeAuthorizationCode", ctx, code.CodeHash).Return(nil, nil).Once()
+	_, err = svc.Token(ctx, request)
+	require.True(t, errors.As(err, &oauthErr))
+	assert.Equal(t, "invalid_grant", oauthErr.Code)
+
+	// OAuth access tokens do not grant the API
+	users := NewUserWebService(ctx, &store.Store{}, tokens, nil)
+	_, err = users.ParseToken(ctx, response.AccessToken)
+	assert.Equal(t, types.ErrUnauthorized, errors.Cause(err))
+
+	discovery := svc.Discovery()
+	assert.Equal(t, "https://id.example.com", discovery.Issuer)
+	assert.Equal(t, "https://id.example.com/oauth/token", discovery.TokenEndpoint)
+	assert.Equal(t, []string{"ES256"}, discovery.IDTokenSigningAlgValuesSupported)
+
+	oauthRepo.AssertExpectations(t)
+	userRepo.AssertExpectations(t)
+}
+
+// TestDiscoveryIssuer checks discovery names the issuer exactly as ID
+// tokens carry it
+func TestDiscoveryIssuer(t *testing.T) {
+	tests := []struct {
+		testName      string
+		issuer        string
+		tokenEndpoint string
+	}{
+		{testName: "issuer", issuer: "https://id.example.com", tokenEndpoint: "https://id.example.com/oauth/token"},
+		{testName: "issuer with a trailing slash", issuer: "https://id.example.com/", tokenEndpoint: "https://id.example.com/oauth/token"},
+	}
+
+	for _, test := range tests {
+		t.Logf("running %v", test.testName)
+
+		tokens, err := NewTokenIssuer(&config.Config{JWTSecrets: map[string]string{"test": "secret"}, JWTIssuer: test.issuer})
+		require.NoError(t, err)
+		discovery := NewOAuthWebService(context.Background(), &store.Store{}, tokens).Discovery()
+		assert.Equal(t, tokens.Issuer(), discovery.Issuer)
+		assert.Equal(t, test.tokenEndpoint, discovery.TokenEndpoint)
+	}
+}
+
+// TestRegisterOAuthClient registers confidential and public clients
+func TestRegisterOAuthClient(t *testing.T) {
+	ctx := context.Background()
+	oauthRepo := &mocks.OAuthRepo{}
+	auditRepo := &mocks.AuditRepo{}
+	svc := NewOAuthWebService(ctx, &store.Store{OAuth: oauthRepo, Audit: auditRepo}, newTestTokenIssuer(t))
+	svc.now = func() time.Time { return time.Date(2023, 10, 17, 0, 0, 0, 0, time.UTC) }
+
+	var stored []*model.DBOAuthClient
+	oauthRepo.On("CreateOAuthClient", ctx, mock.Anything).Run(func(args mock.Arguments) {
+		stored = append(stored, args.Get(1).(*model.DBOAuthClient))
+	}).Return(nil)
+	auditRepo.On("CreateAuditEvent", ctx, mock.Anything).Return(nil)
+
+	confidential, err := svc.RegisterClient(ctx, &model.OAuthClientInput{Name: "wiki", RedirectURIs: []string{"https://wiki.example.com/callback"}})
+	require.NoError(t, err)
+	assert.NotEmpty(t, confidential.ClientSecret)
+	assert.False(t, confidential.Public)
+	assert.Equal(t, hashToken(confidential.ClientSecret), stored[0].SecretHash)
+
+	public, err := svc.RegisterClient(ctx, &model.OAuthClientInput{Name: "app", RedirectURIs: []string{"com.example.app:/callback"}, Public: true})
+	require.NoError(t, err)
+	assert.Empty(t, public.ClientSecret)
+	assert.True(t, public.Public)
+	assert.NotEqual(t, confidential.ClientID, public.ClientID)
+
+	oauthRepo.AssertExpectations(t)
+}
@@ -0,0 +1,315 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/VikaGo/REST_API/logger"
+	"github.com/VikaGo/REST_API/model"
+	"github.com/VikaGo/REST_API/pkg/oidc"
+	"github.com/VikaGo/REST_API/pkg/types"
+	"github.com/VikaGo/REST_API/store"
+	"github.com/golang-jwt/jwt"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+const (
+	// oidcStatePurpose marks the tokens that only complete an external login
+	oidcStatePurpose = "oidc_state"
+
+	defaultOIDCLoginTTL = 10 * time.Minute
+	oidcHTTPTimeout     = 10 * time.Second
+)
+
+// oidcStateClaims remember an external login between the redirect to the
+// provider and the callback: the state and nonce it was started with and
+// the PKCE code verifier
+type oidcStateClaims struct {
+	jwt.StandardClaims
+	Purpose      string `json:"purpose"`
+	Provider     string `json:"provider"`
+	State        string `json:"state"`
+	Nonce        string `json:"nonce"`
+	CodeVerifier string `json:"code_verifier"`
+	// Session asks the login for a cookie session instead of tokens
+	Session bool `json:"session,omitempty"`
+}
+
+// NewOIDCProviders creates the providers of configs, by name
+func NewOIDCProviders(configs []oidc.ProviderConfig) (map[string]*oidc.Provider, error) {
+	client := &http.Client{Timeout: oidcHTTPTimeout}
+	providers := map[string]*oidc.Provider{}
+	for _, cfg := range configs {
+		if _, ok := providers[cfg.Name]; ok {
+			return nil, errors.Errorf("OIDC provider '%s' is configured twice", cfg.Name)
+		}
+		if cfg.DefaultRole == "" {
+			cfg.DefaultRole = model.RoleViewer
+		}
+		if !model.IsValidRole(cfg.DefaultRole) {
+			return nil, errors.Errorf("OIDC provider '%s' has unknown default role '%s'", cfg.Name, cfg.DefaultRole)
+		}
+		for value, role := range cfg.RoleMapping {
+			if !model.IsValidRole(role) {
+				return nil, errors.Errorf("OIDC provider '%s' maps '%s' to unknown role '%s'", cfg.Name, value, role)
+			}
+		}
+		providers[cfg.Name] = oidc.NewProvider(cfg, client)
+	}
+	return providers, nil
+}
+
+// OIDCLogin starts a login at an external provider. The user is sent to
+// the returned URL; the state token must be presented with the callback.
+// The login ends with a cookie session if ctx asks for one.
+func (svc *UserWebService) OIDCLogin(ctx context.Context, providerName string) (*model.OIDCLoginRedirect, error) {
+	provider, err := svc.oidcProvider(providerName)
+	if err != nil {
+		return nil, err
+	}
+	if err := svc.checkSessionLogin(ctx); err != nil {
+		return nil, err
+	}
+
+	var secrets [3]string
+	for i := range secrets {
+		if secrets[i], err = newOpaqueToken(); err != nil {
+			return nil, errors.Wrap(err, "could not generate OIDC login secrets")
+		}
+	}
+	state, nonce, verifier := secrets[0], secrets[1], secrets[2]
+	challenge := sha256.Sum256([]byte(verifier))
+
+	authURL, err := provider.AuthCodeURL(ctx, state, nonce, base64.RawURLEncoding.EncodeToString(challenge[:]))
+	if err != nil {
+		return nil, errors.Wrap(err, "could not start OIDC login")
+	}
+
+	stateToken, err := svc.tokens.Sign(&oidcStateClaims{
+		StandardClaims: jwt.StandardClaims{
+			ExpiresAt: time.Now().Add(svc.oidcLoginTTL).Unix(),
+			IssuedAt:  time.Now().Unix(),
+			Issuer:    svc.tokens.Issuer(),
+		},
+		Purpose:      oidcStatePurpose,
+		Provider:     providerName,
+		State:        state,
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		Session:      IsSessionLogin(ctx),
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "could not sign OIDC state token")
+	}
+
+	return &model.OIDCLoginRedirect{
+		URL:        authURL,
+		StateToken: stateToken,
+		ExpiresIn:  int64(svc.oidcLoginTTL.Seconds()),
+	}, nil
+}
+
+// CompleteOIDCLogin finishes a login at an external provider. The identity
+// is looked up, linked to the user with the same nickname or provisioned as
+// a new user, as the provider allows. Like GenerateToken it returns tokens,
+// or the cookie session the login was started for, or a challenge if the
+// user needs a second factor.
+func (svc *UserWebService) CompleteOIDCLogin(ctx context.Context, providerName string, callback *model.OIDCCallback, stateToken string) (*model.LoginResult, error) {
+	provider, err := svc.oidcProvider(providerName)
+	if err != nil {
+		return nil, err
+	}
+
+	claims := &oidcStateClaims{}
+	token, err := svc.tokens.Parse(stateToken, claims)
+	if err != nil || !token.Valid || claims.Purpose != oidcStatePurpose || claims.Provider != providerName ||
+		subtle.ConstantTimeCompare([]byte(claims.State), []byte(callback.State)) != 1 {
+		return nil, errors.Wrap(types.ErrUnauthorized, "invalid OIDC login state")
+	}
+	if callback.Error != "" {
+		return nil, errors.Wrap(types.ErrUnauthorized, fmt.Sprintf("OIDC login failed: %s %s", callback.Error, callback.ErrorDescription))
+	}
+	if claims.Session {
+		ctx = WithSessionLogin(ctx)
+	}
+
+	idClaims, err := provider.Exchange(ctx, callback.Code, claims.CodeVerifier, claims.Nonce)
+	if err != nil {
+		// the details are for the logs, not for the user
+		logger.Get().Warn().Err(err).Msgf("[service.user] OIDC login with '%s' failed", providerName)
+		return nil, errors.Wrap(types.ErrUnauthorized, "OIDC login failed")
+	}
+
+	user, err := svc.identityUser(ctx, provider, idClaims)
+	if err != nil {
+		return nil, err
+	}
+
+	challenge, enrolled, err := svc.twoFactorChallenge(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+	if challenge {
+		return svc.challengeLogin(ctx, user, enrolled)
+	}
+	recordAudit(ctx, svc.store, model.AuditLoginSuccess, &user.ID, nil)
+
+	result := &model.LoginResult{}
+	if err := svc.finishLogin(ctx, user, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (svc *UserWebService) oidcProvider(name string) (*oidc.Provider, error) {
+	provider, ok := svc.oidcProviders[name]
+	if !ok {
+		return nil, errors.Wrap(types.ErrNotFound, fmt.Sprintf("OIDC provider '%s' not found", name))
+	}
+	return provider, nil
+}
+
+// identityUser returns the user linked to the identity of the ID token,
+// linking or provisioning one on its first login
+func (svc *UserWebService) identityUser(ctx context.Context, provider *oidc.Provider, claims oidc.Claims) (*model.DBUser, error) {
+	cfg := provider.Config()
+	subject := claims.String("sub")
+
+	identity, err := svc.store.Identity.GetIdentity(ctx, cfg.Issuer, subject)
+	if err != nil {
+		return nil, errors.Wrap(err, "svc.identity.GetIdentity error")
+	}
+	if identity != nil {
+		user, err := svc.store.User.GetUser(ctx, identity.UserID)
+		if err != nil {
+			return nil, errors.Wrap(err, "svc.user.GetUser error")
+		}
+		if user == nil {
+			return nil, errors.Wrap(types.ErrUnauthorized, "the linked user no longer exists")
+		}
+		return user, nil
+	}
+
+	// the user is provisioned and linked as one unit of work, so a failed
+	// link leaves no user holding the nickname
+	nickname := claims.String(cfg.NicknameClaim)
+	var user *model.DBUser
+	err = svc.store.Tx(ctx, func(repos *store.Store) error {
+		user = nil
+		if cfg.LinkByNickname && nickname != "" {
+			user, err = repos.User.GetUserByNickname(ctx, nickname)
+			if err != nil {
+				return errors.Wrap(err, "svc.user.GetUserByNickname error")
+			}
+		}
+		if user == nil {
+			if !cfg.AutoProvision {
+				return errors.Wrap(types.ErrForbidden, "no user is linked to this identity")
+			}
+			if user, err = svc.provisionUser(ctx, repos, cfg, claims, nickname); err != nil {
+				return err
+			}
+		}
+
+		err = repos.Identity.CreateIdentity(ctx, &model.DBUserIdentity{
+			ID:        uuid.New(),
+			UserID:    user.ID,
+			Provider:  cfg.Name,
+			Issuer:    cfg.Issuer,
+			Subject:   subject,
+			CreatedAt: time.Now(),
+		})
+		if err != nil {
+			return errors.Wrap(err, "svc.identity.CreateIdentity error")
+		}
+		return recordAuditTx(ctx, repos, model.AuditIdentityLink, &user.ID, nil)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// provisionUser creates the user of an identity logging in for the first
+// time. The user gets a random password, so it can only log in through
+// the provider until it resets its password.
+func (svc *UserWebService) provisionUser(ctx context.Context, repos *store.Store, cfg oidc.ProviderConfig, claims oidc.Claims, nickname string) (*model.DBUser, error) {
+	if nickname == "" {
+		return nil, errors.Wrap(types.ErrForbidden, fmt.Sprintf("the identity has no '%s' claim to name the user", cfg.NicknameClaim))
+	}
+
+	password, err := newOpaqueToken()
+	if err != nil {
+		return nil, errors.Wrap(err, "could not generate password")
+	}
+	hash, err := svc.hasher.Hash(password)
+	if err != nil {
+		return nil, err
+	}
+
+	firstname, lastname := claims.String("given_name"), claims.String("family_name")
+	if firstname == "" && lastname == "" {
+		firstname, lastname, _ = strings.Cut(claims.String("name"), " ")
+	}
+	if firstname == "" {
+		firstname = nickname
+	}
+	if lastname == "" {
+		lastname = "-"
+	}
+
+	user := &model.DBUser{
+		ID:        uuid.New(),
+		Role:      mapRole(cfg, claims),
+		Firstname: firstname,
+		Lastname:  lastname,
+		Nickname:  nickname,
+		Password:  hash,
+	}
+	// unverified emails could take over the password reset of the user
+	if verified, _ := claims["email_verified"].(bool); verified {
+		user.Email = claims.String("email")
+	}
+
+	created, err := repos.User.CreateUser(ctx, user)
+	if err != nil {
+		return nil, errors.Wrap(err, "svc.user.CreateUser error")
+	}
+	if created == nil {
+		created = user
+	}
+	if err := recordAuditTx(ctx, repos, model.AuditUserCreate, &created.ID, nil); err != nil {
+		return nil, err
+	}
+
+	return created, nil
+}
+
+// mapRole returns the most privileged role the role claim maps to, or the
+// default role of the provider
+func mapRole(cfg oidc.ProviderConfig, claims oidc.Claims) string {
+	best := -1
+	for _, value := range claims.Strings(cfg.RoleClaim) {
+		role, ok := cfg.RoleMapping[value]
+		if !ok {
+			continue
+		}
+		for i, r := range model.Roles {
+			if r == role && (best < 0 || i < best) {
+				best = i
+			}
+		}
+	}
+	if best < 0 {
+		return cfg.DefaultRole
+	}
+	return model.Roles[best]
+}
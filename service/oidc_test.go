@@ -0,0 +1,336 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/VikaGo/REST_API/model"
+	"github.com/VikaGo/REST_API/pkg/oidc"
+	"github.com/VikaGo/REST_API/pkg/types"
+	"github.com/VikaGo/REST_API/store/mocks"
+	"github.com/golang-jwt/jwt"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// mockIdP is a local OpenID Connect provider. Codes are registered with
+// the claims of the ID token they are exchanged for.
+type mockIdP struct {
+	*httptest.Server
+	key *rsa.PrivateKey
+
+	mu    sync.Mutex
+	codes map[string]mockIdPCode
+}
+
+type mockIdPCode struct {
+	challenge string
+	claims    jwt.MapClaims
+}
+
+func newMockIdP(t *testing.T) *mockIdP {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	idp := &mockIdP{key: key, codes: map[string]mockIdPCode{}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(oidc.Metadata{
+			Issuer:                idp.URL,
+			AuthorizationEndpoint: idp.URL + "/authorize",
+			TokenEndpoint:         idp.URL + "/token",
+			JWKSURI:               idp.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "idp",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		idp.mu.Lock()
+		code, ok := idp.codes[r.FormValue("code")]
+		delete(idp.codes, r.FormValue("code"))
+		idp.mu.Unlock()
+
+		sum := sha256.Sum256([]byte(r.FormValue("code_verifier")))
+		if !ok || r.FormValue("client_secret") != "app-secret" || base64.RawURLEncoding.EncodeToString(sum[:]) != code.challenge {
+			w.WriteHeader(http.StatusBadRequest)
+			_ = json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]string{"id_token": idp.sign(t, code.claims), "token_type": "Bearer"})
+	})
+	idp.Server = httptest.NewServer(mux)
+	t.Cleanup(idp.Close)
+	return idp
+}
+
+func (idp *mockIdP) sign(t *testing.T, claims jwt.MapClaims) string {
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = "idp"
+	signed, err := token.SignedString(idp.key)
+	require.NoError(t, err)
+	return signed
+}
+
+// authorize plays the user logging in at the provider: it registers a code
+// for the authorization URL and returns the callback with it
+func (idp *mockIdP) authorize(t *testing.T, authURL string, claims jwt.MapClaims) *model.OIDCCallback {
+	u, err := url.Parse(authURL)
+	require.NoError(t, err)
+	query := u.Query()
+
+	idClaims := jwt.MapClaims{
+		"iss":   idp.URL,
+		"aud":   "app",
+		"exp":   time.Now().Add(time.Minute).Unix(),
+		"iat":   time.Now().Unix(),
+		"nonce": query.Get("nonce"),
+	}
+	for name, value := range claims {
+		idClaims[name] = value
+	}
+	code := uuid.NewString()
+	idp.mu.Lock()
+	idp.codes[code] = mockIdPCode{challenge: query.Get("code_challenge"), claims: idClaims}
+	idp.mu.Unlock()
+
+	return &model.OIDCCallback{Code: code, State: query.Get("state")}
+}
+
+// TestOIDCLogin logs in through a local mock provider
+func TestOIDCLogin(t *testing.T) {
+	ctx := context.Background()
+	idp := newMockIdP(t)
+	provider := func(name string, apply func(*oidc.ProviderConfig)) oidc.ProviderConfig {
+		cfg := oidc.ProviderConfig{
+			Name:         name,
+			Issuer:       idp.URL,
+			ClientID:     "app",
+			ClientSecret: "app-secret",
+			RedirectURL:  "https://api.example.com/v1/auth/oidc/" + name + "/callback",
+		}
+		apply(&cfg)
+		return cfg
+	}
+	providers, err := NewOIDCProviders([]oidc.ProviderConfig{
+		provider("closed", func(cfg *oidc.ProviderConfig) {}),
+		provider("corp", func(cfg *oidc.ProviderConfig) { cfg.LinkByNickname = true }),
+		provider("social", func(cfg *oidc.ProviderConfig) {
+			cfg.AutoProvision = true
+			cfg.RoleClaim = "groups"
+			cfg.RoleMapping = map[string]string{"wiki-viewers": model.RoleViewer, "wiki-editors": model.RoleEditor}
+		}),
+	})
+	require.NoError(t, err)
+
+	existing := &model.DBUser{ID: uuid.New(), Role: model.RoleEditor, Nickname: "petro"}
+
+	tests := []struct {
+		testName string
+		provider string
+		claims   jwt.MapClaims
+		// tamper changes the callback or the ID token before the exchange
+		tamper  func(callback *model.OIDCCallback, code *mockIdPCode)
+		expect  func(m *twoFactorMocks, identities *mocks.IdentityRepo)
+		created func(t *testing.T, user *model.DBUser)
+		cause   error
+	}{
+		{
+			testName: "linked identity",
+			provider: "closed",
+			claims:   jwt.MapClaims{"sub": "sub-1"},
+			expect: func(m *twoFactorMocks, identities *mocks.IdentityRepo) {
+				identities.On("GetIdentity", ctx, idp.URL, "sub-1").Return(&model.DBUserIdentity{UserID: existing.ID}, nil)
+				m.user.On("GetUser", ctx, existing.ID).Return(existing, nil)
+			},
+		},
+		{
+			testName: "unlinked identity without provisioning",
+			provider: "closed",
+			claims:   jwt.MapClaims{"sub": "sub-2", "preferred_username": "petro"},
+			expect: func(m *twoFactorMocks, identities *mocks.IdentityRepo) {
+				identities.On("GetIdentity", ctx, idp.URL, "sub-2").Return(nil, nil)
+			},
+			cause: types.ErrForbidden,
+		},
+		{
+			testName: "linked by nickname",
+			provider: "corp",
+			claims:   jwt.MapClaims{"sub": "sub-3", "preferred_username": "petro"},
+			expect: func(m *twoFactorMocks, identities *mocks.IdentityRepo) {
+				identities.On("GetIdentity", ctx, idp.URL, "sub-3").Return(nil, nil)
+				m.user.On("GetUserByNickname", ctx, "petro").Return(existing, nil)
+				identities.On("CreateIdentity", ctx, mock.MatchedBy(func(identity *model.DBUserIdentity) bool {
+					return identity.UserID == existing.ID && identity.Provider == "corp" && identity.Subject == "sub-3"
+				})).Return(nil)
+			},
+		},
+		{
+			testName: "provisioned with the highest mapped role",
+			provider: "social",
+			claims: jwt.MapClaims{
+				"sub":                "sub-4",
+				"preferred_username": "oksana",
+				"name":               "Oksana Bilyk",
+				"email":              "oksana@example.com",
+				"groups":             []string{"wiki-viewers", "wiki-editors", "other"},
+			},
+			expect: func(m *twoFactorMocks, identities *mocks.IdentityRepo) {
+				identities.On("GetIdentity", ctx, idp.URL, "sub-4").Return(nil, nil)
+				identities.On("CreateIdentity", ctx, mock.Anything).Return(nil)
+			},
+			created: func(t *testing.T, user *model.DBUser) {
+				assert.Equal(t, model.RoleEditor, user.Role)
+				assert.Equal(t, "oksana", user.Nickname)
+				assert.Equal(t, "Oksana", user.Firstname)
+				assert.Equal(t, "Bilyk", user.Lastname)
+				assert.Empty(t, user.Email, "unverified emails are not taken over")
+				assert.NotEmpty(t, user.Password)
+			},
+		},
+		{
+			testName: "provisioned with the default role",
+			provider: "social",
+			claims:   jwt.MapClaims{"sub": "sub-5", "preferred_username": "ivan", "email": "ivan@example.com", "email_verified": true},
+			expect: func(m *twoFactorMocks, identities *mocks.IdentityRepo) {
+				identities.On("GetIdentity", ctx, idp.URL, "sub-5").Return(nil, nil)
+				identities.On("CreateIdentity", ctx, mock.Anything).Return(nil)
+			},
+			created: func(t *testing.T, user *model.DBUser) {
+				assert.Equal(t, model.RoleViewer, user.Role)
+				assert.Equal(t, "ivan@example.com", user.Email)
+			},
+		},
+		{
+			testName: "provisioned user whose identity cannot be linked",
+			provider: "social",
+			claims:   jwt.MapClaims{"sub": "sub-6", "preferred_username": "taras"},
+			expect: func(m *twoFactorMocks, identities *mocks.IdentityRepo) {
+				identities.On("GetIdentity", ctx, idp.URL, "sub-6").Return(nil, nil)
+				identities.On("CreateIdentity", ctx, mock.Anything).Return(types.ErrDuplicateEntry)
+			},
+			created: func(t *testing.T, user *model.DBUser) {
+				assert.Equal(t, "taras", user.Nickname)
+			},
+			cause: types.ErrDuplicateEntry,
+		},
+		{
+			testName: "state mismatch",
+			provider: "closed",
+			claims:   jwt.MapClaims{"sub": "sub-1"},
+			tamper: func(callback *model.OIDCCallback, code *mockIdPCode) {
+				callback.State = "forged"
+			},
+			cause: types.ErrUnauthorized,
+		},
+		{
+			testName: "nonce mismatch",
+			provider: "closed",
+			claims:   jwt.MapClaims{"sub": "sub-1"},
+			tamper: func(callback *model.OIDCCallback, code *mockIdPCode) {
+				code.claims["nonce"] = "replayed"
+			},
+			cause: types.ErrUnauthorized,
+		},
+		{
+			testName: "ID token for another client",
+			provider: "closed",
+			claims:   jwt.MapClaims{"sub": "sub-1", "aud": "other-app"},
+			cause:    types.ErrUnauthorized,
+		},
+		{
+			testName: "expired ID token",
+			provider: "closed",
+			claims:   jwt.MapClaims{"sub": "sub-1", "exp": time.Now().Add(-time.Minute).Unix()},
+			cause:    types.ErrUnauthorized,
+		},
+		{
+			testName: "provider error",
+			provider: "closed",
+			tamper: func(callback *model.OIDCCallback, code *mockIdPCode) {
+				callback.Code = ""
+				callback.Error = "access_denied"
+			},
+			cause: types.ErrUnauthorized,
+		},
+	}
+
+	for _, test := range tests {
+		t.Logf("running %v", test.testName)
+
+		svc, m := newTwoFactorService(t)
+		identities := &mocks.IdentityRepo{}
+		svc.store.Identity = identities
+		svc.oidcProviders = providers
+		if test.expect != nil {
+			test.expect(m, identities)
+		}
+		var created *model.DBUser
+		if test.created != nil {
+			m.user.On("CreateUser", ctx, mock.Anything).Run(func(args mock.Arguments) {
+				created = args.Get(1).(*model.DBUser)
+			}).Return(nil, nil)
+		}
+		if test.cause == nil {
+			m.twoFactor.On("GetTOTP", ctx, mock.Anything).Return(nil, nil)
+			m.roleSettings.On("GetRoleSettings", ctx, mock.Anything).Return(nil, nil)
+			m.token.On("CreateRefreshToken", ctx, mock.Anything).Return(nil)
+		}
+
+		redirect, err := svc.OIDCLogin(ctx, test.provider)
+		require.NoError(t, err)
+		callback := idp.authorize(t, redirect.URL, test.claims)
+		if test.tamper != nil {
+			idp.mu.Lock()
+			code := idp.codes[callback.Code]
+			test.tamper(callback, &code)
+			idp.mu.Unlock()
+		}
+
+		result, err := svc.CompleteOIDCLogin(ctx, test.provider, callback, redirect.StateToken)
+		if test.cause != nil {
+			assert.Equal(t, test.cause, errors.Cause(err))
+		} else {
+			require.NoError(t, err)
+			require.NotNil(t, result.TokenPair)
+			assert.NotEmpty(t, result.AccessToken)
+		}
+		if test.created != nil {
+			require.NotNil(t, created)
+			test.created(t, created)
+		}
+		m.assertExpectations(t)
+		identities.AssertExpectations(t)
+	}
+
+	svc, _ := newTwoFactorService(t)
+	svc.oidcProviders = providers
+	_, err = svc.OIDCLogin(ctx, "unknown")
+	assert.Equal(t, types.ErrNotFound, errors.Cause(err))
+
+	// the state token only completes a login with the provider it was issued for
+	redirect, err := svc.OIDCLogin(ctx, "closed")
+	require.NoError(t, err)
+	_, err = svc.CompleteOIDCLogin(ctx, "social", idp.authorize(t, redirect.URL, jwt.MapClaims{"sub": "sub-1"}), redirect.StateToken)
+	assert.Equal(t, types.ErrUnauthorized, errors.Cause(err))
+}
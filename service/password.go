@@ -0,0 +1,308 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/VikaGo/REST_API/logger"
+	"github.com/VikaGo/REST_API/model"
+	"github.com/VikaGo/REST_API/pkg/mail"
+	"github.com/VikaGo/REST_API/pkg/types"
+	"github.com/VikaGo/REST_API/pkg/validator"
+	"github.com/VikaGo/REST_API/store"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultPasswordResetTTL = time.Hour
+	defaultPasswordHistory  = 5
+	// passwordResetMailTimeout bounds the lookup and mailing of a reset
+	// token, which outlive the request asking for it
+	passwordResetMailTimeout = time.Minute
+	// passwordResetWorkers bounds the reset tokens looked up and mailed at
+	// once; requests beyond it are dropped
+	passwordResetWorkers = 4
+)
+
+// PasswordWebService resets forgotten passwords with single-use tokens sent by email
+type PasswordWebService struct {
+	ctx      context.Context
+	store    *store.Store
+	mailer   mail.Mailer
+	throttle *LoginThrottle
+	// workers holds a slot for every reset token being mailed
+	workers  chan struct{}
+	resetTTL time.Duration
+	resetURL string
+	history  int
+	hasher   PasswordHasher
+	policy   *validator.PasswordPolicy
+}
+
+// NewPasswordWebService creates a new password web service
+func NewPasswordWebService(ctx context.Context, store *store.Store, mailer mail.Mailer, throttle *LoginThrottle) *PasswordWebService {
+	return &PasswordWebService{
+		ctx:      ctx,
+		store:    store,
+		mailer:   mailer,
+		throttle: throttle,
+		workers:  make(chan struct{}, passwordResetWorkers),
+		resetTTL: defaultPasswordResetTTL,
+		history:  defaultPasswordHistory,
+		hasher:   defaultPasswordHasher(),
+		policy:   validator.DefaultPasswordPolicy(),
+	}
+}
+
+// ForgotPassword emails a password reset token to the user with the nickname
+// or email login. It does not tell whether such a user exists: the lookup
+// and the mail happen in the background, so neither the result nor the
+// response time differ. Requests for a login or from an IP are throttled
+// like failed logins, with a types.RetryError.
+func (svc *PasswordWebService) ForgotPassword(ctx context.Context, login string) error {
+	info := RequestInfoFromContext(ctx)
+	if err := svc.throttle.ResetRequest(ctx, login, info.IP); err != nil {
+		return err
+	}
+
+	select {
+	case svc.workers <- struct{}{}:
+	default:
+		logger.Get().Warn().Msg("[service.password] Too many password reset tokens being mailed, dropping a request")
+		return nil
+	}
+	go func() {
+		defer func() { <-svc.workers }()
+		ctx, cancel := context.WithTimeout(WithRequestInfo(svc.ctx, info), passwordResetMailTimeout)
+		defer cancel()
+		if err := svc.sendResetToken(ctx, login); err != nil {
+			logger.Get().Error().Err(err).Msg("[service.password] Could not send password reset token")
+		}
+	}()
+	return nil
+}
+
+// sendResetToken creates a reset token for the user with the nickname or
+// email login and mails it to the user
+func (svc *PasswordWebService) sendResetToken(ctx context.Context, login string) error {
+	user, err := svc.findUser(ctx, login)
+	if err != nil {
+		return err
+	}
+	if user == nil || user.Email == "" {
+		// nobody to send the token to
+		return nil
+	}
+
+	token, err := newOpaqueToken()
+	if err != nil {
+		return errors.Wrap(err, "could not generate reset token")
+	}
+	err = svc.store.Tx(ctx, func(repos *store.Store) error {
+		err := repos.PasswordReset.CreatePasswordResetToken(ctx, &model.DBPasswordResetToken{
+			ID:        uuid.New(),
+			UserID:    user.ID,
+			TokenHash: hashToken(token),
+			ExpiresAt: time.Now().Add(svc.resetTTL),
+		})
+		if err != nil {
+			return errors.Wrap(err, "svc.passwordReset.CreatePasswordResetToken error")
+		}
+		return recordAuditTx(ctx, repos, model.AuditPasswordForgot, &user.ID, nil)
+	})
+	if err != nil {
+		return err
+	}
+
+	err = svc.mailer.Send(ctx, &mail.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body:    svc.resetMailBody(user, token),
+	})
+	if err != nil {
+		return errors.Wrap(err, "svc.mailer.Send error")
+	}
+	return nil
+}
+
+func (svc *PasswordWebService) findUser(ctx context.Context, login string) (*model.DBUser, error) {
+	if strings.Contains(login, "@") {
+		user, err := svc.store.User.GetUserByEmail(ctx, login)
+		if err != nil {
+			return nil, errors.Wrap(err, "svc.user.GetUserByEmail error")
+		}
+		if user != nil {
+			return user, nil
+		}
+	}
+	user, err := svc.store.User.GetUserByNickname(ctx, login)
+	if err != nil {
+		return nil, errors.Wrap(err, "svc.user.GetUserByNickname error")
+	}
+	return user, nil
+}
+
+func (svc *PasswordWebService) resetMailBody(user *model.DBUser, token string) string {
+	reset := "Your password reset token is:\n\n" + token
+	if svc.resetURL != "" {
+		reset = "Open this link to choose a new password:\n\n" + svc.resetURL + "?" + url.Values{"token": {token}}.Encode()
+	}
+	return fmt.Sprintf("Hello %s,\n\nsomeone asked to reset the password of your account '%s'. %s\n\n"+
+		"The token expires in %s and works only once. If you did not ask for it, ignore this email.\n",
+		user.Firstname, user.Nickname, reset, svc.resetTTL)
+}
+
+// ResetPassword sets the password of the user a reset token was sent to.
+// The token is used up, and every refresh token and other reset token of
+// the user is revoked.
+func (svc *PasswordWebService) ResetPassword(ctx context.Context, token, password string) error {
+	// the token is only used up once the password was accepted
+	dbToken, err := svc.store.PasswordReset.GetPasswordResetToken(ctx, hashToken(token))
+	if err != nil {
+		return errors.Wrap(err, "svc.passwordReset.GetPasswordResetToken error")
+	}
+	if dbToken == nil {
+		return invalidResetToken("invalid, expired or used reset token")
+	}
+	var nickname string
+	err = svc.store.Tx(ctx, func(repos *store.Store) error {
+		// the user is read within the unit of work, so its version is the
+		// one the password is set on
+		userDB, err := repos.User.GetUser(ctx, dbToken.UserID)
+		if err != nil {
+			return errors.Wrap(err, "svc.user.GetUser error")
+		}
+		if userDB == nil {
+			return invalidResetToken("reset token owner no longer exists")
+		}
+		nickname = userDB.Nickname
+		if err := checkPasswordPolicy(svc.policy, userDB, password, "password"); err != nil {
+			return err
+		}
+		if err := checkPasswordReuse(ctx, repos, svc.hasher, userDB, password, svc.history, "password"); err != nil {
+			return err
+		}
+
+		consumed, err := repos.PasswordReset.ConsumePasswordResetToken(ctx, dbToken.TokenHash)
+		if err != nil {
+			return errors.Wrap(err, "svc.passwordReset.ConsumePasswordResetToken error")
+		}
+		if consumed == nil {
+			// a concurrent request has already used this token
+			return invalidResetToken("invalid, expired or used reset token")
+		}
+		if err := setPassword(ctx, repos, svc.hasher, userDB, password, svc.history); err != nil {
+			return err
+		}
+
+		// whoever knew the old password must not stay logged in
+		if err := repos.RefreshToken.RevokeUserTokens(ctx, userDB.ID); err != nil {
+			return errors.Wrap(err, "svc.refreshToken.RevokeUserTokens error")
+		}
+		if err := repos.Session.RevokeUserSessions(ctx, userDB.ID, nil); err != nil {
+			return errors.Wrap(err, "svc.session.RevokeUserSessions error")
+		}
+		if err := repos.PasswordReset.DeleteUserPasswordResetTokens(ctx, userDB.ID); err != nil {
+			return errors.Wrap(err, "svc.passwordReset.DeleteUserPasswordResetTokens error")
+		}
+		return recordAuditTx(ctx, repos, model.AuditPasswordReset, &userDB.ID, []string{"password"})
+	})
+	if err != nil {
+		return err
+	}
+
+	// the lockout is not stored with the password, so it is lifted once the
+	// new password is committed
+	return svc.throttle.Unlock(ctx, nickname)
+}
+
+func invalidResetToken(msg string) error {
+	return &types.FieldError{
+		Err:   errors.Wrap(types.ErrUnprocessableEntity, msg),
+		Field: "token",
+	}
+}
+
+// checkPasswordPolicy returns a types.ValidationError listing every rule of
+// policy password breaks. field names the password in the violations.
+func checkPasswordPolicy(policy *validator.PasswordPolicy, user *model.DBUser, password, field string) error {
+	violations, err := policy.Check(password, user.Nickname, user.Firstname, user.Lastname)
+	if err != nil {
+		return errors.Wrap(err, "could not check password policy")
+	}
+	if len(violations) == 0 {
+		return nil
+	}
+	for i := range violations {
+		violations[i].Field = field
+	}
+	return &types.ValidationError{
+		Err:        errors.Wrap(types.ErrUnprocessableEntity, "password does not follow the policy"),
+		Violations: violations,
+	}
+}
+
+// checkPasswordReuse refuses password if it is the current password of user
+// or one of the history-1 previous ones. field names the password in errors.
+func checkPasswordReuse(ctx context.Context, store *store.Store, hasher PasswordHasher, user *model.DBUser, password string, history int, field string) error {
+	if history < 1 {
+		return nil
+	}
+	reused := &types.FieldError{
+		Err:   errors.Wrap(types.ErrUnprocessableEntity, fmt.Sprintf("password must differ from the last %d passwords", history)),
+		Field: field,
+	}
+	if ok, _, _ := hasher.Verify(user.Password, password); ok {
+		return reused
+	}
+	if history == 1 {
+		return nil
+	}
+
+	previous, err := store.PasswordHistory.ListPasswordHistory(ctx, user.ID, history-1)
+	if err != nil {
+		return errors.Wrap(err, "svc.passwordHistory.ListPasswordHistory error")
+	}
+	for _, hash := range previous {
+		if ok, _, _ := hasher.Verify(hash, password); ok {
+			return reused
+		}
+	}
+	return nil
+}
+
+// setPassword hashes password and makes it the password of user. The
+// replaced hash goes to the password history.
+func setPassword(ctx context.Context, store *store.Store, hasher PasswordHasher, user *model.DBUser, password string, history int) error {
+	hash, err := hasher.Hash(password)
+	if err != nil {
+		return err
+	}
+
+	previous := user.Password
+	updated := *user
+	updated.Password = hash
+	userDB, err := store.User.UpdateUser(ctx, &updated)
+	if err != nil {
+		return errors.Wrap(err, "svc.user.UpdateUser error")
+	}
+	if userDB == nil {
+		return errors.Wrap(types.ErrNotFound, fmt.Sprintf("User '%s' not found", user.ID.String()))
+	}
+
+	if history > 1 && previous != "" {
+		err = store.PasswordHistory.AddPasswordHistory(ctx, &model.DBPasswordHistory{
+			ID:           uuid.New(),
+			UserID:       user.ID,
+			PasswordHash: previous,
+		}, history-1)
+		if err != nil {
+			return errors.Wrap(err, "svc.passwordHistory.AddPasswordHistory error")
+		}
+	}
+	return nil
+}
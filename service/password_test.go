@@ -0,0 +1,517 @@
+package service
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/VikaGo/REST_API/config"
+	"github.com/VikaGo/REST_API/model"
+	"github.com/VikaGo/REST_API/pkg/mail"
+	"github.com/VikaGo/REST_API/pkg/types"
+	"github.com/VikaGo/REST_API/pkg/validator"
+	"github.com/VikaGo/REST_API/store"
+	"github.com/VikaGo/REST_API/store/memory"
+	"github.com/VikaGo/REST_API/store/mocks"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var resetLink = regexp.MustCompile(`https://example\.com/reset\?token=([A-Za-z0-9_-]+)`)
+
+// TestPasswordReset mails a reset token and sets a new password with it
+func TestPasswordReset(t *testing.T) {
+	ctx := context.Background()
+	user := &model.DBUser{
+		ID:       uuid.MustParse("7a2f922c-073a-11eb-adc1-0242ac120002"),
+		Role:     model.RoleViewer,
+		Nickname: "topol",
+		Email:    "topol@example.com",
+		Password: "old-hash",
+		Version:  2,
+	}
+
+	tests := []struct {
+		testName string
+		login    string
+		lookups  func(repo *mocks.UserRepo)
+		mailed   bool
+	}{
+		{
+			testName: "by email",
+			login:    "Topol@Example.com",
+			lookups: func(repo *mocks.UserRepo) {
+				repo.On("GetUserByEmail", ctx, "Topol@Example.com").Return(user, nil)
+			},
+			mailed: true,
+		},
+		{
+			testName: "by nickname",
+			login:    "topol",
+			lookups: func(repo *mocks.UserRepo) {
+				repo.On("GetUserByNickname", ctx, "topol").Return(user, nil)
+			},
+			mailed: true,
+		},
+		{
+			testName: "unknown user",
+			login:    "nobody@example.com",
+			lookups: func(repo *mocks.UserRepo) {
+				repo.On("GetUserByEmail", ctx, "nobody@example.com").Return(nil, nil)
+				repo.On("GetUserByNickname", ctx, "nobody@example.com").Return((*model.DBUser)(nil), nil)
+			},
+			mailed: false,
+		},
+		{
+			testName: "user without email",
+			login:    "anon",
+			lookups: func(repo *mocks.UserRepo) {
+				repo.On("GetUserByNickname", ctx, "anon").Return(&model.DBUser{ID: uuid.New(), Nickname: "anon"}, nil)
+			},
+			mailed: false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Logf("running %v", test.testName)
+
+		userRepo := &mocks.UserRepo{}
+		resetRepo := &mocks.PasswordResetRepo{}
+		auditRepo := &mocks.AuditRepo{}
+		auditRepo.On("CreateAuditEvent", mock.Anything, mock.Anything).Return(nil)
+		test.lookups(userRepo)
+
+		var created *model.DBPasswordResetToken
+		if test.mailed {
+			resetRepo.On("CreatePasswordResetToken", ctx, mock.Anything).Run(func(args mock.Arguments) {
+				created = args.Get(1).(*model.DBPasswordResetToken)
+			}).Return(nil).Once()
+		}
+
+		dir := t.TempDir()
+		svc := NewPasswordWebService(ctx, &store.Store{
+			User:          userRepo,
+			PasswordReset: resetRepo,
+			Audit:         auditRepo,
+		}, mail.NewFileMailer(dir, "no-reply@example.com"), nil)
+		svc.resetURL = "https://example.com/reset"
+
+		err := svc.sendResetToken(ctx, test.login)
+		require.NoError(t, err)
+
+		files, err := os.ReadDir(dir)
+		require.NoError(t, err)
+		userRepo.AssertExpectations(t)
+		resetRepo.AssertExpectations(t)
+		if !test.mailed {
+			assert.Empty(t, files)
+			continue
+		}
+		require.Len(t, files, 1)
+		data, err := os.ReadFile(filepath.Join(dir, files[0].Name()))
+		require.NoError(t, err)
+		assert.Contains(t, string(data), "To: topol@example.com\r\n")
+		match := resetLink.FindStringSubmatch(string(data))
+		require.NotNil(t, match)
+
+		// only the hash of the mailed token is stored
+		token := match[1]
+		assert.Equal(t, user.ID, created.UserID)
+		assert.Equal(t, hashToken(token), created.TokenHash)
+		assert.NotContains(t, string(data), created.TokenHash)
+	}
+}
+
+// TestForgotPassword checks reset requests are throttled and mailed by a
+// bounded number of workers
+func TestForgotPassword(t *testing.T) {
+	throttle := NewLoginThrottle(memory.NewLoginAttemptRepo(), &config.Config{
+		LoginMaxFailures:   5,
+		LoginMaxIPFailures: 20,
+		LoginBackoff:       time.Minute,
+		LoginLockout:       time.Hour,
+	})
+
+	tests := []struct {
+		testName string
+		login    string
+		ip       string
+		busy     bool
+		cause    error
+		mailed   bool
+	}{
+		{
+			testName: "first request",
+			login:    "topol",
+			ip:       "192.0.2.1",
+			mailed:   true,
+		},
+		{
+			testName: "request again from another IP",
+			login:    "Topol",
+			ip:       "198.51.100.7",
+			cause:    types.ErrTooManyRequests,
+		},
+		{
+			testName: "request for another login",
+			login:    "bohdan",
+			ip:       "192.0.2.1",
+			cause:    types.ErrTooManyRequests,
+		},
+		{
+			testName: "every worker busy",
+			login:    "shevchenko",
+			ip:       "203.0.113.5",
+			busy:     true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Logf("running %v", test.testName)
+
+		ctx := WithRequestInfo(context.Background(), &model.RequestInfo{IP: test.ip})
+		userRepo := &mocks.UserRepo{}
+		lookedUp := make(chan struct{})
+		if test.mailed {
+			userRepo.On("GetUserByNickname", mock.Anything, test.login).Run(func(mock.Arguments) {
+				close(lookedUp)
+			}).Return((*model.DBUser)(nil), nil).Once()
+		}
+		svc := NewPasswordWebService(ctx, &store.Store{User: userRepo}, mail.NewLogMailer(os.Stderr, "no-reply@example.com"), throttle)
+		if test.busy {
+			for i := 0; i < cap(svc.workers); i++ {
+				svc.workers <- struct{}{}
+			}
+		}
+
+		err := svc.ForgotPassword(ctx, test.login)
+		assert.Equal(t, test.cause, errors.Cause(err))
+		if test.mailed {
+			select {
+			case <-lookedUp:
+			case <-time.After(time.Second):
+				t.Error("the reset token was not looked up")
+			}
+			// the worker gives its slot back
+			assert.Eventually(t, func() bool { return len(svc.workers) == 0 }, time.Second, time.Millisecond)
+		}
+		userRepo.AssertExpectations(t)
+	}
+}
+
+func TestResetPassword(t *testing.T) {
+	ctx := context.Background()
+	oldHash, err := bcrypt.GenerateFromPassword([]byte("0ld-passw0rd"), bcrypt.MinCost)
+	require.NoError(t, err)
+	olderHash, err := bcrypt.GenerateFromPassword([]byte("0lder-passw0rd"), bcrypt.MinCost)
+	require.NoError(t, err)
+	user := &model.DBUser{
+		ID:       uuid.MustParse("7a2f922c-073a-11eb-adc1-0242ac120002"),
+		Nickname: "topol",
+		Password: string(oldHash),
+		Version:  2,
+	}
+	resetToken := &model.DBPasswordResetToken{ID: uuid.New(), UserID: user.ID, TokenHash: hashToken("valid")}
+
+	tests := []struct {
+		testName     string
+		token        string
+		password     string
+		expectations func(m *passwordMocks)
+		err          error
+	}{
+		{
+			testName: "valid token",
+			token:    "valid",
+			password: "n3w-passw0rd",
+			expectations: func(m *passwordMocks) {
+				m.reset.On("GetPasswordResetToken", ctx, hashToken("valid")).Return(resetToken, nil)
+				m.user.On("GetUser", ctx, user.ID).Return(user, nil)
+				m.history.On("ListPasswordHistory", ctx, user.ID, 4).Return([]string{string(olderHash)}, nil)
+				m.reset.On("ConsumePasswordResetToken", ctx, hashToken("valid")).Return(resetToken, nil)
+				m.user.On("UpdateUser", ctx, mock.MatchedBy(func(updated *model.DBUser) bool {
+					ok, _, _ := testPasswordHasher().Verify(updated.Password, "n3w-passw0rd")
+					return ok && updated.Version == 2
+				})).Return(user, nil)
+				m.history.On("AddPasswordHistory", ctx, mock.MatchedBy(func(entry *model.DBPasswordHistory) bool {
+					return entry.UserID == user.ID && entry.PasswordHash == string(oldHash)
+				}), 4).Return(nil)
+				m.token.On("RevokeUserTokens", ctx, user.ID).Return(nil)
+				m.session.On("RevokeUserSessions", ctx, user.ID, (*uuid.UUID)(nil)).Return(nil)
+				m.reset.On("DeleteUserPasswordResetTokens", ctx, user.ID).Return(nil)
+			},
+		},
+		{
+			testName: "used or expired token",
+			token:    "used",
+			password: "n3w-passw0rd",
+			expectations: func(m *passwordMocks) {
+				m.reset.On("GetPasswordResetToken", ctx, hashToken("used")).Return(nil, nil)
+			},
+			err: types.ErrUnprocessableEntity,
+		},
+		{
+			testName: "deleted user",
+			token:    "valid",
+			password: "n3w-passw0rd",
+			expectations: func(m *passwordMocks) {
+				m.reset.On("GetPasswordResetToken", ctx, hashToken("valid")).Return(resetToken, nil)
+				m.user.On("GetUser", ctx, user.ID).Return(nil, nil)
+			},
+			err: types.ErrUnprocessableEntity,
+		},
+		{
+			testName: "reused password keeps the token",
+			token:    "valid",
+			password: "0lder-passw0rd",
+			expectations: func(m *passwordMocks) {
+				m.reset.On("GetPasswordResetToken", ctx, hashToken("valid")).Return(resetToken, nil)
+				m.user.On("GetUser", ctx, user.ID).Return(user, nil)
+				m.history.On("ListPasswordHistory", ctx, user.ID, 4).Return([]string{string(olderHash)}, nil)
+			},
+			err: types.ErrUnprocessableEntity,
+		},
+		{
+			testName: "concurrently used token",
+			token:    "valid",
+			password: "n3w-passw0rd",
+			expectations: func(m *passwordMocks) {
+				m.reset.On("GetPasswordResetToken", ctx, hashToken("valid")).Return(resetToken, nil)
+				m.user.On("GetUser", ctx, user.ID).Return(user, nil)
+				m.history.On("ListPasswordHistory", ctx, user.ID, 4).Return(nil, nil)
+				m.reset.On("ConsumePasswordResetToken", ctx, hashToken("valid")).Return(nil, nil)
+			},
+			err: types.ErrUnprocessableEntity,
+		},
+		{
+			testName: "concurrently changed user",
+			token:    "valid",
+			password: "n3w-passw0rd",
+			expectations: func(m *passwordMocks) {
+				m.reset.On("GetPasswordResetToken", ctx, hashToken("valid")).Return(resetToken, nil)
+				m.user.On("GetUser", ctx, user.ID).Return(user, nil)
+				m.history.On("ListPasswordHistory", ctx, user.ID, 4).Return(nil, nil)
+				m.reset.On("ConsumePasswordResetToken", ctx, hashToken("valid")).Return(resetToken, nil)
+				m.user.On("UpdateUser", ctx, mock.Anything).Return(nil, types.ErrConflict)
+			},
+			err: types.ErrConflict,
+		},
+	}
+
+	for _, test := range tests {
+		t.Logf("running %v", test.testName)
+
+		m := newPasswordMocks()
+		test.expectations(m)
+
+		svc := NewPasswordWebService(ctx, m.store(), mail.NewLogMailer(os.Stderr, "no-reply@example.com"), nil)
+		svc.hasher = testPasswordHasher()
+
+		err := svc.ResetPassword(ctx, test.token, test.password)
+		if test.err != nil {
+			assert.Equal(t, test.err, errors.Cause(err))
+		} else {
+			assert.NoError(t, err)
+		}
+		m.assertExpectations(t)
+	}
+}
+
+// passwordMocks are the stores a password change touches
+type passwordMocks struct {
+	user    *mocks.UserRepo
+	token   *mocks.RefreshTokenRepo
+	reset   *mocks.PasswordResetRepo
+	history *mocks.PasswordHistoryRepo
+	session *mocks.SessionRepo
+	audit   *mocks.AuditRepo
+}
+
+func newPasswordMocks() *passwordMocks {
+	m := &passwordMocks{
+		user:    &mocks.UserRepo{},
+		token:   &mocks.RefreshTokenRepo{},
+		reset:   &mocks.PasswordResetRepo{},
+		history: &mocks.PasswordHistoryRepo{},
+		session: &mocks.SessionRepo{},
+		audit:   &mocks.AuditRepo{},
+	}
+	m.audit.On("CreateAuditEvent", mock.Anything, mock.Anything).Return(nil)
+	return m
+}
+
+func (m *passwordMocks) store() *store.Store {
+	return &store.Store{
+		User:            m.user,
+		RefreshToken:    m.token,
+		PasswordReset:   m.reset,
+		PasswordHistory: m.history,
+		Session:         m.session,
+		Audit:           m.audit,
+	}
+}
+
+func (m *passwordMocks) assertExpectations(t *testing.T) {
+	m.user.AssertExpectations(t)
+	m.token.AssertExpectations(t)
+	m.reset.AssertExpectations(t)
+	m.history.AssertExpectations(t)
+	m.session.AssertExpectations(t)
+}
+
+func TestChangePassword(t *testing.T) {
+	ctx := context.Background()
+	oldHash, err := bcrypt.GenerateFromPassword([]byte("0ld-passw0rd"), bcrypt.MinCost)
+	require.NoError(t, err)
+	user := &model.DBUser{
+		ID:       uuid.MustParse("7a2f922c-073a-11eb-adc1-0242ac120002"),
+		Nickname: "topol",
+		Password: string(oldHash),
+		Version:  2,
+	}
+	session := &model.DBRefreshToken{ID: uuid.New(), UserID: user.ID, FamilyID: uuid.New()}
+
+	// changed stubs a successful password change
+	changed := func(m *passwordMocks) {
+		m.history.On("ListPasswordHistory", ctx, user.ID, 4).Return(nil, nil)
+		m.user.On("UpdateUser", ctx, mock.Anything).Return(user, nil)
+		m.history.On("AddPasswordHistory", ctx, mock.Anything, 4).Return(nil)
+		m.reset.On("DeleteUserPasswordResetTokens", ctx, user.ID).Return(nil)
+	}
+
+	tests := []struct {
+		testName      string
+		change        *model.PasswordChange
+		verifyCurrent bool
+		expectations  func(m *passwordMocks)
+		err           error
+	}{
+		{
+			testName:      "own password",
+			change:        &model.PasswordChange{CurrentPassword: "0ld-passw0rd", NewPassword: "n3w-passw0rd"},
+			verifyCurrent: true,
+			expectations: func(m *passwordMocks) {
+				m.user.On("GetUser", ctx, user.ID).Return(user, nil)
+				changed(m)
+				m.token.On("RevokeUserTokens", ctx, user.ID).Return(nil)
+				m.session.On("RevokeUserSessions", ctx, user.ID, (*uuid.UUID)(nil)).Return(nil)
+			},
+		},
+		{
+			testName:      "keeps the current session",
+			change:        &model.PasswordChange{CurrentPassword: "0ld-passw0rd", NewPassword: "n3w-passw0rd", RefreshToken: "session"},
+			verifyCurrent: true,
+			expectations: func(m *passwordMocks) {
+				m.user.On("GetUser", ctx, user.ID).Return(user, nil)
+				changed(m)
+				m.token.On("GetRefreshTokenByHash", ctx, hashToken("session")).Return(session, nil)
+				m.token.On("RevokeOtherUserTokens", ctx, user.ID, session.FamilyID).Return(nil)
+				m.session.On("RevokeUserSessions", ctx, user.ID, (*uuid.UUID)(nil)).Return(nil)
+			},
+		},
+		{
+			testName:      "wrong current password",
+			change:        &model.PasswordChange{CurrentPassword: "guess", NewPassword: "n3w-passw0rd"},
+			verifyCurrent: true,
+			expectations: func(m *passwordMocks) {
+				m.user.On("GetUser", ctx, user.ID).Return(user, nil)
+			},
+			err: types.ErrForbidden,
+		},
+		{
+			testName:      "admin sets password",
+			change:        &model.PasswordChange{NewPassword: "n3w-passw0rd"},
+			verifyCurrent: false,
+			expectations: func(m *passwordMocks) {
+				m.user.On("GetUser", ctx, user.ID).Return(user, nil)
+				changed(m)
+				m.token.On("RevokeUserTokens", ctx, user.ID).Return(nil)
+				m.session.On("RevokeUserSessions", ctx, user.ID, (*uuid.UUID)(nil)).Return(nil)
+			},
+		},
+		{
+			testName:      "current password again",
+			change:        &model.PasswordChange{CurrentPassword: "0ld-passw0rd", NewPassword: "0ld-passw0rd"},
+			verifyCurrent: true,
+			expectations: func(m *passwordMocks) {
+				m.user.On("GetUser", ctx, user.ID).Return(user, nil)
+			},
+			err: types.ErrUnprocessableEntity,
+		},
+	}
+
+	for _, test := range tests {
+		t.Logf("running %v", test.testName)
+
+		m := newPasswordMocks()
+		test.expectations(m)
+
+		svc := NewUserWebService(ctx, m.store(), newTestTokenIssuer(t), nil)
+		svc.hasher = testPasswordHasher()
+		err := svc.ChangePassword(ctx, user.ID, test.change, test.verifyCurrent)
+		if test.err != nil {
+			assert.Equal(t, test.err, errors.Cause(err))
+		} else {
+			assert.NoError(t, err)
+		}
+		m.assertExpectations(t)
+	}
+}
+
+// TestPasswordPolicy lists every rule a new password breaks
+func TestPasswordPolicy(t *testing.T) {
+	breachedDir := t.TempDir()
+	sum := sha1.Sum([]byte("Tr0ub4dor&3"))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	rangeFile := "0018A45C4D1DEF81644B54AB7F969B88D65:1\n" + hash[5:] + ":3645804\n"
+	require.NoError(t, os.WriteFile(filepath.Join(breachedDir, hash[:5]), []byte(rangeFile), 0o600))
+
+	policy := validator.DefaultPasswordPolicy()
+	policy.RequireUpper = true
+	policy.Breached = validator.NewBreachedPasswords(breachedDir)
+	user := &model.DBUser{Nickname: "topol", Firstname: "Vika", Lastname: "Gorobets"}
+
+	tests := []struct {
+		testName string
+		password string
+		rules    []string
+	}{
+		{testName: "strong", password: "n3w-Passw0rd"},
+		{testName: "short", password: "aaaa", rules: []string{"min_length", "uppercase", "digit", "special", "strength"}},
+		{testName: "too long", password: "Ab1!" + strings.Repeat("x9#Q", 18), rules: []string{"max_length"}},
+		{testName: "missing classes", password: "correcthorsebatterystaple", rules: []string{"uppercase", "digit", "special"}},
+		{testName: "common", password: "Password1!", rules: []string{"strength"}},
+		{testName: "nickname", password: "Topol2023!", rules: []string{"personal_info"}},
+		{testName: "breached", password: "Tr0ub4dor&3", rules: []string{"breached"}},
+	}
+
+	for _, test := range tests {
+		t.Logf("running %v", test.testName)
+
+		err := checkPasswordPolicy(policy, user, test.password, "new_password")
+		if test.rules == nil {
+			assert.NoError(t, err)
+			continue
+		}
+
+		var validationErr *types.ValidationError
+		require.True(t, errors.As(err, &validationErr))
+		assert.Equal(t, types.ErrUnprocessableEntity, errors.Cause(err))
+		var rules []string
+		for _, violation := range validationErr.Violations {
+			assert.Equal(t, "new_password", violation.Field)
+			rules = append(rules, violation.Rule)
+		}
+		assert.Equal(t, test.rules, rules)
+	}
+}
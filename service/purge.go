@@ -0,0 +1,53 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/VikaGo/REST_API/logger"
+	"github.com/VikaGo/REST_API/store"
+)
+
+// UserPurger permanently removes users that were soft-deleted longer than
+// the retention period ago
+type UserPurger struct {
+	store     *store.Store
+	retention time.Duration
+	interval  time.Duration
+}
+
+// NewUserPurger creates a new user purger
+func NewUserPurger(store *store.Store, retention, interval time.Duration) *UserPurger {
+	return &UserPurger{
+		store:     store,
+		retention: retention,
+		interval:  interval,
+	}
+}
+
+// Run purges users every interval until ctx is done
+func (p *UserPurger) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+	for {
+		p.Purge(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Purge removes users deleted before the retention period once
+func (p *UserPurger) Purge(ctx context.Context) {
+	logger := logger.Get()
+	purged, err := p.store.User.PurgeDeletedUsers(ctx, time.Now().Add(-p.retention))
+	if err != nil {
+		logger.Error().Err(err).Msg("[service.UserPurger] Could not purge deleted users")
+		return
+	}
+	if purged > 0 {
+		logger.Info().Msgf("[service.UserPurger] Purged %d deleted users", purged)
+	}
+}
@@ -0,0 +1,71 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/VikaGo/REST_API/model"
+	"github.com/VikaGo/REST_API/pkg/types"
+	"github.com/VikaGo/REST_API/store"
+	"github.com/pkg/errors"
+)
+
+// RoleWebService ...
+type RoleWebService struct {
+	ctx   context.Context
+	store *store.Store
+}
+
+// NewRoleWebService creates a new role web service
+func NewRoleWebService(ctx context.Context, store *store.Store) *RoleWebService {
+	return &RoleWebService{
+		ctx:   ctx,
+		store: store,
+	}
+}
+
+// GetRoleSettings returns the settings of a role, the defaults if they were never changed
+func (svc *RoleWebService) GetRoleSettings(ctx context.Context, role string) (*model.RoleSettings, error) {
+	return getRoleSettings(ctx, svc.store, role)
+}
+
+func getRoleSettings(ctx context.Context, repos *store.Store, role string) (*model.RoleSettings, error) {
+	if !model.IsValidRole(role) {
+		return nil, errors.Wrap(types.ErrNotFound, fmt.Sprintf("Role '%s' not found", role))
+	}
+
+	settings, err := repos.RoleSettings.GetRoleSettings(ctx, role)
+	if err != nil {
+		return nil, errors.Wrap(err, "svc.roleSettings.GetRoleSettings")
+	}
+	if settings == nil {
+		settings = &model.RoleSettings{Role: role}
+	}
+	return settings, nil
+}
+
+// UpdateRoleSettings replaces the settings of a role
+func (svc *RoleWebService) UpdateRoleSettings(ctx context.Context, settings *model.RoleSettings) (*model.RoleSettings, error) {
+	err := svc.store.Tx(ctx, func(repos *store.Store) error {
+		current, err := getRoleSettings(ctx, repos, settings.Role)
+		if err != nil {
+			return err
+		}
+
+		err = repos.RoleSettings.SaveRoleSettings(ctx, settings)
+		if err != nil {
+			return errors.Wrap(err, "svc.roleSettings.SaveRoleSettings error")
+		}
+
+		var fields []string
+		if current.Require2FA != settings.Require2FA {
+			fields = append(fields, "require_2fa")
+		}
+		return recordAuditTx(ctx, repos, model.AuditRoleUpdate, nil, fields)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return settings, nil
+}
@@ -9,11 +9,67 @@ import (
 
 type UserService interface {
 	GetUser(context.Context, uuid.UUID) (*model.User, error)
-	CreateUser(context.Context, *model.User) (*model.User, error)
-	UpdateUser(context.Context, *model.User) (*model.User, error)
-	DeleteUser(context.Context, uuid.UUID) error
-	GetPassword(context.Context, uuid.UUID) (string, error)
-	UpdatePassword(ctx context.Context, id uuid.UUID, newPassword string) error
+	GetUserIncludingDeleted(context.Context, uuid.UUID) (*model.User, error)
+	CreateUser(context.Context, *model.UserCreate) (*model.User, error)
+	UpdateUser(ctx context.Context, id uuid.UUID, version int, update *model.UserUpdate) (*model.User, error)
+	PatchUser(ctx context.Context, id uuid.UUID, version int, patch *model.UserPatch) (*model.User, error)
+	DeleteUser(ctx context.Context, id uuid.UUID, version int) error
+	RestoreUser(context.Context, uuid.UUID) (*model.User, error)
+	UnlockUser(context.Context, uuid.UUID) error
+	ChangePassword(ctx context.Context, id uuid.UUID, change *model.PasswordChange, verifyCurrent bool) error
 	GetUserByNickname(ctx context.Context, nickname string) (*model.User, error)
-	GenerateToken(ctx context.Context, nickname string, password string) (string, error)
+	ListUsers(context.Context, *model.UserListParams) (*model.UserPage, error)
+	GenerateToken(ctx context.Context, nickname string, password string) (*model.LoginResult, error)
+	CompleteLogin(ctx context.Context, challengeToken string, code string) (*model.LoginResult, error)
+	OIDCLogin(ctx context.Context, provider string) (*model.OIDCLoginRedirect, error)
+	CompleteOIDCLogin(ctx context.Context, provider string, callback *model.OIDCCallback, stateToken string) (*model.LoginResult, error)
+	EnrollTwoFactor(context.Context, uuid.UUID) (*model.TOTPEnrollment, error)
+	EnrollTwoFactorWithChallenge(ctx context.Context, challengeToken string) (*model.TOTPEnrollment, error)
+	ConfirmTwoFactor(ctx context.Context, id uuid.UUID, code string) (*model.RecoveryCodes, error)
+	RefreshToken(ctx context.Context, refreshToken string) (*model.TokenPair, error)
+	Logout(ctx context.Context, refreshToken string) error
+	LogoutAll(ctx context.Context, userID uuid.UUID) error
+	ParseToken(ctx context.Context, accessToken string) (*model.Principal, error)
+	ParseSession(ctx context.Context, sessionToken string, csrfToken string, checkCSRF bool) (*model.Principal, error)
+	ListSessions(ctx context.Context, userID uuid.UUID) ([]*model.Session, error)
+	RevokeSession(ctx context.Context, userID uuid.UUID, sessionID uuid.UUID) error
+}
+
+type KeyService interface {
+	JWKS() *model.JWKSet
+}
+
+type AuditService interface {
+	ListAuditEvents(context.Context, *model.AuditListParams) (*model.AuditPage, error)
+}
+
+type RoleService interface {
+	GetRoleSettings(ctx context.Context, role string) (*model.RoleSettings, error)
+	UpdateRoleSettings(context.Context, *model.RoleSettings) (*model.RoleSettings, error)
+}
+
+type PasswordService interface {
+	ForgotPassword(ctx context.Context, login string) error
+	ResetPassword(ctx context.Context, token string, password string) error
+}
+
+type APIKeyService interface {
+	CreateAPIKey(ctx context.Context, userID uuid.UUID, input *model.APIKeyInput) (*model.CreatedAPIKey, error)
+	ListAPIKeys(ctx context.Context, userID uuid.UUID) ([]*model.APIKey, error)
+	RevokeAPIKey(ctx context.Context, userID uuid.UUID, keyID uuid.UUID) error
+	ParseAPIKey(ctx context.Context, key string) (*model.Principal, error)
+}
+
+type OAuthService interface {
+	RegisterClient(context.Context, *model.OAuthClientInput) (*model.RegisteredOAuthClient, error)
+	ListClients(context.Context) ([]*model.OAuthClient, error)
+	DeleteClient(ctx context.Context, clientID string) error
+	Authorize(ctx context.Context, userID uuid.UUID, req *model.AuthorizeRequest) (string, error)
+	Token(context.Context, *model.TokenRequest) (*model.OAuthTokenResponse, error)
+	UserInfo(ctx context.Context, accessToken string) (*model.UserInfo, error)
+	Discovery() *model.OIDCDiscovery
+}
+
+type HealthService interface {
+	Health(context.Context) *model.Health
 }
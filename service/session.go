@@ -0,0 +1,215 @@
+package service
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/VikaGo/REST_API/model"
+	"github.com/VikaGo/REST_API/pkg/types"
+	"github.com/VikaGo/REST_API/store"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultSessionTTL         = 24 * time.Hour
+	defaultSessionIdleTimeout = 30 * time.Minute
+	// sessionTouchInterval bounds how often the last use of a session is written
+	sessionTouchInterval = time.Minute
+)
+
+type sessionLoginKey struct{}
+
+// WithSessionLogin asks the login completed with ctx for a cookie session
+// instead of tokens
+func WithSessionLogin(ctx context.Context) context.Context {
+	return context.WithValue(ctx, sessionLoginKey{}, true)
+}
+
+// IsSessionLogin reports whether ctx asks for a cookie session
+func IsSessionLogin(ctx context.Context) bool {
+	session, _ := ctx.Value(sessionLoginKey{}).(bool)
+	return session
+}
+
+// checkSessionLogin rejects logins asking for a cookie session if they are disabled
+func (svc *UserWebService) checkSessionLogin(ctx context.Context) error {
+	if IsSessionLogin(ctx) && !svc.sessions {
+		return errors.Wrap(types.ErrBadRequest, "cookie sessions are disabled")
+	}
+	return nil
+}
+
+// finishLogin gives the logged in user the tokens, or the cookie session,
+// the login asked for
+func (svc *UserWebService) finishLogin(ctx context.Context, user *model.DBUser, result *model.LoginResult) error {
+	var err error
+	if IsSessionLogin(ctx) {
+		result.Session, err = svc.createSession(ctx, user)
+	} else {
+		// every login starts a new refresh token family
+		result.TokenPair, err = svc.issueTokens(ctx, svc.store, user, uuid.New(), uuid.New())
+	}
+	return err
+}
+
+func (svc *UserWebService) createSession(ctx context.Context, user *model.DBUser) (*model.CreatedSession, error) {
+	if err := svc.checkSessionLogin(ctx); err != nil {
+		return nil, err
+	}
+
+	token, err := newOpaqueToken()
+	if err != nil {
+		return nil, errors.Wrap(err, "could not generate session token")
+	}
+	csrfToken, err := newOpaqueToken()
+	if err != nil {
+		return nil, errors.Wrap(err, "could not generate CSRF token")
+	}
+
+	info := RequestInfoFromContext(ctx)
+	now := time.Now()
+	session := &model.DBSession{
+		ID:         uuid.New(),
+		UserID:     user.ID,
+		TokenHash:  hashToken(token),
+		CSRFHash:   hashToken(csrfToken),
+		Device:     describeDevice(info.UserAgent),
+		IP:         info.IP,
+		UserAgent:  info.UserAgent,
+		CreatedAt:  now,
+		LastSeenAt: now,
+		ExpiresAt:  now.Add(svc.sessionTTL),
+	}
+	if err := svc.store.Session.CreateSession(ctx, session); err != nil {
+		return nil, errors.Wrap(err, "svc.session.CreateSession error")
+	}
+
+	return &model.CreatedSession{
+		ID:        session.ID,
+		Token:     token,
+		CSRFToken: csrfToken,
+		ExpiresAt: session.ExpiresAt,
+	}, nil
+}
+
+// ParseSession validates a session token and returns the principal it
+// belongs to. Requests that change state must present the CSRF token of
+// the session when checkCSRF is set.
+func (svc *UserWebService) ParseSession(ctx context.Context, sessionToken, csrfToken string, checkCSRF bool) (*model.Principal, error) {
+	session, err := svc.store.Session.GetSessionByHash(ctx, hashToken(sessionToken))
+	if err != nil {
+		return nil, errors.Wrap(err, "svc.session.GetSessionByHash error")
+	}
+	now := time.Now()
+	switch {
+	case session == nil:
+		return nil, errors.Wrap(types.ErrUnauthorized, "invalid session")
+	case session.RevokedAt != nil:
+		return nil, errors.Wrap(types.ErrUnauthorized, "session was revoked")
+	case !now.Before(session.ExpiresAt):
+		return nil, errors.Wrap(types.ErrUnauthorized, "session expired")
+	case svc.sessionIdleTimeout > 0 && now.Sub(session.LastSeenAt) > svc.sessionIdleTimeout:
+		return nil, errors.Wrap(types.ErrUnauthorized, "session expired after being idle")
+	}
+	if checkCSRF && subtle.ConstantTimeCompare([]byte(hashToken(csrfToken)), []byte(session.CSRFHash)) != 1 {
+		return nil, errors.Wrap(types.ErrForbidden, "invalid CSRF token")
+	}
+
+	// the role is the current one, not the one of the login
+	user, err := svc.store.User.GetUser(ctx, session.UserID)
+	if err != nil {
+		return nil, errors.Wrap(err, "svc.user.GetUser error")
+	}
+	if user == nil {
+		return nil, errors.Wrap(types.ErrUnauthorized, "session owner no longer exists")
+	}
+
+	err = svc.store.Session.TouchSession(ctx, session.ID, now, now.Add(-sessionTouchInterval))
+	if err != nil {
+		return nil, errors.Wrap(err, "svc.session.TouchSession error")
+	}
+
+	return &model.Principal{
+		UserID:    user.ID,
+		Role:      user.Role,
+		SessionID: &session.ID,
+	}, nil
+}
+
+// ListSessions returns the active cookie sessions of a user
+func (svc *UserWebService) ListSessions(ctx context.Context, userID uuid.UUID) ([]*model.Session, error) {
+	sessionsDB, err := svc.store.Session.ListUserSessions(ctx, userID, time.Now())
+	if err != nil {
+		return nil, errors.Wrap(err, "svc.session.ListUserSessions error")
+	}
+
+	current := RequestInfoFromContext(ctx).SessionID
+	sessions := make([]*model.Session, 0, len(sessionsDB))
+	for _, sessionDB := range sessionsDB {
+		session := sessionDB.ToWeb()
+		session.Current = current != nil && *current == session.ID
+		sessions = append(sessions, session)
+	}
+	return sessions, nil
+}
+
+// RevokeSession ends a cookie session of a user
+func (svc *UserWebService) RevokeSession(ctx context.Context, userID, sessionID uuid.UUID) error {
+	return svc.store.Tx(ctx, func(repos *store.Store) error {
+		revoked, err := repos.Session.RevokeSession(ctx, userID, sessionID)
+		if err != nil {
+			return errors.Wrap(err, "svc.session.RevokeSession error")
+		}
+		if !revoked {
+			return errors.Wrap(types.ErrNotFound, fmt.Sprintf("Session '%s' not found", sessionID.String()))
+		}
+		return recordAuditTx(ctx, repos, model.AuditSessionRevoke, &userID, nil)
+	})
+}
+
+// describeDevice names the browser and operating system of a user agent,
+// like "Firefox on Linux", or returns "" for unknown ones
+func describeDevice(userAgent string) string {
+	// the order matters: Edge and Opera claim to be Chrome, Chrome claims to be Safari
+	var browser string
+	for _, b := range []struct{ token, name string }{
+		{"Edg/", "Edge"},
+		{"OPR/", "Opera"},
+		{"Firefox/", "Firefox"},
+		{"Chrome/", "Chrome"},
+		{"Safari/", "Safari"},
+	} {
+		if strings.Contains(userAgent, b.token) {
+			browser = b.name
+			break
+		}
+	}
+
+	var os string
+	for _, o := range []struct{ token, name string }{
+		{"Android", "Android"},
+		{"iPhone", "iOS"},
+		{"iPad", "iOS"},
+		{"Windows", "Windows"},
+		{"Mac OS X", "macOS"},
+		{"Linux", "Linux"},
+	} {
+		if strings.Contains(userAgent, o.token) {
+			os = o.name
+			break
+		}
+	}
+
+	switch {
+	case browser != "" && os != "":
+		return browser + " on " + os
+	case browser != "":
+		return browser
+	default:
+		return os
+	}
+}
@@ -0,0 +1,181 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/VikaGo/REST_API/model"
+	"github.com/VikaGo/REST_API/pkg/types"
+	"github.com/VikaGo/REST_API/store/mocks"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// TestSessionLogin logs in with a cookie session and uses it
+func TestSessionLogin(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("s3cret-pass"), bcrypt.MinCost)
+	require.NoError(t, err)
+	user := &model.DBUser{ID: uuid.New(), Role: model.RoleEditor, Nickname: "topol", Password: string(hash)}
+	info := &model.RequestInfo{IP: "192.0.2.1", UserAgent: "Mozilla/5.0 (X11; Linux x86_64; rv:109.0) Gecko/20100101 Firefox/118.0"}
+	ctx := WithSessionLogin(WithRequestInfo(context.Background(), info))
+
+	svc, m := newTwoFactorService(t)
+	sessions := &mocks.SessionRepo{}
+	svc.store.Session = sessions
+
+	// disabled unless configured
+	_, err = svc.GenerateToken(ctx, user.Nickname, "s3cret-pass")
+	assert.Equal(t, types.ErrBadRequest, errors.Cause(err))
+	svc.sessions = true
+
+	var stored *model.DBSession
+	m.user.On("GetUserByNickname", ctx, user.Nickname).Return(user, nil)
+	m.twoFactor.On("GetTOTP", ctx, user.ID).Return(nil, nil)
+	m.roleSettings.On("GetRoleSettings", ctx, user.Role).Return(nil, nil)
+	sessions.On("CreateSession", ctx, mock.Anything).Run(func(args mock.Arguments) {
+		stored = args.Get(1).(*model.DBSession)
+	}).Return(nil)
+
+	result, err := svc.GenerateToken(ctx, user.Nickname, "s3cret-pass")
+	require.NoError(t, err)
+	assert.Nil(t, result.TokenPair, "a session login issues no tokens")
+	require.NotNil(t, result.Session)
+	require.NotNil(t, stored)
+	assert.Equal(t, hashToken(result.Session.Token), stored.TokenHash)
+	assert.Equal(t, hashToken(result.Session.CSRFToken), stored.CSRFHash)
+	assert.Equal(t, "Firefox on Linux", stored.Device)
+	assert.Equal(t, info.IP, stored.IP)
+	m.assertExpectations(t)
+	sessions.AssertExpectations(t)
+
+	revokedAt := time.Now()
+	tests := []struct {
+		testName  string
+		session   func(session model.DBSession) *model.DBSession
+		csrfToken string
+		checkCSRF bool
+		cause     error
+	}{
+		{
+			testName: "safe request",
+			session:  func(session model.DBSession) *model.DBSession { return &session },
+		},
+		{
+			testName:  "unsafe request",
+			session:   func(session model.DBSession) *model.DBSession { return &session },
+			csrfToken: result.Session.CSRFToken,
+			checkCSRF: true,
+		},
+		{
+			testName:  "unsafe request with another CSRF token",
+			session:   func(session model.DBSession) *model.DBSession { return &session },
+			csrfToken: "forged",
+			checkCSRF: true,
+			cause:     types.ErrForbidden,
+		},
+		{
+			testName: "unknown session",
+			session:  func(session model.DBSession) *model.DBSession { return nil },
+			cause:    types.ErrUnauthorized,
+		},
+		{
+			testName: "revoked session",
+			session: func(session model.DBSession) *model.DBSession {
+				session.RevokedAt = &revokedAt
+				return &session
+			},
+			cause: types.ErrUnauthorized,
+		},
+		{
+			testName: "expired session",
+			session: func(session model.DBSession) *model.DBSession {
+				session.ExpiresAt = time.Now().Add(-time.Second)
+				return &session
+			},
+			cause: types.ErrUnauthorized,
+		},
+		{
+			testName: "idle session",
+			session: func(session model.DBSession) *model.DBSession {
+				session.LastSeenAt = time.Now().Add(-time.Hour)
+				return &session
+			},
+			cause: types.ErrUnauthorized,
+		},
+	}
+
+	for _, test := range tests {
+		t.Logf("running %v", test.testName)
+
+		svc, m := newTwoFactorService(t)
+		sessions := &mocks.SessionRepo{}
+		svc.store.Session = sessions
+		ctx := context.Background()
+		sessions.On("GetSessionByHash", ctx, hashToken(result.Session.Token)).Return(test.session(*stored), nil)
+		if test.cause == nil {
+			m.user.On("GetUser", ctx, user.ID).Return(user, nil)
+			sessions.On("TouchSession", ctx, stored.ID, mock.Anything, mock.Anything).Return(nil)
+		}
+
+		principal, err := svc.ParseSession(ctx, result.Session.Token, test.csrfToken, test.checkCSRF)
+		if test.cause != nil {
+			assert.Equal(t, test.cause, errors.Cause(err))
+		} else {
+			require.NoError(t, err)
+			assert.Equal(t, user.ID, principal.UserID)
+			assert.Equal(t, user.Role, principal.Role)
+			assert.Equal(t, &stored.ID, principal.SessionID)
+		}
+		m.assertExpectations(t)
+		sessions.AssertExpectations(t)
+	}
+}
+
+func TestListSessions(t *testing.T) {
+	userID := uuid.New()
+	current, other := uuid.New(), uuid.New()
+	ctx := WithRequestInfo(context.Background(), &model.RequestInfo{SessionID: &current})
+
+	svc, _ := newTwoFactorService(t)
+	sessions := &mocks.SessionRepo{}
+	svc.store.Session = sessions
+	sessions.On("ListUserSessions", ctx, userID, mock.Anything).Return([]*model.DBSession{
+		{ID: other, UserID: userID, TokenHash: "hash", CSRFHash: "hash"},
+		{ID: current, UserID: userID, TokenHash: "hash", CSRFHash: "hash"},
+	}, nil)
+	sessions.On("RevokeSession", ctx, userID, other).Return(true, nil).Once()
+	sessions.On("RevokeSession", ctx, userID, other).Return(false, nil).Once()
+
+	list, err := svc.ListSessions(ctx, userID)
+	require.NoError(t, err)
+	require.Len(t, list, 2)
+	assert.False(t, list[0].Current)
+	assert.True(t, list[1].Current)
+
+	assert.NoError(t, svc.RevokeSession(ctx, userID, other))
+	assert.Equal(t, types.ErrNotFound, errors.Cause(svc.RevokeSession(ctx, userID, other)))
+	sessions.AssertExpectations(t)
+}
+
+func TestDescribeDevice(t *testing.T) {
+	tests := []struct {
+		userAgent string
+		device    string
+	}{
+		{"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/118.0.0.0 Safari/537.36 Edg/118.0.2088.46", "Edge on Windows"},
+		{"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.0 Safari/605.1.15", "Safari on macOS"},
+		{"Mozilla/5.0 (Linux; Android 10; K) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/118.0.0.0 Mobile Safari/537.36", "Chrome on Android"},
+		{"Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.0 Mobile/15E148 Safari/604.1", "Safari on iOS"},
+		{"curl/8.0", ""},
+	}
+
+	for _, test := range tests {
+		t.Logf("running %v", test.device)
+		assert.Equal(t, test.device, describeDevice(test.userAgent))
+	}
+}
@@ -0,0 +1,168 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"time"
+
+	"github.com/VikaGo/REST_API/model"
+	"github.com/VikaGo/REST_API/pkg/types"
+	"github.com/VikaGo/REST_API/store"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+// opaqueTokenBytes is the amount of randomness in refresh and reset tokens
+const opaqueTokenBytes = 32
+
+// issueTokens signs an access token for user and stores a new refresh token
+// with the given ID in the token family through repos
+func (svc *UserWebService) issueTokens(ctx context.Context, repos *store.Store, user *model.DBUser, familyID, tokenID uuid.UUID) (*model.TokenPair, error) {
+	accessToken, err := svc.signAccessToken(user)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not sign access token")
+	}
+
+	refreshToken, err := newOpaqueToken()
+	if err != nil {
+		return nil, errors.Wrap(err, "could not generate refresh token")
+	}
+
+	err = repos.RefreshToken.CreateRefreshToken(ctx, &model.DBRefreshToken{
+		ID:        tokenID,
+		UserID:    user.ID,
+		FamilyID:  familyID,
+		TokenHash: hashToken(refreshToken),
+		ExpiresAt: time.Now().Add(svc.tokens.RefreshTokenTTL),
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "svc.refreshToken.CreateRefreshToken error")
+	}
+
+	return &model.TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(svc.tokens.AccessTokenTTL.Seconds()),
+	}, nil
+}
+
+// RefreshToken exchanges a refresh token for a new token pair. The presented
+// token is revoked; presenting it again revokes its whole family.
+func (svc *UserWebService) RefreshToken(ctx context.Context, refreshToken string) (*model.TokenPair, error) {
+	dbToken, err := svc.store.RefreshToken.GetRefreshTokenByHash(ctx, hashToken(refreshToken))
+	if err != nil {
+		return nil, errors.Wrap(err, "svc.refreshToken.GetRefreshTokenByHash error")
+	}
+	if dbToken == nil {
+		return nil, errors.Wrap(types.ErrUnauthorized, "invalid refresh token")
+	}
+	if dbToken.RevokedAt != nil {
+		return nil, svc.revokeReusedToken(ctx, dbToken)
+	}
+	if time.Now().After(dbToken.ExpiresAt) {
+		return nil, errors.Wrap(types.ErrUnauthorized, "refresh token expired")
+	}
+
+	// the token is only revoked together with storing its successor, so a
+	// failed rotation leaves the presented token usable
+	var (
+		pair   *model.TokenPair
+		reused bool
+	)
+	err = svc.store.Tx(ctx, func(repos *store.Store) error {
+		reused = false
+		user, err := repos.User.GetUser(ctx, dbToken.UserID)
+		if err != nil {
+			return errors.Wrap(err, "svc.user.GetUser error")
+		}
+		if user == nil {
+			return errors.Wrap(types.ErrUnauthorized, "refresh token owner no longer exists")
+		}
+
+		newTokenID := uuid.New()
+		rotated, err := repos.RefreshToken.RevokeRefreshToken(ctx, dbToken.ID, &newTokenID)
+		if err != nil {
+			return errors.Wrap(err, "svc.refreshToken.RevokeRefreshToken error")
+		}
+		if !rotated {
+			// a concurrent request has already used this token
+			reused = true
+			return nil
+		}
+
+		pair, err = svc.issueTokens(ctx, repos, user, dbToken.FamilyID, newTokenID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if reused {
+		return nil, svc.revokeReusedToken(ctx, dbToken)
+	}
+	return pair, nil
+}
+
+// Logout revokes the refresh token family of the presented token
+func (svc *UserWebService) Logout(ctx context.Context, refreshToken string) error {
+	return svc.store.Tx(ctx, func(repos *store.Store) error {
+		dbToken, err := repos.RefreshToken.GetRefreshTokenByHash(ctx, hashToken(refreshToken))
+		if err != nil {
+			return errors.Wrap(err, "svc.refreshToken.GetRefreshTokenByHash error")
+		}
+		if dbToken == nil {
+			// already logged out or never issued: nothing to revoke
+			return nil
+		}
+
+		err = repos.RefreshToken.RevokeTokenFamily(ctx, dbToken.FamilyID)
+		if err != nil {
+			return errors.Wrap(err, "svc.refreshToken.RevokeTokenFamily error")
+		}
+		return nil
+	})
+}
+
+// LogoutAll revokes every refresh token and cookie session of the user.
+// Access tokens already issued stay valid until they expire.
+func (svc *UserWebService) LogoutAll(ctx context.Context, userID uuid.UUID) error {
+	return svc.store.Tx(ctx, func(repos *store.Store) error {
+		err := repos.RefreshToken.RevokeUserTokens(ctx, userID)
+		if err != nil {
+			return errors.Wrap(err, "svc.refreshToken.RevokeUserTokens error")
+		}
+		err = repos.Session.RevokeUserSessions(ctx, userID, nil)
+		if err != nil {
+			return errors.Wrap(err, "svc.session.RevokeUserSessions error")
+		}
+		return nil
+	})
+}
+
+// revokeReusedToken handles a revoked refresh token being presented again:
+// the family may be compromised, so every token in it is revoked.
+func (svc *UserWebService) revokeReusedToken(ctx context.Context, dbToken *model.DBRefreshToken) error {
+	err := svc.store.RefreshToken.RevokeTokenFamily(ctx, dbToken.FamilyID)
+	if err != nil {
+		return errors.Wrap(err, "svc.refreshToken.RevokeTokenFamily error")
+	}
+	return errors.Wrap(types.ErrUnauthorized, "refresh token reuse detected")
+}
+
+// newOpaqueToken generates an opaque random token
+func newOpaqueToken() (string, error) {
+	b := make([]byte, opaqueTokenBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashToken returns the hash under which an opaque token is stored
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,119 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/VikaGo/REST_API/model"
+	"github.com/VikaGo/REST_API/pkg/types"
+	"github.com/VikaGo/REST_API/store"
+	"github.com/VikaGo/REST_API/store/mocks"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// TestRefreshToken runs tests for refresh token rotation
+func TestRefreshToken(t *testing.T) {
+	user := &model.DBUser{
+		ID:   uuid.MustParse("7a2f922c-073a-11eb-adc1-0242ac120002"),
+		Role: model.RoleViewer,
+	}
+	revokedAt := time.Now().Add(-time.Minute)
+	active := &model.DBRefreshToken{
+		ID:        uuid.MustParse("3f1c2a1e-5d0b-4b8e-9a57-2f6c1e8d9b10"),
+		UserID:    user.ID,
+		FamilyID:  uuid.MustParse("c0a8e7d2-6b1f-4c3e-8a9d-5e4f3b2a1c0d"),
+		ExpiresAt: time.Now().Add(time.Hour),
+	}
+	revoked := *active
+	revoked.RevokedAt = &revokedAt
+	expired := *active
+	expired.ExpiresAt = time.Now().Add(-time.Hour)
+
+	const token = "opaque-refresh-token"
+	ctx := context.Background()
+	errInsert := errors.New("insert failed")
+
+	tests := []struct {
+		name         string
+		expectations func(userRepo *mocks.UserRepo, tokenRepo *mocks.RefreshTokenRepo)
+		err          error
+	}{
+		{
+			name: "valid token is rotated",
+			expectations: func(userRepo *mocks.UserRepo, tokenRepo *mocks.RefreshTokenRepo) {
+				tokenRepo.On("GetRefreshTokenByHash", ctx, hashToken(token)).Return(active, nil)
+				userRepo.On("GetUser", ctx, user.ID).Return(user, nil)
+				tokenRepo.On("RevokeRefreshToken", ctx, active.ID, mock.AnythingOfType("*uuid.UUID")).Return(true, nil)
+				tokenRepo.On("CreateRefreshToken", ctx, mock.MatchedBy(func(t *model.DBRefreshToken) bool {
+					return t.FamilyID == active.FamilyID && t.UserID == user.ID && t.TokenHash != hashToken(token)
+				})).Return(nil)
+			},
+		},
+		{
+			name: "unknown token",
+			expectations: func(userRepo *mocks.UserRepo, tokenRepo *mocks.RefreshTokenRepo) {
+				tokenRepo.On("GetRefreshTokenByHash", ctx, hashToken(token)).Return(nil, nil)
+			},
+			err: types.ErrUnauthorized,
+		},
+		{
+			name: "expired token",
+			expectations: func(userRepo *mocks.UserRepo, tokenRepo *mocks.RefreshTokenRepo) {
+				tokenRepo.On("GetRefreshTokenByHash", ctx, hashToken(token)).Return(&expired, nil)
+			},
+			err: types.ErrUnauthorized,
+		},
+		{
+			name: "reused token revokes family",
+			expectations: func(userRepo *mocks.UserRepo, tokenRepo *mocks.RefreshTokenRepo) {
+				tokenRepo.On("GetRefreshTokenByHash", ctx, hashToken(token)).Return(&revoked, nil)
+				tokenRepo.On("RevokeTokenFamily", ctx, active.FamilyID).Return(nil)
+			},
+			err: types.ErrUnauthorized,
+		},
+		{
+			name: "concurrent reuse revokes family",
+			expectations: func(userRepo *mocks.UserRepo, tokenRepo *mocks.RefreshTokenRepo) {
+				tokenRepo.On("GetRefreshTokenByHash", ctx, hashToken(token)).Return(active, nil)
+				userRepo.On("GetUser", ctx, user.ID).Return(user, nil)
+				tokenRepo.On("RevokeRefreshToken", ctx, active.ID, mock.AnythingOfType("*uuid.UUID")).Return(false, nil)
+				tokenRepo.On("RevokeTokenFamily", ctx, active.FamilyID).Return(nil)
+			},
+			err: types.ErrUnauthorized,
+		},
+		{
+			// the revoke is rolled back with the insert, the family stays
+			name: "failed rotation keeps the family",
+			expectations: func(userRepo *mocks.UserRepo, tokenRepo *mocks.RefreshTokenRepo) {
+				tokenRepo.On("GetRefreshTokenByHash", ctx, hashToken(token)).Return(active, nil)
+				userRepo.On("GetUser", ctx, user.ID).Return(user, nil)
+				tokenRepo.On("RevokeRefreshToken", ctx, active.ID, mock.AnythingOfType("*uuid.UUID")).Return(true, nil)
+				tokenRepo.On("CreateRefreshToken", ctx, mock.Anything).Return(errInsert)
+			},
+			err: errInsert,
+		},
+	}
+	for _, test := range tests {
+		t.Logf("running: %s", test.name)
+
+		userRepo := &mocks.UserRepo{}
+		tokenRepo := &mocks.RefreshTokenRepo{}
+		svc := NewUserWebService(ctx, &store.Store{User: userRepo, RefreshToken: tokenRepo}, newTestTokenIssuer(t), nil)
+		test.expectations(userRepo, tokenRepo)
+
+		tokens, err := svc.RefreshToken(ctx, token)
+		if test.err != nil {
+			assert.Equal(t, test.err, errors.Cause(err))
+		} else {
+			assert.NoError(t, err)
+			assert.NotEmpty(t, tokens.AccessToken)
+			assert.NotEqual(t, token, tokens.RefreshToken)
+		}
+		userRepo.AssertExpectations(t)
+		tokenRepo.AssertExpectations(t)
+	}
+}
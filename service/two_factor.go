@@ -0,0 +1,279 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"strings"
+	"time"
+
+	"github.com/VikaGo/REST_API/model"
+	"github.com/VikaGo/REST_API/pkg/totp"
+	"github.com/VikaGo/REST_API/pkg/types"
+	"github.com/VikaGo/REST_API/store"
+	"github.com/golang-jwt/jwt"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+const (
+	// challengePurpose marks the tokens that only complete a two-factor login
+	challengePurpose = "2fa"
+
+	recoveryCodeCount = 10
+	recoveryCodeBytes = 5
+)
+
+var recoveryCodeEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// twoFactorChallenge tells whether a login of user needs a second factor:
+// because the user enrolled one, or because the role of the user requires
+// it. enrolled is false in the latter case if the user did not enroll yet.
+func (svc *UserWebService) twoFactorChallenge(ctx context.Context, user *model.DBUser) (challenge, enrolled bool, err error) {
+	userTOTP, err := svc.store.TwoFactor.GetTOTP(ctx, user.ID)
+	if err != nil {
+		return false, false, errors.Wrap(err, "svc.twoFactor.GetTOTP error")
+	}
+	if userTOTP != nil && userTOTP.ConfirmedAt != nil {
+		return true, true, nil
+	}
+
+	settings, err := svc.store.RoleSettings.GetRoleSettings(ctx, user.Role)
+	if err != nil {
+		return false, false, errors.Wrap(err, "svc.roleSettings.GetRoleSettings error")
+	}
+	return settings != nil && settings.Require2FA, false, nil
+}
+
+// challengeLogin answers the password step of a login with a challenge token
+func (svc *UserWebService) challengeLogin(ctx context.Context, user *model.DBUser, enrolled bool) (*model.LoginResult, error) {
+	challengeToken, err := svc.tokens.Sign(&tokenClaims{
+		StandardClaims: jwt.StandardClaims{
+			ExpiresAt: time.Now().Add(svc.tokens.ChallengeTokenTTL).Unix(),
+			IssuedAt:  time.Now().Unix(),
+			Issuer:    svc.tokens.Issuer(),
+			Subject:   user.ID.String(),
+		},
+		UserId:  user.ID,
+		Role:    user.Role,
+		Purpose: challengePurpose,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "could not sign challenge token")
+	}
+	recordAudit(ctx, svc.store, model.AuditLoginChallenge, &user.ID, nil)
+
+	return &model.LoginResult{
+		ChallengeToken:     challengeToken,
+		ChallengeExpiresIn: int64(svc.tokens.ChallengeTokenTTL.Seconds()),
+		EnrollmentRequired: !enrolled,
+	}, nil
+}
+
+// challengeUser returns the user a challenge token was issued to
+func (svc *UserWebService) challengeUser(ctx context.Context, challengeToken string) (*model.DBUser, error) {
+	claims := &tokenClaims{}
+	token, err := svc.tokens.Parse(challengeToken, claims)
+	if err != nil || !token.Valid || claims.Purpose != challengePurpose {
+		return nil, errors.Wrap(types.ErrUnauthorized, "invalid challenge token")
+	}
+
+	user, err := svc.store.User.GetUser(ctx, claims.UserId)
+	if err != nil {
+		return nil, errors.Wrap(err, "svc.user.GetUser error")
+	}
+	if user == nil {
+		return nil, errors.Wrap(types.ErrUnauthorized, "challenge token owner no longer exists")
+	}
+	return user, nil
+}
+
+// CompleteLogin exchanges a challenge token and a TOTP or recovery code for
+// tokens. If the role of the user required an enrollment, the code confirms
+// it and the new recovery codes are returned along with the tokens.
+func (svc *UserWebService) CompleteLogin(ctx context.Context, challengeToken, code string) (*model.LoginResult, error) {
+	user, err := svc.challengeUser(ctx, challengeToken)
+	if err != nil {
+		return nil, err
+	}
+
+	// codes are guessed like passwords, so they count as failed logins
+	ip := RequestInfoFromContext(ctx).IP
+	if err := svc.throttle.Check(ctx, user.Nickname, ip); err != nil {
+		return nil, err
+	}
+
+	userTOTP, err := svc.store.TwoFactor.GetTOTP(ctx, user.ID)
+	if err != nil {
+		return nil, errors.Wrap(err, "svc.twoFactor.GetTOTP error")
+	}
+	if userTOTP == nil {
+		return nil, errors.Wrap(types.ErrUnauthorized, "two-factor authentication is not enrolled")
+	}
+
+	result := &model.LoginResult{}
+	if userTOTP.ConfirmedAt == nil {
+		result.RecoveryCodes, err = svc.confirmTOTP(ctx, userTOTP, code)
+	} else {
+		err = svc.verifySecondFactor(ctx, userTOTP, code)
+	}
+	if err != nil {
+		if errors.Cause(err) != types.ErrUnauthorized {
+			return nil, err
+		}
+		recordAudit(ctx, svc.store, model.AuditLoginFailure, &user.ID, nil)
+		if err := svc.throttle.Failure(ctx, user.Nickname, ip); err != nil {
+			return nil, err
+		}
+		return nil, err
+	}
+	recordAudit(ctx, svc.store, model.AuditLoginSuccess, &user.ID, nil)
+
+	if err := svc.throttle.Unlock(ctx, user.Nickname); err != nil {
+		return nil, err
+	}
+
+	if err := svc.finishLogin(ctx, user, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// verifySecondFactor accepts a TOTP code that was not used yet or an unused
+// recovery code
+func (svc *UserWebService) verifySecondFactor(ctx context.Context, userTOTP *model.DBUserTOTP, code string) error {
+	if step, ok := totp.Validate(userTOTP.Secret, code, time.Now()); ok {
+		fresh, err := svc.store.TwoFactor.UseTOTPStep(ctx, userTOTP.UserID, step)
+		if err != nil {
+			return errors.Wrap(err, "svc.twoFactor.UseTOTPStep error")
+		}
+		if !fresh {
+			return errors.Wrap(types.ErrUnauthorized, "two-factor code was already used")
+		}
+		return nil
+	}
+
+	used, err := svc.store.TwoFactor.UseRecoveryCode(ctx, userTOTP.UserID, hashToken(normalizeRecoveryCode(code)))
+	if err != nil {
+		return errors.Wrap(err, "svc.twoFactor.UseRecoveryCode error")
+	}
+	if !used {
+		return errors.Wrap(types.ErrUnauthorized, "invalid two-factor code")
+	}
+	return nil
+}
+
+// EnrollTwoFactor starts a TOTP enrollment of a user. It only takes effect
+// once ConfirmTwoFactor is called with a code of the returned secret.
+func (svc *UserWebService) EnrollTwoFactor(ctx context.Context, userID uuid.UUID) (*model.TOTPEnrollment, error) {
+	user, err := svc.store.User.GetUser(ctx, userID)
+	if err != nil {
+		return nil, errors.Wrap(err, "svc.user.GetUser error")
+	}
+	if user == nil {
+		return nil, missingUserError(ctx, svc.store, userID)
+	}
+	return svc.enrollTOTP(ctx, user)
+}
+
+// EnrollTwoFactorWithChallenge starts the TOTP enrollment a role requires
+// during a login. CompleteLogin confirms it.
+func (svc *UserWebService) EnrollTwoFactorWithChallenge(ctx context.Context, challengeToken string) (*model.TOTPEnrollment, error) {
+	user, err := svc.challengeUser(ctx, challengeToken)
+	if err != nil {
+		return nil, err
+	}
+	return svc.enrollTOTP(ctx, user)
+}
+
+func (svc *UserWebService) enrollTOTP(ctx context.Context, user *model.DBUser) (*model.TOTPEnrollment, error) {
+	userTOTP, err := svc.store.TwoFactor.GetTOTP(ctx, user.ID)
+	if err != nil {
+		return nil, errors.Wrap(err, "svc.twoFactor.GetTOTP error")
+	}
+	if userTOTP != nil && userTOTP.ConfirmedAt != nil {
+		return nil, errors.Wrap(types.ErrConflict, "two-factor authentication is already enrolled")
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+	err = svc.store.TwoFactor.SaveTOTP(ctx, &model.DBUserTOTP{UserID: user.ID, Secret: secret})
+	if err != nil {
+		return nil, errors.Wrap(err, "svc.twoFactor.SaveTOTP error")
+	}
+
+	return &model.TOTPEnrollment{
+		Secret: secret,
+		URI:    totp.URI(svc.totpIssuer, user.Nickname, secret),
+	}, nil
+}
+
+// ConfirmTwoFactor completes the TOTP enrollment of a user with a code of
+// the enrolled secret and returns the recovery codes
+func (svc *UserWebService) ConfirmTwoFactor(ctx context.Context, userID uuid.UUID, code string) (*model.RecoveryCodes, error) {
+	userTOTP, err := svc.store.TwoFactor.GetTOTP(ctx, userID)
+	if err != nil {
+		return nil, errors.Wrap(err, "svc.twoFactor.GetTOTP error")
+	}
+	if userTOTP == nil || userTOTP.ConfirmedAt != nil {
+		return nil, errors.Wrap(types.ErrConflict, "no two-factor enrollment is pending")
+	}
+
+	codes, err := svc.confirmTOTP(ctx, userTOTP, code)
+	if err != nil {
+		if errors.Cause(err) == types.ErrUnauthorized {
+			return nil, errors.Wrap(types.ErrUnprocessableEntity, "invalid two-factor code")
+		}
+		return nil, err
+	}
+	return &model.RecoveryCodes{Codes: codes}, nil
+}
+
+// confirmTOTP confirms a pending enrollment with code and replaces the
+// recovery codes of the user
+func (svc *UserWebService) confirmTOTP(ctx context.Context, userTOTP *model.DBUserTOTP, code string) ([]string, error) {
+	step, ok := totp.Validate(userTOTP.Secret, code, time.Now())
+	if !ok {
+		return nil, errors.Wrap(types.ErrUnauthorized, "invalid two-factor code")
+	}
+
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		b := make([]byte, recoveryCodeBytes)
+		if _, err := rand.Read(b); err != nil {
+			return nil, errors.Wrap(err, "could not generate recovery code")
+		}
+		code := strings.ToLower(recoveryCodeEncoding.EncodeToString(b))
+		codes[i] = code[:4] + "-" + code[4:]
+		hashes[i] = hashToken(code)
+	}
+
+	// an enrollment is only confirmed along with its recovery codes
+	err := svc.store.Tx(ctx, func(repos *store.Store) error {
+		confirmed, err := repos.TwoFactor.ConfirmTOTP(ctx, userTOTP.UserID, step)
+		if err != nil {
+			return errors.Wrap(err, "svc.twoFactor.ConfirmTOTP error")
+		}
+		if !confirmed {
+			return errors.Wrap(types.ErrConflict, "no two-factor enrollment is pending")
+		}
+		err = repos.TwoFactor.ReplaceRecoveryCodes(ctx, userTOTP.UserID, hashes)
+		if err != nil {
+			return errors.Wrap(err, "svc.twoFactor.ReplaceRecoveryCodes error")
+		}
+		return recordAuditTx(ctx, repos, model.AuditTwoFactorEnroll, &userTOTP.UserID, nil)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// normalizeRecoveryCode drops the formatting users may type along with a code
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+}
@@ -0,0 +1,219 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/VikaGo/REST_API/model"
+	"github.com/VikaGo/REST_API/pkg/totp"
+	"github.com/VikaGo/REST_API/pkg/types"
+	"github.com/VikaGo/REST_API/store"
+	"github.com/VikaGo/REST_API/store/mocks"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// twoFactorMocks are the stores a two-factor login touches
+type twoFactorMocks struct {
+	user         *mocks.UserRepo
+	token        *mocks.RefreshTokenRepo
+	twoFactor    *mocks.TwoFactorRepo
+	roleSettings *mocks.RoleSettingsRepo
+	audit        *mocks.AuditRepo
+}
+
+func newTwoFactorService(t *testing.T) (*UserWebService, *twoFactorMocks) {
+	m := &twoFactorMocks{
+		user:         &mocks.UserRepo{},
+		token:        &mocks.RefreshTokenRepo{},
+		twoFactor:    &mocks.TwoFactorRepo{},
+		roleSettings: &mocks.RoleSettingsRepo{},
+		audit:        &mocks.AuditRepo{},
+	}
+	m.audit.On("CreateAuditEvent", mock.Anything, mock.Anything).Return(nil)
+	svc := NewUserWebService(context.Background(), &store.Store{
+		User:         m.user,
+		RefreshToken: m.token,
+		TwoFactor:    m.twoFactor,
+		RoleSettings: m.roleSettings,
+		Audit:        m.audit,
+	}, newTestTokenIssuer(t), nil)
+	svc.hasher = testPasswordHasher()
+	return svc, m
+}
+
+func (m *twoFactorMocks) assertExpectations(t *testing.T) {
+	m.user.AssertExpectations(t)
+	m.token.AssertExpectations(t)
+	m.twoFactor.AssertExpectations(t)
+	m.roleSettings.AssertExpectations(t)
+}
+
+// TestTwoFactorLogin runs a login through the password and the TOTP steps
+func TestTwoFactorLogin(t *testing.T) {
+	ctx := context.Background()
+	hash, err := bcrypt.GenerateFromPassword([]byte("s3cret-pass"), bcrypt.MinCost)
+	require.NoError(t, err)
+	user := &model.DBUser{
+		ID:       uuid.MustParse("7a2f922c-073a-11eb-adc1-0242ac120002"),
+		Role:     model.RoleViewer,
+		Nickname: "topol",
+		Password: string(hash),
+	}
+	secret, err := totp.GenerateSecret()
+	require.NoError(t, err)
+	confirmedAt := time.Now().Add(-time.Hour)
+	userTOTP := &model.DBUserTOTP{UserID: user.ID, Secret: secret, ConfirmedAt: &confirmedAt}
+
+	svc, m := newTwoFactorService(t)
+	m.user.On("GetUserByNickname", ctx, user.Nickname).Return(user, nil)
+	m.user.On("GetUser", ctx, user.ID).Return(user, nil)
+	m.twoFactor.On("GetTOTP", ctx, user.ID).Return(userTOTP, nil)
+
+	// the password step only returns a challenge
+	result, err := svc.GenerateToken(ctx, user.Nickname, "s3cret-pass")
+	require.NoError(t, err)
+	assert.Nil(t, result.TokenPair)
+	assert.NotEmpty(t, result.ChallengeToken)
+	assert.False(t, result.EnrollmentRequired)
+
+	// the challenge is no access token
+	_, err = svc.ParseToken(ctx, result.ChallengeToken)
+	assert.Equal(t, types.ErrUnauthorized, errors.Cause(err))
+
+	code, err := totp.Code(secret, totp.Step(time.Now()))
+	require.NoError(t, err)
+	m.twoFactor.On("UseTOTPStep", ctx, user.ID, mock.AnythingOfType("int64")).Return(true, nil).Once()
+	m.token.On("CreateRefreshToken", ctx, mock.Anything).Return(nil)
+	completed, err := svc.CompleteLogin(ctx, result.ChallengeToken, code)
+	require.NoError(t, err)
+	assert.NotEmpty(t, completed.AccessToken)
+
+	// a replayed code is refused
+	m.twoFactor.On("UseTOTPStep", ctx, user.ID, mock.AnythingOfType("int64")).Return(false, nil).Once()
+	_, err = svc.CompleteLogin(ctx, result.ChallengeToken, code)
+	assert.Equal(t, types.ErrUnauthorized, errors.Cause(err))
+
+	// a recovery code is accepted once, whatever its formatting
+	m.twoFactor.On("UseRecoveryCode", ctx, user.ID, hashToken("abcdefgh")).Return(true, nil).Once()
+	completed, err = svc.CompleteLogin(ctx, result.ChallengeToken, "ABCD-EFGH")
+	require.NoError(t, err)
+	assert.NotEmpty(t, completed.AccessToken)
+
+	// an access token is no challenge
+	_, err = svc.CompleteLogin(ctx, completed.AccessToken, code)
+	assert.Equal(t, types.ErrUnauthorized, errors.Cause(err))
+
+	m.assertExpectations(t)
+}
+
+// TestTwoFactorRequiredByRole checks a role can force users to enroll
+func TestTwoFactorRequiredByRole(t *testing.T) {
+	ctx := context.Background()
+	hash, err := bcrypt.GenerateFromPassword([]byte("s3cret-pass"), bcrypt.MinCost)
+	require.NoError(t, err)
+	user := &model.DBUser{
+		ID:       uuid.MustParse("7a2f922c-073a-11eb-adc1-0242ac120002"),
+		Role:     model.RoleAdmin,
+		Nickname: "topol",
+		Password: string(hash),
+	}
+
+	svc, m := newTwoFactorService(t)
+	m.user.On("GetUserByNickname", ctx, user.Nickname).Return(user, nil)
+	m.user.On("GetUser", ctx, user.ID).Return(user, nil)
+	m.twoFactor.On("GetTOTP", ctx, user.ID).Return(nil, nil).Twice()
+	m.roleSettings.On("GetRoleSettings", ctx, model.RoleAdmin).Return(&model.RoleSettings{Role: model.RoleAdmin, Require2FA: true}, nil)
+
+	result, err := svc.GenerateToken(ctx, user.Nickname, "s3cret-pass")
+	require.NoError(t, err)
+	assert.Nil(t, result.TokenPair)
+	assert.True(t, result.EnrollmentRequired)
+
+	// the challenge enrolls the user
+	var saved *model.DBUserTOTP
+	m.twoFactor.On("SaveTOTP", ctx, mock.AnythingOfType("*model.DBUserTOTP")).Run(func(args mock.Arguments) {
+		saved = args.Get(1).(*model.DBUserTOTP)
+	}).Return(nil)
+	enrollment, err := svc.EnrollTwoFactorWithChallenge(ctx, result.ChallengeToken)
+	require.NoError(t, err)
+	assert.Contains(t, enrollment.URI, "otpauth://totp/REST_API:topol?")
+	assert.Equal(t, enrollment.Secret, saved.Secret)
+
+	// and a code of the new secret confirms it and completes the login
+	code, err := totp.Code(saved.Secret, totp.Step(time.Now()))
+	require.NoError(t, err)
+	m.twoFactor.On("GetTOTP", ctx, user.ID).Return(saved, nil)
+	m.twoFactor.On("ConfirmTOTP", ctx, user.ID, mock.AnythingOfType("int64")).Return(true, nil)
+	m.twoFactor.On("ReplaceRecoveryCodes", ctx, user.ID, mock.MatchedBy(func(hashes []string) bool {
+		return len(hashes) == recoveryCodeCount
+	})).Return(nil)
+	m.token.On("CreateRefreshToken", ctx, mock.Anything).Return(nil)
+	completed, err := svc.CompleteLogin(ctx, result.ChallengeToken, code)
+	require.NoError(t, err)
+	assert.NotEmpty(t, completed.AccessToken)
+	assert.Len(t, completed.RecoveryCodes, recoveryCodeCount)
+
+	m.assertExpectations(t)
+}
+
+// TestConfirmTwoFactor runs tests for the confirmation of an enrollment
+func TestConfirmTwoFactor(t *testing.T) {
+	ctx := context.Background()
+	userID := uuid.MustParse("7a2f922c-073a-11eb-adc1-0242ac120002")
+	secret, err := totp.GenerateSecret()
+	require.NoError(t, err)
+	confirmedAt := time.Now()
+	validCode, err := totp.Code(secret, totp.Step(time.Now()))
+	require.NoError(t, err)
+	errReplace := errors.New("replace failed")
+
+	tests := []struct {
+		name         string
+		expectations func(m *twoFactorMocks)
+		code         string
+		err          error
+	}{
+		{
+			// the enrollment is rolled back with the recovery codes
+			name: "recovery codes not stored",
+			expectations: func(m *twoFactorMocks) {
+				m.twoFactor.On("GetTOTP", ctx, userID).Return(&model.DBUserTOTP{UserID: userID, Secret: secret}, nil)
+				m.twoFactor.On("ConfirmTOTP", ctx, userID, mock.AnythingOfType("int64")).Return(true, nil)
+				m.twoFactor.On("ReplaceRecoveryCodes", ctx, userID, mock.Anything).Return(errReplace)
+			},
+			code: validCode,
+			err:  errReplace,
+		},
+		{
+			name: "wrong code",
+			expectations: func(m *twoFactorMocks) {
+				m.twoFactor.On("GetTOTP", ctx, userID).Return(&model.DBUserTOTP{UserID: userID, Secret: secret}, nil)
+			},
+			code: "000000x",
+			err:  types.ErrUnprocessableEntity,
+		},
+		{
+			name: "nothing pending",
+			expectations: func(m *twoFactorMocks) {
+				m.twoFactor.On("GetTOTP", ctx, userID).Return(&model.DBUserTOTP{UserID: userID, Secret: secret, ConfirmedAt: &confirmedAt}, nil)
+			},
+			err: types.ErrConflict,
+		},
+	}
+	for _, test := range tests {
+		t.Logf("running: %s", test.name)
+
+		svc, m := newTwoFactorService(t)
+		test.expectations(m)
+
+		_, err := svc.ConfirmTwoFactor(ctx, userID, test.code)
+		assert.Equal(t, test.err, errors.Cause(err))
+		m.assertExpectations(t)
+	}
+}
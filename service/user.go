@@ -2,33 +2,59 @@ package service
 
 import (
 	"context"
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"github.com/golang-jwt/jwt"
-	"golang.org/x/crypto/bcrypt"
 	"log"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/VikaGo/REST_API/logger"
 	model "github.com/VikaGo/REST_API/model"
+	"github.com/VikaGo/REST_API/pkg/oidc"
 	"github.com/VikaGo/REST_API/pkg/types"
+	"github.com/VikaGo/REST_API/pkg/validator"
 	"github.com/VikaGo/REST_API/store"
 	"github.com/google/uuid"
 	"github.com/pkg/errors"
 )
 
-const (
-	signingKey = "qrkjk#4#%35FSFJlja#4353KSFjH"
-	tokenTTL   = 24 * time.Hour
-)
-
 type tokenClaims struct {
 	jwt.StandardClaims
 	UserId uuid.UUID `json:"user_id"`
+	Role   string    `json:"role"`
+	// Purpose restricts what a token may be used for; access tokens have none
+	Purpose string `json:"purpose,omitempty"`
+	// Scope is the space separated OAuth scopes granted to a client
+	Scope string `json:"scope,omitempty"`
 }
 
 // UserWebService ...
 type UserWebService struct {
-	ctx   context.Context
-	store *store.Store
+	ctx      context.Context
+	store    *store.Store
+	tokens   *TokenIssuer
+	throttle *LoginThrottle
+	// totpIssuer names the service in authenticator apps
+	totpIssuer string
+	// passwordHistory is the number of last passwords a new one must differ from
+	passwordHistory int
+	hasher          PasswordHasher
+	policy          *validator.PasswordPolicy
+	// dummyHash is verified for unknown users, so logins take as long
+	// whether the nickname exists or not
+	dummyHash     string
+	dummyHashOnce sync.Once
+	// oidcProviders are the external providers users may log in with
+	oidcProviders map[string]*oidc.Provider
+	oidcLoginTTL  time.Duration
+	// sessions enables logins with cookie sessions instead of tokens
+	sessions           bool
+	sessionTTL         time.Duration
+	sessionIdleTimeout time.Duration
 }
 type CustomError struct {
 	Code    int
@@ -39,11 +65,23 @@ func (e *CustomError) Error() string {
 	return e.Message
 }
 
-// NewUserWebService creates a new user web service
-func NewUserWebService(ctx context.Context, store *store.Store) *UserWebService {
+const defaultTOTPIssuer = "REST_API"
+
+// NewUserWebService creates a new user web service. Logins are not
+// throttled if throttle is nil.
+func NewUserWebService(ctx context.Context, store *store.Store, tokens *TokenIssuer, throttle *LoginThrottle) *UserWebService {
 	return &UserWebService{
-		ctx:   ctx,
-		store: store,
+		ctx:                ctx,
+		store:              store,
+		tokens:             tokens,
+		throttle:           throttle,
+		totpIssuer:         defaultTOTPIssuer,
+		passwordHistory:    defaultPasswordHistory,
+		hasher:             defaultPasswordHasher(),
+		policy:             validator.DefaultPasswordPolicy(),
+		oidcLoginTTL:       defaultOIDCLoginTTL,
+		sessionTTL:         defaultSessionTTL,
+		sessionIdleTimeout: defaultSessionIdleTimeout,
 	}
 }
 
@@ -53,6 +91,19 @@ func (svc *UserWebService) GetUser(ctx context.Context, userID uuid.UUID) (*mode
 	if err != nil {
 		return nil, errors.Wrap(err, "svc.user.GetUser")
 	}
+	if userDB == nil {
+		return nil, missingUserError(ctx, svc.store, userID)
+	}
+
+	return userDB.ToWeb(), nil
+}
+
+// GetUserIncludingDeleted returns user by ID even if it was soft-deleted
+func (svc *UserWebService) GetUserIncludingDeleted(ctx context.Context, userID uuid.UUID) (*model.User, error) {
+	userDB, err := svc.store.User.GetUserIncludingDeleted(ctx, userID)
+	if err != nil {
+		return nil, errors.Wrap(err, "svc.user.GetUserIncludingDeleted")
+	}
 	if userDB == nil {
 		return nil, errors.Wrap(types.ErrNotFound, fmt.Sprintf("User '%s' not found", userID.String()))
 	}
@@ -60,150 +111,434 @@ func (svc *UserWebService) GetUser(ctx context.Context, userID uuid.UUID) (*mode
 	return userDB.ToWeb(), nil
 }
 
+// missingUserError tells a user that never existed apart from a soft-deleted one
+func missingUserError(ctx context.Context, repos *store.Store, userID uuid.UUID) error {
+	userDB, err := repos.User.GetUserIncludingDeleted(ctx, userID)
+	if err != nil {
+		return errors.Wrap(err, "svc.user.GetUserIncludingDeleted")
+	}
+	if userDB != nil {
+		return errors.Wrap(types.ErrGone, fmt.Sprintf("User '%s' was deleted", userID.String()))
+	}
+	return errors.Wrap(types.ErrNotFound, fmt.Sprintf("User '%s' not found", userID.String()))
+}
+
 // CreateUser ...
-func (svc *UserWebService) CreateUser(ctx context.Context, reqUser *model.User) (*model.User, error) {
+func (svc *UserWebService) CreateUser(ctx context.Context, reqUser *model.UserCreate) (*model.User, error) {
 
 	if reqUser == nil {
 		return nil, errors.New("reqUser is nil")
 	}
 
-	reqUser.ID = uuid.New()
+	dbUser := reqUser.ToDB()
+	err := checkPasswordPolicy(svc.policy, dbUser, string(reqUser.Password), "password")
+	if err != nil {
+		return nil, err
+	}
+
+	dbUser.ID = uuid.New()
+	dbUser.Password, err = svc.hasher.Hash(string(reqUser.Password))
+	if err != nil {
+		return nil, err
+	}
 
 	if svc.store.User == nil {
 		return nil, errors.New("svc.store.User is nil")
 	}
 
-	dbUser := reqUser.ToDB()
-	if dbUser == nil {
-		return nil, errors.New("conversion from reqUser to DBUser resulted in nil pointer")
-	}
+	var createdDBUser *model.DBUser
+	err = svc.store.Tx(ctx, func(repos *store.Store) error {
+		_, err := repos.User.CreateUser(ctx, dbUser)
+		if err != nil {
+			return errors.Wrap(err, "svc.user.CreateUser error")
+		}
+		if err := recordAuditTx(ctx, repos, model.AuditUserCreate, &dbUser.ID, nil); err != nil {
+			return err
+		}
 
-	_, err := svc.store.User.CreateUser(ctx, reqUser.ToDB())
+		// get created user by ID
+		createdDBUser, err = repos.User.GetUser(ctx, dbUser.ID)
+		if err != nil {
+			return errors.Wrap(err, "svc.user.GetUser error")
+		}
+		return nil
+	})
 	if err != nil {
-		return nil, errors.Wrap(err, "svc.user.CreateUser error")
+		return nil, err
+	}
+
+	if createdDBUser == nil {
+		return nil, errors.New("createdDBUser is nil")
 	}
 
-	// get created user by ID
-	createdDBUser, err := svc.store.User.GetUser(ctx, reqUser.ID)
+	return createdDBUser.ToWeb(), nil
+}
+
+// UpdateUser replaces the fields of a user, keeping its password. A
+// non-zero version makes the update conditional on the user still having
+// that version.
+func (svc *UserWebService) UpdateUser(ctx context.Context, userID uuid.UUID, version int, update *model.UserUpdate) (*model.User, error) {
+	var updatedUserDB *model.DBUser
+	err := svc.store.Tx(ctx, func(repos *store.Store) error {
+		// the current user tells which fields the update changes
+		currentUserDB, err := repos.User.GetUser(ctx, userID)
+		if err != nil {
+			return errors.Wrap(err, "svc.user.GetUser error")
+		}
+		if currentUserDB == nil {
+			return missingUserError(ctx, repos, userID)
+		}
+
+		updated := update.Apply(currentUserDB)
+		updated.Version = version
+
+		// Perform the update in the store
+		updatedUserDB, err = repos.User.UpdateUser(ctx, updated)
+		if err != nil {
+			return errors.Wrap(err, "svc.user.UpdateUser error")
+		}
+		if updatedUserDB == nil {
+			return missingUserError(ctx, repos, userID)
+		}
+		return recordAuditTx(ctx, repos, model.AuditUserUpdate, &userID, currentUserDB.ChangedFields(updatedUserDB))
+	})
 	if err != nil {
-		return nil, errors.Wrap(err, "svc.user.GetUser error")
+		return nil, err
 	}
 
-	if createdDBUser == nil {
-		return nil, errors.New("createdDBUser is nil")
+	return updatedUserDB.ToWeb(), nil
+}
+
+// PatchUser updates only the fields set in patch. A non-zero version makes
+// the update conditional on the user still having that version.
+func (svc *UserWebService) PatchUser(ctx context.Context, userID uuid.UUID, version int, patch *model.UserPatch) (*model.User, error) {
+	columns := patch.ToDB()
+	if len(columns) == 0 {
+		return svc.GetUser(ctx, userID)
 	}
 
-	webUser := createdDBUser.ToWeb()
-	if webUser == nil {
-		return nil, errors.New("conversion from DBUser to User (web) resulted in nil pointer")
+	fields := make([]string, 0, len(columns))
+	for name := range columns {
+		fields = append(fields, name)
+	}
+	sort.Strings(fields)
+
+	var userDB *model.DBUser
+	err := svc.store.Tx(ctx, func(repos *store.Store) error {
+		var err error
+		userDB, err = repos.User.PatchUser(ctx, userID, version, columns)
+		if err != nil {
+			return errors.Wrap(err, "svc.user.PatchUser error")
+		}
+		if userDB == nil {
+			return missingUserError(ctx, repos, userID)
+		}
+		return recordAuditTx(ctx, repos, model.AuditUserUpdate, &userID, fields)
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	return webUser, nil
+	return userDB.ToWeb(), nil
+}
+
+// DeleteUser soft-deletes a user. A non-zero version makes the deletion
+// conditional on the user still having that version.
+func (svc *UserWebService) DeleteUser(ctx context.Context, userID uuid.UUID, version int) error {
+	return svc.store.Tx(ctx, func(repos *store.Store) error {
+		// Check if user exists
+		userDB, err := repos.User.GetUser(ctx, userID)
+		if err != nil {
+			return errors.Wrap(err, "svc.user.GetUser error")
+		}
+		if userDB == nil {
+			return missingUserError(ctx, repos, userID)
+		}
+
+		err = repos.User.DeleteUser(ctx, userID, version)
+		if err != nil {
+			return errors.Wrap(err, "svc.user.DeleteUser error")
+		}
+		if err := recordAuditTx(ctx, repos, model.AuditUserDelete, &userID, nil); err != nil {
+			return err
+		}
+
+		// a deleted user must not be able to refresh its sessions
+		err = repos.RefreshToken.RevokeUserTokens(ctx, userID)
+		if err != nil {
+			return errors.Wrap(err, "svc.refreshToken.RevokeUserTokens error")
+		}
+		err = repos.Session.RevokeUserSessions(ctx, userID, nil)
+		if err != nil {
+			return errors.Wrap(err, "svc.session.RevokeUserSessions error")
+		}
+		return nil
+	})
 }
 
-// UpdateUser ...
-func (svc *UserWebService) UpdateUser(ctx context.Context, reqUser *model.User) (*model.User, error) {
-	// Perform the update in the store
-	updatedUserDB, err := svc.store.User.UpdateUser(ctx, reqUser.ToDB())
+// RestoreUser undoes the soft deletion of a user
+func (svc *UserWebService) RestoreUser(ctx context.Context, userID uuid.UUID) (*model.User, error) {
+	var userDB *model.DBUser
+	err := svc.store.Tx(ctx, func(repos *store.Store) error {
+		restored, err := repos.User.RestoreUser(ctx, userID)
+		if err != nil {
+			return errors.Wrap(err, "svc.user.RestoreUser error")
+		}
+		userDB, err = repos.User.GetUser(ctx, userID)
+		if err != nil {
+			return errors.Wrap(err, "svc.user.GetUser error")
+		}
+		if userDB == nil {
+			return errors.Wrap(types.ErrNotFound, fmt.Sprintf("User '%s' not found", userID.String()))
+		}
+		if !restored {
+			return errors.Wrap(types.ErrConflict, fmt.Sprintf("User '%s' is not deleted", userID.String()))
+		}
+		return recordAuditTx(ctx, repos, model.AuditUserRestore, &userID, nil)
+	})
 	if err != nil {
-		return nil, errors.Wrap(err, "svc.user.UpdateUser error")
+		return nil, err
 	}
 
-	return updatedUserDB.ToWeb(), nil
+	return userDB.ToWeb(), nil
 }
 
-// DeleteUser ...
-func (svc *UserWebService) DeleteUser(ctx context.Context, userID uuid.UUID) error {
-	// Check if user exists
+// ChangePassword changes the password of a user. With verifyCurrent the
+// current password must be confirmed; wrong guesses count as failed logins.
+// Every refresh token of the user is revoked, except the family of
+// change.RefreshToken so the session changing the password stays logged in.
+func (svc *UserWebService) ChangePassword(ctx context.Context, userID uuid.UUID, change *model.PasswordChange, verifyCurrent bool) error {
 	userDB, err := svc.store.User.GetUser(ctx, userID)
 	if err != nil {
 		return errors.Wrap(err, "svc.user.GetUser error")
 	}
 	if userDB == nil {
-		return errors.Wrap(types.ErrNotFound, fmt.Sprintf("User '%s' not found", userID.String()))
+		return missingUserError(ctx, svc.store, userID)
 	}
 
-	err = svc.store.User.DeleteUser(ctx, userID)
+	if verifyCurrent {
+		ip := RequestInfoFromContext(ctx).IP
+		if err := svc.throttle.Check(ctx, userDB.Nickname, ip); err != nil {
+			return err
+		}
+		ok, _, err := svc.hasher.Verify(userDB.Password, string(change.CurrentPassword))
+		if err != nil {
+			return err
+		}
+		if !ok {
+			if err := svc.throttle.Failure(ctx, userDB.Nickname, ip); err != nil {
+				return err
+			}
+			return &types.FieldError{
+				Err:   errors.Wrap(types.ErrForbidden, "current password is incorrect"),
+				Field: "current_password",
+			}
+		}
+	}
+
+	err = checkPasswordPolicy(svc.policy, userDB, string(change.NewPassword), "new_password")
 	if err != nil {
-		return errors.Wrap(err, "svc.user.DeleteUser error")
+		return err
 	}
+	return svc.store.Tx(ctx, func(repos *store.Store) error {
+		err := checkPasswordReuse(ctx, repos, svc.hasher, userDB, string(change.NewPassword), svc.passwordHistory, "new_password")
+		if err != nil {
+			return err
+		}
+		err = setPassword(ctx, repos, svc.hasher, userDB, string(change.NewPassword), svc.passwordHistory)
+		if err != nil {
+			return err
+		}
 
-	return nil
+		if err := revokeOtherSessions(ctx, repos, userID, change.RefreshToken); err != nil {
+			return err
+		}
+		err = repos.PasswordReset.DeleteUserPasswordResetTokens(ctx, userID)
+		if err != nil {
+			return errors.Wrap(err, "svc.passwordReset.DeleteUserPasswordResetTokens error")
+		}
+		return recordAuditTx(ctx, repos, model.AuditPasswordChange, &userID, []string{"password"})
+	})
 }
 
-// Get Password
-func (svc *UserWebService) GetPassword(ctx context.Context, userID uuid.UUID) (string, error) {
-	password, err := svc.store.User.GetPassword(ctx, userID)
+// revokeOtherSessions revokes the refresh tokens of a user, except the
+// family of refreshToken if it is a live token of that user, and the
+// cookie sessions of the user except the one of the request
+func revokeOtherSessions(ctx context.Context, repos *store.Store, userID uuid.UUID, refreshToken string) error {
+	err := repos.Session.RevokeUserSessions(ctx, userID, RequestInfoFromContext(ctx).SessionID)
+	if err != nil {
+		return errors.Wrap(err, "svc.session.RevokeUserSessions error")
+	}
+
+	if refreshToken != "" {
+		dbToken, err := repos.RefreshToken.GetRefreshTokenByHash(ctx, hashToken(refreshToken))
+		if err != nil {
+			return errors.Wrap(err, "svc.refreshToken.GetRefreshTokenByHash error")
+		}
+		if dbToken != nil && dbToken.UserID == userID && dbToken.RevokedAt == nil {
+			err = repos.RefreshToken.RevokeOtherUserTokens(ctx, userID, dbToken.FamilyID)
+			if err != nil {
+				return errors.Wrap(err, "svc.refreshToken.RevokeOtherUserTokens error")
+			}
+			return nil
+		}
+	}
+
+	err = repos.RefreshToken.RevokeUserTokens(ctx, userID)
 	if err != nil {
-		return "", err
+		return errors.Wrap(err, "svc.refreshToken.RevokeUserTokens error")
 	}
-	return password, nil
+	return nil
 }
 
-// ChangePassword updates the password for a user with the specified userID.
-func (svc *UserWebService) UpdatePassword(ctx context.Context, userID uuid.UUID, newPassword string) error {
-	// First, retrieve the user from the database
-	userDB, err := svc.store.User.GetUser(ctx, userID)
+// verifyDummyHash does the work of checking password against a hash of
+// the current policy, and throws the result away
+func (svc *UserWebService) verifyDummyHash(password string) {
+	svc.dummyHashOnce.Do(func() {
+		hash, err := svc.hasher.Hash(uuid.NewString())
+		if err != nil {
+			logger.Get().Error().Err(err).Msg("[service.user] Could not hash the dummy password")
+			return
+		}
+		svc.dummyHash = hash
+	})
+	_, _, _ = svc.hasher.Verify(svc.dummyHash, password)
+}
+
+// GenerateToken checks the password of a user. It returns tokens, or a
+// cookie session if ctx asks for one, or a challenge to complete with
+// CompleteLogin if a second factor is needed.
+func (svc *UserWebService) GenerateToken(ctx context.Context, nickname, password string) (*model.LoginResult, error) {
+	if err := svc.checkSessionLogin(ctx); err != nil {
+		return nil, err
+	}
+	ip := RequestInfoFromContext(ctx).IP
+	if err := svc.throttle.Check(ctx, nickname, ip); err != nil {
+		return nil, err
+	}
+
+	user, err := svc.store.User.GetUserByNickname(ctx, nickname)
 	if err != nil {
-		return errors.Wrap(err, "Error fetching user")
+		return nil, errors.Wrap(err, "error getting user by nickname")
 	}
-	if userDB == nil {
-		return errors.Wrap(types.ErrNotFound, fmt.Sprintf("User '%s' not found", userID.String()))
+	if user == nil {
+		svc.verifyDummyHash(password)
+		recordAudit(ctx, svc.store, model.AuditLoginFailure, nil, nil)
+		return nil, svc.loginFailed(ctx, nickname, ip)
 	}
 
-	// Update the user's password
-	userDB.Password = newPassword
+	ok, rehash, err := svc.hasher.Verify(user.Password, password)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		recordAudit(ctx, svc.store, model.AuditLoginFailure, &user.ID, nil)
+		return nil, svc.loginFailed(ctx, nickname, ip)
+	}
+	if rehash {
+		svc.rehashPassword(ctx, user, password)
+	}
 
-	// Use the UpdateUser function from the data store to update the user in the database
-	_, err = svc.store.User.UpdateUser(ctx, userDB)
+	// the failed logins are only forgotten once the second factor passed too
+	challenge, enrolled, err := svc.twoFactorChallenge(ctx, user)
 	if err != nil {
-		return errors.Wrap(err, "Error updating user's password")
+		return nil, err
+	}
+	if challenge {
+		return svc.challengeLogin(ctx, user, enrolled)
 	}
+	recordAudit(ctx, svc.store, model.AuditLoginSuccess, &user.ID, nil)
 
-	return nil
-}
+	if err := svc.throttle.Unlock(ctx, nickname); err != nil {
+		return nil, err
+	}
 
-// Checking LogIn
-func (svc *UserWebService) GenerateToken(ctx context.Context, nickname, password string) (string, error) {
+	result := &model.LoginResult{}
+	if err := svc.finishLogin(ctx, user, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
 
-	user, err := svc.store.User.GetUserByNickname(ctx, nickname)
+// rehashPassword upgrades the password hash of user to the current policy.
+// The login goes on with the old hash if that fails.
+func (svc *UserWebService) rehashPassword(ctx context.Context, user *model.DBUser, password string) {
+	hash, err := svc.hasher.Hash(password)
+	if err == nil {
+		// only replaces the hash the password was verified against
+		err = svc.store.User.RehashPassword(ctx, user.ID, user.Password, hash)
+	}
 	if err != nil {
-		return "", errors.Wrap(err, "error getting user by nickname")
+		logger.Get().Error().Err(err).Msgf("[service.user] Could not rehash password of user '%s'", user.ID.String())
+	}
+}
+
+// loginFailed counts a failed login and returns the error to report. The
+// same error is returned for unknown nicknames and wrong passwords.
+func (svc *UserWebService) loginFailed(ctx context.Context, nickname, ip string) error {
+	if err := svc.throttle.Failure(ctx, nickname, ip); err != nil {
+		return err
 	}
+	return errors.Wrap(types.ErrUnauthorized, "incorrect nickname or password")
+}
 
-	err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password))
+// UnlockUser forgets the failed logins of a user, lifting a lockout
+func (svc *UserWebService) UnlockUser(ctx context.Context, userID uuid.UUID) error {
+	userDB, err := svc.store.User.GetUser(ctx, userID)
 	if err != nil {
-		return "", errors.Wrap(err, "incorrect password")
+		return errors.Wrap(err, "svc.user.GetUser error")
+	}
+	if userDB == nil {
+		return missingUserError(ctx, svc.store, userID)
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, &tokenClaims{
+	if err := svc.throttle.Unlock(ctx, userDB.Nickname); err != nil {
+		return err
+	}
+	recordAudit(ctx, svc.store, model.AuditUserUnlock, &userID, nil)
+
+	return nil
+}
+
+// signAccessToken issues a short-lived JWT for user
+func (svc *UserWebService) signAccessToken(user *model.DBUser) (string, error) {
+	return svc.tokens.Sign(&tokenClaims{
 		StandardClaims: jwt.StandardClaims{
-			ExpiresAt: time.Now().Add(tokenTTL).Unix(),
+			ExpiresAt: time.Now().Add(svc.tokens.AccessTokenTTL).Unix(),
 			IssuedAt:  time.Now().Unix(),
+			Issuer:    svc.tokens.Issuer(),
+			Subject:   user.ID.String(),
 		},
+		UserId: user.ID,
+		Role:   user.Role,
 	})
-
-	return token.SignedString([]byte(signingKey))
 }
 
-func (svc *UserWebService) ParseToken(accessToken string) (uuid.UUID, error) {
-	token, err := jwt.ParseWithClaims(accessToken, &tokenClaims{}, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, errors.New("invalid signing method")
-		}
-
-		return []byte(signingKey), nil
-	})
+// ParseToken validates an access token and returns the principal it was issued to
+func (svc *UserWebService) ParseToken(ctx context.Context, accessToken string) (*model.Principal, error) {
+	token, err := svc.tokens.Parse(accessToken, &tokenClaims{})
 	if err != nil {
-		return uuid.Nil, err
+		return nil, errors.Wrap(types.ErrUnauthorized, err.Error())
 	}
 
 	claims, ok := token.Claims.(*tokenClaims)
-	if !ok {
-		return uuid.Nil, errors.New("token claims are not of type *tokenClaims")
+	if !ok || !token.Valid {
+		return nil, errors.Wrap(types.ErrUnauthorized, "invalid token claims")
+	}
+	if issuer := svc.tokens.Issuer(); issuer != "" && !claims.VerifyIssuer(issuer, true) {
+		return nil, errors.Wrap(types.ErrUnauthorized, "token issued by another issuer")
+	}
+	if claims.UserId == uuid.Nil {
+		return nil, errors.Wrap(types.ErrUnauthorized, "token has no subject")
+	}
+	if claims.Purpose != "" {
+		return nil, errors.Wrap(types.ErrUnauthorized, "not an access token")
 	}
 
-	return claims.UserId, nil
+	return &model.Principal{
+		UserID: claims.UserId,
+		Role:   claims.Role,
+	}, nil
 }
 
 func (svc *UserWebService) GetUserByNickname(ctx context.Context, nickname string) (*model.User, error) {
@@ -227,3 +562,89 @@ func (svc *UserWebService) GetUserByNickname(ctx context.Context, nickname strin
 
 	return userDB.ToWeb(), nil
 }
+
+const defaultUserPageSize = 20
+
+// ListUsers returns one page of users matching params
+func (svc *UserWebService) ListUsers(ctx context.Context, params *model.UserListParams) (*model.UserPage, error) {
+	query := &model.UserListQuery{
+		UserFilter: model.UserFilter{
+			Role:           params.Role,
+			NicknamePrefix: params.NicknamePrefix,
+			CreatedFrom:    params.CreatedFrom,
+			CreatedTo:      params.CreatedTo,
+			IncludeDeleted: params.IncludeDeleted,
+		},
+		Sort:  strings.TrimPrefix(params.Sort, "-"),
+		Desc:  strings.HasPrefix(params.Sort, "-"),
+		Limit: params.Limit,
+	}
+	if query.Sort == "" {
+		query.Sort = model.UserSortCreatedAt
+	}
+	if query.Limit <= 0 {
+		query.Limit = defaultUserPageSize
+	}
+	if params.Cursor != "" {
+		cursor, err := decodeUserCursor(params.Cursor)
+		if err != nil {
+			return nil, errors.Wrap(types.ErrBadRequest, "invalid cursor")
+		}
+		if cursor.Sort != query.Sort || cursor.Desc != query.Desc {
+			return nil, errors.Wrap(types.ErrBadRequest, "cursor does not match the sort order")
+		}
+		query.After = cursor
+	}
+
+	// fetch one extra row to know whether there is a next page
+	pageSize := query.Limit
+	query.Limit++
+	usersDB, err := svc.store.User.ListUsers(ctx, query)
+	if err != nil {
+		return nil, errors.Wrap(err, "svc.user.ListUsers")
+	}
+
+	page := &model.UserPage{Items: []*model.User{}}
+	if len(usersDB) > pageSize {
+		usersDB = usersDB[:pageSize]
+		last := usersDB[pageSize-1]
+		page.NextCursor = encodeUserCursor(&model.UserCursor{
+			Sort:      query.Sort,
+			Desc:      query.Desc,
+			Lastname:  last.Lastname,
+			Firstname: last.Firstname,
+			CreatedAt: last.CreatedAt,
+			ID:        last.ID,
+		})
+	}
+	for _, userDB := range usersDB {
+		page.Items = append(page.Items, userDB.ToWeb())
+	}
+
+	if params.IncludeTotal {
+		total, err := svc.store.User.CountUsers(ctx, &query.UserFilter)
+		if err != nil {
+			return nil, errors.Wrap(err, "svc.user.CountUsers")
+		}
+		page.Total = &total
+	}
+
+	return page, nil
+}
+
+func encodeUserCursor(cursor *model.UserCursor) string {
+	b, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeUserCursor(s string) (*model.UserCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	cursor := &model.UserCursor{}
+	if err := json.Unmarshal(b, cursor); err != nil {
+		return nil, err
+	}
+	return cursor, nil
+}
@@ -0,0 +1,123 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"testing"
+	"time"
+
+	"github.com/VikaGo/REST_API/model"
+	"github.com/VikaGo/REST_API/store"
+	"github.com/VikaGo/REST_API/store/pg"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+// slowQuery is how long the queries of slowConn run unless they are aborted
+const slowQuery = time.Minute
+
+// slowConnector opens connections whose queries take slowQuery, like a
+// Postgres waiting for a lock
+type slowConnector struct{}
+
+func (slowConnector) Connect(context.Context) (driver.Conn, error) { return slowConn{}, nil }
+func (slowConnector) Driver() driver.Driver                        { return nil }
+
+type slowConn struct{}
+
+func (slowConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (slowConn) Close() error                        { return nil }
+func (slowConn) Begin() (driver.Tx, error)           { return nil, errors.New("not supported") }
+
+func (slowConn) QueryContext(ctx context.Context, _ string, _ []driver.NamedValue) (driver.Rows, error) {
+	return nil, slowConn{}.wait(ctx)
+}
+
+func (slowConn) ExecContext(ctx context.Context, _ string, _ []driver.NamedValue) (driver.Result, error) {
+	return nil, slowConn{}.wait(ctx)
+}
+
+func (slowConn) wait(ctx context.Context) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(slowQuery):
+		return errors.New("query was not aborted")
+	}
+}
+
+// TestQueryCancellation runs user operations against a database that does
+// not answer, and checks that a canceled request or a timeout aborts them
+func TestQueryCancellation(t *testing.T) {
+	db := sqlx.NewDb(sql.OpenDB(slowConnector{}), "postgres")
+	defer db.Close()
+	userID := uuid.New()
+
+	tests := []struct {
+		testName string
+		timeouts pg.Timeouts
+		cancel   bool
+		run      func(ctx context.Context, svc *UserWebService) error
+		cause    error
+	}{
+		{
+			testName: "canceled request",
+			cancel:   true,
+			run: func(ctx context.Context, svc *UserWebService) error {
+				_, err := svc.GetUser(ctx, userID)
+				return err
+			},
+			cause: context.Canceled,
+		},
+		{
+			testName: "canceled list",
+			cancel:   true,
+			run: func(ctx context.Context, svc *UserWebService) error {
+				_, err := svc.ListUsers(ctx, &model.UserListParams{})
+				return err
+			},
+			cause: context.Canceled,
+		},
+		{
+			testName: "read timeout",
+			timeouts: pg.Timeouts{Read: 20 * time.Millisecond},
+			run: func(ctx context.Context, svc *UserWebService) error {
+				_, err := svc.GetUser(ctx, userID)
+				return err
+			},
+			cause: context.DeadlineExceeded,
+		},
+		{
+			testName: "write timeout",
+			timeouts: pg.Timeouts{Write: 20 * time.Millisecond},
+			run: func(ctx context.Context, svc *UserWebService) error {
+				_, err := svc.RestoreUser(ctx, userID)
+				return err
+			},
+			cause: context.DeadlineExceeded,
+		},
+	}
+
+	for _, test := range tests {
+		t.Logf("running %v", test.testName)
+
+		svc := NewUserWebService(context.Background(), &store.Store{
+			User: pg.NewUserRepo(db, test.timeouts),
+		}, newTestTokenIssuer(t), nil)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		if test.cancel {
+			time.AfterFunc(20*time.Millisecond, cancel)
+		}
+
+		start := time.Now()
+		err := test.run(ctx, svc)
+		cancel()
+
+		assert.Equal(t, test.cause, errors.Cause(err))
+		assert.Less(t, time.Since(start), slowQuery/2, "the query was not aborted")
+	}
+}
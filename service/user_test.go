@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/VikaGo/REST_API/model"
 	"github.com/VikaGo/REST_API/store"
@@ -14,32 +15,43 @@ import (
 
 // TestGetUser runs tests for GetUser service
 func TestGetUser(t *testing.T) {
-	input := &model.User{
+	input := &model.DBUser{
 		ID:        uuid.MustParse("7a2f922c-073a-11eb-adc1-0242ac120002"),
 		Firstname: "Olexandr",
 		Lastname:  "Topol",
 	}
+	deletedAt := time.Now()
 
 	tests := []struct {
 		name         string
 		expectations func(userRepo *mocks.UserRepo)
-		input        *model.User
+		input        *model.DBUser
 		err          error
 	}{
 		{
 			name: "valid and found",
 			expectations: func(userRepo *mocks.UserRepo) {
-				userRepo.On("GetUser", context.Background(), input.ID).Return(input.ToDB(), nil)
+				userRepo.On("GetUser", context.Background(), input.ID).Return(input, nil)
 			},
 			input: input,
 		}, {
 			name: "valid user ID but not found",
 			expectations: func(userRepo *mocks.UserRepo) {
 				userRepo.On("GetUser", context.Background(), input.ID).Return(nil, nil)
+				userRepo.On("GetUserIncludingDeleted", context.Background(), input.ID).Return(nil, nil)
 			},
 			input: input,
 			err:   errors.New("User '7a2f922c-073a-11eb-adc1-0242ac120002' not found: resource not found"),
 		},
+		{
+			name: "valid user ID but deleted",
+			expectations: func(userRepo *mocks.UserRepo) {
+				userRepo.On("GetUser", context.Background(), input.ID).Return(nil, nil)
+				userRepo.On("GetUserIncludingDeleted", context.Background(), input.ID).Return(&model.DBUser{ID: input.ID, DeletedAt: &deletedAt}, nil)
+			},
+			input: input,
+			err:   errors.New("User '7a2f922c-073a-11eb-adc1-0242ac120002' was deleted: resource gone"),
+		},
 		{
 			name: "store error",
 			expectations: func(userRepo *mocks.UserRepo) {
@@ -55,7 +67,7 @@ func TestGetUser(t *testing.T) {
 		ctx := context.Background()
 
 		userRepo := &mocks.UserRepo{}
-		svc := NewUserWebService(context.Background(), &store.Store{User: userRepo})
+		svc := NewUserWebService(context.Background(), &store.Store{User: userRepo}, nil, nil)
 		test.expectations(userRepo)
 
 		_, err := svc.GetUser(ctx, test.input.ID)
@@ -69,3 +81,92 @@ func TestGetUser(t *testing.T) {
 		userRepo.AssertExpectations(t)
 	}
 }
+
+// TestListUsers runs tests for ListUsers service
+func TestListUsers(t *testing.T) {
+	created := time.Date(2023, 10, 1, 12, 0, 0, 0, time.UTC)
+	users := []*model.DBUser{
+		{ID: uuid.MustParse("7a2f922c-073a-11eb-adc1-0242ac120002"), Lastname: "Topol", CreatedAt: created},
+		{ID: uuid.MustParse("9b1deb4d-3b7d-4bad-9bdd-2b0d7b3dcb6d"), Lastname: "Shevchenko", CreatedAt: created.Add(time.Hour)},
+		{ID: uuid.MustParse("1b9d6bcd-bbfd-4b2d-9b5d-ab8dfbbd4bed"), Lastname: "Franko", CreatedAt: created.Add(2 * time.Hour)},
+	}
+	ctx := context.Background()
+
+	tests := []struct {
+		name         string
+		params       *model.UserListParams
+		expectations func(userRepo *mocks.UserRepo)
+		items        int
+		nextCursor   bool
+		total        *int
+		err          error
+	}{
+		{
+			name:   "first page with more results",
+			params: &model.UserListParams{Limit: 2},
+			expectations: func(userRepo *mocks.UserRepo) {
+				userRepo.On("ListUsers", ctx, &model.UserListQuery{Sort: model.UserSortCreatedAt, Limit: 3}).Return(users, nil)
+			},
+			items:      2,
+			nextCursor: true,
+		},
+		{
+			name: "following page sorted by name descending",
+			params: &model.UserListParams{
+				Sort:   "-name",
+				Limit:  2,
+				Cursor: encodeUserCursor(&model.UserCursor{Sort: model.UserSortName, Desc: true, Lastname: "Topol", ID: users[0].ID}),
+			},
+			expectations: func(userRepo *mocks.UserRepo) {
+				userRepo.On("ListUsers", ctx, &model.UserListQuery{
+					Sort:  model.UserSortName,
+					Desc:  true,
+					Limit: 3,
+					After: &model.UserCursor{Sort: model.UserSortName, Desc: true, Lastname: "Topol", ID: users[0].ID},
+				}).Return(users[1:], nil)
+			},
+			items: 2,
+		},
+		{
+			name:   "filtered with total",
+			params: &model.UserListParams{Role: model.RoleAdmin, IncludeTotal: true},
+			expectations: func(userRepo *mocks.UserRepo) {
+				filter := model.UserFilter{Role: model.RoleAdmin}
+				userRepo.On("ListUsers", ctx, &model.UserListQuery{UserFilter: filter, Sort: model.UserSortCreatedAt, Limit: 21}).Return(users[:1], nil)
+				userRepo.On("CountUsers", ctx, &filter).Return(1, nil)
+			},
+			items: 1,
+			total: func() *int { n := 1; return &n }(),
+		},
+		{
+			name:         "malformed cursor",
+			params:       &model.UserListParams{Cursor: "not a cursor"},
+			expectations: func(userRepo *mocks.UserRepo) {},
+			err:          errors.New("invalid cursor: bad request"),
+		},
+		{
+			name:         "cursor of another sort order",
+			params:       &model.UserListParams{Sort: "name", Cursor: encodeUserCursor(&model.UserCursor{Sort: model.UserSortCreatedAt})},
+			expectations: func(userRepo *mocks.UserRepo) {},
+			err:          errors.New("cursor does not match the sort order: bad request"),
+		},
+	}
+	for _, test := range tests {
+		t.Logf("running: %s", test.name)
+
+		userRepo := &mocks.UserRepo{}
+		svc := NewUserWebService(ctx, &store.Store{User: userRepo}, nil, nil)
+		test.expectations(userRepo)
+
+		page, err := svc.ListUsers(ctx, test.params)
+		if test.err != nil {
+			assert.EqualError(t, err, test.err.Error())
+		} else {
+			assert.NoError(t, err)
+			assert.Len(t, page.Items, test.items)
+			assert.Equal(t, test.nextCursor, page.NextCursor != "")
+			assert.Equal(t, test.total, page.Total)
+		}
+		userRepo.AssertExpectations(t)
+	}
+}
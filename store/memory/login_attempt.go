@@ -0,0 +1,72 @@
+package memory
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/VikaGo/REST_API/model"
+)
+
+// sweepInterval is how often stale counters are dropped
+const sweepInterval = time.Minute
+
+// LoginAttemptRepo counts failed logins in memory. The counters are lost on
+// restart and not shared between instances.
+type LoginAttemptRepo struct {
+	mu        sync.Mutex
+	attempts  map[string]model.LoginAttempts
+	lastSweep time.Time
+}
+
+// NewLoginAttemptRepo ...
+func NewLoginAttemptRepo() *LoginAttemptRepo {
+	return &LoginAttemptRepo{attempts: map[string]model.LoginAttempts{}}
+}
+
+// GetLoginAttempts returns the failed logins counted for key
+func (repo *LoginAttemptRepo) GetLoginAttempts(ctx context.Context, key string) (*model.LoginAttempts, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	attempts, ok := repo.attempts[key]
+	if !ok {
+		return nil, nil
+	}
+	return &attempts, nil
+}
+
+// RecordLoginFailure counts a failed login for key. Failures not after
+// resetBefore are forgotten first.
+func (repo *LoginAttemptRepo) RecordLoginFailure(ctx context.Context, key string, at, resetBefore time.Time) (*model.LoginAttempts, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	// drop stale counters, so clients spraying attempts can't grow the map forever
+	if at.Sub(repo.lastSweep) >= sweepInterval {
+		for k, attempts := range repo.attempts {
+			if !attempts.LastFailure.After(resetBefore) {
+				delete(repo.attempts, k)
+			}
+		}
+		repo.lastSweep = at
+	}
+
+	attempts, ok := repo.attempts[key]
+	if !ok || !attempts.LastFailure.After(resetBefore) {
+		attempts = model.LoginAttempts{Key: key}
+	}
+	attempts.Failures++
+	attempts.LastFailure = at
+	repo.attempts[key] = attempts
+	return &attempts, nil
+}
+
+// ResetLoginAttempts forgets the failed logins counted for key
+func (repo *LoginAttemptRepo) ResetLoginAttempts(ctx context.Context, key string) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	delete(repo.attempts, key)
+	return nil
+}
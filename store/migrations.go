@@ -2,23 +2,26 @@ package store
 
 import (
 	"embed"
+
 	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
 	"github.com/pressly/goose/v3"
 )
 
 //go:embed migrations/*.sql
 var embedMigrations embed.FS
 
-// runPgMigrations runs Postgres migrations
+// runPgMigrations runs Postgres migrations. A failed migration is
+// rolled back and returned, so the service does not start on it.
 func runPgMigrations(db *sqlx.DB) error {
 	goose.SetBaseFS(embedMigrations)
 
 	if err := goose.SetDialect("postgres"); err != nil {
-		panic(err)
+		return errors.Wrap(err, "goose.SetDialect failed")
 	}
 
 	if err := goose.Up(db.DB, "migrations"); err != nil {
-		panic(err)
+		return errors.Wrap(err, "goose.Up failed")
 	}
 
 	return nil
@@ -0,0 +1,7 @@
+-- +goose Up
+ALTER TABLE users ADD COLUMN role text NOT NULL DEFAULT 'viewer';
+ALTER TABLE users ADD CONSTRAINT "chk_user_role" CHECK (role IN ('admin', 'editor', 'viewer'));
+
+-- +goose Down
+ALTER TABLE users DROP CONSTRAINT "chk_user_role";
+ALTER TABLE users DROP COLUMN role;
@@ -0,0 +1,19 @@
+-- +goose Up
+CREATE TABLE refresh_tokens (
+    id uuid NOT NULL,
+    user_id uuid NOT NULL,
+    family_id uuid NOT NULL,
+    token_hash text NOT NULL,
+    expires_at timestamp NOT NULL,
+    created_at timestamp default current_timestamp,
+    revoked_at timestamp,
+    replaced_by uuid,
+    CONSTRAINT "pk_refresh_token_id" PRIMARY KEY (id),
+    CONSTRAINT "fk_refresh_token_user_id" FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+CREATE UNIQUE INDEX "idx_refresh_tokens_token_hash" ON refresh_tokens (token_hash);
+CREATE INDEX "idx_refresh_tokens_family_id" ON refresh_tokens (family_id);
+CREATE INDEX "idx_refresh_tokens_user_id" ON refresh_tokens (user_id);
+
+-- +goose Down
+DROP TABLE refresh_tokens;
@@ -0,0 +1,10 @@
+-- +goose Up
+-- deleted_at used to default to the creation time; users were hard-deleted
+-- until now, so no existing row is actually deleted.
+ALTER TABLE users ALTER COLUMN deleted_at DROP DEFAULT;
+UPDATE users SET deleted_at = NULL;
+CREATE INDEX "idx_users_deleted_at" ON users (deleted_at) WHERE deleted_at IS NOT NULL;
+
+-- +goose Down
+DROP INDEX "idx_users_deleted_at";
+ALTER TABLE users ALTER COLUMN deleted_at SET DEFAULT current_timestamp;
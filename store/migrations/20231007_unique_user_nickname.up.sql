@@ -0,0 +1,16 @@
+-- +goose Up
+-- Nicknames that differ only by case keep the oldest user; the others get
+-- a suffix from their id, so every user can still log in with the nickname
+-- they are told about.
+UPDATE users SET nickname = users.nickname || '_' || substr(users.id::text, 1, 8)
+FROM (
+    SELECT id, row_number() OVER (PARTITION BY lower(nickname) ORDER BY created_at, id) AS n
+    FROM users
+) duplicates
+WHERE users.id = duplicates.id AND duplicates.n > 1;
+
+-- Nicknames of soft-deleted users stay reserved until they are purged.
+CREATE UNIQUE INDEX "idx_users_nickname" ON users (lower(nickname));
+
+-- +goose Down
+DROP INDEX "idx_users_nickname";
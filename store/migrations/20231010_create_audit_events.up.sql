@@ -0,0 +1,32 @@
+-- +goose Up
+-- Audit events outlive the users they refer to, so there are no foreign keys.
+CREATE TABLE audit_events (
+    id uuid NOT NULL,
+    action text NOT NULL,
+    actor_id uuid,
+    target_id uuid,
+    fields text[] NOT NULL DEFAULT '{}',
+    ip text NOT NULL DEFAULT '',
+    user_agent text NOT NULL DEFAULT '',
+    request_id text NOT NULL DEFAULT '',
+    created_at timestamp NOT NULL DEFAULT current_timestamp,
+    CONSTRAINT "pk_audit_event_id" PRIMARY KEY (id)
+);
+CREATE INDEX "idx_audit_events_created_at" ON audit_events (created_at, id);
+CREATE INDEX "idx_audit_events_actor_id" ON audit_events (actor_id, created_at);
+CREATE INDEX "idx_audit_events_target_id" ON audit_events (target_id, created_at);
+
+-- The audit log is append-only
+-- +goose StatementBegin
+CREATE FUNCTION audit_events_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_events is append-only';
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+CREATE TRIGGER "trg_audit_events_append_only" BEFORE UPDATE OR DELETE ON audit_events
+    FOR EACH ROW EXECUTE FUNCTION audit_events_append_only();
+
+-- +goose Down
+DROP TABLE audit_events;
+DROP FUNCTION audit_events_append_only();
@@ -0,0 +1,10 @@
+-- +goose Up
+CREATE TABLE login_attempts (
+    attempt_key text NOT NULL,
+    failures integer NOT NULL,
+    last_failure timestamp NOT NULL,
+    CONSTRAINT "pk_login_attempt_key" PRIMARY KEY (attempt_key)
+);
+
+-- +goose Down
+DROP TABLE login_attempts;
@@ -0,0 +1,31 @@
+-- +goose Up
+CREATE TABLE user_totp (
+    user_id uuid NOT NULL,
+    secret text NOT NULL,
+    last_step bigint NOT NULL DEFAULT 0,
+    created_at timestamp NOT NULL DEFAULT current_timestamp,
+    confirmed_at timestamp,
+    CONSTRAINT "pk_user_totp_user_id" PRIMARY KEY (user_id),
+    CONSTRAINT "fk_user_totp_user_id" FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE TABLE recovery_codes (
+    id uuid NOT NULL,
+    user_id uuid NOT NULL,
+    code_hash text NOT NULL,
+    used_at timestamp,
+    CONSTRAINT "pk_recovery_code_id" PRIMARY KEY (id),
+    CONSTRAINT "fk_recovery_code_user_id" FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+CREATE UNIQUE INDEX "idx_recovery_codes_user_id_code_hash" ON recovery_codes (user_id, code_hash);
+
+CREATE TABLE role_settings (
+    role text NOT NULL,
+    require_2fa boolean NOT NULL DEFAULT false,
+    CONSTRAINT "pk_role_settings_role" PRIMARY KEY (role)
+);
+
+-- +goose Down
+DROP TABLE role_settings;
+DROP TABLE recovery_codes;
+DROP TABLE user_totp;
@@ -0,0 +1,7 @@
+-- +goose Up
+ALTER TABLE users ADD COLUMN email text NOT NULL DEFAULT '';
+CREATE UNIQUE INDEX "idx_users_email" ON users (lower(email)) WHERE email <> '';
+
+-- +goose Down
+DROP INDEX "idx_users_email";
+ALTER TABLE users DROP COLUMN email;
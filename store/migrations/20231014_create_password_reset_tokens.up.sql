@@ -0,0 +1,16 @@
+-- +goose Up
+CREATE TABLE password_reset_tokens (
+    id uuid NOT NULL,
+    user_id uuid NOT NULL,
+    token_hash text NOT NULL,
+    expires_at timestamp NOT NULL,
+    created_at timestamp default current_timestamp,
+    used_at timestamp,
+    CONSTRAINT "pk_password_reset_token_id" PRIMARY KEY (id),
+    CONSTRAINT "fk_password_reset_token_user_id" FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+CREATE UNIQUE INDEX "idx_password_reset_tokens_token_hash" ON password_reset_tokens (token_hash);
+CREATE INDEX "idx_password_reset_tokens_user_id" ON password_reset_tokens (user_id);
+
+-- +goose Down
+DROP TABLE password_reset_tokens;
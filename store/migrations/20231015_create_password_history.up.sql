@@ -0,0 +1,13 @@
+-- +goose Up
+CREATE TABLE password_history (
+    id uuid NOT NULL,
+    user_id uuid NOT NULL,
+    password_hash text NOT NULL,
+    created_at timestamp NOT NULL default current_timestamp,
+    CONSTRAINT "pk_password_history_id" PRIMARY KEY (id),
+    CONSTRAINT "fk_password_history_user_id" FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+CREATE INDEX "idx_password_history_user_id_created_at" ON password_history (user_id, created_at DESC);
+
+-- +goose Down
+DROP TABLE password_history;
@@ -0,0 +1,20 @@
+-- +goose Up
+CREATE TABLE api_keys (
+    id uuid NOT NULL,
+    user_id uuid NOT NULL,
+    name text NOT NULL,
+    prefix text NOT NULL,
+    key_hash text NOT NULL,
+    scopes text[],
+    expires_at timestamp,
+    last_used_at timestamp,
+    created_at timestamp default current_timestamp,
+    revoked_at timestamp,
+    CONSTRAINT "pk_api_key_id" PRIMARY KEY (id),
+    CONSTRAINT "fk_api_key_user_id" FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+CREATE UNIQUE INDEX "idx_api_keys_key_hash" ON api_keys (key_hash);
+CREATE INDEX "idx_api_keys_user_id" ON api_keys (user_id);
+
+-- +goose Down
+DROP TABLE api_keys;
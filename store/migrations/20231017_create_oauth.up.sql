@@ -0,0 +1,31 @@
+-- +goose Up
+CREATE TABLE oauth_clients (
+    id text NOT NULL,
+    name text NOT NULL,
+    secret_hash text NOT NULL DEFAULT '',
+    redirect_uris text[] NOT NULL,
+    created_at timestamp default current_timestamp,
+    CONSTRAINT "pk_oauth_client_id" PRIMARY KEY (id)
+);
+
+CREATE TABLE oauth_authorization_codes (
+    id uuid NOT NULL,
+    code_hash text NOT NULL,
+    client_id text NOT NULL,
+    user_id uuid NOT NULL,
+    redirect_uri text NOT NULL,
+    scope text NOT NULL DEFAULT '',
+    nonce text NOT NULL DEFAULT '',
+    code_challenge text NOT NULL,
+    expires_at timestamp NOT NULL,
+    created_at timestamp default current_timestamp,
+    used_at timestamp,
+    CONSTRAINT "pk_oauth_authorization_code_id" PRIMARY KEY (id),
+    CONSTRAINT "fk_oauth_authorization_code_client_id" FOREIGN KEY (client_id) REFERENCES oauth_clients (id) ON DELETE CASCADE,
+    CONSTRAINT "fk_oauth_authorization_code_user_id" FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+CREATE UNIQUE INDEX "idx_oauth_authorization_codes_code_hash" ON oauth_authorization_codes (code_hash);
+
+-- +goose Down
+DROP TABLE oauth_authorization_codes;
+DROP TABLE oauth_clients;
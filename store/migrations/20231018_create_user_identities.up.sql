@@ -0,0 +1,16 @@
+-- +goose Up
+CREATE TABLE user_identities (
+    id uuid NOT NULL,
+    user_id uuid NOT NULL,
+    provider text NOT NULL,
+    issuer text NOT NULL,
+    subject text NOT NULL,
+    created_at timestamp default current_timestamp,
+    CONSTRAINT "pk_user_identity_id" PRIMARY KEY (id),
+    CONSTRAINT "fk_user_identity_user_id" FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+CREATE UNIQUE INDEX "idx_user_identities_issuer_subject" ON user_identities (issuer, subject);
+CREATE INDEX "idx_user_identities_user_id" ON user_identities (user_id);
+
+-- +goose Down
+DROP TABLE user_identities;
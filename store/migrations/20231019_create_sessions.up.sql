@@ -0,0 +1,21 @@
+-- +goose Up
+CREATE TABLE sessions (
+    id uuid NOT NULL,
+    user_id uuid NOT NULL,
+    token_hash text NOT NULL,
+    csrf_hash text NOT NULL,
+    device text NOT NULL default '',
+    ip text NOT NULL default '',
+    user_agent text NOT NULL default '',
+    created_at timestamp default current_timestamp,
+    last_seen_at timestamp default current_timestamp,
+    expires_at timestamp NOT NULL,
+    revoked_at timestamp,
+    CONSTRAINT "pk_session_id" PRIMARY KEY (id),
+    CONSTRAINT "fk_session_user_id" FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+CREATE UNIQUE INDEX "idx_sessions_token_hash" ON sessions (token_hash);
+CREATE INDEX "idx_sessions_user_id" ON sessions (user_id);
+
+-- +goose Down
+DROP TABLE sessions;
@@ -0,0 +1,86 @@
+package store
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/pressly/goose/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestPgSchema connects to the Postgres at TEST_PG_URL with a new empty
+// schema, which is dropped after the test. The test is skipped without
+// TEST_PG_URL.
+func newTestPgSchema(t *testing.T) *sqlx.DB {
+	url := os.Getenv("TEST_PG_URL")
+	if url == "" {
+		t.Skip("TEST_PG_URL is not set")
+	}
+	admin, err := sqlx.Connect("postgres", url)
+	require.NoError(t, err)
+	t.Cleanup(func() { admin.Close() })
+
+	schema := "test_" + strings.ReplaceAll(uuid.NewString(), "-", "")
+	_, err = admin.Exec("CREATE SCHEMA " + schema)
+	require.NoError(t, err)
+	t.Cleanup(func() { admin.Exec("DROP SCHEMA " + schema + " CASCADE") })
+
+	sep := "?"
+	if strings.Contains(url, "?") {
+		sep = "&"
+	}
+	db, err := sqlx.Connect("postgres", url+sep+"search_path="+schema)
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// TestPgMigrationsDuplicateNicknames migrates a database holding nicknames
+// that differ only by case, from before they had to be unique
+func TestPgMigrationsDuplicateNicknames(t *testing.T) {
+	db := newTestPgSchema(t)
+
+	goose.SetBaseFS(embedMigrations)
+	require.NoError(t, goose.SetDialect("postgres"))
+	require.NoError(t, goose.UpTo(db.DB, "migrations", 20231006))
+
+	ids := []uuid.UUID{uuid.New(), uuid.New(), uuid.New(), uuid.New()}
+	nicknames := []string{"Topol", "topol", "TOPOL", "bohdan"}
+	created := time.Now().Add(-time.Hour)
+	for i, id := range ids {
+		deletedAt := (*time.Time)(nil)
+		if i == 2 {
+			deletedAt = &created
+		}
+		_, err := db.Exec("INSERT INTO users (id, firstname, lastname, nickname, password, created_at, deleted_at) VALUES ($1, 'Olexandr', 'Topol', $2, 'hash', $3, $4)",
+			id, nicknames[i], created.Add(time.Duration(i)*time.Minute), deletedAt)
+		require.NoError(t, err)
+	}
+
+	require.NoError(t, runPgMigrations(db))
+
+	tests := []struct {
+		testName string
+		id       uuid.UUID
+		expected string
+	}{
+		{testName: "oldest duplicate keeps its nickname", id: ids[0], expected: "Topol"},
+		{testName: "newer duplicate is renamed", id: ids[1], expected: fmt.Sprintf("topol_%s", ids[1].String()[:8])},
+		{testName: "deleted duplicate is renamed", id: ids[2], expected: fmt.Sprintf("TOPOL_%s", ids[2].String()[:8])},
+		{testName: "unique nickname stays", id: ids[3], expected: "bohdan"},
+	}
+
+	for _, test := range tests {
+		t.Logf("running %v", test.testName)
+
+		var nickname string
+		require.NoError(t, db.Get(&nickname, "SELECT nickname FROM users WHERE id = $1", test.id))
+		assert.Equal(t, test.expected, nickname)
+	}
+}
@@ -0,0 +1,110 @@
+package mocks
+
+import (
+	"context"
+	"time"
+
+	"github.com/VikaGo/REST_API/model"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/mock"
+)
+
+// APIKeyRepo is an autogenerated mock type for the APIKeyRepo type
+type APIKeyRepo struct {
+	mock.Mock
+}
+
+// CreateAPIKey provides a mock function with given fields: _a0, _a1
+func (_m *APIKeyRepo) CreateAPIKey(_a0 context.Context, _a1 *model.DBAPIKey) error {
+	ret := _m.Called(_a0, _a1)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *model.DBAPIKey) error); ok {
+		r0 = rf(_a0, _a1)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// GetAPIKeyByHash provides a mock function with given fields: ctx, keyHash
+func (_m *APIKeyRepo) GetAPIKeyByHash(ctx context.Context, keyHash string) (*model.DBAPIKey, error) {
+	ret := _m.Called(ctx, keyHash)
+
+	var r0 *model.DBAPIKey
+	if rf, ok := ret.Get(0).(func(context.Context, string) *model.DBAPIKey); ok {
+		r0 = rf(ctx, keyHash)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.DBAPIKey)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, keyHash)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ListUserAPIKeys provides a mock function with given fields: ctx, userID
+func (_m *APIKeyRepo) ListUserAPIKeys(ctx context.Context, userID uuid.UUID) ([]*model.DBAPIKey, error) {
+	ret := _m.Called(ctx, userID)
+
+	var r0 []*model.DBAPIKey
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID) []*model.DBAPIKey); ok {
+		r0 = rf(ctx, userID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*model.DBAPIKey)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, uuid.UUID) error); ok {
+		r1 = rf(ctx, userID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// RevokeAPIKey provides a mock function with given fields: ctx, userID, id
+func (_m *APIKeyRepo) RevokeAPIKey(ctx context.Context, userID uuid.UUID, id uuid.UUID) (bool, error) {
+	ret := _m.Called(ctx, userID, id)
+
+	var r0 bool
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID, uuid.UUID) bool); ok {
+		r0 = rf(ctx, userID, id)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, uuid.UUID, uuid.UUID) error); ok {
+		r1 = rf(ctx, userID, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// TouchAPIKey provides a mock function with given fields: ctx, id, at, notBefore
+func (_m *APIKeyRepo) TouchAPIKey(ctx context.Context, id uuid.UUID, at time.Time, notBefore time.Time) error {
+	ret := _m.Called(ctx, id, at, notBefore)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID, time.Time, time.Time) error); ok {
+		r0 = rf(ctx, id, at, notBefore)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
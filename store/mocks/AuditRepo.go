@@ -0,0 +1,50 @@
+package mocks
+
+import (
+	"context"
+
+	"github.com/VikaGo/REST_API/model"
+	"github.com/stretchr/testify/mock"
+)
+
+// AuditRepo is an autogenerated mock type for the AuditRepo type
+type AuditRepo struct {
+	mock.Mock
+}
+
+// CreateAuditEvent provides a mock function with given fields: _a0, _a1
+func (_m *AuditRepo) CreateAuditEvent(_a0 context.Context, _a1 *model.DBAuditEvent) error {
+	ret := _m.Called(_a0, _a1)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *model.DBAuditEvent) error); ok {
+		r0 = rf(_a0, _a1)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// ListAuditEvents provides a mock function with given fields: _a0, _a1
+func (_m *AuditRepo) ListAuditEvents(_a0 context.Context, _a1 *model.AuditQuery) ([]*model.DBAuditEvent, error) {
+	ret := _m.Called(_a0, _a1)
+
+	var r0 []*model.DBAuditEvent
+	if rf, ok := ret.Get(0).(func(context.Context, *model.AuditQuery) []*model.DBAuditEvent); ok {
+		r0 = rf(_a0, _a1)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*model.DBAuditEvent)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, *model.AuditQuery) error); ok {
+		r1 = rf(_a0, _a1)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
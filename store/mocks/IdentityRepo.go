@@ -0,0 +1,50 @@
+package mocks
+
+import (
+	"context"
+
+	"github.com/VikaGo/REST_API/model"
+	"github.com/stretchr/testify/mock"
+)
+
+// IdentityRepo is an autogenerated mock type for the IdentityRepo type
+type IdentityRepo struct {
+	mock.Mock
+}
+
+// CreateIdentity provides a mock function with given fields: _a0, _a1
+func (_m *IdentityRepo) CreateIdentity(_a0 context.Context, _a1 *model.DBUserIdentity) error {
+	ret := _m.Called(_a0, _a1)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *model.DBUserIdentity) error); ok {
+		r0 = rf(_a0, _a1)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// GetIdentity provides a mock function with given fields: ctx, issuer, subject
+func (_m *IdentityRepo) GetIdentity(ctx context.Context, issuer string, subject string) (*model.DBUserIdentity, error) {
+	ret := _m.Called(ctx, issuer, subject)
+
+	var r0 *model.DBUserIdentity
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) *model.DBUserIdentity); ok {
+		r0 = rf(ctx, issuer, subject)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.DBUserIdentity)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, string) error); ok {
+		r1 = rf(ctx, issuer, subject)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
@@ -0,0 +1,131 @@
+package mocks
+
+import (
+	"context"
+
+	"github.com/VikaGo/REST_API/model"
+	"github.com/stretchr/testify/mock"
+)
+
+// OAuthRepo is an autogenerated mock type for the OAuthRepo type
+type OAuthRepo struct {
+	mock.Mock
+}
+
+// CreateOAuthClient provides a mock function with given fields: _a0, _a1
+func (_m *OAuthRepo) CreateOAuthClient(_a0 context.Context, _a1 *model.DBOAuthClient) error {
+	ret := _m.Called(_a0, _a1)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *model.DBOAuthClient) error); ok {
+		r0 = rf(_a0, _a1)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// GetOAuthClient provides a mock function with given fields: ctx, id
+func (_m *OAuthRepo) GetOAuthClient(ctx context.Context, id string) (*model.DBOAuthClient, error) {
+	ret := _m.Called(ctx, id)
+
+	var r0 *model.DBOAuthClient
+	if rf, ok := ret.Get(0).(func(context.Context, string) *model.DBOAuthClient); ok {
+		r0 = rf(ctx, id)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.DBOAuthClient)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ListOAuthClients provides a mock function with given fields: _a0
+func (_m *OAuthRepo) ListOAuthClients(_a0 context.Context) ([]*model.DBOAuthClient, error) {
+	ret := _m.Called(_a0)
+
+	var r0 []*model.DBOAuthClient
+	if rf, ok := ret.Get(0).(func(context.Context) []*model.DBOAuthClient); ok {
+		r0 = rf(_a0)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*model.DBOAuthClient)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(_a0)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// DeleteOAuthClient provides a mock function with given fields: ctx, id
+func (_m *OAuthRepo) DeleteOAuthClient(ctx context.Context, id string) (bool, error) {
+	ret := _m.Called(ctx, id)
+
+	var r0 bool
+	if rf, ok := ret.Get(0).(func(context.Context, string) bool); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// CreateAuthorizationCode provides a mock function with given fields: _a0, _a1
+func (_m *OAuthRepo) CreateAuthorizationCode(_a0 context.Context, _a1 *model.DBAuthorizationCode) error {
+	ret := _m.Called(_a0, _a1)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *model.DBAuthorizationCode) error); ok {
+		r0 = rf(_a0, _a1)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// ConsumeAuthorizationCode provides a mock function with given fields: ctx, codeHash
+func (_m *OAuthRepo) ConsumeAuthorizationCode(ctx context.Context, codeHash string) (*model.DBAuthorizationCode, error) {
+	ret := _m.Called(ctx, codeHash)
+
+	var r0 *model.DBAuthorizationCode
+	if rf, ok := ret.Get(0).(func(context.Context, string) *model.DBAuthorizationCode); ok {
+		r0 = rf(ctx, codeHash)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.DBAuthorizationCode)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, codeHash)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
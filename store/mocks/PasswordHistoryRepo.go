@@ -0,0 +1,51 @@
+package mocks
+
+import (
+	"context"
+
+	"github.com/VikaGo/REST_API/model"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/mock"
+)
+
+// PasswordHistoryRepo is an autogenerated mock type for the PasswordHistoryRepo type
+type PasswordHistoryRepo struct {
+	mock.Mock
+}
+
+// AddPasswordHistory provides a mock function with given fields: ctx, entry, keep
+func (_m *PasswordHistoryRepo) AddPasswordHistory(ctx context.Context, entry *model.DBPasswordHistory, keep int) error {
+	ret := _m.Called(ctx, entry, keep)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *model.DBPasswordHistory, int) error); ok {
+		r0 = rf(ctx, entry, keep)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// ListPasswordHistory provides a mock function with given fields: ctx, userID, limit
+func (_m *PasswordHistoryRepo) ListPasswordHistory(ctx context.Context, userID uuid.UUID, limit int) ([]string, error) {
+	ret := _m.Called(ctx, userID, limit)
+
+	var r0 []string
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID, int) []string); ok {
+		r0 = rf(ctx, userID, limit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]string)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, uuid.UUID, int) error); ok {
+		r1 = rf(ctx, userID, limit)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
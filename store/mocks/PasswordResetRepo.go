@@ -0,0 +1,88 @@
+package mocks
+
+import (
+	"context"
+
+	"github.com/VikaGo/REST_API/model"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/mock"
+)
+
+// PasswordResetRepo is an autogenerated mock type for the PasswordResetRepo type
+type PasswordResetRepo struct {
+	mock.Mock
+}
+
+// CreatePasswordResetToken provides a mock function with given fields: _a0, _a1
+func (_m *PasswordResetRepo) CreatePasswordResetToken(_a0 context.Context, _a1 *model.DBPasswordResetToken) error {
+	ret := _m.Called(_a0, _a1)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *model.DBPasswordResetToken) error); ok {
+		r0 = rf(_a0, _a1)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// GetPasswordResetToken provides a mock function with given fields: ctx, tokenHash
+func (_m *PasswordResetRepo) GetPasswordResetToken(ctx context.Context, tokenHash string) (*model.DBPasswordResetToken, error) {
+	ret := _m.Called(ctx, tokenHash)
+
+	var r0 *model.DBPasswordResetToken
+	if rf, ok := ret.Get(0).(func(context.Context, string) *model.DBPasswordResetToken); ok {
+		r0 = rf(ctx, tokenHash)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.DBPasswordResetToken)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, tokenHash)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ConsumePasswordResetToken provides a mock function with given fields: ctx, tokenHash
+func (_m *PasswordResetRepo) ConsumePasswordResetToken(ctx context.Context, tokenHash string) (*model.DBPasswordResetToken, error) {
+	ret := _m.Called(ctx, tokenHash)
+
+	var r0 *model.DBPasswordResetToken
+	if rf, ok := ret.Get(0).(func(context.Context, string) *model.DBPasswordResetToken); ok {
+		r0 = rf(ctx, tokenHash)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.DBPasswordResetToken)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, tokenHash)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// DeleteUserPasswordResetTokens provides a mock function with given fields: ctx, userID
+func (_m *PasswordResetRepo) DeleteUserPasswordResetTokens(ctx context.Context, userID uuid.UUID) error {
+	ret := _m.Called(ctx, userID)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID) error); ok {
+		r0 = rf(ctx, userID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
@@ -0,0 +1,114 @@
+package mocks
+
+import (
+	"context"
+
+	"github.com/VikaGo/REST_API/model"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/mock"
+)
+
+// RefreshTokenRepo is an autogenerated mock type for the RefreshTokenRepo type
+type RefreshTokenRepo struct {
+	mock.Mock
+}
+
+// CreateRefreshToken provides a mock function with given fields: _a0, _a1
+func (_m *RefreshTokenRepo) CreateRefreshToken(_a0 context.Context, _a1 *model.DBRefreshToken) error {
+	ret := _m.Called(_a0, _a1)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *model.DBRefreshToken) error); ok {
+		r0 = rf(_a0, _a1)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// GetRefreshTokenByHash provides a mock function with given fields: ctx, tokenHash
+func (_m *RefreshTokenRepo) GetRefreshTokenByHash(ctx context.Context, tokenHash string) (*model.DBRefreshToken, error) {
+	ret := _m.Called(ctx, tokenHash)
+
+	var r0 *model.DBRefreshToken
+	if rf, ok := ret.Get(0).(func(context.Context, string) *model.DBRefreshToken); ok {
+		r0 = rf(ctx, tokenHash)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.DBRefreshToken)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, tokenHash)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// RevokeRefreshToken provides a mock function with given fields: ctx, id, replacedBy
+func (_m *RefreshTokenRepo) RevokeRefreshToken(ctx context.Context, id uuid.UUID, replacedBy *uuid.UUID) (bool, error) {
+	ret := _m.Called(ctx, id, replacedBy)
+
+	var r0 bool
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID, *uuid.UUID) bool); ok {
+		r0 = rf(ctx, id, replacedBy)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, uuid.UUID, *uuid.UUID) error); ok {
+		r1 = rf(ctx, id, replacedBy)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// RevokeTokenFamily provides a mock function with given fields: ctx, familyID
+func (_m *RefreshTokenRepo) RevokeTokenFamily(ctx context.Context, familyID uuid.UUID) error {
+	ret := _m.Called(ctx, familyID)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID) error); ok {
+		r0 = rf(ctx, familyID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// RevokeUserTokens provides a mock function with given fields: ctx, userID
+func (_m *RefreshTokenRepo) RevokeUserTokens(ctx context.Context, userID uuid.UUID) error {
+	ret := _m.Called(ctx, userID)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID) error); ok {
+		r0 = rf(ctx, userID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// RevokeOtherUserTokens provides a mock function with given fields: ctx, userID, keepFamilyID
+func (_m *RefreshTokenRepo) RevokeOtherUserTokens(ctx context.Context, userID uuid.UUID, keepFamilyID uuid.UUID) error {
+	ret := _m.Called(ctx, userID, keepFamilyID)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID, uuid.UUID) error); ok {
+		r0 = rf(ctx, userID, keepFamilyID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
@@ -0,0 +1,50 @@
+package mocks
+
+import (
+	"context"
+
+	"github.com/VikaGo/REST_API/model"
+	"github.com/stretchr/testify/mock"
+)
+
+// RoleSettingsRepo is an autogenerated mock type for the RoleSettingsRepo type
+type RoleSettingsRepo struct {
+	mock.Mock
+}
+
+// GetRoleSettings provides a mock function with given fields: ctx, role
+func (_m *RoleSettingsRepo) GetRoleSettings(ctx context.Context, role string) (*model.RoleSettings, error) {
+	ret := _m.Called(ctx, role)
+
+	var r0 *model.RoleSettings
+	if rf, ok := ret.Get(0).(func(context.Context, string) *model.RoleSettings); ok {
+		r0 = rf(ctx, role)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.RoleSettings)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, role)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// SaveRoleSettings provides a mock function with given fields: _a0, _a1
+func (_m *RoleSettingsRepo) SaveRoleSettings(_a0 context.Context, _a1 *model.RoleSettings) error {
+	ret := _m.Called(_a0, _a1)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *model.RoleSettings) error); ok {
+		r0 = rf(_a0, _a1)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
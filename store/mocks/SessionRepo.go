@@ -0,0 +1,124 @@
+package mocks
+
+import (
+	"context"
+	"time"
+
+	"github.com/VikaGo/REST_API/model"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/mock"
+)
+
+// SessionRepo is an autogenerated mock type for the SessionRepo type
+type SessionRepo struct {
+	mock.Mock
+}
+
+// CreateSession provides a mock function with given fields: _a0, _a1
+func (_m *SessionRepo) CreateSession(_a0 context.Context, _a1 *model.DBSession) error {
+	ret := _m.Called(_a0, _a1)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *model.DBSession) error); ok {
+		r0 = rf(_a0, _a1)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// GetSessionByHash provides a mock function with given fields: ctx, tokenHash
+func (_m *SessionRepo) GetSessionByHash(ctx context.Context, tokenHash string) (*model.DBSession, error) {
+	ret := _m.Called(ctx, tokenHash)
+
+	var r0 *model.DBSession
+	if rf, ok := ret.Get(0).(func(context.Context, string) *model.DBSession); ok {
+		r0 = rf(ctx, tokenHash)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.DBSession)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, tokenHash)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ListUserSessions provides a mock function with given fields: ctx, userID, now
+func (_m *SessionRepo) ListUserSessions(ctx context.Context, userID uuid.UUID, now time.Time) ([]*model.DBSession, error) {
+	ret := _m.Called(ctx, userID, now)
+
+	var r0 []*model.DBSession
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID, time.Time) []*model.DBSession); ok {
+		r0 = rf(ctx, userID, now)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*model.DBSession)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, uuid.UUID, time.Time) error); ok {
+		r1 = rf(ctx, userID, now)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// RevokeSession provides a mock function with given fields: ctx, userID, id
+func (_m *SessionRepo) RevokeSession(ctx context.Context, userID uuid.UUID, id uuid.UUID) (bool, error) {
+	ret := _m.Called(ctx, userID, id)
+
+	var r0 bool
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID, uuid.UUID) bool); ok {
+		r0 = rf(ctx, userID, id)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, uuid.UUID, uuid.UUID) error); ok {
+		r1 = rf(ctx, userID, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// RevokeUserSessions provides a mock function with given fields: ctx, userID, keepID
+func (_m *SessionRepo) RevokeUserSessions(ctx context.Context, userID uuid.UUID, keepID *uuid.UUID) error {
+	ret := _m.Called(ctx, userID, keepID)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID, *uuid.UUID) error); ok {
+		r0 = rf(ctx, userID, keepID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// TouchSession provides a mock function with given fields: ctx, id, at, notBefore
+func (_m *SessionRepo) TouchSession(ctx context.Context, id uuid.UUID, at time.Time, notBefore time.Time) error {
+	ret := _m.Called(ctx, id, at, notBefore)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID, time.Time, time.Time) error); ok {
+		r0 = rf(ctx, id, at, notBefore)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
@@ -2,6 +2,7 @@ package mocks
 
 import (
 	"context"
+	"time"
 
 	"github.com/VikaGo/REST_API/model"
 	"github.com/google/uuid"
@@ -18,6 +19,43 @@ func (_m *UserRepo) GetUserByNickname(ctx context.Context, nickname string) (*mo
 	return args.Get(0).(*model.DBUser), args.Error(1)
 }
 
+// RehashPassword provides a mock function with given fields: ctx, id, oldHash, newHash
+func (_m *UserRepo) RehashPassword(ctx context.Context, id uuid.UUID, oldHash string, newHash string) error {
+	ret := _m.Called(ctx, id, oldHash, newHash)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID, string, string) error); ok {
+		r0 = rf(ctx, id, oldHash, newHash)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// GetUserByEmail provides a mock function with given fields: ctx, email
+func (_m *UserRepo) GetUserByEmail(ctx context.Context, email string) (*model.DBUser, error) {
+	ret := _m.Called(ctx, email)
+
+	var r0 *model.DBUser
+	if rf, ok := ret.Get(0).(func(context.Context, string) *model.DBUser); ok {
+		r0 = rf(ctx, email)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.DBUser)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, email)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // CreateUser provides a mock function with given fields: _a0, _a1
 func (_m *UserRepo) CreateUser(_a0 context.Context, _a1 *model.DBUser) (*model.DBUser, error) {
 	ret := _m.Called(_a0, _a1)
@@ -41,13 +79,13 @@ func (_m *UserRepo) CreateUser(_a0 context.Context, _a1 *model.DBUser) (*model.D
 	return r0, r1
 }
 
-// DeleteUser provides a mock function with given fields: _a0, _a1
-func (_m *UserRepo) DeleteUser(_a0 context.Context, _a1 uuid.UUID) error {
-	ret := _m.Called(_a0, _a1)
+// DeleteUser provides a mock function with given fields: ctx, id, version
+func (_m *UserRepo) DeleteUser(ctx context.Context, id uuid.UUID, version int) error {
+	ret := _m.Called(ctx, id, version)
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID) error); ok {
-		r0 = rf(_a0, _a1)
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID, int) error); ok {
+		r0 = rf(ctx, id, version)
 	} else {
 		r0 = ret.Error(0)
 	}
@@ -106,3 +144,79 @@ func (_m *UserRepo) GetPassword(ctx context.Context, u uuid.UUID) (string, error
 	args := _m.Called(ctx, u)
 	return args.String(0), args.Error(1)
 }
+
+// ListUsers provides a mock function with given fields: _a0, _a1
+func (_m *UserRepo) ListUsers(_a0 context.Context, _a1 *model.UserListQuery) ([]*model.DBUser, error) {
+	ret := _m.Called(_a0, _a1)
+
+	var r0 []*model.DBUser
+	if rf, ok := ret.Get(0).(func(context.Context, *model.UserListQuery) []*model.DBUser); ok {
+		r0 = rf(_a0, _a1)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*model.DBUser)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, *model.UserListQuery) error); ok {
+		r1 = rf(_a0, _a1)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// CountUsers provides a mock function with given fields: _a0, _a1
+func (_m *UserRepo) CountUsers(_a0 context.Context, _a1 *model.UserFilter) (int, error) {
+	ret := _m.Called(_a0, _a1)
+	return ret.Int(0), ret.Error(1)
+}
+
+// GetUserIncludingDeleted provides a mock function with given fields: _a0, _a1
+func (_m *UserRepo) GetUserIncludingDeleted(_a0 context.Context, _a1 uuid.UUID) (*model.DBUser, error) {
+	ret := _m.Called(_a0, _a1)
+
+	var r0 *model.DBUser
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID) *model.DBUser); ok {
+		r0 = rf(_a0, _a1)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.DBUser)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, uuid.UUID) error); ok {
+		r1 = rf(_a0, _a1)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// RestoreUser provides a mock function with given fields: _a0, _a1
+func (_m *UserRepo) RestoreUser(_a0 context.Context, _a1 uuid.UUID) (bool, error) {
+	ret := _m.Called(_a0, _a1)
+	return ret.Bool(0), ret.Error(1)
+}
+
+// PurgeDeletedUsers provides a mock function with given fields: ctx, deletedBefore
+func (_m *UserRepo) PurgeDeletedUsers(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	ret := _m.Called(ctx, deletedBefore)
+	return ret.Get(0).(int64), ret.Error(1)
+}
+
+// PatchUser provides a mock function with given fields: ctx, id, version, columns
+func (_m *UserRepo) PatchUser(ctx context.Context, id uuid.UUID, version int, columns map[string]interface{}) (*model.DBUser, error) {
+	ret := _m.Called(ctx, id, version, columns)
+
+	var r0 *model.DBUser
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(*model.DBUser)
+	}
+
+	return r0, ret.Error(1)
+}
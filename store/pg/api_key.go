@@ -0,0 +1,74 @@
+package pg
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/VikaGo/REST_API/model"
+	"github.com/google/uuid"
+)
+
+// APIKeyRepo ...
+type APIKeyRepo struct {
+	db DB
+}
+
+// NewAPIKeyRepo ...
+func NewAPIKeyRepo(db DB) *APIKeyRepo {
+	return &APIKeyRepo{db: db}
+}
+
+// CreateAPIKey stores an API key in Postgres
+func (repo *APIKeyRepo) CreateAPIKey(ctx context.Context, key *model.DBAPIKey) error {
+	_, err := repo.db.NamedExecContext(ctx, `INSERT INTO api_keys (id, user_id, name, prefix, key_hash, scopes, expires_at, created_at)
+		VALUES (:id, :user_id, :name, :prefix, :key_hash, :scopes, :expires_at, :created_at)`, key)
+	return err
+}
+
+// GetAPIKeyByHash retrieves the API key with the given hash from Postgres,
+// including revoked and expired keys
+func (repo *APIKeyRepo) GetAPIKeyByHash(ctx context.Context, keyHash string) (*model.DBAPIKey, error) {
+	key := &model.DBAPIKey{}
+	err := repo.db.GetContext(ctx, key, "SELECT * FROM api_keys WHERE key_hash = $1", keyHash)
+	if err != nil {
+		if err == sql.ErrNoRows { //not found
+			return nil, nil
+		}
+		return nil, err
+	}
+	return key, nil
+}
+
+// ListUserAPIKeys retrieves the API keys of a user that were not revoked
+// from Postgres, newest first
+func (repo *APIKeyRepo) ListUserAPIKeys(ctx context.Context, userID uuid.UUID) ([]*model.DBAPIKey, error) {
+	keys := []*model.DBAPIKey{}
+	err := repo.db.SelectContext(ctx, &keys, "SELECT * FROM api_keys WHERE user_id = $1 AND revoked_at IS NULL ORDER BY created_at DESC", userID)
+	if err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// RevokeAPIKey revokes an API key of a user in Postgres. It returns false
+// if the user has no such key or it was revoked already.
+func (repo *APIKeyRepo) RevokeAPIKey(ctx context.Context, userID, id uuid.UUID) (bool, error) {
+	result, err := repo.db.ExecContext(ctx, "UPDATE api_keys SET revoked_at = current_timestamp WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL", id, userID)
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rows > 0, nil
+}
+
+// TouchAPIKey sets the last use of an API key in Postgres, unless it was
+// already used after notBefore; keys used by busy jobs are not written on
+// every request.
+func (repo *APIKeyRepo) TouchAPIKey(ctx context.Context, id uuid.UUID, at, notBefore time.Time) error {
+	_, err := repo.db.ExecContext(ctx, "UPDATE api_keys SET last_used_at = $2 WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < $3)", id, at, notBefore)
+	return err
+}
@@ -0,0 +1,74 @@
+package pg
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/VikaGo/REST_API/model"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// AuditRepo ...
+type AuditRepo struct {
+	db DB
+}
+
+// NewAuditRepo ...
+func NewAuditRepo(db DB) *AuditRepo {
+	return &AuditRepo{db: db}
+}
+
+// CreateAuditEvent appends an event to the audit log in Postgres
+func (repo *AuditRepo) CreateAuditEvent(ctx context.Context, event *model.DBAuditEvent) error {
+	if event.Fields == nil {
+		// a NULL would not get the column default
+		withFields := *event
+		withFields.Fields = pq.StringArray{}
+		event = &withFields
+	}
+	_, err := repo.db.NamedExecContext(ctx, "INSERT INTO audit_events (id, action, actor_id, target_id, fields, ip, user_agent, request_id) VALUES (:id, :action, :actor_id, :target_id, :fields, :ip, :user_agent, :request_id)", event)
+	return err
+}
+
+// ListAuditEvents retrieves one page of audit events from Postgres, newest first
+func (repo *AuditRepo) ListAuditEvents(ctx context.Context, query *model.AuditQuery) ([]*model.DBAuditEvent, error) {
+	var (
+		conds []string
+		args  []interface{}
+	)
+	if query.ActorID != uuid.Nil {
+		args = append(args, query.ActorID)
+		conds = append(conds, fmt.Sprintf("actor_id = $%d", len(args)))
+	}
+	if query.TargetID != uuid.Nil {
+		args = append(args, query.TargetID)
+		conds = append(conds, fmt.Sprintf("target_id = $%d", len(args)))
+	}
+	if query.Action != "" {
+		args = append(args, query.Action)
+		conds = append(conds, fmt.Sprintf("action = $%d", len(args)))
+	}
+	if !query.From.IsZero() {
+		args = append(args, query.From)
+		conds = append(conds, fmt.Sprintf("created_at >= $%d", len(args)))
+	}
+	if !query.To.IsZero() {
+		args = append(args, query.To)
+		conds = append(conds, fmt.Sprintf("created_at < $%d", len(args)))
+	}
+	if query.Before != nil {
+		args = append(args, query.Before.CreatedAt, query.Before.ID)
+		conds = append(conds, fmt.Sprintf("(created_at, id) < ($%d, $%d)", len(args)-1, len(args)))
+	}
+
+	args = append(args, query.Limit)
+	sqlQuery := fmt.Sprintf("SELECT * FROM audit_events%s ORDER BY created_at DESC, id DESC LIMIT $%d", whereClause(conds), len(args))
+
+	events := []*model.DBAuditEvent{}
+	err := repo.db.SelectContext(ctx, &events, sqlQuery, args...)
+	if err != nil {
+		return nil, err
+	}
+	return events, nil
+}
@@ -0,0 +1,81 @@
+package pg
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"testing"
+
+	"github.com/VikaGo/REST_API/model"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingConnector opens connections that keep the arguments of the
+// statements they execute, like Postgres would receive them
+type recordingConnector struct {
+	args *[][]driver.NamedValue
+}
+
+func (c recordingConnector) Connect(context.Context) (driver.Conn, error) {
+	return recordingConn(c), nil
+}
+func (recordingConnector) Driver() driver.Driver { return nil }
+
+type recordingConn recordingConnector
+
+func (recordingConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (recordingConn) Close() error                        { return nil }
+func (recordingConn) Begin() (driver.Tx, error)           { return nil, errors.New("not supported") }
+
+func (c recordingConn) ExecContext(_ context.Context, _ string, args []driver.NamedValue) (driver.Result, error) {
+	*c.args = append(*c.args, args)
+	return driver.RowsAffected(1), nil
+}
+
+func TestCreateAuditEvent(t *testing.T) {
+	var args [][]driver.NamedValue
+	db := sqlx.NewDb(sql.OpenDB(recordingConnector{args: &args}), "postgres")
+	defer db.Close()
+	repo := NewAuditRepo(db)
+
+	tests := []struct {
+		testName string
+		fields   pq.StringArray
+		expected driver.Value
+	}{
+		{
+			testName: "nil fields",
+			fields:   nil,
+			expected: "{}",
+		},
+		{
+			testName: "no fields",
+			fields:   pq.StringArray{},
+			expected: "{}",
+		},
+		{
+			testName: "changed fields",
+			fields:   pq.StringArray{"firstname", "role"},
+			expected: `{"firstname","role"}`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Logf("running %v", test.testName)
+
+		args = nil
+		event := &model.DBAuditEvent{ID: uuid.New(), Action: model.AuditUserCreate, Fields: test.fields}
+		require.NoError(t, repo.CreateAuditEvent(context.Background(), event))
+		require.Len(t, args, 1)
+
+		// fields is NOT NULL, and an explicit NULL does not get the default
+		fields := args[0][4]
+		assert.Equal(t, test.expected, fields.Value)
+		assert.Equal(t, test.fields, event.Fields, "the event was changed")
+	}
+}
@@ -0,0 +1,68 @@
+package pg
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// DB runs the queries of the repositories: a *sqlx.DB, or the *sqlx.Tx of a
+// unit of work
+type DB interface {
+	sqlx.ExtContext
+	GetContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error
+	SelectContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error
+	NamedExecContext(ctx context.Context, query string, arg interface{}) (sql.Result, error)
+	PrepareNamedContext(ctx context.Context, query string) (*sqlx.NamedStmt, error)
+}
+
+var (
+	_ DB = (*sqlx.DB)(nil)
+	_ DB = (*sqlx.Tx)(nil)
+)
+
+// withTx runs fn in a transaction of db, or right in db if it already is a
+// transaction
+func withTx(ctx context.Context, db DB, fn func(tx DB) error) error {
+	sqlxDB, ok := db.(*sqlx.DB)
+	if !ok {
+		return fn(db)
+	}
+
+	tx, err := sqlxDB.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Timeouts bound how long a query may run by the class of its operation.
+// A zero timeout leaves the query to the context of the caller.
+type Timeouts struct {
+	Read  time.Duration
+	Write time.Duration
+}
+
+// read derives the context of a query that only reads data
+func (timeouts Timeouts) read(ctx context.Context) (context.Context, context.CancelFunc) {
+	return withTimeout(ctx, timeouts.Read)
+}
+
+// write derives the context of a query that changes data
+func (timeouts Timeouts) write(ctx context.Context) (context.Context, context.CancelFunc) {
+	return withTimeout(ctx, timeouts.Write)
+}
+
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
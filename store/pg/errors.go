@@ -0,0 +1,53 @@
+package pg
+
+import (
+	"context"
+
+	"github.com/VikaGo/REST_API/pkg/types"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+)
+
+// Postgres error codes
+const (
+	// uniqueViolation is the code of a unique constraint violation
+	uniqueViolation = "23505"
+	// serializationFailure and deadlockDetected abort transactions that
+	// would succeed if they were run again
+	serializationFailure = "40001"
+	deadlockDetected     = "40P01"
+)
+
+// constraintFields names the field every unique constraint guards
+var constraintFields = map[string]string{
+	"idx_users_nickname": "nickname",
+	"idx_users_email":    "email",
+}
+
+// translateError turns Postgres constraint violations into domain errors
+func translateError(err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == uniqueViolation {
+		return &types.FieldError{Err: types.ErrDuplicateEntry, Field: constraintFields[pqErr.Constraint]}
+	}
+	return err
+}
+
+// IsRetryable reports whether err aborted a transaction that may succeed
+// if it is run again
+func IsRetryable(err error) bool {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return false
+	}
+	return pqErr.Code == serializationFailure || pqErr.Code == deadlockDetected
+}
+
+// queryError reports a query aborted by ctx as the error of ctx, so callers
+// tell canceled requests and timeouts from failing queries
+func queryError(ctx context.Context, err error) error {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return errors.Wrapf(ctxErr, "query aborted: %v", err)
+	}
+	return err
+}
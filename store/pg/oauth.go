@@ -0,0 +1,86 @@
+package pg
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/VikaGo/REST_API/model"
+)
+
+// OAuthRepo ...
+type OAuthRepo struct {
+	db DB
+}
+
+// NewOAuthRepo ...
+func NewOAuthRepo(db DB) *OAuthRepo {
+	return &OAuthRepo{db: db}
+}
+
+// CreateOAuthClient stores an OAuth client in Postgres
+func (repo *OAuthRepo) CreateOAuthClient(ctx context.Context, client *model.DBOAuthClient) error {
+	_, err := repo.db.NamedExecContext(ctx, `INSERT INTO oauth_clients (id, name, secret_hash, redirect_uris, created_at)
+		VALUES (:id, :name, :secret_hash, :redirect_uris, :created_at)`, client)
+	return err
+}
+
+// GetOAuthClient retrieves an OAuth client from Postgres
+func (repo *OAuthRepo) GetOAuthClient(ctx context.Context, id string) (*model.DBOAuthClient, error) {
+	client := &model.DBOAuthClient{}
+	err := repo.db.GetContext(ctx, client, "SELECT * FROM oauth_clients WHERE id = $1", id)
+	if err != nil {
+		if err == sql.ErrNoRows { //not found
+			return nil, nil
+		}
+		return nil, err
+	}
+	return client, nil
+}
+
+// ListOAuthClients retrieves every OAuth client from Postgres
+func (repo *OAuthRepo) ListOAuthClients(ctx context.Context) ([]*model.DBOAuthClient, error) {
+	clients := []*model.DBOAuthClient{}
+	err := repo.db.SelectContext(ctx, &clients, "SELECT * FROM oauth_clients ORDER BY created_at")
+	if err != nil {
+		return nil, err
+	}
+	return clients, nil
+}
+
+// DeleteOAuthClient deletes an OAuth client and its authorization codes
+// from Postgres. It returns false if there is no such client.
+func (repo *OAuthRepo) DeleteOAuthClient(ctx context.Context, id string) (bool, error) {
+	result, err := repo.db.ExecContext(ctx, "DELETE FROM oauth_clients WHERE id = $1", id)
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rows > 0, nil
+}
+
+// CreateAuthorizationCode stores an OAuth authorization code in Postgres
+func (repo *OAuthRepo) CreateAuthorizationCode(ctx context.Context, code *model.DBAuthorizationCode) error {
+	_, err := repo.db.NamedExecContext(ctx, `INSERT INTO oauth_authorization_codes
+		(id, code_hash, client_id, user_id, redirect_uri, scope, nonce, code_challenge, expires_at)
+		VALUES (:id, :code_hash, :client_id, :user_id, :redirect_uri, :scope, :nonce, :code_challenge, :expires_at)`, code)
+	return err
+}
+
+// ConsumeAuthorizationCode marks the authorization code with the given hash
+// used and returns it. It returns nil if the code does not exist, expired
+// or was used already, so a code can only be exchanged once.
+func (repo *OAuthRepo) ConsumeAuthorizationCode(ctx context.Context, codeHash string) (*model.DBAuthorizationCode, error) {
+	code := &model.DBAuthorizationCode{}
+	err := repo.db.GetContext(ctx, code, `UPDATE oauth_authorization_codes SET used_at = current_timestamp
+		WHERE code_hash = $1 AND used_at IS NULL AND expires_at > current_timestamp RETURNING *`, codeHash)
+	if err != nil {
+		if err == sql.ErrNoRows { //not found, used or expired
+			return nil, nil
+		}
+		return nil, err
+	}
+	return code, nil
+}
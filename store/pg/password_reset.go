@@ -0,0 +1,61 @@
+package pg
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/VikaGo/REST_API/model"
+	"github.com/google/uuid"
+)
+
+// PasswordResetRepo ...
+type PasswordResetRepo struct {
+	db DB
+}
+
+// NewPasswordResetRepo ...
+func NewPasswordResetRepo(db DB) *PasswordResetRepo {
+	return &PasswordResetRepo{db: db}
+}
+
+// CreatePasswordResetToken stores a password reset token in Postgres
+func (repo *PasswordResetRepo) CreatePasswordResetToken(ctx context.Context, token *model.DBPasswordResetToken) error {
+	_, err := repo.db.NamedExecContext(ctx, "INSERT INTO password_reset_tokens (id, user_id, token_hash, expires_at) VALUES (:id, :user_id, :token_hash, :expires_at)", token)
+	return err
+}
+
+// GetPasswordResetToken retrieves the unused and unexpired token with the
+// given hash from Postgres
+func (repo *PasswordResetRepo) GetPasswordResetToken(ctx context.Context, tokenHash string) (*model.DBPasswordResetToken, error) {
+	token := &model.DBPasswordResetToken{}
+	err := repo.db.GetContext(ctx, token, "SELECT * FROM password_reset_tokens WHERE token_hash = $1 AND used_at IS NULL AND expires_at > current_timestamp", tokenHash)
+	if err != nil {
+		if err == sql.ErrNoRows { //not found, used or expired
+			return nil, nil
+		}
+		return nil, err
+	}
+	return token, nil
+}
+
+// ConsumePasswordResetToken marks the token with the given hash used and
+// returns it. It returns nil if the token does not exist, expired or was
+// used already, so a token can only be consumed once.
+func (repo *PasswordResetRepo) ConsumePasswordResetToken(ctx context.Context, tokenHash string) (*model.DBPasswordResetToken, error) {
+	token := &model.DBPasswordResetToken{}
+	err := repo.db.GetContext(ctx, token, `UPDATE password_reset_tokens SET used_at = current_timestamp
+		WHERE token_hash = $1 AND used_at IS NULL AND expires_at > current_timestamp RETURNING *`, tokenHash)
+	if err != nil {
+		if err == sql.ErrNoRows { //not found, used or expired
+			return nil, nil
+		}
+		return nil, err
+	}
+	return token, nil
+}
+
+// DeleteUserPasswordResetTokens deletes every password reset token of a user from Postgres
+func (repo *PasswordResetRepo) DeleteUserPasswordResetTokens(ctx context.Context, userID uuid.UUID) error {
+	_, err := repo.db.ExecContext(ctx, "DELETE FROM password_reset_tokens WHERE user_id = $1", userID)
+	return err
+}
@@ -0,0 +1,38 @@
+package pg
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/VikaGo/REST_API/model"
+)
+
+// RoleSettingsRepo ...
+type RoleSettingsRepo struct {
+	db DB
+}
+
+// NewRoleSettingsRepo ...
+func NewRoleSettingsRepo(db DB) *RoleSettingsRepo {
+	return &RoleSettingsRepo{db: db}
+}
+
+// GetRoleSettings retrieves the settings of a role from Postgres
+func (repo *RoleSettingsRepo) GetRoleSettings(ctx context.Context, role string) (*model.RoleSettings, error) {
+	settings := &model.RoleSettings{}
+	err := repo.db.GetContext(ctx, settings, "SELECT * FROM role_settings WHERE role = $1", role)
+	if err != nil {
+		if err == sql.ErrNoRows { //not found
+			return nil, nil
+		}
+		return nil, err
+	}
+	return settings, nil
+}
+
+// SaveRoleSettings creates or replaces the settings of a role in Postgres
+func (repo *RoleSettingsRepo) SaveRoleSettings(ctx context.Context, settings *model.RoleSettings) error {
+	_, err := repo.db.NamedExecContext(ctx, `INSERT INTO role_settings (role, require_2fa) VALUES (:role, :require_2fa)
+		ON CONFLICT (role) DO UPDATE SET require_2fa = EXCLUDED.require_2fa`, settings)
+	return err
+}
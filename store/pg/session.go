@@ -0,0 +1,80 @@
+package pg
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/VikaGo/REST_API/model"
+	"github.com/google/uuid"
+)
+
+// SessionRepo ...
+type SessionRepo struct {
+	db DB
+}
+
+// NewSessionRepo ...
+func NewSessionRepo(db DB) *SessionRepo {
+	return &SessionRepo{db: db}
+}
+
+// CreateSession stores a session in Postgres
+func (repo *SessionRepo) CreateSession(ctx context.Context, session *model.DBSession) error {
+	_, err := repo.db.NamedExecContext(ctx, `INSERT INTO sessions (id, user_id, token_hash, csrf_hash, device, ip, user_agent, created_at, last_seen_at, expires_at)
+		VALUES (:id, :user_id, :token_hash, :csrf_hash, :device, :ip, :user_agent, :created_at, :last_seen_at, :expires_at)`, session)
+	return err
+}
+
+// GetSessionByHash retrieves the session with the given token hash from
+// Postgres, including revoked and expired sessions
+func (repo *SessionRepo) GetSessionByHash(ctx context.Context, tokenHash string) (*model.DBSession, error) {
+	session := &model.DBSession{}
+	err := repo.db.GetContext(ctx, session, "SELECT * FROM sessions WHERE token_hash = $1", tokenHash)
+	if err != nil {
+		if err == sql.ErrNoRows { //not found
+			return nil, nil
+		}
+		return nil, err
+	}
+	return session, nil
+}
+
+// ListUserSessions retrieves the sessions of a user that were not revoked
+// and did not expire by now from Postgres, most recently used first
+func (repo *SessionRepo) ListUserSessions(ctx context.Context, userID uuid.UUID, now time.Time) ([]*model.DBSession, error) {
+	sessions := []*model.DBSession{}
+	err := repo.db.SelectContext(ctx, &sessions, "SELECT * FROM sessions WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > $2 ORDER BY last_seen_at DESC", userID, now)
+	if err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
+
+// RevokeSession revokes a session of a user in Postgres. It returns false
+// if the user has no such session or it was revoked already.
+func (repo *SessionRepo) RevokeSession(ctx context.Context, userID, id uuid.UUID) (bool, error) {
+	result, err := repo.db.ExecContext(ctx, "UPDATE sessions SET revoked_at = current_timestamp WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL", id, userID)
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rows > 0, nil
+}
+
+// RevokeUserSessions revokes every session of a user in Postgres, except
+// keepID if it is not nil
+func (repo *SessionRepo) RevokeUserSessions(ctx context.Context, userID uuid.UUID, keepID *uuid.UUID) error {
+	_, err := repo.db.ExecContext(ctx, "UPDATE sessions SET revoked_at = current_timestamp WHERE user_id = $1 AND ($2::uuid IS NULL OR id <> $2) AND revoked_at IS NULL", userID, keepID)
+	return err
+}
+
+// TouchSession sets the last use of a session in Postgres, unless it was
+// already used after notBefore
+func (repo *SessionRepo) TouchSession(ctx context.Context, id uuid.UUID, at, notBefore time.Time) error {
+	_, err := repo.db.ExecContext(ctx, "UPDATE sessions SET last_seen_at = $2 WHERE id = $1 AND last_seen_at < $3", id, at, notBefore)
+	return err
+}
@@ -0,0 +1,103 @@
+package pg
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/VikaGo/REST_API/model"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+// TwoFactorRepo ...
+type TwoFactorRepo struct {
+	db DB
+}
+
+// NewTwoFactorRepo ...
+func NewTwoFactorRepo(db DB) *TwoFactorRepo {
+	return &TwoFactorRepo{db: db}
+}
+
+// GetTOTP retrieves the TOTP secret of a user from Postgres
+func (repo *TwoFactorRepo) GetTOTP(ctx context.Context, userID uuid.UUID) (*model.DBUserTOTP, error) {
+	totp := &model.DBUserTOTP{}
+	err := repo.db.GetContext(ctx, totp, "SELECT * FROM user_totp WHERE user_id = $1", userID)
+	if err != nil {
+		if err == sql.ErrNoRows { //not found
+			return nil, nil
+		}
+		return nil, err
+	}
+	return totp, nil
+}
+
+// SaveTOTP stores an unconfirmed TOTP secret in Postgres, replacing a
+// previous unconfirmed one
+func (repo *TwoFactorRepo) SaveTOTP(ctx context.Context, totp *model.DBUserTOTP) error {
+	_, err := repo.db.NamedExecContext(ctx, `INSERT INTO user_totp (user_id, secret) VALUES (:user_id, :secret)
+		ON CONFLICT (user_id) DO UPDATE SET secret = EXCLUDED.secret, last_step = 0, created_at = current_timestamp, confirmed_at = NULL
+		WHERE user_totp.confirmed_at IS NULL`, totp)
+	return err
+}
+
+// ConfirmTOTP marks the TOTP secret of a user confirmed by the code of step.
+// It reports false if there was no unconfirmed secret.
+func (repo *TwoFactorRepo) ConfirmTOTP(ctx context.Context, userID uuid.UUID, step int64) (bool, error) {
+	res, err := repo.db.ExecContext(ctx, "UPDATE user_totp SET confirmed_at = current_timestamp, last_step = $2 WHERE user_id = $1 AND confirmed_at IS NULL", userID, step)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+// UseTOTPStep records a code of step as used. It reports false if a code of
+// that step or a later one was used already, which means the code is replayed.
+func (repo *TwoFactorRepo) UseTOTPStep(ctx context.Context, userID uuid.UUID, step int64) (bool, error) {
+	res, err := repo.db.ExecContext(ctx, "UPDATE user_totp SET last_step = $2 WHERE user_id = $1 AND confirmed_at IS NOT NULL AND last_step < $2", userID, step)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+// ReplaceRecoveryCodes stores new recovery codes of a user in Postgres and
+// drops the previous ones
+func (repo *TwoFactorRepo) ReplaceRecoveryCodes(ctx context.Context, userID uuid.UUID, codeHashes []string) error {
+	err := withTx(ctx, repo.db, func(tx DB) error {
+		_, err := tx.ExecContext(ctx, "DELETE FROM recovery_codes WHERE user_id = $1", userID)
+		if err != nil {
+			return err
+		}
+		for _, hash := range codeHashes {
+			_, err = tx.ExecContext(ctx, "INSERT INTO recovery_codes (id, user_id, code_hash) VALUES ($1, $2, $3)", uuid.New(), userID, hash)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return errors.Wrap(err, "could not replace recovery codes")
+}
+
+// UseRecoveryCode marks a recovery code used. It reports false if the code
+// does not exist or was used already.
+func (repo *TwoFactorRepo) UseRecoveryCode(ctx context.Context, userID uuid.UUID, codeHash string) (bool, error) {
+	res, err := repo.db.ExecContext(ctx, "UPDATE recovery_codes SET used_at = current_timestamp WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL", userID, codeHash)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
@@ -3,90 +3,359 @@ package pg
 import (
 	"context"
 	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
 	"github.com/VikaGo/REST_API/model"
+	"github.com/VikaGo/REST_API/pkg/types"
 	"github.com/google/uuid"
-	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
 )
 
 // UserRepo ...
 type UserRepo struct {
-	db *sqlx.DB
+	db       DB
+	timeouts Timeouts
 }
 
 // NewUserRepo ...
-func NewUserRepo(db *sqlx.DB) *UserRepo {
-	return &UserRepo{db: db}
+func NewUserRepo(db DB, timeouts Timeouts) *UserRepo {
+	return &UserRepo{db: db, timeouts: timeouts}
 }
 
 // GetUser retrieves user from Postgres
 func (repo *UserRepo) GetUser(ctx context.Context, id uuid.UUID) (*model.DBUser, error) {
+	ctx, cancel := repo.timeouts.read(ctx)
+	defer cancel()
+
+	user := &model.DBUser{}
+	err := repo.db.GetContext(ctx, user, "SELECT * FROM users WHERE id = $1 AND deleted_at IS NULL", id)
+	if err != nil {
+		if err == sql.ErrNoRows { //not found
+			return nil, nil
+		}
+		return nil, queryError(ctx, err)
+	}
+	return user, nil
+}
+
+// GetUserIncludingDeleted retrieves user from Postgres even if it was soft-deleted
+func (repo *UserRepo) GetUserIncludingDeleted(ctx context.Context, id uuid.UUID) (*model.DBUser, error) {
+	ctx, cancel := repo.timeouts.read(ctx)
+	defer cancel()
+
 	user := &model.DBUser{}
-	err := repo.db.Get(user, "SELECT * FROM users WHERE id = $1", id)
+	err := repo.db.GetContext(ctx, user, "SELECT * FROM users WHERE id = $1", id)
 	if err != nil {
 		if err == sql.ErrNoRows { //not found
 			return nil, nil
 		}
-		return nil, err
+		return nil, queryError(ctx, err)
 	}
 	return user, nil
 }
 
 // CreateUser creates user in Postgres
 func (repo *UserRepo) CreateUser(ctx context.Context, user *model.DBUser) (*model.DBUser, error) {
-	_, err := repo.db.NamedExec("INSERT INTO users (id, firstname, lastname, nickname, password) VALUES (:id, :firstname, :lastname, :nickname, :password)", user)
+	ctx, cancel := repo.timeouts.write(ctx)
+	defer cancel()
+
+	_, err := repo.db.NamedExecContext(ctx, "INSERT INTO users (id, role, firstname, lastname, nickname, email, password) VALUES (:id, :role, :firstname, :lastname, :nickname, :email, :password)", user)
 	if err != nil {
-		return nil, err
+		return nil, translateError(queryError(ctx, err))
 	}
 	return user, nil
 }
 
-// UpdateUser updates user in Postgres
+// UpdateUser updates user in Postgres. If user.Version is set, the update
+// only applies to that version of the user.
 func (repo *UserRepo) UpdateUser(ctx context.Context, user *model.DBUser) (*model.DBUser, error) {
-	_, err := repo.db.NamedExec("UPDATE users SET id = :id, firstname = :firstname, lastname =:lastname, nickname =:nickname, password =:password WHERE id = :id", user)
+	ctx, cancel := repo.timeouts.write(ctx)
+	defer cancel()
+
+	stmt, err := repo.db.PrepareNamedContext(ctx, "UPDATE users SET role = :role, firstname = :firstname, lastname = :lastname, nickname = :nickname, email = :email, password = :password, updated_at = current_timestamp, version = version + 1 WHERE id = :id AND deleted_at IS NULL AND (:version = 0 OR version = :version) RETURNING *")
 	if err != nil {
-		if err == sql.ErrNoRows { //not found
-			return nil, nil
+		return nil, queryError(ctx, err)
+	}
+	defer stmt.Close()
+
+	updated := &model.DBUser{}
+	err = stmt.GetContext(ctx, updated, user)
+	if err != nil {
+		if err == sql.ErrNoRows { //not found or another version
+			return nil, repo.versionConflict(ctx, user.ID, user.Version)
 		}
-		return nil, err
+		return nil, translateError(queryError(ctx, err))
 	}
 
-	return user, nil
+	return updated, nil
 }
 
-// DeleteUser deletes user in Postgres
-func (repo *UserRepo) DeleteUser(ctx context.Context, id uuid.UUID) error {
-	_, err := repo.db.Exec("DELETE FROM users WHERE id = $1", id)
+// versionConflict explains why a conditional write matched no row: it
+// returns types.ErrConflict if the user exists in another version and nil
+// if it does not exist.
+func (repo *UserRepo) versionConflict(ctx context.Context, id uuid.UUID, version int) error {
+	if version == 0 {
+		return nil
+	}
+	var exists bool
+	err := repo.db.GetContext(ctx, &exists, "SELECT EXISTS (SELECT 1 FROM users WHERE id = $1 AND deleted_at IS NULL)", id)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil
+		return queryError(ctx, err)
+	}
+	if exists {
+		return types.ErrConflict
+	}
+	return nil
+}
+
+// patchableColumns are the users columns PatchUser may set
+var patchableColumns = map[string]bool{
+	"role":      true,
+	"firstname": true,
+	"lastname":  true,
+	"nickname":  true,
+	"email":     true,
+}
+
+// PatchUser updates only the given columns of user in Postgres. If version
+// is set, the update only applies to that version of the user.
+func (repo *UserRepo) PatchUser(ctx context.Context, id uuid.UUID, version int, columns map[string]interface{}) (*model.DBUser, error) {
+	names := make([]string, 0, len(columns))
+	for name := range columns {
+		if !patchableColumns[name] {
+			return nil, errors.Errorf("column '%s' cannot be patched", name)
 		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	sets := make([]string, 0, len(names)+1)
+	args := make([]interface{}, 0, len(names)+1)
+	for _, name := range names {
+		args = append(args, columns[name])
+		sets = append(sets, fmt.Sprintf("%s = $%d", name, len(args)))
+	}
+	sets = append(sets, "updated_at = current_timestamp", "version = version + 1")
+	args = append(args, id, version)
+	query := fmt.Sprintf("UPDATE users SET %s WHERE id = $%d AND deleted_at IS NULL AND ($%d = 0 OR version = $%[3]d) RETURNING *", strings.Join(sets, ", "), len(args)-1, len(args))
+
+	ctx, cancel := repo.timeouts.write(ctx)
+	defer cancel()
+
+	user := &model.DBUser{}
+	err := repo.db.GetContext(ctx, user, query, args...)
+	if err != nil {
+		if err == sql.ErrNoRows { //not found or another version
+			return nil, repo.versionConflict(ctx, id, version)
+		}
+		return nil, translateError(queryError(ctx, err))
+	}
+	return user, nil
+}
+
+// DeleteUser soft-deletes user in Postgres. If version is set, only that
+// version of the user is deleted.
+func (repo *UserRepo) DeleteUser(ctx context.Context, id uuid.UUID, version int) error {
+	ctx, cancel := repo.timeouts.write(ctx)
+	defer cancel()
+
+	res, err := repo.db.ExecContext(ctx, "UPDATE users SET deleted_at = current_timestamp, version = version + 1 WHERE id = $1 AND deleted_at IS NULL AND ($2 = 0 OR version = $2)", id, version)
+	if err != nil {
+		return queryError(ctx, err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
 		return err
 	}
+	if n == 0 {
+		return repo.versionConflict(ctx, id, version)
+	}
 	return nil
 }
 
+// RestoreUser undoes the soft deletion of user in Postgres. It reports false
+// if the user was not deleted.
+func (repo *UserRepo) RestoreUser(ctx context.Context, id uuid.UUID) (bool, error) {
+	ctx, cancel := repo.timeouts.write(ctx)
+	defer cancel()
+
+	res, err := repo.db.ExecContext(ctx, "UPDATE users SET deleted_at = NULL, updated_at = current_timestamp, version = version + 1 WHERE id = $1 AND deleted_at IS NOT NULL", id)
+	if err != nil {
+		return false, queryError(ctx, err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+// PurgeDeletedUsers permanently removes users soft-deleted before the given time
+func (repo *UserRepo) PurgeDeletedUsers(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	ctx, cancel := repo.timeouts.write(ctx)
+	defer cancel()
+
+	res, err := repo.db.ExecContext(ctx, "DELETE FROM users WHERE deleted_at IS NOT NULL AND deleted_at < $1", deletedBefore)
+	if err != nil {
+		return 0, queryError(ctx, err)
+	}
+	return res.RowsAffected()
+}
+
 func (repo *UserRepo) GetPassword(ctx context.Context, id uuid.UUID) (string, error) {
+	ctx, cancel := repo.timeouts.read(ctx)
+	defer cancel()
+
 	var password string
-	err := repo.db.Get(&password, "SELECT password FROM users WHERE id = $1", id)
+	err := repo.db.GetContext(ctx, &password, "SELECT password FROM users WHERE id = $1 AND deleted_at IS NULL", id)
 	if err != nil {
 		if err == sql.ErrNoRows { // If the user is not found, return an empty string and no error.
 			return "", nil
 		}
-		return "", err // Otherwise, return an error if one occurred during the database query.
+		return "", queryError(ctx, err) // Otherwise, return an error if one occurred during the database query.
 	}
 
 	// If the query was successful, return the retrieved password as a string and no error.
 	return password, nil
 }
 
+// RehashPassword replaces the password hash of a user in Postgres if it is
+// still oldHash. The user is not otherwise changed, so the version stays.
+func (repo *UserRepo) RehashPassword(ctx context.Context, id uuid.UUID, oldHash, newHash string) error {
+	ctx, cancel := repo.timeouts.write(ctx)
+	defer cancel()
+
+	_, err := repo.db.ExecContext(ctx, "UPDATE users SET password = $3 WHERE id = $1 AND password = $2", id, oldHash, newHash)
+	if err != nil {
+		return queryError(ctx, err)
+	}
+	return nil
+}
+
 func (repo *UserRepo) GetUserByNickname(ctx context.Context, nickname string) (*model.DBUser, error) {
+	ctx, cancel := repo.timeouts.read(ctx)
+	defer cancel()
+
 	user := &model.DBUser{}
-	err := repo.db.Get(user, "SELECT * FROM users WHERE nickname = $1", nickname)
+	err := repo.db.GetContext(ctx, user, "SELECT * FROM users WHERE lower(nickname) = lower($1) AND deleted_at IS NULL", nickname)
 	if err != nil {
 		if err == sql.ErrNoRows { //not found
 			return nil, nil
 		}
-		return nil, err
+		return nil, queryError(ctx, err)
 	}
 	return user, nil
 }
+
+// GetUserByEmail retrieves a user by email from Postgres
+func (repo *UserRepo) GetUserByEmail(ctx context.Context, email string) (*model.DBUser, error) {
+	ctx, cancel := repo.timeouts.read(ctx)
+	defer cancel()
+
+	user := &model.DBUser{}
+	err := repo.db.GetContext(ctx, user, "SELECT * FROM users WHERE lower(email) = lower($1) AND email <> '' AND deleted_at IS NULL", email)
+	if err != nil {
+		if err == sql.ErrNoRows { //not found
+			return nil, nil
+		}
+		return nil, queryError(ctx, err)
+	}
+	return user, nil
+}
+
+// ListUsers retrieves a page of users from Postgres using keyset pagination
+func (repo *UserRepo) ListUsers(ctx context.Context, query *model.UserListQuery) ([]*model.DBUser, error) {
+	conds, args := userFilterConditions(&query.UserFilter)
+
+	dir, cmp := "ASC", ">"
+	if query.Desc {
+		dir, cmp = "DESC", "<"
+	}
+
+	var orderBy string
+	switch query.Sort {
+	case model.UserSortName:
+		orderBy = fmt.Sprintf("lastname %[1]s, firstname %[1]s, id %[1]s", dir)
+		if query.After != nil {
+			args = append(args, query.After.Lastname, query.After.Firstname, query.After.ID)
+			conds = append(conds, fmt.Sprintf("(lastname, firstname, id) %s ($%d, $%d, $%d)", cmp, len(args)-2, len(args)-1, len(args)))
+		}
+	default:
+		orderBy = fmt.Sprintf("created_at %[1]s, id %[1]s", dir)
+		if query.After != nil {
+			args = append(args, query.After.CreatedAt, query.After.ID)
+			conds = append(conds, fmt.Sprintf("(created_at, id) %s ($%d, $%d)", cmp, len(args)-1, len(args)))
+		}
+	}
+
+	args = append(args, query.Limit)
+	sqlQuery := fmt.Sprintf("SELECT * FROM users%s ORDER BY %s LIMIT $%d", whereClause(conds), orderBy, len(args))
+
+	ctx, cancel := repo.timeouts.read(ctx)
+	defer cancel()
+
+	users := []*model.DBUser{}
+	err := repo.db.SelectContext(ctx, &users, sqlQuery, args...)
+	if err != nil {
+		return nil, queryError(ctx, err)
+	}
+	return users, nil
+}
+
+// CountUsers counts the users matching filter in Postgres
+func (repo *UserRepo) CountUsers(ctx context.Context, filter *model.UserFilter) (int, error) {
+	conds, args := userFilterConditions(filter)
+
+	ctx, cancel := repo.timeouts.read(ctx)
+	defer cancel()
+
+	var count int
+	err := repo.db.GetContext(ctx, &count, "SELECT count(*) FROM users"+whereClause(conds), args...)
+	if err != nil {
+		return 0, queryError(ctx, err)
+	}
+	return count, nil
+}
+
+// userFilterConditions turns filter into SQL conditions with positional arguments
+func userFilterConditions(filter *model.UserFilter) ([]string, []interface{}) {
+	var (
+		conds []string
+		args  []interface{}
+	)
+	if !filter.IncludeDeleted {
+		conds = append(conds, "deleted_at IS NULL")
+	}
+	if filter.Role != "" {
+		args = append(args, filter.Role)
+		conds = append(conds, fmt.Sprintf("role = $%d", len(args)))
+	}
+	if filter.NicknamePrefix != "" {
+		args = append(args, escapeLike(filter.NicknamePrefix)+"%")
+		conds = append(conds, fmt.Sprintf("nickname ILIKE $%d", len(args)))
+	}
+	if !filter.CreatedFrom.IsZero() {
+		args = append(args, filter.CreatedFrom)
+		conds = append(conds, fmt.Sprintf("created_at >= $%d", len(args)))
+	}
+	if !filter.CreatedTo.IsZero() {
+		args = append(args, filter.CreatedTo)
+		conds = append(conds, fmt.Sprintf("created_at < $%d", len(args)))
+	}
+	return conds, args
+}
+
+func whereClause(conds []string) string {
+	if len(conds) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(conds, " AND ")
+}
+
+// escapeLike escapes the LIKE wildcards in s
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
@@ -0,0 +1,126 @@
+package store
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/VikaGo/REST_API/config"
+	"github.com/VikaGo/REST_API/logger"
+	"github.com/VikaGo/REST_API/model"
+	"github.com/jmoiron/sqlx"
+)
+
+// defaultHealthInterval is how often the pool is checked unless configured
+const defaultHealthInterval = 3 * time.Second
+
+// Pool watches the database connection pool of a store. The repositories
+// keep running on the same pool, whose lost connections database/sql
+// replaces with new ones, so a recovered database reaches them without
+// swapping anything.
+type Pool struct {
+	db         *sqlx.DB
+	interval   time.Duration
+	maxBackoff time.Duration
+
+	mu       sync.RWMutex
+	healthy  bool
+	failures int
+	checked  time.Time
+}
+
+// NewPool sizes the connection pool of db from cfg. The pool counts as
+// healthy until a check fails.
+func NewPool(db *sqlx.DB, cfg *config.Config) *Pool {
+	db.SetMaxOpenConns(cfg.DbMaxOpenConns)
+	db.SetMaxIdleConns(cfg.DbMaxIdleConns)
+	db.SetConnMaxLifetime(cfg.DbConnMaxLifetime)
+	db.SetConnMaxIdleTime(cfg.DbConnMaxIdleTime)
+
+	pool := &Pool{
+		db:         db,
+		interval:   cfg.DbHealthInterval,
+		maxBackoff: cfg.DbReconnectMaxBackoff,
+		healthy:    true,
+		checked:    time.Now(),
+	}
+	if pool.interval <= 0 {
+		pool.interval = defaultHealthInterval
+	}
+	if pool.maxBackoff < pool.interval {
+		pool.maxBackoff = pool.interval
+	}
+	return pool
+}
+
+// Run checks the pool until ctx is done. While the database is unreachable
+// the checks back off exponentially; the first one to reach it again opens
+// a new connection.
+func (pool *Pool) Run(ctx context.Context) {
+	timer := time.NewTimer(pool.interval)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+		timer.Reset(pool.check(ctx))
+	}
+}
+
+// check pings the database and returns the delay until the next check
+func (pool *Pool) check(ctx context.Context) time.Duration {
+	pingCtx, cancel := context.WithTimeout(ctx, pool.interval)
+	err := pool.db.PingContext(pingCtx)
+	cancel()
+	if ctx.Err() != nil {
+		// shutting down, the ping says nothing about the database
+		return pool.interval
+	}
+
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+	pool.checked = time.Now()
+	if err != nil {
+		pool.healthy = false
+		pool.failures++
+		logger.Get().Warn().Err(err).Int("failures", pool.failures).Msg("[store.Pool] Database is unreachable")
+		return pool.backoff(pool.failures)
+	}
+	if !pool.healthy {
+		logger.Get().Info().Msg("[store.Pool] Database reconnected")
+	}
+	pool.healthy = true
+	pool.failures = 0
+	return pool.interval
+}
+
+// backoff returns the delay after the given number of failed checks: the
+// check interval, doubled with every further failure up to maxBackoff
+func (pool *Pool) backoff(failures int) time.Duration {
+	delay := pool.interval
+	for i := 1; i < failures && delay < pool.maxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > pool.maxBackoff {
+		delay = pool.maxBackoff
+	}
+	return delay
+}
+
+// Health returns the state of the pool as of its last check
+func (pool *Pool) Health() *model.DatabaseHealth {
+	stats := pool.db.Stats()
+
+	pool.mu.RLock()
+	defer pool.mu.RUnlock()
+	return &model.DatabaseHealth{
+		Healthy:         pool.healthy,
+		CheckedAt:       pool.checked,
+		Failures:        pool.failures,
+		OpenConnections: stats.OpenConnections,
+		InUse:           stats.InUse,
+		Idle:            stats.Idle,
+	}
+}
@@ -0,0 +1,109 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/VikaGo/REST_API/config"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// flakyConnector opens connections to a database that is unreachable while
+// down is set
+type flakyConnector struct {
+	down *atomic.Bool
+}
+
+func (c flakyConnector) Connect(context.Context) (driver.Conn, error) {
+	if c.down.Load() {
+		return nil, errors.New("connection refused")
+	}
+	return flakyConn(c), nil
+}
+
+func (flakyConnector) Driver() driver.Driver { return nil }
+
+type flakyConn flakyConnector
+
+func (flakyConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (flakyConn) Close() error                        { return nil }
+func (flakyConn) Begin() (driver.Tx, error)           { return nil, errors.New("not supported") }
+
+// Ping loses the connection once the database is down
+func (c flakyConn) Ping(context.Context) error {
+	if c.down.Load() {
+		return driver.ErrBadConn
+	}
+	return nil
+}
+
+// TestPool takes the database down and up again while the pool is watched
+func TestPool(t *testing.T) {
+	down := &atomic.Bool{}
+	db := sqlx.NewDb(sql.OpenDB(flakyConnector{down: down}), "postgres")
+	pool := NewPool(db, &config.Config{
+		DbMaxOpenConns:        2,
+		DbMaxIdleConns:        1,
+		DbHealthInterval:      5 * time.Millisecond,
+		DbReconnectMaxBackoff: 20 * time.Millisecond,
+	})
+	store := &Store{DB: db, pool: pool}
+	assert.True(t, store.DatabaseHealth().Healthy)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		pool.Run(ctx)
+		close(stopped)
+	}()
+
+	down.Store(true)
+	require.Eventually(t, func() bool {
+		health := store.DatabaseHealth()
+		return !health.Healthy && health.Failures >= 2
+	}, time.Second, time.Millisecond)
+
+	down.Store(false)
+	require.Eventually(t, func() bool {
+		health := store.DatabaseHealth()
+		return health.Healthy && health.Failures == 0 && health.OpenConnections == 1
+	}, time.Second, time.Millisecond)
+
+	cancel()
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("the pool was still watched after its context was canceled")
+	}
+	assert.NoError(t, store.Close())
+}
+
+func TestPoolBackoff(t *testing.T) {
+	pool := NewPool(sqlx.NewDb(sql.OpenDB(flakyConnector{down: &atomic.Bool{}}), "postgres"), &config.Config{
+		DbHealthInterval:      time.Second,
+		DbReconnectMaxBackoff: 5 * time.Second,
+	})
+
+	tests := []struct {
+		failures int
+		delay    time.Duration
+	}{
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{3, 4 * time.Second},
+		{4, 5 * time.Second},
+		{20, 5 * time.Second},
+	}
+
+	for _, test := range tests {
+		t.Logf("running %v failures", test.failures)
+		assert.Equal(t, test.delay, pool.backoff(test.failures))
+	}
+}
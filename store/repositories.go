@@ -2,6 +2,7 @@ package store
 
 import (
 	"context"
+	"time"
 
 	"github.com/VikaGo/REST_API/model"
 	"github.com/google/uuid"
@@ -12,9 +13,127 @@ import (
 //go:generate mockery --dir . --name UserRepo --output ./mocks
 type UserRepo interface {
 	GetUser(context.Context, uuid.UUID) (*model.DBUser, error)
+	GetUserIncludingDeleted(context.Context, uuid.UUID) (*model.DBUser, error)
 	CreateUser(context.Context, *model.DBUser) (*model.DBUser, error)
 	UpdateUser(context.Context, *model.DBUser) (*model.DBUser, error)
-	DeleteUser(context.Context, uuid.UUID) error
+	PatchUser(ctx context.Context, id uuid.UUID, version int, columns map[string]interface{}) (*model.DBUser, error)
+	DeleteUser(ctx context.Context, id uuid.UUID, version int) error
+	RestoreUser(context.Context, uuid.UUID) (bool, error)
+	PurgeDeletedUsers(ctx context.Context, deletedBefore time.Time) (int64, error)
 	GetPassword(ctx context.Context, id uuid.UUID) (string, error)
+	RehashPassword(ctx context.Context, id uuid.UUID, oldHash, newHash string) error
 	GetUserByNickname(ctx context.Context, nickname string) (*model.DBUser, error)
+	GetUserByEmail(ctx context.Context, email string) (*model.DBUser, error)
+	ListUsers(context.Context, *model.UserListQuery) ([]*model.DBUser, error)
+	CountUsers(context.Context, *model.UserFilter) (int, error)
+}
+
+// RefreshTokenRepo is a store for refresh tokens
+//
+//go:generate mockery --dir . --name RefreshTokenRepo --output ./mocks
+type RefreshTokenRepo interface {
+	CreateRefreshToken(context.Context, *model.DBRefreshToken) error
+	GetRefreshTokenByHash(ctx context.Context, tokenHash string) (*model.DBRefreshToken, error)
+	RevokeRefreshToken(ctx context.Context, id uuid.UUID, replacedBy *uuid.UUID) (bool, error)
+	RevokeTokenFamily(ctx context.Context, familyID uuid.UUID) error
+	RevokeUserTokens(ctx context.Context, userID uuid.UUID) error
+	RevokeOtherUserTokens(ctx context.Context, userID, keepFamilyID uuid.UUID) error
+}
+
+// AuditRepo is an append-only store for audit events
+//
+//go:generate mockery --dir . --name AuditRepo --output ./mocks
+type AuditRepo interface {
+	CreateAuditEvent(context.Context, *model.DBAuditEvent) error
+	ListAuditEvents(context.Context, *model.AuditQuery) ([]*model.DBAuditEvent, error)
+}
+
+// LoginAttemptRepo is a store for failed login counters
+//
+//go:generate mockery --dir . --name LoginAttemptRepo --output ./mocks
+type LoginAttemptRepo interface {
+	GetLoginAttempts(ctx context.Context, key string) (*model.LoginAttempts, error)
+	RecordLoginFailure(ctx context.Context, key string, at, resetBefore time.Time) (*model.LoginAttempts, error)
+	ResetLoginAttempts(ctx context.Context, key string) error
+}
+
+// TwoFactorRepo is a store for TOTP secrets and recovery codes
+//
+//go:generate mockery --dir . --name TwoFactorRepo --output ./mocks
+type TwoFactorRepo interface {
+	GetTOTP(ctx context.Context, userID uuid.UUID) (*model.DBUserTOTP, error)
+	SaveTOTP(context.Context, *model.DBUserTOTP) error
+	ConfirmTOTP(ctx context.Context, userID uuid.UUID, step int64) (bool, error)
+	UseTOTPStep(ctx context.Context, userID uuid.UUID, step int64) (bool, error)
+	ReplaceRecoveryCodes(ctx context.Context, userID uuid.UUID, codeHashes []string) error
+	UseRecoveryCode(ctx context.Context, userID uuid.UUID, codeHash string) (bool, error)
+}
+
+// RoleSettingsRepo is a store for role policies
+//
+//go:generate mockery --dir . --name RoleSettingsRepo --output ./mocks
+type RoleSettingsRepo interface {
+	GetRoleSettings(ctx context.Context, role string) (*model.RoleSettings, error)
+	SaveRoleSettings(context.Context, *model.RoleSettings) error
+}
+
+// PasswordResetRepo is a store for single-use password reset tokens
+//
+//go:generate mockery --dir . --name PasswordResetRepo --output ./mocks
+type PasswordResetRepo interface {
+	CreatePasswordResetToken(context.Context, *model.DBPasswordResetToken) error
+	GetPasswordResetToken(ctx context.Context, tokenHash string) (*model.DBPasswordResetToken, error)
+	ConsumePasswordResetToken(ctx context.Context, tokenHash string) (*model.DBPasswordResetToken, error)
+	DeleteUserPasswordResetTokens(ctx context.Context, userID uuid.UUID) error
+}
+
+// PasswordHistoryRepo is a store for the previous password hashes of users
+//
+//go:generate mockery --dir . --name PasswordHistoryRepo --output ./mocks
+type PasswordHistoryRepo interface {
+	AddPasswordHistory(ctx context.Context, entry *model.DBPasswordHistory, keep int) error
+	ListPasswordHistory(ctx context.Context, userID uuid.UUID, limit int) ([]string, error)
+}
+
+// APIKeyRepo is a store for the API keys of users
+//
+//go:generate mockery --dir . --name APIKeyRepo --output ./mocks
+type APIKeyRepo interface {
+	CreateAPIKey(context.Context, *model.DBAPIKey) error
+	GetAPIKeyByHash(ctx context.Context, keyHash string) (*model.DBAPIKey, error)
+	ListUserAPIKeys(ctx context.Context, userID uuid.UUID) ([]*model.DBAPIKey, error)
+	RevokeAPIKey(ctx context.Context, userID, id uuid.UUID) (bool, error)
+	TouchAPIKey(ctx context.Context, id uuid.UUID, at, notBefore time.Time) error
+}
+
+// OAuthRepo is a store for OAuth clients and authorization codes
+//
+//go:generate mockery --dir . --name OAuthRepo --output ./mocks
+type OAuthRepo interface {
+	CreateOAuthClient(context.Context, *model.DBOAuthClient) error
+	GetOAuthClient(ctx context.Context, id string) (*model.DBOAuthClient, error)
+	ListOAuthClients(context.Context) ([]*model.DBOAuthClient, error)
+	DeleteOAuthClient(ctx context.Context, id string) (bool, error)
+	CreateAuthorizationCode(context.Context, *model.DBAuthorizationCode) error
+	ConsumeAuthorizationCode(ctx context.Context, codeHash string) (*model.DBAuthorizationCode, error)
+}
+
+// IdentityRepo is a store for the external identities linked to users
+//
+//go:generate mockery --dir . --name IdentityRepo --output ./mocks
+type IdentityRepo interface {
+	GetIdentity(ctx context.Context, issuer, subject string) (*model.DBUserIdentity, error)
+	CreateIdentity(context.Context, *model.DBUserIdentity) error
+}
+
+// SessionRepo is a store for the cookie sessions of browsers
+//
+//go:generate mockery --dir . --name SessionRepo --output ./mocks
+type SessionRepo interface {
+	CreateSession(context.Context, *model.DBSession) error
+	GetSessionByHash(ctx context.Context, tokenHash string) (*model.DBSession, error)
+	ListUserSessions(ctx context.Context, userID uuid.UUID, now time.Time) ([]*model.DBSession, error)
+	RevokeSession(ctx context.Context, userID, id uuid.UUID) (bool, error)
+	RevokeUserSessions(ctx context.Context, userID uuid.UUID, keepID *uuid.UUID) error
+	TouchSession(ctx context.Context, id uuid.UUID, at, notBefore time.Time) error
 }
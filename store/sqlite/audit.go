@@ -0,0 +1,73 @@
+package sqlite
+
+import (
+	"context"
+
+	"github.com/VikaGo/REST_API/model"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// AuditRepo ...
+type AuditRepo struct {
+	db DB
+}
+
+// NewAuditRepo ...
+func NewAuditRepo(db DB) *AuditRepo {
+	return &AuditRepo{db: db}
+}
+
+// CreateAuditEvent appends an event to the audit log in SQLite
+func (repo *AuditRepo) CreateAuditEvent(ctx context.Context, event *model.DBAuditEvent) error {
+	if event.Fields == nil {
+		// a NULL would not get the column default
+		withFields := *event
+		withFields.Fields = pq.StringArray{}
+		event = &withFields
+	}
+	_, err := namedExec(ctx, repo.db, "INSERT INTO audit_events (id, action, actor_id, target_id, fields, ip, user_agent, request_id) VALUES (:id, :action, :actor_id, :target_id, :fields, :ip, :user_agent, :request_id)", event)
+	return err
+}
+
+// ListAuditEvents retrieves one page of audit events from SQLite, newest first
+func (repo *AuditRepo) ListAuditEvents(ctx context.Context, query *model.AuditQuery) ([]*model.DBAuditEvent, error) {
+	var (
+		conds []string
+		args  []interface{}
+	)
+	if query.ActorID != uuid.Nil {
+		args = append(args, query.ActorID)
+		conds = append(conds, "actor_id = ?")
+	}
+	if query.TargetID != uuid.Nil {
+		args = append(args, query.TargetID)
+		conds = append(conds, "target_id = ?")
+	}
+	if query.Action != "" {
+		args = append(args, query.Action)
+		conds = append(conds, "action = ?")
+	}
+	if !query.From.IsZero() {
+		args = append(args, query.From)
+		conds = append(conds, "created_at >= ?")
+	}
+	if !query.To.IsZero() {
+		args = append(args, query.To)
+		conds = append(conds, "created_at < ?")
+	}
+	if query.Before != nil {
+		args = append(args, query.Before.CreatedAt, query.Before.ID)
+		conds = append(conds, "(created_at, id) < (?, ?)")
+	}
+
+	args = append(args, query.Limit)
+	sqlQuery := "SELECT * FROM audit_events" + whereClause(conds) + " ORDER BY created_at DESC, id DESC LIMIT ?"
+
+	events := []*model.DBAuditEvent{}
+	err := selectAll(ctx, repo.db, &events, sqlQuery, args...)
+	if err != nil {
+		return nil, err
+	}
+	return events, nil
+}
@@ -0,0 +1,118 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"net/url"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/pkg/errors"
+	"github.com/pressly/goose/v3"
+)
+
+//go:embed migrations/*.sql
+var embedMigrations embed.FS
+
+// DB runs the queries of the repositories: a *sqlx.DB, or the *sqlx.Tx of a
+// unit of work
+type DB interface {
+	sqlx.ExtContext
+}
+
+var (
+	_ DB = (*sqlx.DB)(nil)
+	_ DB = (*sqlx.Tx)(nil)
+)
+
+// Open opens the SQLite database in the file path, creating it if it does
+// not exist. Transactions take the write lock when they begin, so units of
+// work wait for each other instead of failing halfway.
+func Open(ctx context.Context, path string) (*sqlx.DB, error) {
+	params := url.Values{}
+	params.Set("_foreign_keys", "on")
+	params.Set("_journal_mode", "WAL")
+	params.Set("_busy_timeout", "5000")
+	params.Set("_txlock", "immediate")
+
+	db, err := sqlx.ConnectContext(ctx, "sqlite3", "file:"+path+"?"+params.Encode())
+	if err != nil {
+		return nil, errors.Wrap(err, "could not open SQLite database")
+	}
+	return db, nil
+}
+
+// Migrate brings the schema of db up to date
+func Migrate(db *sqlx.DB) error {
+	goose.SetBaseFS(embedMigrations)
+	if err := goose.SetDialect("sqlite3"); err != nil {
+		return err
+	}
+	return errors.Wrap(goose.Up(db.DB, "migrations"), "could not migrate SQLite database")
+}
+
+// withTx runs fn in a transaction of db, or right in db if it already is a
+// transaction
+func withTx(ctx context.Context, db DB, fn func(tx DB) error) error {
+	sqlxDB, ok := db.(*sqlx.DB)
+	if !ok {
+		return fn(db)
+	}
+
+	tx, err := sqlxDB.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// SQLite stores times as text. They are written in UTC, so the text sorts
+// and compares like the times.
+
+func exec(ctx context.Context, db DB, query string, args ...interface{}) (sql.Result, error) {
+	return db.ExecContext(ctx, query, utc(args)...)
+}
+
+func get(ctx context.Context, db DB, dest interface{}, query string, args ...interface{}) error {
+	return sqlx.GetContext(ctx, db, dest, query, utc(args)...)
+}
+
+func selectAll(ctx context.Context, db DB, dest interface{}, query string, args ...interface{}) error {
+	return sqlx.SelectContext(ctx, db, dest, query, utc(args)...)
+}
+
+// namedExec runs query with the :name parameters bound to the fields of arg
+func namedExec(ctx context.Context, db DB, query string, arg interface{}) (sql.Result, error) {
+	query, args, err := db.BindNamed(query, arg)
+	if err != nil {
+		return nil, err
+	}
+	return exec(ctx, db, query, args...)
+}
+
+// utc converts the times among args to UTC
+func utc(args []interface{}) []interface{} {
+	for i, arg := range args {
+		switch t := arg.(type) {
+		case time.Time:
+			args[i] = t.UTC()
+		case *time.Time:
+			if t != nil {
+				args[i] = t.UTC()
+			}
+		}
+	}
+	return args
+}
+
+// now is the current time as SQLite stores it
+func now() time.Time {
+	return time.Now().UTC()
+}
@@ -0,0 +1,41 @@
+package sqlite
+
+import (
+	"strings"
+
+	"github.com/VikaGo/REST_API/pkg/types"
+	"github.com/mattn/go-sqlite3"
+	"github.com/pkg/errors"
+)
+
+// indexFields names the field every unique index guards
+var indexFields = map[string]string{
+	"idx_users_nickname": "nickname",
+	"idx_users_email":    "email",
+}
+
+// translateError turns SQLite constraint violations into domain errors
+func translateError(err error) error {
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique {
+		// SQLite names the violated index in the message only
+		field := ""
+		for index, name := range indexFields {
+			if strings.Contains(sqliteErr.Error(), index) {
+				field = name
+			}
+		}
+		return &types.FieldError{Err: types.ErrDuplicateEntry, Field: field}
+	}
+	return err
+}
+
+// IsRetryable reports whether err aborted a transaction that may succeed
+// if it is run again
+func IsRetryable(err error) bool {
+	var sqliteErr sqlite3.Error
+	if !errors.As(err, &sqliteErr) {
+		return false
+	}
+	return sqliteErr.Code == sqlite3.ErrBusy || sqliteErr.Code == sqlite3.ErrLocked
+}
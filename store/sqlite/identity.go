@@ -0,0 +1,38 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/VikaGo/REST_API/model"
+)
+
+// IdentityRepo ...
+type IdentityRepo struct {
+	db DB
+}
+
+// NewIdentityRepo ...
+func NewIdentityRepo(db DB) *IdentityRepo {
+	return &IdentityRepo{db: db}
+}
+
+// GetIdentity retrieves the identity with the given issuer and subject from SQLite
+func (repo *IdentityRepo) GetIdentity(ctx context.Context, issuer, subject string) (*model.DBUserIdentity, error) {
+	identity := &model.DBUserIdentity{}
+	err := get(ctx, repo.db, identity, "SELECT * FROM user_identities WHERE issuer = ?1 AND subject = ?2", issuer, subject)
+	if err != nil {
+		if err == sql.ErrNoRows { //not found
+			return nil, nil
+		}
+		return nil, err
+	}
+	return identity, nil
+}
+
+// CreateIdentity stores an identity in SQLite
+func (repo *IdentityRepo) CreateIdentity(ctx context.Context, identity *model.DBUserIdentity) error {
+	_, err := namedExec(ctx, repo.db, `INSERT INTO user_identities (id, user_id, provider, issuer, subject, created_at)
+		VALUES (:id, :user_id, :provider, :issuer, :subject, :created_at)`, identity)
+	return translateError(err)
+}
@@ -0,0 +1,53 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/VikaGo/REST_API/model"
+)
+
+// LoginAttemptRepo ...
+type LoginAttemptRepo struct {
+	db DB
+}
+
+// NewLoginAttemptRepo ...
+func NewLoginAttemptRepo(db DB) *LoginAttemptRepo {
+	return &LoginAttemptRepo{db: db}
+}
+
+// GetLoginAttempts retrieves the failed logins counted for key from SQLite
+func (repo *LoginAttemptRepo) GetLoginAttempts(ctx context.Context, key string) (*model.LoginAttempts, error) {
+	attempts := &model.LoginAttempts{}
+	err := get(ctx, repo.db, attempts, "SELECT * FROM login_attempts WHERE attempt_key = ?1", key)
+	if err != nil {
+		if err == sql.ErrNoRows { //not found
+			return nil, nil
+		}
+		return nil, err
+	}
+	return attempts, nil
+}
+
+// RecordLoginFailure atomically counts a failed login for key in SQLite.
+// Failures not after resetBefore are forgotten first.
+func (repo *LoginAttemptRepo) RecordLoginFailure(ctx context.Context, key string, at, resetBefore time.Time) (*model.LoginAttempts, error) {
+	attempts := &model.LoginAttempts{}
+	err := get(ctx, repo.db, attempts, `INSERT INTO login_attempts (attempt_key, failures, last_failure) VALUES (?1, 1, ?2)
+		ON CONFLICT (attempt_key) DO UPDATE SET
+			failures = CASE WHEN login_attempts.last_failure <= ?3 THEN 1 ELSE login_attempts.failures + 1 END,
+			last_failure = ?2
+		RETURNING *`, key, at, resetBefore)
+	if err != nil {
+		return nil, err
+	}
+	return attempts, nil
+}
+
+// ResetLoginAttempts forgets the failed logins counted for key in SQLite
+func (repo *LoginAttemptRepo) ResetLoginAttempts(ctx context.Context, key string) error {
+	_, err := exec(ctx, repo.db, "DELETE FROM login_attempts WHERE attempt_key = ?1", key)
+	return err
+}
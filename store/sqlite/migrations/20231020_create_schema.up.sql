@@ -0,0 +1,217 @@
+-- +goose Up
+-- The schema of the Postgres migrations up to 20231019. Times are stored as
+-- UTC text in the format Go writes them, so they sort and compare as text;
+-- arrays are stored in the Postgres text format.
+CREATE TABLE users (
+    id text NOT NULL,
+    role text NOT NULL DEFAULT 'viewer',
+    firstname text NOT NULL,
+    lastname text NOT NULL,
+    nickname text NOT NULL,
+    email text NOT NULL DEFAULT '',
+    password text NOT NULL,
+    created_at timestamp NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')),
+    updated_at timestamp NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')),
+    deleted_at timestamp,
+    version integer NOT NULL DEFAULT 1,
+    CONSTRAINT "pk_user_id" PRIMARY KEY (id),
+    CONSTRAINT "chk_user_role" CHECK (role IN ('admin', 'editor', 'viewer'))
+);
+-- Nicknames of soft-deleted users stay reserved until they are purged.
+CREATE UNIQUE INDEX "idx_users_nickname" ON users (lower(nickname));
+CREATE UNIQUE INDEX "idx_users_email" ON users (lower(email)) WHERE email <> '';
+CREATE INDEX "idx_users_deleted_at" ON users (deleted_at) WHERE deleted_at IS NOT NULL;
+
+CREATE TABLE refresh_tokens (
+    id text NOT NULL,
+    user_id text NOT NULL,
+    family_id text NOT NULL,
+    token_hash text NOT NULL,
+    expires_at timestamp NOT NULL,
+    created_at timestamp NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')),
+    revoked_at timestamp,
+    replaced_by text,
+    CONSTRAINT "pk_refresh_token_id" PRIMARY KEY (id),
+    CONSTRAINT "fk_refresh_token_user_id" FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+CREATE UNIQUE INDEX "idx_refresh_tokens_token_hash" ON refresh_tokens (token_hash);
+CREATE INDEX "idx_refresh_tokens_family_id" ON refresh_tokens (family_id);
+CREATE INDEX "idx_refresh_tokens_user_id" ON refresh_tokens (user_id);
+
+-- Audit events outlive the users they refer to, so there are no foreign keys.
+CREATE TABLE audit_events (
+    id text NOT NULL,
+    action text NOT NULL,
+    actor_id text,
+    target_id text,
+    fields text NOT NULL DEFAULT '{}',
+    ip text NOT NULL DEFAULT '',
+    user_agent text NOT NULL DEFAULT '',
+    request_id text NOT NULL DEFAULT '',
+    created_at timestamp NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')),
+    CONSTRAINT "pk_audit_event_id" PRIMARY KEY (id)
+);
+CREATE INDEX "idx_audit_events_created_at" ON audit_events (created_at, id);
+CREATE INDEX "idx_audit_events_actor_id" ON audit_events (actor_id, created_at);
+CREATE INDEX "idx_audit_events_target_id" ON audit_events (target_id, created_at);
+
+-- The audit log is append-only
+-- +goose StatementBegin
+CREATE TRIGGER "trg_audit_events_no_update" BEFORE UPDATE ON audit_events
+BEGIN
+    SELECT RAISE(ABORT, 'audit_events is append-only');
+END;
+-- +goose StatementEnd
+-- +goose StatementBegin
+CREATE TRIGGER "trg_audit_events_no_delete" BEFORE DELETE ON audit_events
+BEGIN
+    SELECT RAISE(ABORT, 'audit_events is append-only');
+END;
+-- +goose StatementEnd
+
+CREATE TABLE login_attempts (
+    attempt_key text NOT NULL,
+    failures integer NOT NULL,
+    last_failure timestamp NOT NULL,
+    CONSTRAINT "pk_login_attempt_key" PRIMARY KEY (attempt_key)
+);
+
+CREATE TABLE user_totp (
+    user_id text NOT NULL,
+    secret text NOT NULL,
+    last_step integer NOT NULL DEFAULT 0,
+    created_at timestamp NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')),
+    confirmed_at timestamp,
+    CONSTRAINT "pk_user_totp_user_id" PRIMARY KEY (user_id),
+    CONSTRAINT "fk_user_totp_user_id" FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE TABLE recovery_codes (
+    id text NOT NULL,
+    user_id text NOT NULL,
+    code_hash text NOT NULL,
+    used_at timestamp,
+    CONSTRAINT "pk_recovery_code_id" PRIMARY KEY (id),
+    CONSTRAINT "fk_recovery_code_user_id" FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+CREATE UNIQUE INDEX "idx_recovery_codes_user_id_code_hash" ON recovery_codes (user_id, code_hash);
+
+CREATE TABLE role_settings (
+    role text NOT NULL,
+    require_2fa boolean NOT NULL DEFAULT false,
+    CONSTRAINT "pk_role_settings_role" PRIMARY KEY (role)
+);
+
+CREATE TABLE password_reset_tokens (
+    id text NOT NULL,
+    user_id text NOT NULL,
+    token_hash text NOT NULL,
+    expires_at timestamp NOT NULL,
+    created_at timestamp NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')),
+    used_at timestamp,
+    CONSTRAINT "pk_password_reset_token_id" PRIMARY KEY (id),
+    CONSTRAINT "fk_password_reset_token_user_id" FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+CREATE UNIQUE INDEX "idx_password_reset_tokens_token_hash" ON password_reset_tokens (token_hash);
+CREATE INDEX "idx_password_reset_tokens_user_id" ON password_reset_tokens (user_id);
+
+CREATE TABLE password_history (
+    id text NOT NULL,
+    user_id text NOT NULL,
+    password_hash text NOT NULL,
+    created_at timestamp NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')),
+    CONSTRAINT "pk_password_history_id" PRIMARY KEY (id),
+    CONSTRAINT "fk_password_history_user_id" FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+CREATE INDEX "idx_password_history_user_id_created_at" ON password_history (user_id, created_at DESC);
+
+CREATE TABLE api_keys (
+    id text NOT NULL,
+    user_id text NOT NULL,
+    name text NOT NULL,
+    prefix text NOT NULL,
+    key_hash text NOT NULL,
+    scopes text,
+    expires_at timestamp,
+    last_used_at timestamp,
+    created_at timestamp NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')),
+    revoked_at timestamp,
+    CONSTRAINT "pk_api_key_id" PRIMARY KEY (id),
+    CONSTRAINT "fk_api_key_user_id" FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+CREATE UNIQUE INDEX "idx_api_keys_key_hash" ON api_keys (key_hash);
+CREATE INDEX "idx_api_keys_user_id" ON api_keys (user_id);
+
+CREATE TABLE oauth_clients (
+    id text NOT NULL,
+    name text NOT NULL,
+    secret_hash text NOT NULL DEFAULT '',
+    redirect_uris text NOT NULL,
+    created_at timestamp NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')),
+    CONSTRAINT "pk_oauth_client_id" PRIMARY KEY (id)
+);
+
+CREATE TABLE oauth_authorization_codes (
+    id text NOT NULL,
+    code_hash text NOT NULL,
+    client_id text NOT NULL,
+    user_id text NOT NULL,
+    redirect_uri text NOT NULL,
+    scope text NOT NULL DEFAULT '',
+    nonce text NOT NULL DEFAULT '',
+    code_challenge text NOT NULL,
+    expires_at timestamp NOT NULL,
+    created_at timestamp NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')),
+    used_at timestamp,
+    CONSTRAINT "pk_oauth_authorization_code_id" PRIMARY KEY (id),
+    CONSTRAINT "fk_oauth_authorization_code_client_id" FOREIGN KEY (client_id) REFERENCES oauth_clients (id) ON DELETE CASCADE,
+    CONSTRAINT "fk_oauth_authorization_code_user_id" FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+CREATE UNIQUE INDEX "idx_oauth_authorization_codes_code_hash" ON oauth_authorization_codes (code_hash);
+
+CREATE TABLE user_identities (
+    id text NOT NULL,
+    user_id text NOT NULL,
+    provider text NOT NULL,
+    issuer text NOT NULL,
+    subject text NOT NULL,
+    created_at timestamp NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')),
+    CONSTRAINT "pk_user_identity_id" PRIMARY KEY (id),
+    CONSTRAINT "fk_user_identity_user_id" FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+CREATE UNIQUE INDEX "idx_user_identities_issuer_subject" ON user_identities (issuer, subject);
+CREATE INDEX "idx_user_identities_user_id" ON user_identities (user_id);
+
+CREATE TABLE sessions (
+    id text NOT NULL,
+    user_id text NOT NULL,
+    token_hash text NOT NULL,
+    csrf_hash text NOT NULL,
+    device text NOT NULL DEFAULT '',
+    ip text NOT NULL DEFAULT '',
+    user_agent text NOT NULL DEFAULT '',
+    created_at timestamp NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')),
+    last_seen_at timestamp NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')),
+    expires_at timestamp NOT NULL,
+    revoked_at timestamp,
+    CONSTRAINT "pk_session_id" PRIMARY KEY (id),
+    CONSTRAINT "fk_session_user_id" FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+CREATE UNIQUE INDEX "idx_sessions_token_hash" ON sessions (token_hash);
+CREATE INDEX "idx_sessions_user_id" ON sessions (user_id);
+
+-- +goose Down
+DROP TABLE sessions;
+DROP TABLE user_identities;
+DROP TABLE oauth_authorization_codes;
+DROP TABLE oauth_clients;
+DROP TABLE api_keys;
+DROP TABLE password_history;
+DROP TABLE password_reset_tokens;
+DROP TABLE role_settings;
+DROP TABLE recovery_codes;
+DROP TABLE user_totp;
+DROP TABLE login_attempts;
+DROP TABLE audit_events;
+DROP TABLE refresh_tokens;
+DROP TABLE users;
@@ -0,0 +1,86 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/VikaGo/REST_API/model"
+)
+
+// OAuthRepo ...
+type OAuthRepo struct {
+	db DB
+}
+
+// NewOAuthRepo ...
+func NewOAuthRepo(db DB) *OAuthRepo {
+	return &OAuthRepo{db: db}
+}
+
+// CreateOAuthClient stores an OAuth client in SQLite
+func (repo *OAuthRepo) CreateOAuthClient(ctx context.Context, client *model.DBOAuthClient) error {
+	_, err := namedExec(ctx, repo.db, `INSERT INTO oauth_clients (id, name, secret_hash, redirect_uris, created_at)
+		VALUES (:id, :name, :secret_hash, :redirect_uris, :created_at)`, client)
+	return err
+}
+
+// GetOAuthClient retrieves an OAuth client from SQLite
+func (repo *OAuthRepo) GetOAuthClient(ctx context.Context, id string) (*model.DBOAuthClient, error) {
+	client := &model.DBOAuthClient{}
+	err := get(ctx, repo.db, client, "SELECT * FROM oauth_clients WHERE id = ?1", id)
+	if err != nil {
+		if err == sql.ErrNoRows { //not found
+			return nil, nil
+		}
+		return nil, err
+	}
+	return client, nil
+}
+
+// ListOAuthClients retrieves every OAuth client from SQLite
+func (repo *OAuthRepo) ListOAuthClients(ctx context.Context) ([]*model.DBOAuthClient, error) {
+	clients := []*model.DBOAuthClient{}
+	err := selectAll(ctx, repo.db, &clients, "SELECT * FROM oauth_clients ORDER BY created_at")
+	if err != nil {
+		return nil, err
+	}
+	return clients, nil
+}
+
+// DeleteOAuthClient deletes an OAuth client and its authorization codes
+// from SQLite. It returns false if there is no such client.
+func (repo *OAuthRepo) DeleteOAuthClient(ctx context.Context, id string) (bool, error) {
+	result, err := exec(ctx, repo.db, "DELETE FROM oauth_clients WHERE id = ?1", id)
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rows > 0, nil
+}
+
+// CreateAuthorizationCode stores an OAuth authorization code in SQLite
+func (repo *OAuthRepo) CreateAuthorizationCode(ctx context.Context, code *model.DBAuthorizationCode) error {
+	_, err := namedExec(ctx, repo.db, `INSERT INTO oauth_authorization_codes
+		(id, code_hash, client_id, user_id, redirect_uri, scope, nonce, code_challenge, expires_at)
+		VALUES (:id, :code_hash, :client_id, :user_id, :redirect_uri, :scope, :nonce, :code_challenge, :expires_at)`, code)
+	return err
+}
+
+// ConsumeAuthorizationCode marks the authorization code with the given hash
+// used and returns it. It returns nil if the code does not exist, expired
+// or was used already, so a code can only be exchanged once.
+func (repo *OAuthRepo) ConsumeAuthorizationCode(ctx context.Context, codeHash string) (*model.DBAuthorizationCode, error) {
+	code := &model.DBAuthorizationCode{}
+	err := get(ctx, repo.db, code, `UPDATE oauth_authorization_codes SET used_at = ?2
+		WHERE code_hash = ?1 AND used_at IS NULL AND expires_at > ?2 RETURNING *`, codeHash, now())
+	if err != nil {
+		if err == sql.ErrNoRows { //not found, used or expired
+			return nil, nil
+		}
+		return nil, err
+	}
+	return code, nil
+}
@@ -0,0 +1,42 @@
+package sqlite
+
+import (
+	"context"
+
+	"github.com/VikaGo/REST_API/model"
+	"github.com/google/uuid"
+)
+
+// PasswordHistoryRepo ...
+type PasswordHistoryRepo struct {
+	db DB
+}
+
+// NewPasswordHistoryRepo ...
+func NewPasswordHistoryRepo(db DB) *PasswordHistoryRepo {
+	return &PasswordHistoryRepo{db: db}
+}
+
+// AddPasswordHistory stores a previous password hash of a user in SQLite
+// and deletes all but the keep newest ones
+func (repo *PasswordHistoryRepo) AddPasswordHistory(ctx context.Context, entry *model.DBPasswordHistory, keep int) error {
+	return withTx(ctx, repo.db, func(tx DB) error {
+		_, err := exec(ctx, tx, "INSERT INTO password_history (id, user_id, password_hash, created_at) VALUES (?1, ?2, ?3, ?4)", entry.ID, entry.UserID, entry.PasswordHash, now())
+		if err != nil {
+			return err
+		}
+		_, err = exec(ctx, tx, `DELETE FROM password_history WHERE user_id = ?1 AND id NOT IN (
+		SELECT id FROM password_history WHERE user_id = ?1 ORDER BY created_at DESC, id LIMIT ?2)`, entry.UserID, keep)
+		return err
+	})
+}
+
+// ListPasswordHistory retrieves the limit newest previous password hashes of a user from SQLite
+func (repo *PasswordHistoryRepo) ListPasswordHistory(ctx context.Context, userID uuid.UUID, limit int) ([]string, error) {
+	var hashes []string
+	err := selectAll(ctx, repo.db, &hashes, "SELECT password_hash FROM password_history WHERE user_id = ?1 ORDER BY created_at DESC, id LIMIT ?2", userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	return hashes, nil
+}
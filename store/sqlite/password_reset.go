@@ -0,0 +1,61 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/VikaGo/REST_API/model"
+	"github.com/google/uuid"
+)
+
+// PasswordResetRepo ...
+type PasswordResetRepo struct {
+	db DB
+}
+
+// NewPasswordResetRepo ...
+func NewPasswordResetRepo(db DB) *PasswordResetRepo {
+	return &PasswordResetRepo{db: db}
+}
+
+// CreatePasswordResetToken stores a password reset token in SQLite
+func (repo *PasswordResetRepo) CreatePasswordResetToken(ctx context.Context, token *model.DBPasswordResetToken) error {
+	_, err := namedExec(ctx, repo.db, "INSERT INTO password_reset_tokens (id, user_id, token_hash, expires_at) VALUES (:id, :user_id, :token_hash, :expires_at)", token)
+	return err
+}
+
+// GetPasswordResetToken retrieves the unused and unexpired token with the
+// given hash from SQLite
+func (repo *PasswordResetRepo) GetPasswordResetToken(ctx context.Context, tokenHash string) (*model.DBPasswordResetToken, error) {
+	token := &model.DBPasswordResetToken{}
+	err := get(ctx, repo.db, token, "SELECT * FROM password_reset_tokens WHERE token_hash = ?1 AND used_at IS NULL AND expires_at > ?2", tokenHash, now())
+	if err != nil {
+		if err == sql.ErrNoRows { //not found, used or expired
+			return nil, nil
+		}
+		return nil, err
+	}
+	return token, nil
+}
+
+// ConsumePasswordResetToken marks the token with the given hash used and
+// returns it. It returns nil if the token does not exist, expired or was
+// used already, so a token can only be consumed once.
+func (repo *PasswordResetRepo) ConsumePasswordResetToken(ctx context.Context, tokenHash string) (*model.DBPasswordResetToken, error) {
+	token := &model.DBPasswordResetToken{}
+	err := get(ctx, repo.db, token, `UPDATE password_reset_tokens SET used_at = ?2
+		WHERE token_hash = ?1 AND used_at IS NULL AND expires_at > ?2 RETURNING *`, tokenHash, now())
+	if err != nil {
+		if err == sql.ErrNoRows { //not found, used or expired
+			return nil, nil
+		}
+		return nil, err
+	}
+	return token, nil
+}
+
+// DeleteUserPasswordResetTokens deletes every password reset token of a user from SQLite
+func (repo *PasswordResetRepo) DeleteUserPasswordResetTokens(ctx context.Context, userID uuid.UUID) error {
+	_, err := exec(ctx, repo.db, "DELETE FROM password_reset_tokens WHERE user_id = ?1", userID)
+	return err
+}
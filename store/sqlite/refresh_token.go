@@ -0,0 +1,70 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/VikaGo/REST_API/model"
+	"github.com/google/uuid"
+)
+
+// RefreshTokenRepo ...
+type RefreshTokenRepo struct {
+	db DB
+}
+
+// NewRefreshTokenRepo ...
+func NewRefreshTokenRepo(db DB) *RefreshTokenRepo {
+	return &RefreshTokenRepo{db: db}
+}
+
+// CreateRefreshToken stores a refresh token in SQLite
+func (repo *RefreshTokenRepo) CreateRefreshToken(ctx context.Context, token *model.DBRefreshToken) error {
+	_, err := namedExec(ctx, repo.db, "INSERT INTO refresh_tokens (id, user_id, family_id, token_hash, expires_at) VALUES (:id, :user_id, :family_id, :token_hash, :expires_at)", token)
+	return err
+}
+
+// GetRefreshTokenByHash retrieves a refresh token by its hash from SQLite
+func (repo *RefreshTokenRepo) GetRefreshTokenByHash(ctx context.Context, tokenHash string) (*model.DBRefreshToken, error) {
+	token := &model.DBRefreshToken{}
+	err := get(ctx, repo.db, token, "SELECT * FROM refresh_tokens WHERE token_hash = ?1", tokenHash)
+	if err != nil {
+		if err == sql.ErrNoRows { //not found
+			return nil, nil
+		}
+		return nil, err
+	}
+	return token, nil
+}
+
+// RevokeRefreshToken revokes a single refresh token. It reports false if the
+// token had already been revoked, which means it is being reused.
+func (repo *RefreshTokenRepo) RevokeRefreshToken(ctx context.Context, id uuid.UUID, replacedBy *uuid.UUID) (bool, error) {
+	res, err := exec(ctx, repo.db, "UPDATE refresh_tokens SET revoked_at = ?3, replaced_by = ?2 WHERE id = ?1 AND revoked_at IS NULL", id, replacedBy, now())
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+// RevokeTokenFamily revokes every token descending from the same login
+func (repo *RefreshTokenRepo) RevokeTokenFamily(ctx context.Context, familyID uuid.UUID) error {
+	_, err := exec(ctx, repo.db, "UPDATE refresh_tokens SET revoked_at = ?2 WHERE family_id = ?1 AND revoked_at IS NULL", familyID, now())
+	return err
+}
+
+// RevokeUserTokens revokes every refresh token of a user
+func (repo *RefreshTokenRepo) RevokeUserTokens(ctx context.Context, userID uuid.UUID) error {
+	_, err := exec(ctx, repo.db, "UPDATE refresh_tokens SET revoked_at = ?2 WHERE user_id = ?1 AND revoked_at IS NULL", userID, now())
+	return err
+}
+
+// RevokeOtherUserTokens revokes every refresh token of a user except those of the family keepFamilyID
+func (repo *RefreshTokenRepo) RevokeOtherUserTokens(ctx context.Context, userID, keepFamilyID uuid.UUID) error {
+	_, err := exec(ctx, repo.db, "UPDATE refresh_tokens SET revoked_at = ?3 WHERE user_id = ?1 AND family_id <> ?2 AND revoked_at IS NULL", userID, keepFamilyID, now())
+	return err
+}
@@ -0,0 +1,38 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/VikaGo/REST_API/model"
+)
+
+// RoleSettingsRepo ...
+type RoleSettingsRepo struct {
+	db DB
+}
+
+// NewRoleSettingsRepo ...
+func NewRoleSettingsRepo(db DB) *RoleSettingsRepo {
+	return &RoleSettingsRepo{db: db}
+}
+
+// GetRoleSettings retrieves the settings of a role from SQLite
+func (repo *RoleSettingsRepo) GetRoleSettings(ctx context.Context, role string) (*model.RoleSettings, error) {
+	settings := &model.RoleSettings{}
+	err := get(ctx, repo.db, settings, "SELECT * FROM role_settings WHERE role = ?1", role)
+	if err != nil {
+		if err == sql.ErrNoRows { //not found
+			return nil, nil
+		}
+		return nil, err
+	}
+	return settings, nil
+}
+
+// SaveRoleSettings creates or replaces the settings of a role in SQLite
+func (repo *RoleSettingsRepo) SaveRoleSettings(ctx context.Context, settings *model.RoleSettings) error {
+	_, err := namedExec(ctx, repo.db, `INSERT INTO role_settings (role, require_2fa) VALUES (:role, :require_2fa)
+		ON CONFLICT (role) DO UPDATE SET require_2fa = excluded.require_2fa`, settings)
+	return err
+}
@@ -0,0 +1,315 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/VikaGo/REST_API/model"
+	"github.com/VikaGo/REST_API/pkg/types"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+// UserRepo ...
+type UserRepo struct {
+	db DB
+}
+
+// NewUserRepo ...
+func NewUserRepo(db DB) *UserRepo {
+	return &UserRepo{db: db}
+}
+
+// GetUser retrieves user from SQLite
+func (repo *UserRepo) GetUser(ctx context.Context, id uuid.UUID) (*model.DBUser, error) {
+	user := &model.DBUser{}
+	err := get(ctx, repo.db, user, "SELECT * FROM users WHERE id = ?1 AND deleted_at IS NULL", id)
+	if err != nil {
+		if err == sql.ErrNoRows { //not found
+			return nil, nil
+		}
+		return nil, err
+	}
+	return user, nil
+}
+
+// GetUserIncludingDeleted retrieves user from SQLite even if it was soft-deleted
+func (repo *UserRepo) GetUserIncludingDeleted(ctx context.Context, id uuid.UUID) (*model.DBUser, error) {
+	user := &model.DBUser{}
+	err := get(ctx, repo.db, user, "SELECT * FROM users WHERE id = ?1", id)
+	if err != nil {
+		if err == sql.ErrNoRows { //not found
+			return nil, nil
+		}
+		return nil, err
+	}
+	return user, nil
+}
+
+// CreateUser creates user in SQLite
+func (repo *UserRepo) CreateUser(ctx context.Context, user *model.DBUser) (*model.DBUser, error) {
+	_, err := namedExec(ctx, repo.db, "INSERT INTO users (id, role, firstname, lastname, nickname, email, password) VALUES (:id, :role, :firstname, :lastname, :nickname, :email, :password)", user)
+	if err != nil {
+		return nil, translateError(err)
+	}
+	return user, nil
+}
+
+// UpdateUser updates user in SQLite. If user.Version is set, the update
+// only applies to that version of the user.
+func (repo *UserRepo) UpdateUser(ctx context.Context, user *model.DBUser) (*model.DBUser, error) {
+	updated := &model.DBUser{}
+	err := get(ctx, repo.db, updated, `UPDATE users SET role = ?3, firstname = ?4, lastname = ?5, nickname = ?6, email = ?7, password = ?8, updated_at = ?9, version = version + 1
+		WHERE id = ?1 AND deleted_at IS NULL AND (?2 = 0 OR version = ?2) RETURNING *`,
+		user.ID, user.Version, user.Role, user.Firstname, user.Lastname, user.Nickname, user.Email, user.Password, now())
+	if err != nil {
+		if err == sql.ErrNoRows { //not found or another version
+			return nil, repo.versionConflict(ctx, user.ID, user.Version)
+		}
+		return nil, translateError(err)
+	}
+
+	return updated, nil
+}
+
+// versionConflict explains why a conditional write matched no row: it
+// returns types.ErrConflict if the user exists in another version and nil
+// if it does not exist.
+func (repo *UserRepo) versionConflict(ctx context.Context, id uuid.UUID, version int) error {
+	if version == 0 {
+		return nil
+	}
+	var exists bool
+	err := get(ctx, repo.db, &exists, "SELECT EXISTS (SELECT 1 FROM users WHERE id = ?1 AND deleted_at IS NULL)", id)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return types.ErrConflict
+	}
+	return nil
+}
+
+// patchableColumns are the users columns PatchUser may set
+var patchableColumns = map[string]bool{
+	"role":      true,
+	"firstname": true,
+	"lastname":  true,
+	"nickname":  true,
+	"email":     true,
+}
+
+// PatchUser updates only the given columns of user in SQLite. If version
+// is set, the update only applies to that version of the user.
+func (repo *UserRepo) PatchUser(ctx context.Context, id uuid.UUID, version int, columns map[string]interface{}) (*model.DBUser, error) {
+	names := make([]string, 0, len(columns))
+	for name := range columns {
+		if !patchableColumns[name] {
+			return nil, errors.Errorf("column '%s' cannot be patched", name)
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	sets := make([]string, 0, len(names)+2)
+	args := make([]interface{}, 0, len(names)+2)
+	for _, name := range names {
+		args = append(args, columns[name])
+		sets = append(sets, fmt.Sprintf("%s = ?%d", name, len(args)))
+	}
+	args = append(args, now())
+	sets = append(sets, fmt.Sprintf("updated_at = ?%d", len(args)), "version = version + 1")
+	args = append(args, id, version)
+	query := fmt.Sprintf("UPDATE users SET %s WHERE id = ?%d AND deleted_at IS NULL AND (?%d = 0 OR version = ?%[3]d) RETURNING *", strings.Join(sets, ", "), len(args)-1, len(args))
+
+	user := &model.DBUser{}
+	err := get(ctx, repo.db, user, query, args...)
+	if err != nil {
+		if err == sql.ErrNoRows { //not found or another version
+			return nil, repo.versionConflict(ctx, id, version)
+		}
+		return nil, translateError(err)
+	}
+	return user, nil
+}
+
+// DeleteUser soft-deletes user in SQLite. If version is set, only that
+// version of the user is deleted.
+func (repo *UserRepo) DeleteUser(ctx context.Context, id uuid.UUID, version int) error {
+	res, err := exec(ctx, repo.db, "UPDATE users SET deleted_at = ?3, version = version + 1 WHERE id = ?1 AND deleted_at IS NULL AND (?2 = 0 OR version = ?2)", id, version, now())
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return repo.versionConflict(ctx, id, version)
+	}
+	return nil
+}
+
+// RestoreUser undoes the soft deletion of user in SQLite. It reports false
+// if the user was not deleted.
+func (repo *UserRepo) RestoreUser(ctx context.Context, id uuid.UUID) (bool, error) {
+	res, err := exec(ctx, repo.db, "UPDATE users SET deleted_at = NULL, updated_at = ?2, version = version + 1 WHERE id = ?1 AND deleted_at IS NOT NULL", id, now())
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+// PurgeDeletedUsers permanently removes users soft-deleted before the given time
+func (repo *UserRepo) PurgeDeletedUsers(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	res, err := exec(ctx, repo.db, "DELETE FROM users WHERE deleted_at IS NOT NULL AND deleted_at < ?1", deletedBefore)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// GetPassword retrieves the password hash of a user from SQLite, or an
+// empty string if there is no such user
+func (repo *UserRepo) GetPassword(ctx context.Context, id uuid.UUID) (string, error) {
+	var password string
+	err := get(ctx, repo.db, &password, "SELECT password FROM users WHERE id = ?1 AND deleted_at IS NULL", id)
+	if err != nil {
+		if err == sql.ErrNoRows { //not found
+			return "", nil
+		}
+		return "", err
+	}
+	return password, nil
+}
+
+// RehashPassword replaces the password hash of a user in SQLite if it is
+// still oldHash. The user is not otherwise changed, so the version stays.
+func (repo *UserRepo) RehashPassword(ctx context.Context, id uuid.UUID, oldHash, newHash string) error {
+	_, err := exec(ctx, repo.db, "UPDATE users SET password = ?3 WHERE id = ?1 AND password = ?2", id, oldHash, newHash)
+	return err
+}
+
+// GetUserByNickname retrieves a user by nickname from SQLite
+func (repo *UserRepo) GetUserByNickname(ctx context.Context, nickname string) (*model.DBUser, error) {
+	user := &model.DBUser{}
+	err := get(ctx, repo.db, user, "SELECT * FROM users WHERE lower(nickname) = lower(?1) AND deleted_at IS NULL", nickname)
+	if err != nil {
+		if err == sql.ErrNoRows { //not found
+			return nil, nil
+		}
+		return nil, err
+	}
+	return user, nil
+}
+
+// GetUserByEmail retrieves a user by email from SQLite
+func (repo *UserRepo) GetUserByEmail(ctx context.Context, email string) (*model.DBUser, error) {
+	user := &model.DBUser{}
+	err := get(ctx, repo.db, user, "SELECT * FROM users WHERE lower(email) = lower(?1) AND email <> '' AND deleted_at IS NULL", email)
+	if err != nil {
+		if err == sql.ErrNoRows { //not found
+			return nil, nil
+		}
+		return nil, err
+	}
+	return user, nil
+}
+
+// ListUsers retrieves a page of users from SQLite using keyset pagination
+func (repo *UserRepo) ListUsers(ctx context.Context, query *model.UserListQuery) ([]*model.DBUser, error) {
+	conds, args := userFilterConditions(&query.UserFilter)
+
+	dir, cmp := "ASC", ">"
+	if query.Desc {
+		dir, cmp = "DESC", "<"
+	}
+
+	var orderBy string
+	switch query.Sort {
+	case model.UserSortName:
+		orderBy = fmt.Sprintf("lastname %[1]s, firstname %[1]s, id %[1]s", dir)
+		if query.After != nil {
+			args = append(args, query.After.Lastname, query.After.Firstname, query.After.ID)
+			conds = append(conds, fmt.Sprintf("(lastname, firstname, id) %s (?, ?, ?)", cmp))
+		}
+	default:
+		orderBy = fmt.Sprintf("created_at %[1]s, id %[1]s", dir)
+		if query.After != nil {
+			args = append(args, query.After.CreatedAt, query.After.ID)
+			conds = append(conds, fmt.Sprintf("(created_at, id) %s (?, ?)", cmp))
+		}
+	}
+
+	args = append(args, query.Limit)
+	sqlQuery := fmt.Sprintf("SELECT * FROM users%s ORDER BY %s LIMIT ?", whereClause(conds), orderBy)
+
+	users := []*model.DBUser{}
+	err := selectAll(ctx, repo.db, &users, sqlQuery, args...)
+	if err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+// CountUsers counts the users matching filter in SQLite
+func (repo *UserRepo) CountUsers(ctx context.Context, filter *model.UserFilter) (int, error) {
+	conds, args := userFilterConditions(filter)
+
+	var count int
+	err := get(ctx, repo.db, &count, "SELECT count(*) FROM users"+whereClause(conds), args...)
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// userFilterConditions turns filter into SQL conditions with parameters in
+// the order of args
+func userFilterConditions(filter *model.UserFilter) ([]string, []interface{}) {
+	var (
+		conds []string
+		args  []interface{}
+	)
+	if !filter.IncludeDeleted {
+		conds = append(conds, "deleted_at IS NULL")
+	}
+	if filter.Role != "" {
+		args = append(args, filter.Role)
+		conds = append(conds, "role = ?")
+	}
+	if filter.NicknamePrefix != "" {
+		// LIKE ignores the case of ASCII letters, like ILIKE in Postgres
+		args = append(args, escapeLike(filter.NicknamePrefix)+"%")
+		conds = append(conds, `nickname LIKE ? ESCAPE '\'`)
+	}
+	if !filter.CreatedFrom.IsZero() {
+		args = append(args, filter.CreatedFrom)
+		conds = append(conds, "created_at >= ?")
+	}
+	if !filter.CreatedTo.IsZero() {
+		args = append(args, filter.CreatedTo)
+		conds = append(conds, "created_at < ?")
+	}
+	return conds, args
+}
+
+func whereClause(conds []string) string {
+	if len(conds) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(conds, " AND ")
+}
+
+// escapeLike escapes the LIKE wildcards in s
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
@@ -0,0 +1,217 @@
+package sqlite
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/VikaGo/REST_API/model"
+	"github.com/VikaGo/REST_API/pkg/types"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestDB opens a migrated database in a temporary file
+func newTestDB(t *testing.T) *sqlx.DB {
+	db, err := Open(context.Background(), filepath.Join(t.TempDir(), "test.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	require.NoError(t, Migrate(db))
+	return db
+}
+
+func newTestUser(nickname, email string) *model.DBUser {
+	return &model.DBUser{
+		ID:        uuid.New(),
+		Role:      model.RoleViewer,
+		Firstname: "Olexandr",
+		Lastname:  "Topol",
+		Nickname:  nickname,
+		Email:     email,
+		Password:  "hash",
+	}
+}
+
+// TestUserRepo runs a user through its life in SQLite
+func TestUserRepo(t *testing.T) {
+	ctx := context.Background()
+	repo := NewUserRepo(newTestDB(t))
+
+	user := newTestUser("topol", "topol@example.com")
+	_, err := repo.CreateUser(ctx, user)
+	require.NoError(t, err)
+
+	stored, err := repo.GetUser(ctx, user.ID)
+	require.NoError(t, err)
+	require.NotNil(t, stored)
+	assert.Equal(t, user.Nickname, stored.Nickname)
+	assert.Equal(t, 1, stored.Version)
+	assert.Equal(t, time.UTC, stored.CreatedAt.Location())
+	assert.WithinDuration(t, time.Now(), stored.CreatedAt, time.Minute)
+
+	byNickname, err := repo.GetUserByNickname(ctx, "TOPOL")
+	require.NoError(t, err)
+	require.NotNil(t, byNickname)
+	assert.Equal(t, user.ID, byNickname.ID)
+	byEmail, err := repo.GetUserByEmail(ctx, "Topol@Example.com")
+	require.NoError(t, err)
+	require.NotNil(t, byEmail)
+	assert.Equal(t, user.ID, byEmail.ID)
+
+	// updates are conditional on the version
+	stored.Firstname = "Oleksandr"
+	_, err = repo.UpdateUser(ctx, &model.DBUser{ID: user.ID, Version: 2, Role: stored.Role, Nickname: stored.Nickname, Password: stored.Password})
+	assert.Equal(t, types.ErrConflict, err)
+	updated, err := repo.UpdateUser(ctx, stored)
+	require.NoError(t, err)
+	assert.Equal(t, "Oleksandr", updated.Firstname)
+	assert.Equal(t, 2, updated.Version)
+
+	patched, err := repo.PatchUser(ctx, user.ID, 2, map[string]interface{}{"lastname": "Topolenko", "role": model.RoleEditor})
+	require.NoError(t, err)
+	assert.Equal(t, "Topolenko", patched.Lastname)
+	assert.Equal(t, model.RoleEditor, patched.Role)
+	assert.Equal(t, 3, patched.Version)
+	_, err = repo.PatchUser(ctx, user.ID, 0, map[string]interface{}{"password": "other"})
+	assert.Error(t, err)
+
+	require.NoError(t, repo.RehashPassword(ctx, user.ID, "hash", "rehashed"))
+	password, err := repo.GetPassword(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "rehashed", password)
+
+	// soft deletion
+	assert.Equal(t, types.ErrConflict, repo.DeleteUser(ctx, user.ID, 1))
+	require.NoError(t, repo.DeleteUser(ctx, user.ID, 3))
+	deleted, err := repo.GetUser(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Nil(t, deleted)
+	deleted, err = repo.GetUserIncludingDeleted(ctx, user.ID)
+	require.NoError(t, err)
+	require.NotNil(t, deleted.DeletedAt)
+
+	restored, err := repo.RestoreUser(ctx, user.ID)
+	require.NoError(t, err)
+	assert.True(t, restored)
+	restored, err = repo.RestoreUser(ctx, user.ID)
+	require.NoError(t, err)
+	assert.False(t, restored)
+
+	// only users deleted before the retention are purged
+	require.NoError(t, repo.DeleteUser(ctx, user.ID, 0))
+	purged, err := repo.PurgeDeletedUsers(ctx, time.Now().Add(-time.Hour))
+	require.NoError(t, err)
+	assert.Zero(t, purged)
+	purged, err = repo.PurgeDeletedUsers(ctx, time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), purged)
+}
+
+func TestUserRepoDuplicates(t *testing.T) {
+	ctx := context.Background()
+	repo := NewUserRepo(newTestDB(t))
+	_, err := repo.CreateUser(ctx, newTestUser("topol", "topol@example.com"))
+	require.NoError(t, err)
+	_, err = repo.CreateUser(ctx, newTestUser("other", ""))
+	require.NoError(t, err)
+
+	tests := []struct {
+		testName string
+		user     *model.DBUser
+		field    string
+	}{
+		{testName: "nickname in another case", user: newTestUser("Topol", ""), field: "nickname"},
+		{testName: "email in another case", user: newTestUser("topol2", "TOPOL@example.com"), field: "email"},
+		{testName: "no email", user: newTestUser("topol3", "")},
+	}
+
+	for _, test := range tests {
+		t.Logf("running %v", test.testName)
+
+		_, err := repo.CreateUser(ctx, test.user)
+		if test.field == "" {
+			assert.NoError(t, err)
+			continue
+		}
+		var fieldErr *types.FieldError
+		require.True(t, errors.As(err, &fieldErr), "unexpected error %v", err)
+		assert.Equal(t, types.ErrDuplicateEntry, fieldErr.Err)
+		assert.Equal(t, test.field, fieldErr.Field)
+	}
+}
+
+func TestListUsers(t *testing.T) {
+	ctx := context.Background()
+	repo := NewUserRepo(newTestDB(t))
+
+	var users []*model.DBUser
+	for _, nickname := range []string{"anna", "andriy", "bohdan", "an_na"} {
+		user := newTestUser(nickname, "")
+		_, err := repo.CreateUser(ctx, user)
+		require.NoError(t, err)
+		user, err = repo.GetUser(ctx, user.ID)
+		require.NoError(t, err)
+		users = append(users, user)
+		// created_at orders the users
+		time.Sleep(2 * time.Millisecond)
+	}
+	require.NoError(t, repo.DeleteUser(ctx, users[1].ID, 0))
+
+	tests := []struct {
+		testName string
+		query    model.UserListQuery
+		count    int
+		expected []string
+	}{
+		{
+			testName: "first page",
+			query:    model.UserListQuery{Limit: 2},
+			count:    3,
+			expected: []string{"anna", "bohdan"},
+		},
+		{
+			testName: "next page",
+			query:    model.UserListQuery{Limit: 2, After: &model.UserCursor{CreatedAt: users[2].CreatedAt, ID: users[2].ID}},
+			count:    3,
+			expected: []string{"an_na"},
+		},
+		{
+			testName: "newest first with deleted users",
+			query:    model.UserListQuery{Limit: 10, Desc: true, UserFilter: model.UserFilter{IncludeDeleted: true}},
+			count:    4,
+			expected: []string{"an_na", "bohdan", "andriy", "anna"},
+		},
+		{
+			testName: "nickname prefix with a wildcard",
+			query:    model.UserListQuery{Limit: 10, UserFilter: model.UserFilter{NicknamePrefix: "AN_"}},
+			count:    1,
+			expected: []string{"an_na"},
+		},
+		{
+			testName: "created after the second user",
+			query:    model.UserListQuery{Limit: 10, UserFilter: model.UserFilter{CreatedFrom: users[2].CreatedAt}},
+			count:    2,
+			expected: []string{"bohdan", "an_na"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Logf("running %v", test.testName)
+
+		list, err := repo.ListUsers(ctx, &test.query)
+		require.NoError(t, err)
+		nicknames := []string{}
+		for _, user := range list {
+			nicknames = append(nicknames, user.Nickname)
+		}
+		assert.Equal(t, test.expected, nicknames)
+
+		count, err := repo.CountUsers(ctx, &test.query.UserFilter)
+		require.NoError(t, err)
+		assert.Equal(t, test.count, count)
+	}
+}
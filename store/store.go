@@ -4,25 +4,97 @@ import (
 	context "context"
 	"github.com/jmoiron/sqlx"
 	"log"
-	"time"
 
 	"github.com/VikaGo/REST_API/config"
-	"github.com/VikaGo/REST_API/logger"
+	"github.com/VikaGo/REST_API/model"
+	"github.com/VikaGo/REST_API/store/memory"
 	"github.com/VikaGo/REST_API/store/pg"
+	"github.com/VikaGo/REST_API/store/sqlite"
 	_ "github.com/lib/pq"
 	"github.com/pkg/errors"
 )
 
+// Store drivers
+const (
+	DriverPostgres = "postgres"
+	DriverSQLite   = "sqlite"
+)
+
 // Store contains all repositories
 type Store struct {
-	Pg   *sqlx.DB // for KeepAlivePg (see below)
-	User UserRepo
+	DB              *sqlx.DB // the connection pool of the SQL repositories
+	User            UserRepo
+	RefreshToken    RefreshTokenRepo
+	Audit           AuditRepo
+	LoginAttempt    LoginAttemptRepo
+	TwoFactor       TwoFactorRepo
+	RoleSettings    RoleSettingsRepo
+	PasswordReset   PasswordResetRepo
+	PasswordHistory PasswordHistoryRepo
+	APIKey          APIKeyRepo
+	OAuth           OAuthRepo
+	Identity        IdentityRepo
+	Session         SessionRepo
+	// driver is the database behind DB
+	driver string
+	// loginAttemptsInDB is set if failed logins are counted in DB
+	loginAttemptsInDB bool
+	// pool watches DB
+	pool *Pool
+	// timeouts bound the queries of the Postgres user repository
+	timeouts pg.Timeouts
+	// inTx is set on the repositories of a unit of work
+	inTx bool
 }
 
-// New creates new store
+// sqlDB is a connection pool or a transaction the repositories run on
+type sqlDB interface {
+	pg.DB
+	sqlite.DB
+}
+
+// New creates new store. The health of its connection pool is checked
+// until ctx is done.
 func New(ctx context.Context) (*Store, error) {
-	cfg := config.Get()
+	return open(ctx, config.Get())
+}
 
+// open connects to the database cfg.StoreDriver selects and migrates it
+func open(ctx context.Context, cfg *config.Config) (*Store, error) {
+	store := &Store{driver: cfg.StoreDriver}
+
+	var err error
+	switch cfg.StoreDriver {
+	case DriverPostgres:
+		store.DB, err = openPg(ctx, cfg)
+	case DriverSQLite:
+		store.DB, err = openSQLite(ctx, cfg)
+	default:
+		return nil, errors.Errorf("unknown store driver '%s'", cfg.StoreDriver)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch cfg.LoginAttemptStore {
+	case "memory":
+		store.LoginAttempt = memory.NewLoginAttemptRepo()
+	case cfg.StoreDriver:
+		store.loginAttemptsInDB = true
+	default:
+		store.DB.Close()
+		return nil, errors.Errorf("unknown login attempt store '%s'", cfg.LoginAttemptStore)
+	}
+
+	store.pool = NewPool(store.DB, cfg)
+	store.timeouts = pg.Timeouts{Read: cfg.DbReadTimeout, Write: cfg.DbWriteTimeout}
+	store.setRepos(store.DB)
+	go store.pool.Run(ctx)
+	return store, nil
+}
+
+// openPg connects to PostgreSQL and runs its migrations
+func openPg(ctx context.Context, cfg *config.Config) (*sqlx.DB, error) {
 	// Connect to PostgreSQL using sqlx
 	pgDB, err := sqlx.Connect("postgres", cfg.PgURL)
 	if err != nil {
@@ -31,49 +103,94 @@ func New(ctx context.Context) (*Store, error) {
 
 	err = pgDB.PingContext(ctx)
 	if err != nil {
+		pgDB.Close()
 		return nil, err
 	}
 
 	// Run PostgreSQL migrations
 	log.Println("Running PostgreSQL migrations...")
 	if err := runPgMigrations(pgDB); err != nil {
+		pgDB.Close()
 		return nil, errors.Wrap(err, "runPgMigrations failed")
 	}
+	return pgDB, nil
+}
+
+// openSQLite opens the SQLite database file and runs its migrations
+func openSQLite(ctx context.Context, cfg *config.Config) (*sqlx.DB, error) {
+	sqliteDB, err := sqlite.Open(ctx, cfg.SQLitePath)
+	if err != nil {
+		return nil, err
+	}
 
-	var store Store
+	log.Println("Running SQLite migrations...")
+	if err := sqlite.Migrate(sqliteDB); err != nil {
+		sqliteDB.Close()
+		return nil, err
+	}
+	return sqliteDB, nil
+}
 
-	// Init PostgreSQL repositories
-	store.Pg = pgDB
-	go store.KeepAlivePg()
-	store.User = pg.NewUserRepo(pgDB)
-	return &store, nil
+// setRepos points the SQL repositories of store at db
+func (store *Store) setRepos(db sqlDB) {
+	switch store.driver {
+	case DriverSQLite:
+		store.setSQLiteRepos(db)
+	default:
+		store.setPgRepos(db)
+	}
 }
 
-// KeepAlivePollPeriod is a Pg keepalive check time period
-const KeepAlivePollPeriod = 3
+// setPgRepos points the repositories of store at the Postgres db
+func (store *Store) setPgRepos(db pg.DB) {
+	store.User = pg.NewUserRepo(db, store.timeouts)
+	store.RefreshToken = pg.NewRefreshTokenRepo(db)
+	store.Audit = pg.NewAuditRepo(db)
+	store.TwoFactor = pg.NewTwoFactorRepo(db)
+	store.RoleSettings = pg.NewRoleSettingsRepo(db)
+	store.PasswordReset = pg.NewPasswordResetRepo(db)
+	store.PasswordHistory = pg.NewPasswordHistoryRepo(db)
+	store.APIKey = pg.NewAPIKeyRepo(db)
+	store.OAuth = pg.NewOAuthRepo(db)
+	store.Identity = pg.NewIdentityRepo(db)
+	store.Session = pg.NewSessionRepo(db)
+	if store.loginAttemptsInDB {
+		store.LoginAttempt = pg.NewLoginAttemptRepo(db)
+	}
+}
 
-// KeepAlivePg makes sure PostgreSQL is alive and reconnects if needed
-func (store *Store) KeepAlivePg() {
-	logger := logger.Get()
-	var err error
-	for {
-		// Check if PostgreSQL is alive every 3 seconds
-		time.Sleep(time.Second * KeepAlivePollPeriod)
-		lostConnect := false
-		if store.Pg == nil {
-			lostConnect = true
-		} else if err = store.Pg.Ping(); err != nil {
-			lostConnect = true
-		}
-		if !lostConnect {
-			continue
-		}
-		logger.Debug().Msg("[store.KeepAlivePg] Lost PostgreSQL connection. Restoring...")
-		store.Pg, err = sqlx.Connect("postgres", config.Get().PgURL)
-		if err != nil {
-			logger.Err(err)
-			continue
-		}
-		logger.Debug().Msg("[store.KeepAlivePg] PostgreSQL reconnected")
+// setSQLiteRepos points the repositories of store at the SQLite db
+func (store *Store) setSQLiteRepos(db sqlite.DB) {
+	store.User = sqlite.NewUserRepo(db)
+	store.RefreshToken = sqlite.NewRefreshTokenRepo(db)
+	store.Audit = sqlite.NewAuditRepo(db)
+	store.TwoFactor = sqlite.NewTwoFactorRepo(db)
+	store.RoleSettings = sqlite.NewRoleSettingsRepo(db)
+	store.PasswordReset = sqlite.NewPasswordResetRepo(db)
+	store.PasswordHistory = sqlite.NewPasswordHistoryRepo(db)
+	store.APIKey = sqlite.NewAPIKeyRepo(db)
+	store.OAuth = sqlite.NewOAuthRepo(db)
+	store.Identity = sqlite.NewIdentityRepo(db)
+	store.Session = sqlite.NewSessionRepo(db)
+	if store.loginAttemptsInDB {
+		store.LoginAttempt = sqlite.NewLoginAttemptRepo(db)
+	}
+}
+
+// DatabaseHealth returns the state of the connection pool, or nil
+// for a store without a database
+func (store *Store) DatabaseHealth() *model.DatabaseHealth {
+	if store.pool == nil {
+		return nil
+	}
+	return store.pool.Health()
+}
+
+// Close closes the connection pool once the queries running on it have
+// ended
+func (store *Store) Close() error {
+	if store.DB == nil {
+		return nil
 	}
+	return store.DB.Close()
 }
@@ -0,0 +1,94 @@
+package store
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/VikaGo/REST_API/config"
+	"github.com/VikaGo/REST_API/model"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestSQLiteTx runs units of work on a store in a temporary SQLite file
+func TestSQLiteTx(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	store, err := open(ctx, &config.Config{
+		StoreDriver:       DriverSQLite,
+		SQLitePath:        filepath.Join(t.TempDir(), "test.db"),
+		LoginAttemptStore: DriverSQLite,
+		DbMaxOpenConns:    4,
+	})
+	require.NoError(t, err)
+	defer store.Close()
+	assert.True(t, store.DatabaseHealth().Healthy)
+
+	errFailed := errors.New("failed")
+
+	tests := []struct {
+		testName    string
+		fn          func(repos *Store, user *model.DBUser) error
+		expectedErr error
+		panics      bool
+		committed   bool
+	}{
+		{
+			testName: "commit",
+			fn: func(repos *Store, user *model.DBUser) error {
+				if _, err := repos.User.CreateUser(ctx, user); err != nil {
+					return err
+				}
+				// audit events without fields are stored as empty lists
+				return repos.Audit.CreateAuditEvent(ctx, &model.DBAuditEvent{ID: uuid.New(), Action: "user.create", TargetID: &user.ID})
+			},
+			committed: true,
+		},
+		{
+			testName: "rollback on error",
+			fn: func(repos *Store, user *model.DBUser) error {
+				if _, err := repos.User.CreateUser(ctx, user); err != nil {
+					return err
+				}
+				return errFailed
+			},
+			expectedErr: errFailed,
+		},
+		{
+			testName: "rollback on panic",
+			fn: func(repos *Store, user *model.DBUser) error {
+				if _, err := repos.User.CreateUser(ctx, user); err != nil {
+					return err
+				}
+				panic(errFailed)
+			},
+			panics: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Logf("running %v", test.testName)
+
+		user := &model.DBUser{ID: uuid.New(), Role: model.RoleViewer, Nickname: uuid.NewString(), Password: "hash"}
+		run := func() error {
+			return store.Tx(ctx, func(repos *Store) error { return test.fn(repos, user) })
+		}
+		if test.panics {
+			assert.Panics(t, func() { run() })
+		} else {
+			assert.Equal(t, test.expectedErr, run())
+		}
+
+		stored, err := store.User.GetUser(ctx, user.ID)
+		require.NoError(t, err)
+		assert.Equal(t, test.committed, stored != nil)
+	}
+
+	events, err := store.Audit.ListAuditEvents(ctx, &model.AuditQuery{Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	assert.Empty(t, events[0].Fields)
+}
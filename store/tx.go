@@ -0,0 +1,81 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/VikaGo/REST_API/store/pg"
+	"github.com/VikaGo/REST_API/store/sqlite"
+	"github.com/pkg/errors"
+)
+
+const (
+	// txMaxAttempts bounds how often a unit of work runs on serialization failures
+	txMaxAttempts = 3
+	// txRetryBackoff grows with every attempt, so conflicting units of work drift apart
+	txRetryBackoff = 20 * time.Millisecond
+)
+
+// Tx runs fn as one unit of work: every repository of repos runs its
+// queries in the same serializable transaction, which is committed if fn
+// returns nil and rolled back if fn fails or panics. Serialization failures,
+// deadlocks and busy SQLite databases run fn again, so fn must not have
+// effects beyond repos.
+//
+// Within a unit of work, or on a Store without a database like one of
+// mocked repositories, fn runs with store itself.
+func (store *Store) Tx(ctx context.Context, fn func(repos *Store) error) error {
+	if store.inTx || store.DB == nil {
+		return fn(store)
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := store.runTx(ctx, fn)
+		if err == nil || !isRetryable(err) || attempt == txMaxAttempts {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return errors.Wrap(ctx.Err(), "unit of work canceled while retrying")
+		case <-time.After(time.Duration(attempt) * txRetryBackoff):
+		}
+	}
+}
+
+// isRetryable reports whether err aborted a transaction of any driver that
+// may succeed if it is run again
+func isRetryable(err error) bool {
+	return pg.IsRetryable(err) || sqlite.IsRetryable(err)
+}
+
+func (store *Store) runTx(ctx context.Context, fn func(repos *Store) error) (err error) {
+	// go-sqlite3 ignores the isolation level. Its transactions are begun
+	// IMMEDIATE instead (see sqlite.Open), taking the write lock up front,
+	// so they run one after the other and are serializable as well.
+	opts := &sql.TxOptions{}
+	if store.driver == DriverPostgres {
+		opts.Isolation = sql.LevelSerializable
+	}
+	tx, err := store.DB.BeginTxx(ctx, opts)
+	if err != nil {
+		return errors.Wrap(err, "could not begin transaction")
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		}
+	}()
+
+	repos := *store
+	repos.inTx = true
+	repos.setRepos(tx)
+
+	if err := fn(&repos); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return errors.Wrap(tx.Commit(), "could not commit transaction")
+}